@@ -1,20 +1,26 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/bkataru/spotigo/internal/crypto"
 	"github.com/bkataru/spotigo/internal/spotify"
+	"github.com/bkataru/spotigo/internal/storage"
 )
 
 var authCmd = &cobra.Command{
@@ -24,21 +30,46 @@ var authCmd = &cobra.Command{
 
 This uses OAuth2 to securely connect to your Spotify account.
 Your credentials are stored locally and never sent anywhere else.
+Without spotify.client_secret the Authorization Code flow with PKCE is used,
+so a public client ID can be shared without distributing a secret.
 
 Required scopes:
   - user-library-read (saved tracks, albums)
   - playlist-read-private (your playlists)
   - user-top-read (top artists/tracks)
   - user-read-recently-played (recent history)
-  - user-follow-read (followed artists)`,
+  - user-follow-read (followed artists)
+  - user-read-playback-position (saved podcast episodes)
+
+Use --write to also grant the scopes needed by 'spotigo backup restore --to-spotify':
+  - user-library-modify (re-save tracks)
+  - user-follow-modify (re-follow artists)
+  - playlist-modify-public, playlist-modify-private (recreate playlists)
+
+Use --headless on servers and containers without a browser. The login URL is
+printed so it can be opened on any machine; after approving access, paste the
+address of the page Spotify redirects to (it does not need to load) or just
+the code from it.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		runAuth()
 	},
 }
 
+var (
+	authWrite    bool
+	authHeadless bool
+	rekeyTo      string
+	rekeyKeyFile string
+)
+
 func init() {
+	authCmd.Flags().BoolVar(&authWrite, "write", false, "also request write access, needed to restore backups into Spotify")
+	authCmd.Flags().BoolVar(&authHeadless, "headless", false, "paste the redirect URL instead of opening a browser and waiting for the callback")
+	authRekeyCmd.Flags().StringVar(&rekeyTo, "to", "", "new key source: machine, passphrase, keyfile, env or keyring")
+	authRekeyCmd.Flags().StringVar(&rekeyKeyFile, "key-file", "", "key file for --to keyfile (default spotify.token_key_file)")
 	authCmd.AddCommand(authStatusCmd)
 	authCmd.AddCommand(authLogoutCmd)
+	authCmd.AddCommand(authRekeyCmd)
 }
 
 var authStatusCmd = &cobra.Command{
@@ -57,6 +88,42 @@ var authLogoutCmd = &cobra.Command{
 	},
 }
 
+var authRekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Re-encrypt the token and other protected files with a different key",
+	Long: `Re-encrypt the stored Spotify token, and any other files in the data
+directory protected with the same key, with a different key.
+
+Files are read with the key configured in spotify.token_key and written
+with the key given by --to:
+  machine     derived from your user account and machine (default)
+  passphrase  derived from a passphrase with scrypt (SPOTIGO_PASSPHRASE or prompt)
+  keyfile     read from --key-file, at least 16 bytes of secret material
+  env         read from SPOTIGO_TOKEN_KEY, at least 16 bytes
+  keyring     a random secret kept in the OS keyring (macOS Keychain,
+              Windows Credential Manager or the Linux Secret Service)
+
+Unlike the machine key, the passphrase, key file and env keys keep working
+after moving your home directory or renaming your user. Afterwards set spotify.token_key (and
+spotify.token_key_file) in your config to match.
+
+--to may name the current source to rotate the key: a passphrase is changed
+by reading the new one from SPOTIGO_NEW_PASSPHRASE or a prompt. The keyring
+secret is kept, so naming keyring again only upgrades the files to the
+current encrypted file format, as it does for every source.
+
+Backups, data files and the play log encrypted with storage.encryption are
+re-encrypted too when --to is machine or passphrase, with a new storage key of
+that kind; a new passphrase is shared with the token. Set storage.encryption
+to match afterwards. With keyfile, env or keyring the storage key is left as
+it is. If the storage rotation is interrupted, Spotigo refuses to read the
+backups until rekey is run again with the same --to and new key to finish it.
+Stop the daemon and the recorder first.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		rekeyFiles()
+	},
+}
+
 func runAuth() {
 	fmt.Println("Spotify Authentication")
 	fmt.Println("======================")
@@ -80,6 +147,15 @@ func runAuth() {
 		fmt.Println("5. Set them in your config file or environment:")
 		fmt.Println("   export SPOTIFY_CLIENT_ID=your_client_id")
 		fmt.Println("   export SPOTIFY_CLIENT_SECRET=your_client_secret")
+		fmt.Println()
+		fmt.Println("The client secret is optional: without one Spotigo uses PKCE, so a")
+		fmt.Println("team can share a single public client ID without sharing a secret.")
+		return
+	}
+
+	tokenKey, err := tokenKeyProvider(cfg.Spotify.TokenKey, cfg.Spotify.TokenKeyFile, cfg.Profile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
 		return
 	}
 
@@ -89,6 +165,8 @@ func runAuth() {
 		ClientSecret: cfg.Spotify.ClientSecret,
 		RedirectURI:  cfg.Spotify.RedirectURI,
 		TokenFile:    cfg.Spotify.TokenFile,
+		WriteAccess:  authWrite,
+		TokenKey:     tokenKey,
 	}
 
 	client, err := spotify.NewClient(spotifyCfg)
@@ -106,6 +184,24 @@ func runAuth() {
 
 	// Get auth URL
 	authURL := client.GetAuthURL(state)
+	if client.UsesPKCE() {
+		fmt.Println("No client secret configured; authenticating with PKCE.")
+	}
+
+	if authHeadless {
+		ctx := context.Background()
+		if err := headlessAuth(ctx, client, state, authURL, cfg.Spotify.RedirectURI, cfg.Spotify.TokenFile, os.Stdin); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Println("✅ Authentication successful!")
+		if err := testAuthentication(client); err != nil {
+			fmt.Printf("Warning: Authentication test failed: %v\n", err)
+		} else {
+			fmt.Println("✅ Authentication verified!")
+		}
+		return
+	}
 
 	fmt.Println("Starting OAuth2 flow...")
 	fmt.Printf("Opening browser: %s\n", authURL)
@@ -143,11 +239,19 @@ func checkAuthStatus() {
 		return
 	}
 
+	tokenKey, err := tokenKeyProvider(cfg.Spotify.TokenKey, cfg.Spotify.TokenKeyFile, cfg.Profile)
+	if err != nil {
+		fmt.Println("Authentication Status:")
+		fmt.Printf("  Error: %v\n", err)
+		return
+	}
+
 	spotifyCfg := spotify.Config{
 		ClientID:     cfg.Spotify.ClientID,
 		ClientSecret: cfg.Spotify.ClientSecret,
 		RedirectURI:  cfg.Spotify.RedirectURI,
 		TokenFile:    cfg.Spotify.TokenFile,
+		TokenKey:     tokenKey,
 	}
 
 	client, err := spotify.NewClient(spotifyCfg)
@@ -174,9 +278,18 @@ func checkAuthStatus() {
 			fmt.Printf("  ID: %s\n", user.ID)
 			fmt.Println("  Token: ✅ Valid")
 		}
+		if info, ok := client.TokenInfo(); ok {
+			printTokenInfo(info, time.Now())
+		}
 	} else {
 		fmt.Println("Authentication Status:")
 		fmt.Println("  Status: ❌ Not authenticated")
+		if err := client.TokenError(); err != nil {
+			fmt.Printf("  Token file: Unreadable with the %s key\n", tokenKey.Name())
+			fmt.Printf("  Error: %v\n", err)
+			fmt.Println("  Check spotify.token_key, or run 'spotigo auth' to authenticate again")
+			return
+		}
 		fmt.Println("  Token file: Missing or invalid")
 		fmt.Println("  Run 'spotigo auth' to authenticate")
 	}
@@ -206,6 +319,115 @@ func logout() {
 	}
 }
 
+// printTokenInfo prints the expiry, refresh state and scopes of a token
+func printTokenInfo(info spotify.TokenInfo, now time.Time) {
+	switch {
+	case info.Expiry.IsZero():
+		fmt.Println("  Expires: unknown")
+	case info.Expiry.After(now):
+		fmt.Printf("  Expires: %s (in %s)\n", info.Expiry.Format("2006-01-02 15:04:05"), info.Expiry.Sub(now).Round(time.Minute))
+	default:
+		fmt.Printf("  Expires: %s (expired, refreshed on next use)\n", info.Expiry.Format("2006-01-02 15:04:05"))
+	}
+
+	if info.LastRefresh.IsZero() {
+		fmt.Println("  Last refresh: unknown")
+	} else {
+		fmt.Printf("  Last refresh: %s\n", info.LastRefresh.Format("2006-01-02 15:04:05"))
+	}
+	if !info.Refreshable {
+		fmt.Println("  Refresh token: ❌ Missing, run 'spotigo auth' when the token expires")
+	}
+
+	if len(info.Scopes) == 0 {
+		fmt.Println("  Scopes: not recorded, run 'spotigo auth' to record them")
+		return
+	}
+	fmt.Println("  Scopes:")
+	for _, scope := range info.Scopes {
+		fmt.Printf("    - %s\n", scope)
+	}
+}
+
+func rekeyFiles() {
+	cfg := GetConfig()
+	if cfg == nil {
+		fmt.Println("Error: Configuration not loaded")
+		return
+	}
+	if rekeyTo == "" {
+		fmt.Println("Error: choose the new key with --to (machine, passphrase, keyfile, env or keyring)")
+		return
+	}
+
+	from, err := tokenKeyProvider(cfg.Spotify.TokenKey, cfg.Spotify.TokenKeyFile, cfg.Profile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	keyFile := rekeyKeyFile
+	if keyFile == "" {
+		keyFile = cfg.Spotify.TokenKeyFile
+	}
+	to, err := tokenKeyProvider(rekeyTo, keyFile, cfg.Profile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	newPassphrase := rememberPassphrase(readNewPassphrase)
+	if rekeyTo == "passphrase" {
+		to = crypto.NewPassphraseKey(newPassphrase)
+	}
+
+	// The token is rotated before the storage key, so an interrupted storage
+	// rotation means it already has the new key
+	if storage.RotationInterrupted(cfg.Storage.BackupDir) {
+		fmt.Println("Finishing an interrupted storage key rotation; the token was already re-encrypted.")
+	} else {
+		count, err := rotateProtectedFiles(cfg, from, to)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("✅ Re-encrypted %d file(s) with the %s key.\n", count, to.Name())
+	}
+	if rekeyTo != cfg.Spotify.TokenKey || keyFile != cfg.Spotify.TokenKeyFile {
+		fmt.Println("Update your config so Spotigo can read it:")
+		fmt.Println("  spotify:")
+		fmt.Printf("    token_key: %s\n", rekeyTo)
+		if rekeyTo == "keyfile" {
+			fmt.Printf("    token_key_file: %s\n", keyFile)
+		}
+	}
+
+	if !storage.EncryptionConfigured(cfg.Storage.BackupDir) {
+		return
+	}
+	storageMode := ""
+	switch rekeyTo {
+	case "machine":
+		storageMode = storage.EncryptionMachine
+	case "passphrase":
+		storageMode = storage.EncryptionPassphrase
+	}
+	if storageMode == "" {
+		fmt.Println("The storage key was not changed: storage.encryption supports machine and passphrase keys only.")
+		return
+	}
+
+	count, err := rotateStorageKey(cfg, storageMode, newPassphrase)
+	if err != nil {
+		fmt.Printf("Error: failed to re-encrypt backups: %v\n", err)
+		return
+	}
+	fmt.Printf("✅ Re-encrypted %d backup and data file(s) with a new %s storage key.\n", count, storageMode)
+	if cfg.Storage.Encryption != storageMode {
+		fmt.Println("Update your config to keep encrypting with it:")
+		fmt.Println("  storage:")
+		fmt.Printf("    encryption: %s\n", storageMode)
+	}
+}
+
 func generateRandomState() (string, error) {
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
@@ -235,6 +457,70 @@ func openBrowser(url string) error {
 	return exec.Command(cmd, args...).Start() // #nosec G204 - Intentional browser opening with sanitized URL
 }
 
+// headlessAuth completes the OAuth flow without a callback server: the user
+// opens authURL anywhere and pastes back the redirect URL or code
+func headlessAuth(ctx context.Context, client *spotify.Client, state, authURL, redirectURI, tokenFile string, in io.Reader) error {
+	fmt.Println("Open this URL in a browser on any machine and approve access:")
+	fmt.Println()
+	fmt.Println("  " + authURL)
+	fmt.Println()
+	fmt.Printf("Spotify then redirects to %s, which may fail to load.\n", redirectURI)
+	fmt.Print("Paste the full address of that page (or just the code): ")
+
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("failed to read redirect URL: %w", err)
+	}
+	code, err := parseAuthResponse(line, state)
+	if err != nil {
+		return err
+	}
+
+	if err := client.ExchangeCode(ctx, code, redirectURI); err != nil {
+		return err
+	}
+	if err := client.SaveToken(tokenFile); err != nil {
+		return fmt.Errorf("failed to save token: %w", err)
+	}
+	return nil
+}
+
+// parseAuthResponse extracts the authorization code from a pasted redirect
+// URL, checking its state, or returns a pasted code as is
+func parseAuthResponse(input, expectedState string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", fmt.Errorf("no redirect URL or code entered")
+	}
+	if !strings.ContainsAny(input, "?=") {
+		return input, nil
+	}
+
+	query := input
+	if i := strings.Index(query, "?"); i >= 0 {
+		query = query[i+1:]
+	}
+	if i := strings.Index(query, "#"); i >= 0 {
+		query = query[:i]
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", fmt.Errorf("invalid redirect URL: %w", err)
+	}
+
+	if reason := values.Get("error"); reason != "" {
+		return "", fmt.Errorf("authorization was not granted: %s", reason)
+	}
+	if values.Get("state") != expectedState {
+		return "", fmt.Errorf("invalid state parameter")
+	}
+	code := values.Get("code")
+	if code == "" {
+		return "", fmt.Errorf("no authorization code in the redirect URL")
+	}
+	return code, nil
+}
+
 func handleCallback(client *spotify.Client, expectedState string, redirectURI string) error {
 	done := make(chan error, 1)
 
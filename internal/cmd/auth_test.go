@@ -1,9 +1,15 @@
 package cmd
 
 import (
+	"context"
 	"net/url"
+	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
+
+	"github.com/bkataru/spotigo/internal/spotify"
 )
 
 func TestGenerateRandomState(t *testing.T) {
@@ -108,3 +114,54 @@ func TestGenerateRandomStateUniqueness(t *testing.T) {
 		t.Errorf("Expected %d unique states, got %d", numStates, len(states))
 	}
 }
+
+func TestParseAuthResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"redirect URL", "http://127.0.0.1:8888/callback?code=abc123&state=xyz\n", "abc123", false},
+		{"query only", "?code=abc123&state=xyz", "abc123", false},
+		{"bare code", "  abc123\n", "abc123", false},
+		{"wrong state", "http://127.0.0.1:8888/callback?code=abc123&state=other", "", true},
+		{"missing state", "http://127.0.0.1:8888/callback?code=abc123", "", true},
+		{"access denied", "http://127.0.0.1:8888/callback?error=access_denied&state=xyz", "", true},
+		{"missing code", "http://127.0.0.1:8888/callback?state=xyz", "", true},
+		{"empty", "\n", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAuthResponse(tt.input, "xyz")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAuthResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseAuthResponse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeadlessAuth_RejectsBadInput(t *testing.T) {
+	client, err := spotify.NewClient(spotify.Config{ClientID: "id", RedirectURI: "http://127.0.0.1:8888/callback"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenFile := filepath.Join(t.TempDir(), "token")
+
+	for _, input := range []string{"", "http://127.0.0.1:8888/callback?code=abc&state=forged\n"} {
+		err := headlessAuth(context.Background(), client, "xyz", "https://accounts.spotify.com/authorize", "http://127.0.0.1:8888/callback", tokenFile, strings.NewReader(input))
+		if err == nil {
+			t.Errorf("headlessAuth(%q) should fail", input)
+		}
+	}
+	if client.IsAuthenticated() {
+		t.Error("client should not be authenticated")
+	}
+	if _, err := os.Stat(tokenFile); !os.IsNotExist(err) {
+		t.Error("no token file should be written")
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -16,8 +18,7 @@ import (
 	"github.com/zmb3/spotify/v2"
 
 	"github.com/bkataru/spotigo/internal/config"
-	"github.com/bkataru/spotigo/internal/ollama"
-	"github.com/bkataru/spotigo/internal/rag"
+	"github.com/bkataru/spotigo/internal/export"
 	spotifyclient "github.com/bkataru/spotigo/internal/spotify"
 	"github.com/bkataru/spotigo/internal/storage"
 )
@@ -30,10 +31,11 @@ const (
 )
 
 var (
-	backupFull  bool
-	backupType  string
-	backupIndex bool
-	backupCmd   = &cobra.Command{
+	backupFull        bool
+	backupType        string
+	backupIndex       bool
+	backupIncremental bool
+	backupCmd         = &cobra.Command{
 		Use:   "backup",
 		Short: "Backup your Spotify library",
 		Long: `Backup your complete Spotify library to local JSON/CSV files.
@@ -43,12 +45,22 @@ This includes:
   - All playlists (with tracks)
   - Followed artists
   - Saved albums
+  - Followed podcasts and saved episodes
   - Recently played tracks
   - Top tracks and artists
 
 Data is stored in the configured data directory (default: ./data/backups).
+When backup.format is csv, ndjson or parquet, each section is also written
+as a flat table under <data_dir>/exports for use in spreadsheets or DuckDB.
+Top items and recently played tracks are also kept as time-stamped
+snapshots under <data_dir>/history so your taste can be tracked over time.
 
-Use --index to automatically build the search index after backup.`,
+Use --index to automatically build the search index after backup.
+
+Use --incremental to skip playlists whose snapshot ID is unchanged and to stop
+paging saved tracks once already-known tracks are reached. Incremental runs
+cannot see tracks removed from your library; run a regular backup periodically
+to pick up removals.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			runBackup()
 		},
@@ -57,12 +69,35 @@ Use --index to automatically build the search index after backup.`,
 
 func init() {
 	backupCmd.Flags().BoolVar(&backupFull, "full", false, "perform full backup including all data types")
-	backupCmd.Flags().StringVar(&backupType, "type", "all", "backup type: all, tracks, playlists, artists")
+	backupCmd.Flags().StringVar(&backupType, "type", "all", "backup type: all, tracks, playlists, artists, albums, shows, top, recent")
 	backupCmd.Flags().BoolVar(&backupIndex, "index", false, "build search index after backup (requires Ollama)")
+	backupCmd.Flags().BoolVar(&backupIncremental, "incremental", false, "only fetch playlists and saved tracks changed since the last backup")
 
 	backupCmd.AddCommand(backupListCmd)
 	backupCmd.AddCommand(backupRestoreCmd)
 	backupCmd.AddCommand(backupStatusCmd)
+	backupCmd.AddCommand(backupGCCmd)
+	backupCmd.AddCommand(backupPruneCmd)
+	backupCmd.AddCommand(backupExportCmd)
+	backupCmd.AddCommand(backupDiffCmd)
+	backupCmd.AddCommand(backupVerifyCmd)
+	backupCmd.AddCommand(backupSnapshotsCmd)
+
+	backupRestoreCmd.Flags().BoolVar(&restoreToSpotify, "to-spotify", false, "restore into your Spotify account instead of the local data files")
+	backupRestoreCmd.Flags().BoolVar(&restoreDryRun, "dry-run", false, "with --to-spotify, show the changes without applying them")
+	backupRestoreCmd.Flags().BoolVarP(&restoreYes, "yes", "y", false, "with --to-spotify, apply the changes without asking")
+	backupRestoreCmd.Flags().BoolVar(&restoreOverwrite, "overwrite", false, "with --to-spotify, replace the items of your playlists that differ from the backup")
+
+	backupDiffCmd.Flags().StringVar(&diffFormat, "format", "table", "output format: table, json")
+
+	backupExportCmd.Flags().StringVar(&exportFormat, "format", "", "export format: csv, ndjson, parquet (default: backup.format)")
+	backupExportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "output directory (default: <data_dir>/exports)")
+
+	backupPruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "list backups that would be removed without deleting them")
+	backupPruneCmd.Flags().IntVar(&pruneRetainDays, "retain-days", 0, "keep every backup younger than this many days (overrides config)")
+	backupPruneCmd.Flags().IntVar(&pruneKeepDaily, "keep-daily", 0, "keep the newest backup of each of the last N days (overrides config)")
+	backupPruneCmd.Flags().IntVar(&pruneKeepWeekly, "keep-weekly", 0, "keep the newest backup of each of the last N weeks (overrides config)")
+	backupPruneCmd.Flags().IntVar(&pruneKeepMonthly, "keep-monthly", 0, "keep the newest backup of each of the last N months (overrides config)")
 }
 
 var backupListCmd = &cobra.Command{
@@ -73,10 +108,33 @@ var backupListCmd = &cobra.Command{
 	},
 }
 
+var backupSnapshotsCmd = &cobra.Command{
+	Use:   "snapshots [top_items|recently_played]",
+	Short: "List the history snapshots kept by each backup",
+	Long: `List the time-stamped snapshots of your top items and recently played
+tracks that each backup keeps under history/ in the data directory, oldest
+first. Give a kind to list only that one.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		listSnapshots(args)
+	},
+}
+
 var backupRestoreCmd = &cobra.Command{
 	Use:   "restore [backup-id]",
 	Short: "Restore from a backup",
-	Args:  cobra.MaximumNArgs(1),
+	Long: `Restore from a backup.
+
+By default the backup's sections are written back to the local data files.
+
+With --to-spotify the backup is written back into your Spotify account:
+missing saved tracks are re-saved, artists are re-followed, your own playlists
+are recreated with their original order and other users' playlists are
+re-followed. A preview of the changes is shown before anything is written,
+and re-running a restore only applies what is still missing.
+
+Restoring to Spotify needs write access; run 'spotigo auth --write' first.`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		restoreBackup(args)
 	},
@@ -90,6 +148,97 @@ var backupStatusCmd = &cobra.Command{
 	},
 }
 
+var backupGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove archived items no longer referenced by any backup",
+	Run: func(cmd *cobra.Command, args []string) {
+		collectBackupGarbage()
+	},
+}
+
+var (
+	pruneDryRun      bool
+	pruneRetainDays  int
+	pruneKeepDaily   int
+	pruneKeepWeekly  int
+	pruneKeepMonthly int
+	backupPruneCmd   = &cobra.Command{
+		Use:   "prune",
+		Short: "Remove backups outside the retention policy",
+		Long: `Remove backups that fall outside the retention policy.
+
+A backup is kept if any rule keeps it:
+  retain_days   every backup younger than N days
+  keep_daily    the newest backup of each of the last N days
+  keep_weekly   the newest backup of each of the last N weeks
+  keep_monthly  the newest backup of each of the last N months
+
+The newest backup of each type is always kept. Rules come from the backup
+section of the config file; flags override them for a single run. Pruning
+also runs automatically after every backup.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runPrune(cmd)
+		},
+	}
+)
+
+var (
+	exportFormat    string
+	exportOutput    string
+	backupExportCmd = &cobra.Command{
+		Use:   "export [backup-id]",
+		Short: "Export backup data as CSV, NDJSON or Parquet",
+		Long: `Export backup data as flat tables, one file per section.
+
+Tracks, playlist items, artists, albums, shows and episodes are written one
+row per item. Without a backup ID the current data files are exported.
+
+Formats:
+  csv      comma-separated values with a header row
+  ndjson   newline-delimited JSON, one object per row
+  parquet  Apache Parquet, readable by DuckDB, pandas and Spark
+
+Exports are written in plaintext, even with storage.encryption on. For that
+reason backups only write the exports set by backup.format when storage is
+not encrypted.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runExport(args)
+		},
+	}
+)
+
+// backupDataKeys lists the data sections a backup can contain, each saved as <key>.json
+var backupDataKeys = []string{
+	"saved_tracks",
+	"playlists",
+	"followed_artists",
+	"saved_albums",
+	"saved_shows",
+	"saved_episodes",
+	"top_items",
+	"recently_played",
+}
+
+// topTimeRanges are the Spotify time ranges captured for top items
+var topTimeRanges = []string{"short", "medium", "long"}
+
+// topItemsSnapshot holds the user's top tracks and artists keyed by time range
+type topItemsSnapshot struct {
+	CapturedAt time.Time                       `json:"captured_at"`
+	Tracks     map[string][]spotify.FullTrack  `json:"tracks"`
+	Artists    map[string][]spotify.FullArtist `json:"artists"`
+}
+
+// recentlyPlayedSnapshot holds the recently played window at capture time
+type recentlyPlayedSnapshot struct {
+	CapturedAt time.Time                    `json:"captured_at"`
+	Items      []spotify.RecentlyPlayedItem `json:"items"`
+}
+
+// snapshotKinds lists the backup data keys that are also kept as history snapshots
+var snapshotKinds = []string{"top_items", "recently_played"}
+
 // backupResult holds the result of a concurrent backup operation
 type backupResult struct {
 	name string
@@ -99,18 +248,39 @@ type backupResult struct {
 
 // playlistData holds playlist information with tracks
 type playlistData struct {
-	ID     spotify.ID  `json:"id"`
-	Name   string      `json:"name"`
-	Owner  string      `json:"owner"`
-	Public bool        `json:"public"`
-	Tracks interface{} `json:"tracks"`
+	ID         spotify.ID  `json:"id"`
+	Name       string      `json:"name"`
+	Owner      string      `json:"owner"`
+	OwnerID    string      `json:"owner_id,omitempty"`
+	Public     bool        `json:"public"`
+	SnapshotID string      `json:"snapshot_id"`
+	Tracks     interface{} `json:"tracks"`
+}
+
+// storedPlaylist is a playlist read back from playlists.json with its tracks left undecoded
+type storedPlaylist struct {
+	ID         spotify.ID      `json:"id"`
+	SnapshotID string          `json:"snapshot_id"`
+	Tracks     json.RawMessage `json:"tracks"`
+}
+
+// errNotAuthenticated is returned when a backup is attempted without a Spotify token
+var errNotAuthenticated = errors.New("not authenticated with Spotify")
+
+// backupOptions controls a single backup run
+type backupOptions struct {
+	Type        string
+	Incremental bool
+	Index       bool
 }
 
 func runBackup() {
-	startTime := time.Now()
 	fmt.Println("Starting Spotify library backup...")
 	fmt.Printf("  Type: %s\n", backupType)
 	fmt.Printf("  Full: %v\n", backupFull)
+	if backupIncremental {
+		fmt.Printf("  Incremental: enabled\n")
+	}
 	if backupIndex {
 		fmt.Printf("  Index: enabled\n")
 	}
@@ -123,40 +293,107 @@ func runBackup() {
 		return
 	}
 
-	// Create Spotify client
+	opts := backupOptions{
+		Type:        backupType,
+		Incremental: backupIncremental && !backupFull,
+		Index:       backupIndex,
+	}
+	if err := executeBackup(context.Background(), cfg, opts); err != nil {
+		if errors.Is(err, errNotAuthenticated) {
+			fmt.Println("Not authenticated with Spotify")
+			fmt.Println("Run 'spotigo auth' to authenticate first.")
+			return
+		}
+		fmt.Printf("Backup failed: %v\n", err)
+	}
+}
+
+// newSpotifyClient creates an authenticated Spotify client using the configured retry settings
+func newSpotifyClient(cfg *config.Config) (*spotifyclient.Client, error) {
+	retryCfg := spotifyclient.DefaultRetryConfig()
+	retryCfg.MaxRetries = cfg.Spotify.MaxRetries
+	retryCfg.RequestBudget = cfg.Spotify.RequestBudget
+
+	tokenKey, err := tokenKeyProvider(cfg.Spotify.TokenKey, cfg.Spotify.TokenKeyFile, cfg.Profile)
+	if err != nil {
+		return nil, err
+	}
+
 	spotifyCfg := spotifyclient.Config{
 		ClientID:     cfg.Spotify.ClientID,
 		ClientSecret: cfg.Spotify.ClientSecret,
 		RedirectURI:  cfg.Spotify.RedirectURI,
 		TokenFile:    cfg.Spotify.TokenFile,
+		Retry:        retryCfg,
+		TokenKey:     tokenKey,
 	}
 
 	client, err := spotifyclient.NewClient(spotifyCfg)
 	if err != nil {
-		fmt.Printf("Error creating Spotify client: %v\n", err)
-		return
+		return nil, fmt.Errorf("failed to create Spotify client: %w", err)
 	}
 
 	if !client.IsAuthenticated() {
-		fmt.Println("Not authenticated with Spotify")
-		fmt.Println("Run 'spotigo auth' to authenticate first.")
-		return
+		if err := client.TokenError(); err != nil {
+			return nil, fmt.Errorf("%w: %v", errNotAuthenticated, err)
+		}
+		return nil, errNotAuthenticated
+	}
+	return client, nil
+}
+
+// executeBackup runs one backup, then prunes old backups and optionally rebuilds the search index.
+// Cancelling ctx aborts in-flight requests; nothing is saved for an interrupted backup.
+func executeBackup(ctx context.Context, cfg *config.Config, opts backupOptions) error {
+	startTime := time.Now()
+
+	client, err := newSpotifyClient(cfg)
+	if err != nil {
+		return err
 	}
 
 	// Create storage
-	store := storage.NewStore(cfg.Storage.DataDir, cfg.Storage.BackupDir)
+	store, err := newStore(cfg)
+	if err != nil {
+		return err
+	}
 
 	// Perform backup
-	if err := performBackupConcurrent(client, store, backupType); err != nil {
-		fmt.Printf("Backup failed: %v\n", err)
-		return
+	if err := performBackupConcurrent(ctx, client, store, opts.Type, opts.Incremental); err != nil {
+		return err
 	}
 
 	elapsed := time.Since(startTime)
 	fmt.Printf("\nBackup completed successfully in %s!\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("  API requests: %d\n", client.RequestCount())
+
+	// Write flat exports when a non-JSON format is configured. Exports are
+	// plaintext, so they are not written automatically for an encrypted store.
+	if format := cfg.Backup.Format; format != "" && format != "json" && store.Encrypted() {
+		fmt.Printf("Warning: Skipped the %s export: exports are not encrypted and storage.encryption is on.\n", format)
+		fmt.Println("Run 'spotigo backup export' to write one anyway.")
+	} else if format != "" && format != "json" {
+		dir := filepath.Join(cfg.Storage.DataDir, "exports")
+		paths, err := exportSections(loadDataSections(store), format, dir)
+		if err != nil {
+			fmt.Printf("Warning: Failed to export backup as %s: %v\n", format, err)
+		} else {
+			fmt.Printf("  Exported %d %s files to %s\n", len(paths), format, dir)
+		}
+	}
+
+	// Enforce the retention policy
+	if policy := retentionPolicy(cfg); policy.Enabled() {
+		result, err := store.Prune(policy, time.Now(), false)
+		if err != nil {
+			fmt.Printf("Warning: Failed to prune old backups: %v\n", err)
+		} else if len(result.Removed) > 0 {
+			fmt.Printf("  Pruned %d old backups (%.2f MB freed)\n", len(result.Removed), float64(result.GC.FreedBytes)/1024/1024)
+		}
+	}
 
 	// Build search index if requested
-	if backupIndex {
+	if opts.Index && ctx.Err() == nil {
 		fmt.Println()
 		fmt.Println("Building search index...")
 		if err := buildSearchIndex(cfg); err != nil {
@@ -166,19 +403,32 @@ func runBackup() {
 			fmt.Println("Search index built successfully!")
 		}
 	}
+
+	return nil
 }
 
 // performBackupConcurrent performs backup operations concurrently
-func performBackupConcurrent(client *spotifyclient.Client, store *storage.Store, backupType string) error {
-	ctx := context.Background()
+func performBackupConcurrent(ctx context.Context, client *spotifyclient.Client, store *storage.Store, backupType string, incremental bool) error {
+	// Load the last-seen state; a full backup still records it for the next incremental run
+	manifest, err := store.LoadSyncManifest()
+	if err != nil {
+		fmt.Printf("  Warning: %v (falling back to a full backup)\n", err)
+		manifest = &storage.SyncManifest{Playlists: make(map[string]storage.PlaylistState)}
+		incremental = false
+	}
+	previousManifest := *manifest
 
 	// Determine which backups to run
 	runTracks := backupType == "all" || backupType == "tracks"
 	runPlaylists := backupType == "all" || backupType == "playlists"
 	runArtists := backupType == "all" || backupType == "artists"
+	runAlbums := backupType == "all" || backupType == "albums"
+	runShows := backupType == "all" || backupType == "shows"
+	runTop := backupType == "all" || backupType == "top"
+	runRecent := backupType == "all" || backupType == "recent"
 
 	// Channel for collecting results
-	results := make(chan backupResult, 3)
+	results := make(chan backupResult, 8)
 	var wg sync.WaitGroup
 
 	// Launch concurrent fetches
@@ -187,9 +437,9 @@ func performBackupConcurrent(client *spotifyclient.Client, store *storage.Store,
 		go func() {
 			defer wg.Done()
 			fmt.Println("  🎵 Fetching saved tracks...")
-			tracks, err := client.GetSavedTracks(ctx)
+			tracks, state, err := fetchSavedTracks(ctx, client, store, previousManifest.SavedTracks, incremental)
 			if err == nil {
-				fmt.Printf("    Found %d saved tracks\n", len(tracks))
+				manifest.SavedTracks = state
 			}
 			results <- backupResult{name: "saved_tracks", data: tracks, err: err}
 		}()
@@ -213,11 +463,80 @@ func performBackupConcurrent(client *spotifyclient.Client, store *storage.Store,
 		go func() {
 			defer wg.Done()
 			fmt.Println("  📋 Fetching playlists...")
-			playlistData, err := fetchPlaylistsConcurrent(ctx, client)
+			var unchanged map[spotify.ID]storedPlaylist
+			if incremental {
+				unchanged = loadStoredPlaylists(store, previousManifest.Playlists)
+			}
+			playlistData, err := fetchPlaylistsConcurrent(ctx, client, unchanged)
 			results <- backupResult{name: "playlists", data: playlistData, err: err}
 		}()
 	}
 
+	if runAlbums {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fmt.Println("  💿 Fetching saved albums...")
+			albums, err := client.GetSavedAlbums(ctx)
+			if err == nil {
+				fmt.Printf("    Found %d saved albums\n", len(albums))
+			}
+			results <- backupResult{name: "saved_albums", data: albums, err: err}
+		}()
+	}
+
+	if runShows {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			fmt.Println("  🎙️ Fetching followed podcasts...")
+			shows, err := client.GetSavedShows(ctx)
+			if err == nil {
+				fmt.Printf("    Found %d followed podcasts\n", len(shows))
+			}
+			results <- backupResult{name: "saved_shows", data: shows, err: err}
+		}()
+		go func() {
+			defer wg.Done()
+			fmt.Println("  📻 Fetching saved episodes...")
+			episodes, err := client.GetSavedEpisodes(ctx)
+			if err == nil {
+				fmt.Printf("    Found %d saved episodes\n", len(episodes))
+			}
+			results <- backupResult{name: "saved_episodes", data: episodes, err: err}
+		}()
+	}
+
+	if runTop {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fmt.Println("  🏆 Fetching top tracks and artists...")
+			top, err := fetchTopItems(ctx, client)
+			if err == nil {
+				fmt.Printf("    Captured top items for %d time ranges\n", len(topTimeRanges))
+			}
+			results <- backupResult{name: "top_items", data: top, err: err}
+		}()
+	}
+
+	if runRecent {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fmt.Println("  🕒 Fetching recently played...")
+			items, err := client.GetRecentlyPlayed(ctx)
+			if err == nil {
+				fmt.Printf("    Found %d recently played tracks\n", len(items))
+			}
+			results <- backupResult{
+				name: "recently_played",
+				data: &recentlyPlayedSnapshot{CapturedAt: time.Now().UTC(), Items: items},
+				err:  err,
+			}
+		}()
+	}
+
 	// Close results channel when all fetches complete
 	go func() {
 		wg.Wait()
@@ -236,6 +555,11 @@ func performBackupConcurrent(client *spotifyclient.Client, store *storage.Store,
 		backupData[result.name] = result.data
 	}
 
+	// Don't overwrite existing data with a partial, interrupted backup
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("backup interrupted: %w", err)
+	}
+
 	// Check for critical errors
 	if len(errors) > 0 && len(backupData) == 0 {
 		return fmt.Errorf("all backup operations failed: %v", errors)
@@ -252,11 +576,234 @@ func performBackupConcurrent(client *spotifyclient.Client, store *storage.Store,
 		return fmt.Errorf("failed to save backup files: %w", err)
 	}
 
+	// Record history snapshots
+	if err := saveHistorySnapshots(store, backupData, time.Now()); err != nil {
+		fmt.Printf("  Warning: %v\n", err)
+	}
+
+	// Record the last-seen state for the next incremental backup
+	if playlists, ok := backupData["playlists"].([]playlistData); ok {
+		manifest.Playlists = playlistStates(playlists)
+	}
+	manifest.UpdatedAt = time.Now().UTC()
+	if err := store.SaveSyncManifest(manifest); err != nil {
+		fmt.Printf("  Warning: %v\n", err)
+	}
+
+	return nil
+}
+
+// fetchSavedTracks fetches saved tracks, either in full or only those added since the last backup.
+// Incremental results are merged with the previously saved tracks, newest first.
+func fetchSavedTracks(ctx context.Context, client *spotifyclient.Client, store *storage.Store, previous storage.SavedTracksState, incremental bool) (interface{}, storage.SavedTracksState, error) {
+	var stored []json.RawMessage
+	if incremental && previous.Count > 0 {
+		if err := store.LoadJSON("saved_tracks.json", &stored); err != nil {
+			fmt.Printf("    Previous saved tracks unavailable, fetching all: %v\n", err)
+			stored = nil
+		}
+	}
+
+	if stored == nil {
+		tracks, err := client.GetSavedTracks(ctx)
+		if err != nil {
+			return nil, storage.SavedTracksState{}, err
+		}
+		fmt.Printf("    Found %d saved tracks\n", len(tracks))
+		return tracks, newSavedTracksState(tracks, len(tracks), previous), nil
+	}
+
+	newTracks, err := client.GetSavedTracksSince(ctx, savedTracksCursor(previous, stored))
+	if err != nil {
+		return nil, storage.SavedTracksState{}, err
+	}
+
+	merged := mergeSavedTracks(newTracks, stored)
+	fmt.Printf("    Found %d new saved tracks (%d total)\n", len(newTracks), len(merged))
+	return merged, newSavedTracksState(newTracks, len(merged), previous), nil
+}
+
+// savedTrackItem holds the fields of a stored saved track used by incremental backups
+type savedTrackItem struct {
+	AddedAt string `json:"added_at"`
+	Track   struct {
+		ID spotify.ID `json:"id"`
+	} `json:"track"`
+}
+
+// savedTracksCursor returns when the newest saved track seen by the last
+// backup was added. Manifests from before the cursor was recorded fall back
+// to the newest stored track.
+func savedTracksCursor(previous storage.SavedTracksState, stored []json.RawMessage) time.Time {
+	if latest, err := time.Parse(time.RFC3339, previous.LatestAddedAt); err == nil {
+		return latest
+	}
+	var latest time.Time
+	for _, raw := range stored {
+		var item savedTrackItem
+		if err := json.Unmarshal(raw, &item); err != nil {
+			continue
+		}
+		if added, err := time.Parse(time.RFC3339, item.AddedAt); err == nil && added.After(latest) {
+			latest = added
+		}
+	}
+	return latest
+}
+
+// mergeSavedTracks puts newly saved tracks before the stored ones, newest
+// first. A track saved again replaces its stored copy.
+func mergeSavedTracks(newTracks []spotify.SavedTrack, stored []json.RawMessage) []interface{} {
+	fresh := make(map[spotify.ID]bool, len(newTracks))
+	merged := make([]interface{}, 0, len(newTracks)+len(stored))
+	for _, track := range newTracks {
+		fresh[track.ID] = true
+		merged = append(merged, track)
+	}
+	for _, raw := range stored {
+		var item savedTrackItem
+		if err := json.Unmarshal(raw, &item); err == nil && fresh[item.Track.ID] {
+			continue
+		}
+		merged = append(merged, raw)
+	}
+	return merged
+}
+
+// newSavedTracksState records the newest saved track, keeping the previous one if nothing is new
+func newSavedTracksState(newest []spotify.SavedTrack, count int, previous storage.SavedTracksState) storage.SavedTracksState {
+	state := storage.SavedTracksState{
+		Count:         count,
+		LatestID:      previous.LatestID,
+		LatestAddedAt: previous.LatestAddedAt,
+	}
+	if len(newest) > 0 {
+		state.LatestID = string(newest[0].ID)
+		state.LatestAddedAt = newest[0].AddedAt
+	}
+	return state
+}
+
+// loadStoredPlaylists returns previously backed-up playlists whose snapshot ID matches the manifest
+func loadStoredPlaylists(store *storage.Store, states map[string]storage.PlaylistState) map[spotify.ID]storedPlaylist {
+	var stored []storedPlaylist
+	if err := store.LoadJSON("playlists.json", &stored); err != nil {
+		return nil
+	}
+
+	playlists := make(map[spotify.ID]storedPlaylist, len(stored))
+	for _, p := range stored {
+		state, ok := states[string(p.ID)]
+		if !ok || p.SnapshotID == "" || state.SnapshotID != p.SnapshotID || len(p.Tracks) == 0 {
+			continue
+		}
+		playlists[p.ID] = p
+	}
+	return playlists
+}
+
+// playlistStates builds the manifest entries for a set of fetched playlists
+func playlistStates(playlists []playlistData) map[string]storage.PlaylistState {
+	states := make(map[string]storage.PlaylistState, len(playlists))
+	for _, p := range playlists {
+		states[string(p.ID)] = storage.PlaylistState{
+			Name:       p.Name,
+			SnapshotID: p.SnapshotID,
+			TrackCount: countPlaylistTracks(p.Tracks),
+		}
+	}
+	return states
+}
+
+// fetchTopItems fetches top tracks and artists for every time range
+func fetchTopItems(ctx context.Context, client *spotifyclient.Client) (*topItemsSnapshot, error) {
+	top := &topItemsSnapshot{
+		CapturedAt: time.Now().UTC(),
+		Tracks:     make(map[string][]spotify.FullTrack, len(topTimeRanges)),
+		Artists:    make(map[string][]spotify.FullArtist, len(topTimeRanges)),
+	}
+
+	for _, timeRange := range topTimeRanges {
+		tracks, err := client.GetTopTracks(ctx, timeRange)
+		if err != nil {
+			return nil, fmt.Errorf("%s term: %w", timeRange, err)
+		}
+		top.Tracks[timeRange] = tracks
+
+		artists, err := client.GetTopArtists(ctx, timeRange)
+		if err != nil {
+			return nil, fmt.Errorf("%s term: %w", timeRange, err)
+		}
+		top.Artists[timeRange] = artists
+	}
+
+	return top, nil
+}
+
+func listSnapshots(args []string) {
+	cfg := GetConfig()
+	if cfg == nil {
+		fmt.Println("Error: Configuration not loaded")
+		return
+	}
+
+	kinds := snapshotKinds
+	if len(args) > 0 {
+		kinds = args
+	}
+	for _, kind := range kinds {
+		if !slices.Contains(snapshotKinds, kind) {
+			fmt.Printf("Unknown snapshot kind: %s\n", kind)
+			fmt.Printf("Available kinds: %s\n", strings.Join(snapshotKinds, ", "))
+			return
+		}
+	}
+
+	store, err := newStore(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	for i, kind := range kinds {
+		snapshots, err := store.ListSnapshots(kind)
+		if err != nil {
+			fmt.Printf("Error listing %s snapshots: %v\n", kind, err)
+			return
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s snapshots:\n", kind)
+		if len(snapshots) == 0 {
+			fmt.Println("  None yet. They are saved by backups that include this section.")
+			continue
+		}
+		for _, snapshot := range snapshots {
+			fmt.Printf("  %s  %s\n", snapshot.Timestamp.Local().Format("2006-01-02 15:04:05"), snapshot.Path)
+		}
+	}
+}
+
+// saveHistorySnapshots stores time-stamped copies of data that changes over time
+func saveHistorySnapshots(store *storage.Store, backupData map[string]interface{}, timestamp time.Time) error {
+	for _, kind := range snapshotKinds {
+		data, ok := backupData[kind]
+		if !ok {
+			continue
+		}
+		info, err := store.SaveSnapshot(kind, timestamp, data)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("    Saved snapshot: %s\n", info.Path)
+	}
 	return nil
 }
 
-// fetchPlaylistsConcurrent fetches all playlists and their tracks concurrently
-func fetchPlaylistsConcurrent(ctx context.Context, client *spotifyclient.Client) ([]playlistData, error) {
+// fetchPlaylistsConcurrent fetches all playlists and their tracks concurrently.
+// Playlists found in unchanged with the same snapshot ID reuse their stored tracks.
+func fetchPlaylistsConcurrent(ctx context.Context, client *spotifyclient.Client, unchanged map[spotify.ID]storedPlaylist) ([]playlistData, error) {
 	// First, get all playlists
 	playlists, err := client.GetPlaylists(ctx)
 	if err != nil {
@@ -281,13 +828,27 @@ func fetchPlaylistsConcurrent(ctx context.Context, client *spotifyclient.Client)
 		err   error
 	}
 
-	jobs := make(chan playlistJob, len(playlists))
-	results := make(chan playlistResult, len(playlists))
+	// Reuse stored tracks for playlists whose snapshot hasn't changed
+	playlistDataSlice := make([]playlistData, len(playlists))
+	toFetch := make([]int, 0, len(playlists))
+	for i, playlist := range playlists {
+		if stored, ok := unchanged[playlist.ID]; ok && playlist.SnapshotID == stored.SnapshotID {
+			playlistDataSlice[i] = newPlaylistData(playlist, stored.Tracks)
+			continue
+		}
+		toFetch = append(toFetch, i)
+	}
+	if skipped := len(playlists) - len(toFetch); skipped > 0 {
+		fmt.Printf("    Skipped %d unchanged playlists\n", skipped)
+	}
+
+	jobs := make(chan playlistJob, len(toFetch))
+	results := make(chan playlistResult, len(toFetch))
 
 	// Start workers
 	numWorkers := maxConcurrentPlaylistFetches
-	if len(playlists) < numWorkers {
-		numWorkers = len(playlists)
+	if len(toFetch) < numWorkers {
+		numWorkers = len(toFetch)
 	}
 
 	var wg sync.WaitGroup
@@ -307,21 +868,15 @@ func fetchPlaylistsConcurrent(ctx context.Context, client *spotifyclient.Client)
 
 				results <- playlistResult{
 					index: job.index,
-					data: playlistData{
-						ID:     job.playlist.ID,
-						Name:   job.playlist.Name,
-						Owner:  job.playlist.Owner.DisplayName,
-						Public: job.playlist.IsPublic,
-						Tracks: items,
-					},
+					data:  newPlaylistData(job.playlist, items),
 				}
 			}
 		}()
 	}
 
 	// Send jobs
-	for i, playlist := range playlists {
-		jobs <- playlistJob{index: i, playlist: playlist}
+	for _, i := range toFetch {
+		jobs <- playlistJob{index: i, playlist: playlists[i]}
 	}
 	close(jobs)
 
@@ -332,7 +887,6 @@ func fetchPlaylistsConcurrent(ctx context.Context, client *spotifyclient.Client)
 	}()
 
 	// Collect results maintaining order
-	playlistDataSlice := make([]playlistData, len(playlists))
 	var errors []error
 	completed := 0
 
@@ -361,10 +915,29 @@ func fetchPlaylistsConcurrent(ctx context.Context, client *spotifyclient.Client)
 	return validPlaylists, nil
 }
 
+// newPlaylistData builds the stored representation of a playlist
+func newPlaylistData(playlist spotify.SimplePlaylist, tracks interface{}) playlistData {
+	return playlistData{
+		ID:         playlist.ID,
+		Name:       playlist.Name,
+		Owner:      playlist.Owner.DisplayName,
+		OwnerID:    playlist.Owner.ID,
+		Public:     playlist.IsPublic,
+		SnapshotID: playlist.SnapshotID,
+		Tracks:     tracks,
+	}
+}
+
 // countPlaylistTracks counts tracks in a playlist
 func countPlaylistTracks(tracks interface{}) int {
-	if items, ok := tracks.([]spotify.PlaylistItem); ok {
+	switch items := tracks.(type) {
+	case []spotify.PlaylistItem:
 		return len(items)
+	case json.RawMessage:
+		var raw []json.RawMessage
+		if err := json.Unmarshal(items, &raw); err == nil {
+			return len(raw)
+		}
 	}
 	return 0
 }
@@ -379,7 +952,13 @@ func saveBackupFilesConcurrent(store *storage.Store, backupData map[string]inter
 		wg.Add(1)
 		go func(filename string, content interface{}) {
 			defer wg.Done()
-			if err := saveJSONBuffered(filepath.Join(store.GetDataDir(), filename+".json"), content); err != nil {
+			var err error
+			if store.Encrypted() {
+				err = store.SaveJSON(filename+".json", content)
+			} else {
+				err = saveJSONBuffered(filepath.Join(store.GetDataDir(), filename+".json"), content)
+			}
+			if err != nil {
 				errChan <- fmt.Errorf("failed to save %s: %w", filename, err)
 			}
 		}(name, data)
@@ -389,12 +968,12 @@ func saveBackupFilesConcurrent(store *storage.Store, backupData map[string]inter
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		metadata, err := createBackupBuffered(store, backupType, backupData)
+		metadata, err := store.CreateBackup(backupType, backupData)
 		if err != nil {
 			errChan <- fmt.Errorf("failed to create backup: %w", err)
 			return
 		}
-		fmt.Printf("    Saved backup: %s (%d bytes)\n", metadata.ID, metadata.Size)
+		fmt.Printf("    Saved backup: %s (%d bytes, %d new)\n", metadata.ID, metadata.Size, metadata.StoredSize)
 	}()
 
 	// Wait for all writes to complete
@@ -448,56 +1027,6 @@ func saveJSONBuffered(path string, data interface{}) error {
 	return nil
 }
 
-// createBackupBuffered creates a backup file with buffered I/O
-func createBackupBuffered(store *storage.Store, backupType string, data interface{}) (*storage.BackupMetadata, error) {
-	timestamp := time.Now()
-	timestampStr := timestamp.Format("20060102-150405")
-	filename := fmt.Sprintf("%s-%s.json", backupType, timestampStr)
-	cleanBackupDir := filepath.Clean(store.GetBackupDir())
-	path := filepath.Join(cleanBackupDir, filename)
-
-	// Ensure backup directory exists
-	if err := os.MkdirAll(cleanBackupDir, 0750); err != nil {
-		return nil, fmt.Errorf("failed to create backup directory: %w", err)
-	}
-
-	file, err := os.Create(path) // #nosec G304 - path is constructed from controlled backupDir and timestamped filename
-	if err != nil {
-		return nil, fmt.Errorf("failed to create backup file: %w", err)
-	}
-	defer func() {
-		if closeErr := file.Close(); closeErr != nil {
-			fmt.Printf("Warning: failed to close backup file %s: %v\n", path, closeErr)
-		}
-	}()
-
-	// Use buffered writer
-	writer := bufio.NewWriterSize(file, writeBufferSize)
-
-	encoder := json.NewEncoder(writer)
-	encoder.SetIndent("", "  ")
-	if encodeErr := encoder.Encode(data); encodeErr != nil {
-		return nil, fmt.Errorf("failed to encode backup: %w", encodeErr)
-	}
-
-	if flushErr := writer.Flush(); flushErr != nil {
-		return nil, fmt.Errorf("failed to flush buffer: %w", flushErr)
-	}
-
-	// Get file size
-	info, err := file.Stat()
-	if err != nil {
-		return nil, fmt.Errorf("failed to stat backup file: %w", err)
-	}
-
-	return &storage.BackupMetadata{
-		ID:        filename,
-		Timestamp: timestamp,
-		Type:      backupType,
-		Size:      info.Size(),
-	}, nil
-}
-
 func listBackups() {
 	cfg := GetConfig()
 	if cfg == nil {
@@ -505,7 +1034,11 @@ func listBackups() {
 		return
 	}
 
-	store := storage.NewStore(cfg.Storage.DataDir, cfg.Storage.BackupDir)
+	store, err := newStore(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
 	backups, err := store.ListBackups()
 	if err != nil {
 		fmt.Printf("Error listing backups: %v\n", err)
@@ -539,7 +1072,11 @@ func restoreBackup(args []string) {
 		return
 	}
 
-	store := storage.NewStore(cfg.Storage.DataDir, cfg.Storage.BackupDir)
+	store, err := newStore(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
 
 	// Get list of available backups
 	backups, err := store.ListBackups()
@@ -554,38 +1091,28 @@ func restoreBackup(args []string) {
 		return
 	}
 
-	// Sort backups by timestamp (newest first)
-	sort.Slice(backups, func(i, j int) bool {
-		return backups[i].Timestamp.After(backups[j].Timestamp)
-	})
-
 	// Determine which backup to restore
-	var selectedBackup *storage.BackupMetadata
 	backupID := "latest"
 	if len(args) > 0 {
 		backupID = args[0]
 	}
 
+	selectedBackup := selectBackup(backups, backupID)
+	if selectedBackup == nil {
+		fmt.Printf("Backup not found: %s\n", backupID)
+		fmt.Println("\nAvailable backups:")
+		for _, b := range backups {
+			fmt.Printf("  %s (%s)\n", b.ID, b.Timestamp.Format("2006-01-02 15:04:05"))
+		}
+		return
+	}
 	if backupID == "latest" {
-		selectedBackup = &backups[0]
 		fmt.Printf("Selected latest backup: %s\n", selectedBackup.ID)
-	} else {
-		// Find matching backup
-		for i := range backups {
-			if backups[i].ID == backupID || strings.Contains(backups[i].ID, backupID) {
-				selectedBackup = &backups[i]
-				break
-			}
-		}
+	}
 
-		if selectedBackup == nil {
-			fmt.Printf("Backup not found: %s\n", backupID)
-			fmt.Println("\nAvailable backups:")
-			for _, b := range backups {
-				fmt.Printf("  %s (%s)\n", b.ID, b.Timestamp.Format("2006-01-02 15:04:05"))
-			}
-			return
-		}
+	if restoreToSpotify {
+		runSpotifyRestore(cfg, store, selectedBackup)
+		return
 	}
 
 	fmt.Printf("Restoring from backup: %s\n", selectedBackup.ID)
@@ -597,6 +1124,7 @@ func restoreBackup(args []string) {
 	var backupData map[string]interface{}
 	if err := store.LoadBackupJSON(selectedBackup.ID, &backupData); err != nil {
 		fmt.Printf("Error loading backup: %v\n", err)
+		fmt.Println("Run 'spotigo backup verify' to check your backups for corruption.")
 		return
 	}
 
@@ -622,19 +1150,11 @@ func restoreBackup(args []string) {
 	}
 
 	// Restore each data type concurrently
-	if tracks, ok := backupData["saved_tracks"]; ok {
-		wg.Add(1)
-		go restoreFile("saved_tracks", "saved_tracks.json", tracks)
-	}
-
-	if playlists, ok := backupData["playlists"]; ok {
-		wg.Add(1)
-		go restoreFile("playlists", "playlists.json", playlists)
-	}
-
-	if artists, ok := backupData["followed_artists"]; ok {
-		wg.Add(1)
-		go restoreFile("followed_artists", "followed_artists.json", artists)
+	for _, key := range backupDataKeys {
+		if data, ok := backupData[key]; ok {
+			wg.Add(1)
+			go restoreFile(key, key+".json", data)
+		}
 	}
 
 	wg.Wait()
@@ -654,6 +1174,24 @@ func restoreBackup(args []string) {
 	}
 }
 
+// selectBackup finds a backup by ID or ID fragment, or the newest backup for "latest".
+// backups is sorted newest first.
+func selectBackup(backups []storage.BackupMetadata, backupID string) *storage.BackupMetadata {
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Timestamp.After(backups[j].Timestamp)
+	})
+
+	if backupID == "latest" {
+		return &backups[0]
+	}
+	for i := range backups {
+		if backups[i].ID == backupID || strings.Contains(backups[i].ID, backupID) {
+			return &backups[i]
+		}
+	}
+	return nil
+}
+
 // countItems returns the count of items in a slice interface
 func countItems(data interface{}) int {
 	if slice, ok := data.([]interface{}); ok {
@@ -669,7 +1207,11 @@ func showBackupStatus() {
 		return
 	}
 
-	store := storage.NewStore(cfg.Storage.DataDir, cfg.Storage.BackupDir)
+	store, err := newStore(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
 	backups, err := store.ListBackups()
 	if err != nil {
 		fmt.Printf("Error checking backup status: %v\n", err)
@@ -686,22 +1228,252 @@ func showBackupStatus() {
 		fmt.Printf("  Last backup: %s\n", latest.Timestamp.Format("2006-01-02 15:04:05"))
 		fmt.Printf("  Latest ID: %s\n", latest.ID)
 
-		// Calculate total size
-		var totalSize int64
+		fmt.Printf("  Backups: %d\n", len(backups))
+
+		// Archived backups share objects, so measure the object store rather than summing backups
+		objects, totalSize, err := store.ArchiveUsage()
+		if err != nil {
+			fmt.Printf("  Warning: %v\n", err)
+		}
 		for _, backup := range backups {
-			totalSize += backup.Size
+			if _, err := store.LoadManifest(backup.ID); err != nil {
+				totalSize += backup.Size
+			}
 		}
+		fmt.Printf("  Archived items: %d\n", objects)
 		fmt.Printf("  Total storage: %.2f MB\n", float64(totalSize)/1024/1024)
 	}
 
 	fmt.Printf("  Schedule: %s\n", cfg.Backup.Schedule)
-	fmt.Printf("  Retention: %d days\n", cfg.Backup.RetainDays)
+	if state := loadDaemonState(store); !state.NextRun.IsZero() {
+		fmt.Printf("  Next scheduled run: %s\n", state.NextRun.Format("2006-01-02 15:04:05"))
+		if state.LastStatus != "" {
+			fmt.Printf("  Last scheduled run: %s (%s)\n", state.LastRunStart.Format("2006-01-02 15:04:05"), state.LastStatus)
+		}
+	}
+	fmt.Printf("  Retention: %s\n", describeRetention(retentionPolicy(cfg)))
+}
+
+// retentionPolicy builds the backup retention policy from config
+func retentionPolicy(cfg *config.Config) storage.RetentionPolicy {
+	return storage.RetentionPolicy{
+		RetainDays:  cfg.Backup.RetainDays,
+		KeepDaily:   cfg.Backup.KeepDaily,
+		KeepWeekly:  cfg.Backup.KeepWeekly,
+		KeepMonthly: cfg.Backup.KeepMonthly,
+	}
+}
+
+// describeRetention summarizes a retention policy for display
+func describeRetention(policy storage.RetentionPolicy) string {
+	if !policy.Enabled() {
+		return "keep all backups"
+	}
+
+	var rules []string
+	if policy.RetainDays > 0 {
+		rules = append(rules, fmt.Sprintf("%d days", policy.RetainDays))
+	}
+	if policy.KeepDaily > 0 {
+		rules = append(rules, fmt.Sprintf("%d daily", policy.KeepDaily))
+	}
+	if policy.KeepWeekly > 0 {
+		rules = append(rules, fmt.Sprintf("%d weekly", policy.KeepWeekly))
+	}
+	if policy.KeepMonthly > 0 {
+		rules = append(rules, fmt.Sprintf("%d monthly", policy.KeepMonthly))
+	}
+	return strings.Join(rules, ", ")
+}
+
+func runPrune(cmd *cobra.Command) {
+	cfg := GetConfig()
+	if cfg == nil {
+		fmt.Println("Error: Configuration not loaded")
+		return
+	}
+
+	policy := retentionPolicy(cfg)
+	if cmd.Flags().Changed("retain-days") {
+		policy.RetainDays = pruneRetainDays
+	}
+	if cmd.Flags().Changed("keep-daily") {
+		policy.KeepDaily = pruneKeepDaily
+	}
+	if cmd.Flags().Changed("keep-weekly") {
+		policy.KeepWeekly = pruneKeepWeekly
+	}
+	if cmd.Flags().Changed("keep-monthly") {
+		policy.KeepMonthly = pruneKeepMonthly
+	}
+
+	if !policy.Enabled() {
+		fmt.Println("No retention policy configured; nothing to prune.")
+		fmt.Println("Set backup.retain_days or backup.keep_daily/keep_weekly/keep_monthly in your config.")
+		return
+	}
+
+	store, err := newStore(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	result, err := store.Prune(policy, time.Now(), pruneDryRun)
+	if err != nil {
+		fmt.Printf("Error pruning backups: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Retention: %s\n", describeRetention(policy))
+	fmt.Println()
+
+	if len(result.Removed) == 0 {
+		fmt.Printf("Nothing to prune (%d backups kept).\n", result.Kept)
+		return
+	}
+
+	if pruneDryRun {
+		fmt.Println("Would remove:")
+	} else {
+		fmt.Println("Removed:")
+	}
+	for _, backup := range result.Removed {
+		fmt.Printf("  %s  %s\n", backup.ID, backup.Timestamp.Format("2006-01-02 15:04:05"))
+	}
+	fmt.Println()
+
+	if pruneDryRun {
+		fmt.Printf("%d backups would be removed, %d kept. Run without --dry-run to delete them.\n", len(result.Removed), result.Kept)
+		return
+	}
+	fmt.Printf("%d backups removed, %d kept (%.2f MB freed)\n", len(result.Removed), result.Kept, float64(result.GC.FreedBytes)/1024/1024)
+}
+
+func collectBackupGarbage() {
+	cfg := GetConfig()
+	if cfg == nil {
+		fmt.Println("Error: Configuration not loaded")
+		return
+	}
+
+	store, err := newStore(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	result, err := store.GarbageCollect()
+	if err != nil {
+		fmt.Printf("Error collecting garbage: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Removed %d unreferenced items (%.2f MB freed)\n", result.Removed, float64(result.FreedBytes)/1024/1024)
+	fmt.Printf("%d items remain in the archive\n", result.Objects)
+	if result.Recent > 0 {
+		fmt.Printf("%d recently written items were kept in case a backup is in progress; a later run removes them\n", result.Recent)
+	}
+}
+
+// loadDataSections reads the current <key>.json data files
+func loadDataSections(store *storage.Store) map[string]json.RawMessage {
+	sections := make(map[string]json.RawMessage)
+	for _, key := range backupDataKeys {
+		var raw json.RawMessage
+		if !store.Exists(key + ".json") {
+			continue
+		}
+		if err := store.LoadJSON(key+".json", &raw); err != nil {
+			fmt.Printf("  Warning: skipping %s: %v\n", key, err)
+			continue
+		}
+		sections[key] = raw
+	}
+	return sections
+}
+
+// exportSections writes each known section as a table in the given format and returns the file paths
+func exportSections(sections map[string]json.RawMessage, format, dir string) ([]string, error) {
+	exporter, err := export.Get(format)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(sections))
+	for _, key := range backupDataKeys {
+		data, ok := sections[key]
+		if !ok {
+			continue
+		}
+		table, err := export.SectionTable(key, data)
+		if err != nil {
+			return paths, err
+		}
+		path, err := export.WriteFile(dir, exporter, table)
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func runExport(args []string) {
+	cfg := GetConfig()
+	if cfg == nil {
+		fmt.Println("Error: Configuration not loaded")
+		return
+	}
+
+	format := exportFormat
+	if format == "" {
+		format = cfg.Backup.Format
+	}
+	if format == "" || format == "json" {
+		fmt.Println("Error: choose an export format with --format (csv, ndjson or parquet)")
+		return
+	}
+
+	dir := exportOutput
+	if dir == "" {
+		dir = filepath.Join(cfg.Storage.DataDir, "exports")
+	}
+
+	store, err := newStore(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	var sections map[string]json.RawMessage
+	if len(args) > 0 {
+		if err := store.LoadBackupJSON(args[0], &sections); err != nil {
+			fmt.Printf("Error loading backup: %v\n", err)
+			return
+		}
+	} else {
+		sections = loadDataSections(store)
+	}
+
+	if len(sections) == 0 {
+		fmt.Println("No backup data found. Run 'spotigo backup' first.")
+		return
+	}
+
+	paths, err := exportSections(sections, format, dir)
+	for _, path := range paths {
+		fmt.Printf("  Wrote %s\n", path)
+	}
+	if err != nil {
+		fmt.Printf("Error exporting backup: %v\n", err)
+		return
+	}
+	fmt.Printf("\nExported %d sections as %s\n", len(paths), format)
 }
 
 // buildSearchIndex creates vector embeddings for semantic search
 func buildSearchIndex(cfg *config.Config) error {
 	// Create Ollama client
-	ollamaClient := ollama.NewClient(cfg.Ollama.Host, time.Duration(cfg.Ollama.Timeout)*time.Second)
+	ollamaClient := newEmbedClient(cfg)
 
 	// Check if Ollama is available
 	ctx := context.Background()
@@ -709,10 +1481,13 @@ func buildSearchIndex(cfg *config.Config) error {
 		return fmt.Errorf("ollama not available: %w", err)
 	}
 
-	// Load the vector store
-	embeddingModel := "nomic-embed-text"
-	storePath := filepath.Join(cfg.Storage.EmbeddingsDir, "vectors.json")
-	store := rag.NewStore(ollamaClient, embeddingModel, storePath)
+	// Load the vector store, so only new and changed items are embedded
+	embeddingModel := searchIndexModel(cfg)
+	store := newSearchStore(cfg, ollamaClient, embeddingModel)
+	defer store.Close()
+	if err := store.Load(); err != nil {
+		store.Clear()
+	}
 
 	// Load backup data and create documents
 	docs := loadBackupDocuments(cfg)
@@ -723,24 +1498,11 @@ func buildSearchIndex(cfg *config.Config) error {
 
 	fmt.Printf("  Indexing %d items...\n", len(docs))
 
-	// Add documents with progress
-	for i, doc := range docs {
-		if err := store.Add(ctx, doc); err != nil {
-			// Log but continue on individual failures
-			continue
-		}
-
-		// Show progress every 25 items
-		if (i+1)%25 == 0 || i+1 == len(docs) {
-			fmt.Printf("  Indexed %d/%d items...\r", i+1, len(docs))
-		}
-	}
-	fmt.Println()
-
-	// Save the store
-	if err := store.Save(); err != nil {
+	result, err := updateSearchIndex(ctx, store, docs)
+	if err != nil {
 		return fmt.Errorf("failed to save index: %w", err)
 	}
+	fmt.Printf("  %d new, %d changed, %d removed\n", result.Added, result.Updated, result.Removed)
 
 	counts := store.CountByType()
 	for typ, count := range counts {
@@ -4,8 +4,14 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/zmb3/spotify/v2"
+
+	"github.com/bkataru/spotigo/internal/config"
+	"github.com/bkataru/spotigo/internal/storage"
 )
 
 func TestParseBackupType(t *testing.T) {
@@ -435,3 +441,223 @@ func TestBackupTimestampParsing(t *testing.T) {
 		})
 	}
 }
+
+func TestSaveHistorySnapshots(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := storage.NewStore(tmpDir, filepath.Join(tmpDir, "backups"))
+	timestamp := time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)
+
+	top := &topItemsSnapshot{
+		CapturedAt: timestamp,
+		Tracks: map[string][]spotify.FullTrack{
+			"short": {{SimpleTrack: spotify.SimpleTrack{
+				Name:    "Everything In Its Right Place",
+				Artists: []spotify.SimpleArtist{{Name: "Radiohead"}},
+			}}},
+		},
+		Artists: map[string][]spotify.FullArtist{},
+	}
+
+	backupData := map[string]interface{}{
+		"saved_tracks": []string{},
+		"top_items":    top,
+	}
+
+	if err := saveHistorySnapshots(store, backupData, timestamp); err != nil {
+		t.Fatalf("saveHistorySnapshots() error = %v", err)
+	}
+
+	snapshots, err := store.ListSnapshots("top_items")
+	if err != nil {
+		t.Fatalf("ListSnapshots() error = %v", err)
+	}
+	if len(snapshots) != 1 {
+		t.Fatalf("expected 1 top_items snapshot, got %d", len(snapshots))
+	}
+
+	// Data that isn't tracked over time should not produce snapshots
+	if others, _ := store.ListSnapshots("saved_tracks"); len(others) != 0 {
+		t.Errorf("expected no saved_tracks snapshots, got %d", len(others))
+	}
+
+	// The latest top items file feeds 'stats top tracks'
+	if err := store.SaveJSON("top_items.json", top); err != nil {
+		t.Fatalf("SaveJSON() error = %v", err)
+	}
+	cfg := &config.Config{Storage: config.StorageConfig{DataDir: tmpDir}}
+	names, err := loadTopTrackNames(cfg, "short")
+	if err != nil {
+		t.Fatalf("loadTopTrackNames() error = %v", err)
+	}
+	if len(names) != 1 || names[0] != "Everything In Its Right Place - Radiohead" {
+		t.Errorf("unexpected top track names: %v", names)
+	}
+}
+
+func TestTopRangeForPeriod(t *testing.T) {
+	tests := map[string]string{
+		"week":  "short",
+		"month": "short",
+		"year":  "medium",
+		"all":   "long",
+		"":      "long",
+	}
+
+	for period, want := range tests {
+		if got := topRangeForPeriod(period); got != want {
+			t.Errorf("topRangeForPeriod(%q) = %q, want %q", period, got, want)
+		}
+	}
+}
+
+func TestLoadStoredPlaylists(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := storage.NewStore(tmpDir, filepath.Join(tmpDir, "backups"))
+
+	playlists := []playlistData{
+		{ID: "p1", Name: "Unchanged", SnapshotID: "s1", Tracks: []spotify.PlaylistItem{{}, {}}},
+		{ID: "p2", Name: "Edited", SnapshotID: "s2", Tracks: []spotify.PlaylistItem{{}}},
+		{ID: "p3", Name: "Legacy", Tracks: []spotify.PlaylistItem{{}}},
+	}
+	if err := store.SaveJSON("playlists.json", playlists); err != nil {
+		t.Fatalf("SaveJSON() error = %v", err)
+	}
+
+	states := playlistStates(playlists)
+	if states["p1"].TrackCount != 2 || states["p1"].SnapshotID != "s1" {
+		t.Errorf("unexpected state for p1: %+v", states["p1"])
+	}
+
+	// p2's manifest entry is stale, so its stored tracks must not be reused
+	states["p2"] = storage.PlaylistState{SnapshotID: "old"}
+
+	stored := loadStoredPlaylists(store, states)
+	if len(stored) != 1 {
+		t.Fatalf("expected 1 reusable playlist, got %d", len(stored))
+	}
+	p1, ok := stored["p1"]
+	if !ok {
+		t.Fatal("expected p1 to be reusable")
+	}
+	if got := countPlaylistTracks(p1.Tracks); got != 2 {
+		t.Errorf("countPlaylistTracks(raw) = %d, want 2", got)
+	}
+}
+
+func TestNewSavedTracksState(t *testing.T) {
+	previous := storage.SavedTracksState{Count: 10, LatestID: "old", LatestAddedAt: "2024-01-01T00:00:00Z"}
+
+	unchanged := newSavedTracksState(nil, 10, previous)
+	if unchanged.LatestID != "old" || unchanged.Count != 10 {
+		t.Errorf("expected previous cursor to be kept, got %+v", unchanged)
+	}
+
+	newest := []spotify.SavedTrack{{
+		AddedAt:   "2024-02-01T00:00:00Z",
+		FullTrack: spotify.FullTrack{SimpleTrack: spotify.SimpleTrack{ID: "new"}},
+	}}
+	updated := newSavedTracksState(newest, 11, previous)
+	if updated.LatestID != "new" || updated.LatestAddedAt != "2024-02-01T00:00:00Z" || updated.Count != 11 {
+		t.Errorf("unexpected state: %+v", updated)
+	}
+}
+
+func TestMergeSavedTracks(t *testing.T) {
+	stored := []json.RawMessage{
+		json.RawMessage(`{"added_at":"2024-03-01T00:00:00Z","track":{"id":"t2"}}`),
+		json.RawMessage(`{"added_at":"2024-01-01T00:00:00Z","track":{"id":"t1"}}`),
+	}
+	previous := storage.SavedTracksState{Count: 2, LatestID: "t2", LatestAddedAt: "2024-03-01T00:00:00Z"}
+	if got := savedTracksCursor(previous, stored); !got.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("savedTracksCursor() = %v", got)
+	}
+	// Without a recorded cursor the newest stored track is used
+	if got := savedTracksCursor(storage.SavedTracksState{Count: 2}, stored); !got.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("savedTracksCursor() without cursor = %v", got)
+	}
+
+	// t1 was saved again after the last backup, alongside the new t3
+	newTracks := []spotify.SavedTrack{
+		{AddedAt: "2024-04-02T00:00:00Z", FullTrack: spotify.FullTrack{SimpleTrack: spotify.SimpleTrack{ID: "t3"}}},
+		{AddedAt: "2024-04-01T00:00:00Z", FullTrack: spotify.FullTrack{SimpleTrack: spotify.SimpleTrack{ID: "t1"}}},
+	}
+	merged := mergeSavedTracks(newTracks, stored)
+	if len(merged) != 3 {
+		t.Fatalf("expected 3 tracks with the re-saved one deduplicated, got %d", len(merged))
+	}
+	raw, err := json.Marshal(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []savedTrackItem
+	if err := json.Unmarshal(raw, &items); err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, item := range items {
+		order = append(order, string(item.Track.ID)+"@"+item.AddedAt[:10])
+	}
+	if want := "t3@2024-04-02 t1@2024-04-01 t2@2024-03-01"; strings.Join(order, " ") != want {
+		t.Errorf("merged order = %v, want %s", order, want)
+	}
+}
+
+func TestDescribeRetention(t *testing.T) {
+	tests := []struct {
+		policy storage.RetentionPolicy
+		want   string
+	}{
+		{storage.RetentionPolicy{}, "keep all backups"},
+		{storage.RetentionPolicy{RetainDays: 30}, "30 days"},
+		{storage.RetentionPolicy{KeepDaily: 7, KeepWeekly: 4, KeepMonthly: 12}, "7 daily, 4 weekly, 12 monthly"},
+	}
+
+	for _, tt := range tests {
+		if got := describeRetention(tt.policy); got != tt.want {
+			t.Errorf("describeRetention(%+v) = %q, want %q", tt.policy, got, tt.want)
+		}
+	}
+
+	cfg := &config.Config{Backup: config.BackupConfig{RetainDays: 14, KeepMonthly: 6}}
+	if got := retentionPolicy(cfg); got.RetainDays != 14 || got.KeepMonthly != 6 {
+		t.Errorf("retentionPolicy() = %+v", got)
+	}
+}
+
+func TestExportSections(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := storage.NewStore(tmpDir, filepath.Join(tmpDir, "backups"))
+
+	tracks := []spotify.SavedTrack{{AddedAt: "2024-01-01T00:00:00Z", FullTrack: spotify.FullTrack{
+		SimpleTrack: spotify.SimpleTrack{ID: "t1", Name: "Idioteque"},
+	}}}
+	if err := store.SaveJSON("saved_tracks.json", tracks); err != nil {
+		t.Fatalf("SaveJSON() error = %v", err)
+	}
+
+	sections := loadDataSections(store)
+	if len(sections) != 1 {
+		t.Fatalf("expected 1 section, got %d", len(sections))
+	}
+
+	outDir := filepath.Join(tmpDir, "exports")
+	paths, err := exportSections(sections, "csv", outDir)
+	if err != nil {
+		t.Fatalf("exportSections() error = %v", err)
+	}
+	if len(paths) != 1 || filepath.Base(paths[0]) != "saved_tracks.csv" {
+		t.Fatalf("unexpected export paths: %v", paths)
+	}
+
+	content, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "Idioteque") {
+		t.Errorf("export missing track row:\n%s", content)
+	}
+
+	if _, err := exportSections(sections, "xlsx", outDir); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
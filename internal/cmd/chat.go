@@ -137,7 +137,7 @@ You have access to tools that can query the user's music library. Use these tool
 - get_recently_added_tracks: Get recently added tracks
 - get_all_artists: Get all unique artists
 - get_playlist_by_name: Find a playlist
-- query_music_data: Execute custom queries with filters, sorting, aggregation
+- query_music_data: Execute custom queries with filters, sorting, aggregation (play_history.json holds individual plays, if imported)
 
 When you need specific information from the library, use the appropriate tool. After getting results, summarize them in a natural, conversational way.`
 		}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -462,3 +464,54 @@ func TestCommandFlags(t *testing.T) {
 		})
 	}
 }
+
+// TestLoadLibraryExtrasFromFile tests loading saved albums, podcasts and episodes for indexing
+func TestLoadLibraryExtrasFromFile(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	write := func(name, content string) string {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	albumsPath := write("saved_albums.json", `[
+		{"added_at": "2024-01-01T00:00:00Z", "album": {"id": "al1", "name": "Kid A", "artists": [{"name": "Radiohead"}], "release_date": "2000-10-02"}},
+		{"added_at": "2024-01-01T00:00:00Z", "album": {"id": "", "name": "No ID"}}
+	]`)
+	showsPath := write("saved_shows.json", `[
+		{"added_at": "2024-01-01T00:00:00Z", "show": {"id": "sh1", "name": "Song Exploder", "publisher": "Hrishikesh Hirway"}}
+	]`)
+	episodesPath := write("saved_episodes.json", `[
+		{"added_at": "2024-01-01T00:00:00Z", "episode": {"id": "ep1", "name": "Episode One", "show": {"name": "Song Exploder"}}}
+	]`)
+
+	albums, err := loadAlbumsFromFile(albumsPath)
+	if err != nil {
+		t.Fatalf("loadAlbumsFromFile() error = %v", err)
+	}
+	if len(albums) != 1 {
+		t.Fatalf("expected 1 album, got %d", len(albums))
+	}
+	if albums[0].Name != "Kid A" || len(albums[0].Artists) != 1 || albums[0].Artists[0] != "Radiohead" {
+		t.Errorf("unexpected album: %+v", albums[0])
+	}
+
+	shows, err := loadShowsFromFile(showsPath)
+	if err != nil {
+		t.Fatalf("loadShowsFromFile() error = %v", err)
+	}
+	if len(shows) != 1 || shows[0].Publisher != "Hrishikesh Hirway" {
+		t.Errorf("unexpected shows: %+v", shows)
+	}
+
+	episodes, err := loadEpisodesFromFile(episodesPath)
+	if err != nil {
+		t.Fatalf("loadEpisodesFromFile() error = %v", err)
+	}
+	if len(episodes) != 1 || episodes[0].Show != "Song Exploder" {
+		t.Errorf("unexpected episodes: %+v", episodes)
+	}
+}
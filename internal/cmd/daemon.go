@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bkataru/spotigo/internal/schedule"
+	"github.com/bkataru/spotigo/internal/storage"
+)
+
+// daemonStateFile is the data file recording the daemon's last and next runs
+const daemonStateFile = "daemon_state.json"
+
+// Daemon run statuses recorded in the state file
+const (
+	runStatusRunning     = "running"
+	runStatusSuccess     = "success"
+	runStatusFailed      = "failed"
+	runStatusInterrupted = "interrupted"
+)
+
+var (
+	daemonIndex       bool
+	daemonIncremental bool
+	daemonRunNow      bool
+	daemonCmd         = &cobra.Command{
+		Use:   "daemon",
+		Short: "Run backups on the configured schedule",
+		Long: `Run in the foreground and back up your library on the schedule set
+by backup.schedule in the config file.
+
+Supported schedules:
+  hourly, daily, weekly, monthly   (daily runs at midnight local time)
+  @every <duration>                e.g. "@every 6h"
+  a five-field cron expression     e.g. "30 3 * * *" for 03:30 every day
+
+The last and next run times are written to <data_dir>/daemon_state.json.
+A run that was due while the daemon was stopped is started immediately.
+
+SIGINT or SIGTERM stops the daemon. A backup in progress is abandoned
+without overwriting your existing data.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runDaemon()
+		},
+	}
+)
+
+func init() {
+	daemonCmd.Flags().BoolVar(&daemonIndex, "index", false, "rebuild the search index after each backup (requires Ollama)")
+	daemonCmd.Flags().BoolVar(&daemonIncremental, "incremental", false, "run incremental backups")
+	daemonCmd.Flags().BoolVar(&daemonRunNow, "run-now", false, "run a backup immediately on start")
+}
+
+// daemonState is persisted between runs so schedules survive restarts
+type daemonState struct {
+	Schedule     string    `json:"schedule"`
+	PID          int       `json:"pid"`
+	LastRunStart time.Time `json:"last_run_start,omitempty"`
+	LastRunEnd   time.Time `json:"last_run_end,omitempty"`
+	LastStatus   string    `json:"last_status,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+	NextRun      time.Time `json:"next_run,omitempty"`
+}
+
+// loadDaemonState reads the daemon state file, returning an empty state if there is none
+func loadDaemonState(store *storage.Store) *daemonState {
+	state := &daemonState{}
+	if store.Exists(daemonStateFile) {
+		if err := store.LoadJSON(daemonStateFile, state); err != nil {
+			fmt.Printf("Warning: ignoring unreadable daemon state: %v\n", err)
+			return &daemonState{}
+		}
+	}
+	return state
+}
+
+// firstRun returns when the daemon should run first, catching up on a run missed while stopped
+func firstRun(sched schedule.Schedule, state *daemonState, now time.Time) time.Time {
+	if !state.NextRun.IsZero() && !state.NextRun.After(now) {
+		return now
+	}
+	return sched.Next(now)
+}
+
+func runDaemon() {
+	cfg := GetConfig()
+	if cfg == nil {
+		fmt.Println("Error: Configuration not loaded")
+		return
+	}
+
+	sched, err := schedule.Parse(cfg.Backup.Schedule)
+	if err != nil {
+		fmt.Printf("Error: invalid backup.schedule: %v\n", err)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	store, err := newStore(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	state := loadDaemonState(store)
+	state.Schedule = cfg.Backup.Schedule
+	state.PID = os.Getpid()
+
+	next := firstRun(sched, state, time.Now())
+	if daemonRunNow {
+		next = time.Now()
+	}
+
+	saveState := func() {
+		if err := store.SaveJSON(daemonStateFile, state); err != nil {
+			fmt.Printf("Warning: failed to save daemon state: %v\n", err)
+		}
+	}
+
+	fmt.Printf("Spotigo daemon started (schedule: %s)\n", cfg.Backup.Schedule)
+
+	opts := backupOptions{
+		Type:        "all",
+		Incremental: daemonIncremental,
+		Index:       daemonIndex,
+	}
+
+	for {
+		if next.IsZero() {
+			fmt.Println("Schedule has no upcoming runs; exiting.")
+			return
+		}
+
+		state.NextRun = next
+		saveState()
+		fmt.Printf("Next backup: %s\n", next.Format("2006-01-02 15:04:05"))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			fmt.Println("\nShutting down.")
+			return
+		case <-timer.C:
+		}
+
+		fmt.Printf("\n[%s] Starting scheduled backup...\n", time.Now().Format("2006-01-02 15:04:05"))
+		state.LastRunStart = time.Now()
+		state.LastRunEnd = time.Time{}
+		state.LastStatus = runStatusRunning
+		state.LastError = ""
+		saveState()
+
+		err := executeBackup(ctx, cfg, opts)
+		state.LastRunEnd = time.Now()
+		switch {
+		case err != nil && ctx.Err() != nil:
+			state.LastStatus = runStatusInterrupted
+		case err != nil:
+			state.LastStatus = runStatusFailed
+			state.LastError = err.Error()
+			fmt.Printf("Backup failed: %v\n", err)
+			if errors.Is(err, errNotAuthenticated) {
+				fmt.Println("Run 'spotigo auth' to authenticate; the daemon will retry at the next scheduled time.")
+			}
+		default:
+			state.LastStatus = runStatusSuccess
+		}
+
+		if ctx.Err() != nil {
+			state.NextRun = sched.Next(time.Now())
+			if state.LastStatus == runStatusInterrupted {
+				// Leave NextRun in the past so the interrupted run is retried on restart
+				state.NextRun = state.LastRunStart
+			}
+			saveState()
+			fmt.Println("\nShutting down.")
+			return
+		}
+
+		next = sched.Next(time.Now())
+	}
+}
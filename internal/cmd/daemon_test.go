@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bkataru/spotigo/internal/schedule"
+	"github.com/bkataru/spotigo/internal/storage"
+)
+
+func TestFirstRun(t *testing.T) {
+	sched, err := schedule.Parse("daily")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	now := time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC)
+	tomorrow := time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		state daemonState
+		want  time.Time
+	}{
+		{"no state", daemonState{}, tomorrow},
+		{"upcoming run", daemonState{NextRun: tomorrow}, tomorrow},
+		{"missed run", daemonState{NextRun: now.Add(-time.Hour)}, now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := firstRun(sched, &tt.state, now); !got.Equal(tt.want) {
+				t.Errorf("firstRun() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaemonState_RoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := storage.NewStore(tmpDir, filepath.Join(tmpDir, "backups"))
+
+	if state := loadDaemonState(store); !state.NextRun.IsZero() {
+		t.Errorf("expected empty state, got %+v", state)
+	}
+
+	next := time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC)
+	saved := &daemonState{Schedule: "daily", LastStatus: runStatusSuccess, NextRun: next}
+	if err := store.SaveJSON(daemonStateFile, saved); err != nil {
+		t.Fatalf("SaveJSON() error = %v", err)
+	}
+
+	loaded := loadDaemonState(store)
+	if !loaded.NextRun.Equal(next) || loaded.LastStatus != runStatusSuccess {
+		t.Errorf("unexpected state: %+v", loaded)
+	}
+}
@@ -0,0 +1,494 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/zmb3/spotify/v2"
+
+	"github.com/bkataru/spotigo/internal/storage"
+)
+
+var (
+	diffFormat    string
+	backupDiffCmd = &cobra.Command{
+		Use:   "diff <from> <to>",
+		Short: "Show what changed between two backups",
+		Long: `Show what changed between two backups.
+
+Reports saved tracks added and removed, artists followed and unfollowed, and
+for each playlist the items added, removed and moved. Playlists that appear
+or disappear between the backups are reported as added or removed.
+
+Sections missing from either backup, e.g. when comparing a playlists-only
+backup with a full one, are listed as not compared.
+
+Backups are given by ID, a unique part of an ID, or "latest".`,
+		Example: `  spotigo backup diff full-20240101 latest
+  spotigo backup diff full-20240101 full-20240201 --format json`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			runBackupDiff(args[0], args[1])
+		},
+	}
+)
+
+// Playlist diff statuses
+const (
+	diffAdded   = "added"
+	diffRemoved = "removed"
+	diffChanged = "changed"
+)
+
+// backupDiff is the difference between two backups
+type backupDiff struct {
+	From            string         `json:"from"`
+	To              string         `json:"to"`
+	SavedTracks     itemChanges    `json:"saved_tracks"`
+	FollowedArtists itemChanges    `json:"followed_artists"`
+	Playlists       []playlistDiff `json:"playlists"`
+	// NotCompared lists sections missing from either backup, e.g. when one
+	// is a partial backup, rather than reporting their items as changed
+	NotCompared []string `json:"not_compared"`
+}
+
+// diffSections are the backup sections compared by diff
+var diffSections = []string{"saved_tracks", "followed_artists", "playlists"}
+
+// diffSource is the library of one backup and the sections it holds
+type diffSource struct {
+	restoreSource
+	sections map[string]bool
+}
+
+// diffItem is a track, episode or artist in a diff
+type diffItem struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Artists []string `json:"artists,omitempty"`
+	// Position is the 1-based playlist position: in the newer backup for
+	// additions and in the older backup for removals
+	Position int `json:"position,omitempty"`
+}
+
+// movedItem is a playlist item whose position changed relative to the other items
+type movedItem struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Artists []string `json:"artists,omitempty"`
+	From    int      `json:"from"`
+	To      int      `json:"to"`
+}
+
+// itemChanges lists items added and removed between two backups
+type itemChanges struct {
+	Added   []diffItem `json:"added"`
+	Removed []diffItem `json:"removed"`
+}
+
+// playlistDiff describes how one playlist changed
+type playlistDiff struct {
+	ID           string      `json:"id"`
+	Name         string      `json:"name"`
+	PreviousName string      `json:"previous_name,omitempty"`
+	Status       string      `json:"status"`
+	Items        int         `json:"items"`
+	Added        []diffItem  `json:"added,omitempty"`
+	Removed      []diffItem  `json:"removed,omitempty"`
+	Moved        []movedItem `json:"moved,omitempty"`
+}
+
+// empty reports whether the diff has no changes
+func (d *backupDiff) empty() bool {
+	return len(d.SavedTracks.Added)+len(d.SavedTracks.Removed)+
+		len(d.FollowedArtists.Added)+len(d.FollowedArtists.Removed)+len(d.Playlists) == 0
+}
+
+func runBackupDiff(fromID, toID string) {
+	cfg := GetConfig()
+	if cfg == nil {
+		fmt.Println("Error: Configuration not loaded")
+		return
+	}
+
+	if diffFormat != "table" && diffFormat != "json" {
+		fmt.Printf("Error: unknown format %q (use table or json)\n", diffFormat)
+		return
+	}
+
+	store, err := newStore(cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	backups, err := store.ListBackups()
+	if err != nil {
+		fmt.Printf("Error listing backups: %v\n", err)
+		return
+	}
+	if len(backups) == 0 {
+		fmt.Println("No backups available. Run 'spotigo backup' to create one first.")
+		return
+	}
+
+	var sources [2]diffSource
+	var backupIDs [2]string
+	for i, id := range []string{fromID, toID} {
+		backup := selectBackup(backups, id)
+		if backup == nil {
+			fmt.Printf("Backup not found: %s\n", id)
+			return
+		}
+		if sources[i], err = loadDiffSource(store, backup.ID); err != nil {
+			fmt.Printf("Error loading backup %s: %v\n", backup.ID, err)
+			return
+		}
+		backupIDs[i] = backup.ID
+	}
+
+	diff := diffBackups(sources[0], sources[1])
+	diff.From, diff.To = backupIDs[0], backupIDs[1]
+
+	if diffFormat == "json" {
+		data, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			fmt.Printf("Error encoding diff: %v\n", err)
+			return
+		}
+		fmt.Println(string(data))
+		return
+	}
+	printBackupDiff(diff)
+}
+
+// loadDiffSource loads a backup, recording which sections it holds
+func loadDiffSource(store *storage.Store, backupID string) (diffSource, error) {
+	source := diffSource{sections: make(map[string]bool, len(diffSections))}
+
+	var sections map[string]json.RawMessage
+	if err := store.LoadBackupJSON(backupID, &sections); err != nil {
+		return source, err
+	}
+	targets := map[string]interface{}{
+		"saved_tracks":     &source.SavedTracks,
+		"followed_artists": &source.FollowedArtists,
+		"playlists":        &source.Playlists,
+	}
+	for _, name := range diffSections {
+		raw, ok := sections[name]
+		if !ok || string(raw) == "null" {
+			continue
+		}
+		if err := json.Unmarshal(raw, targets[name]); err != nil {
+			return source, fmt.Errorf("failed to decode %s: %w", name, err)
+		}
+		source.sections[name] = true
+	}
+	return source, nil
+}
+
+// diffBackups compares the library sections of two backups. Sections missing
+// from either backup are listed as not compared instead of being diffed.
+func diffBackups(from, to diffSource) *backupDiff {
+	trackItems := func(src restoreSource) []diffItem {
+		items := make([]diffItem, 0, len(src.SavedTracks))
+		for _, saved := range src.SavedTracks {
+			items = append(items, diffItem{ID: string(saved.Track.ID), Name: saved.Track.Name, Artists: artistNames(saved.Track.Artists)})
+		}
+		return items
+	}
+	artistItems := func(src restoreSource) []diffItem {
+		items := make([]diffItem, 0, len(src.FollowedArtists))
+		for _, artist := range src.FollowedArtists {
+			items = append(items, diffItem{ID: string(artist.ID), Name: artist.Name})
+		}
+		return items
+	}
+
+	diff := &backupDiff{
+		SavedTracks:     itemChanges{Added: []diffItem{}, Removed: []diffItem{}},
+		FollowedArtists: itemChanges{Added: []diffItem{}, Removed: []diffItem{}},
+		Playlists:       []playlistDiff{},
+		NotCompared:     []string{},
+	}
+	for _, name := range diffSections {
+		if !from.sections[name] || !to.sections[name] {
+			diff.NotCompared = append(diff.NotCompared, name)
+		}
+	}
+	compared := func(name string) bool {
+		return from.sections[name] && to.sections[name]
+	}
+
+	if compared("saved_tracks") {
+		diff.SavedTracks = diffSets(trackItems(from.restoreSource), trackItems(to.restoreSource))
+	}
+	if compared("followed_artists") {
+		diff.FollowedArtists = diffSets(artistItems(from.restoreSource), artistItems(to.restoreSource))
+	}
+	if compared("playlists") {
+		diff.Playlists = diffPlaylists(from.Playlists, to.Playlists)
+	}
+	return diff
+}
+
+// diffPlaylists compares the playlists of two backups
+func diffPlaylists(from, to []restorePlaylist) []playlistDiff {
+	diffs := []playlistDiff{}
+	old := make(map[spotify.ID]restorePlaylist, len(from))
+	for _, p := range from {
+		old[p.ID] = p
+	}
+	seen := make(map[spotify.ID]bool, len(to))
+
+	for _, p := range to {
+		seen[p.ID] = true
+		items := playlistDiffItems(p)
+		prev, ok := old[p.ID]
+		if !ok {
+			diffs = append(diffs, playlistDiff{ID: string(p.ID), Name: p.Name, Status: diffAdded, Items: len(items)})
+			continue
+		}
+
+		changes := diffSequences(playlistDiffItems(prev), items)
+		pd := playlistDiff{
+			ID:      string(p.ID),
+			Name:    p.Name,
+			Status:  diffChanged,
+			Items:   len(items),
+			Added:   changes.Added,
+			Removed: changes.Removed,
+			Moved:   changes.Moved,
+		}
+		if prev.Name != p.Name {
+			pd.PreviousName = prev.Name
+		}
+		if pd.PreviousName != "" || len(pd.Added)+len(pd.Removed)+len(pd.Moved) > 0 {
+			diffs = append(diffs, pd)
+		}
+	}
+
+	for _, p := range from {
+		if !seen[p.ID] {
+			diffs = append(diffs, playlistDiff{ID: string(p.ID), Name: p.Name, Status: diffRemoved, Items: len(playlistDiffItems(p))})
+		}
+	}
+
+	return diffs
+}
+
+// diffSets returns the items of b missing from a and of a missing from b, keyed by ID
+func diffSets(a, b []diffItem) itemChanges {
+	inA := make(map[string]bool, len(a))
+	for _, item := range a {
+		inA[item.ID] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, item := range b {
+		inB[item.ID] = true
+	}
+
+	changes := itemChanges{Added: []diffItem{}, Removed: []diffItem{}}
+	for _, item := range b {
+		if !inA[item.ID] {
+			changes.Added = append(changes.Added, item)
+		}
+	}
+	for _, item := range a {
+		if !inB[item.ID] {
+			changes.Removed = append(changes.Removed, item)
+		}
+	}
+	return changes
+}
+
+// playlistDiffItems returns a playlist's items with their positions. Unavailable items are left out.
+func playlistDiffItems(p restorePlaylist) []diffItem {
+	items := make([]diffItem, 0, len(p.Tracks))
+	for i, item := range p.Tracks {
+		ref := item.ref()
+		if ref == nil || ref.URI == "" {
+			continue
+		}
+		items = append(items, diffItem{ID: string(ref.URI), Name: ref.Name, Artists: artistNames(ref.Artists), Position: i + 1})
+	}
+	return items
+}
+
+// sequenceChanges lists the additions, removals and moves between two playlist versions
+type sequenceChanges struct {
+	Added   []diffItem
+	Removed []diffItem
+	Moved   []movedItem
+}
+
+// diffSequences compares two versions of a playlist. Repeated items are matched
+// by occurrence. Items kept in both versions count as moved unless they are part
+// of the longest run of items whose relative order is unchanged.
+func diffSequences(a, b []diffItem) sequenceChanges {
+	occurrenceKeys := func(items []diffItem) []string {
+		counts := make(map[string]int, len(items))
+		keys := make([]string, len(items))
+		for i, item := range items {
+			keys[i] = fmt.Sprintf("%s#%d", item.ID, counts[item.ID])
+			counts[item.ID]++
+		}
+		return keys
+	}
+
+	keysA, keysB := occurrenceKeys(a), occurrenceKeys(b)
+	indexA := make(map[string]int, len(a))
+	for i, key := range keysA {
+		indexA[key] = i
+	}
+
+	var changes sequenceChanges
+	kept := make(map[string]bool, len(b))
+	var common []int // indexes into b of items also in a
+	for i, key := range keysB {
+		if _, ok := indexA[key]; ok {
+			kept[key] = true
+			common = append(common, i)
+		} else {
+			changes.Added = append(changes.Added, b[i])
+		}
+	}
+	for i, key := range keysA {
+		if !kept[key] {
+			changes.Removed = append(changes.Removed, a[i])
+		}
+	}
+
+	positions := make([]int, len(common))
+	for i, j := range common {
+		positions[i] = indexA[keysB[j]]
+	}
+	inOrder := longestIncreasing(positions)
+	for i, j := range common {
+		if inOrder[i] {
+			continue
+		}
+		from := a[positions[i]]
+		changes.Moved = append(changes.Moved, movedItem{
+			ID: b[j].ID, Name: b[j].Name, Artists: b[j].Artists, From: from.Position, To: b[j].Position,
+		})
+	}
+
+	return changes
+}
+
+// longestIncreasing marks the elements of one longest strictly increasing subsequence of values
+func longestIncreasing(values []int) []bool {
+	// tails[k] is the index of the smallest tail of an increasing run of length k+1
+	var tails []int
+	prev := make([]int, len(values))
+	for i, v := range values {
+		k := sort.Search(len(tails), func(k int) bool { return values[tails[k]] >= v })
+		if k > 0 {
+			prev[i] = tails[k-1]
+		} else {
+			prev[i] = -1
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+
+	marked := make([]bool, len(values))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+			marked[i] = true
+		}
+	}
+	return marked
+}
+
+// artistNames returns the names of artists
+func artistNames(artists []spotify.SimpleArtist) []string {
+	if len(artists) == 0 {
+		return nil
+	}
+	names := make([]string, len(artists))
+	for i, a := range artists {
+		names[i] = a.Name
+	}
+	return names
+}
+
+// displayName formats an item as "Name - Artist, Artist"
+func (item diffItem) displayName() string {
+	if len(item.Artists) == 0 {
+		return item.Name
+	}
+	return item.Name + " - " + strings.Join(item.Artists, ", ")
+}
+
+// printBackupDiff prints a diff as a table
+func printBackupDiff(diff *backupDiff) {
+	fmt.Printf("Comparing %s -> %s\n\n", diff.From, diff.To)
+	if len(diff.NotCompared) > 0 {
+		fmt.Printf("Not compared, missing from one of the backups: %s\n\n", strings.Join(diff.NotCompared, ", "))
+	}
+
+	if diff.empty() {
+		fmt.Println("No changes.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SECTION\tCHANGE\tITEM\tDETAIL")
+
+	for _, item := range diff.SavedTracks.Added {
+		fmt.Fprintf(w, "saved_tracks\tadded\t%s\t\n", item.displayName())
+	}
+	for _, item := range diff.SavedTracks.Removed {
+		fmt.Fprintf(w, "saved_tracks\tremoved\t%s\t\n", item.displayName())
+	}
+	for _, item := range diff.FollowedArtists.Added {
+		fmt.Fprintf(w, "followed_artists\tfollowed\t%s\t\n", item.Name)
+	}
+	for _, item := range diff.FollowedArtists.Removed {
+		fmt.Fprintf(w, "followed_artists\tunfollowed\t%s\t\n", item.Name)
+	}
+
+	for _, p := range diff.Playlists {
+		switch p.Status {
+		case diffAdded, diffRemoved:
+			fmt.Fprintf(w, "playlists\t%s\t%s\t%d items\n", p.Status, p.Name, p.Items)
+			continue
+		}
+
+		section := "playlist:" + p.Name
+		if p.PreviousName != "" {
+			fmt.Fprintf(w, "playlists\trenamed\t%s\twas %q\n", p.Name, p.PreviousName)
+		}
+		for _, item := range p.Added {
+			fmt.Fprintf(w, "%s\tadded\t%s\tat %d\n", section, item.displayName(), item.Position)
+		}
+		for _, item := range p.Removed {
+			fmt.Fprintf(w, "%s\tremoved\t%s\twas at %d\n", section, item.displayName(), item.Position)
+		}
+		for _, item := range p.Moved {
+			moved := diffItem{Name: item.Name, Artists: item.Artists}
+			fmt.Fprintf(w, "%s\tmoved\t%s\t%d -> %d\n", section, moved.displayName(), item.From, item.To)
+		}
+	}
+	_ = w.Flush()
+
+	counts := make(map[string]int)
+	for _, p := range diff.Playlists {
+		counts[p.Status]++
+	}
+	fmt.Println()
+	fmt.Printf("Saved tracks: +%d -%d  Followed artists: +%d -%d  Playlists: %d added, %d removed, %d changed\n",
+		len(diff.SavedTracks.Added), len(diff.SavedTracks.Removed),
+		len(diff.FollowedArtists.Added), len(diff.FollowedArtists.Removed),
+		counts[diffAdded], counts[diffRemoved], counts[diffChanged])
+}
@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zmb3/spotify/v2"
+
+	"github.com/bkataru/spotigo/internal/storage"
+)
+
+func seq(ids ...string) []diffItem {
+	items := make([]diffItem, len(ids))
+	for i, id := range ids {
+		items[i] = diffItem{ID: id, Name: id, Position: i + 1}
+	}
+	return items
+}
+
+func itemIDs[T any](items []T, id func(T) string) []string {
+	out := []string{}
+	for _, item := range items {
+		out = append(out, id(item))
+	}
+	return out
+}
+
+func TestDiffSequences(t *testing.T) {
+	tests := []struct {
+		name                  string
+		a, b                  []diffItem
+		added, removed, moved []string
+	}{
+		{"identical", seq("a", "b", "c"), seq("a", "b", "c"), nil, nil, nil},
+		{"append", seq("a", "b"), seq("a", "b", "c"), []string{"c"}, nil, nil},
+		{"remove", seq("a", "b", "c"), seq("a", "c"), nil, []string{"b"}, nil},
+		{"move to front", seq("a", "b", "c", "d"), seq("d", "a", "b", "c"), nil, nil, []string{"d"}},
+		{"swap", seq("a", "b"), seq("b", "a"), nil, nil, []string{"b"}},
+		{"duplicates", seq("a", "b", "a"), seq("a", "b"), nil, []string{"a"}, nil},
+		{"mixed", seq("a", "b", "c", "d"), seq("c", "a", "x", "d"), []string{"x"}, []string{"b"}, []string{"c"}},
+	}
+
+	id := func(item diffItem) string { return item.ID }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffSequences(tt.a, tt.b)
+			if g := itemIDs(got.Added, id); !reflect.DeepEqual(g, append([]string{}, tt.added...)) {
+				t.Errorf("added = %v, want %v", g, tt.added)
+			}
+			if g := itemIDs(got.Removed, id); !reflect.DeepEqual(g, append([]string{}, tt.removed...)) {
+				t.Errorf("removed = %v, want %v", g, tt.removed)
+			}
+			if g := itemIDs(got.Moved, func(m movedItem) string { return m.ID }); !reflect.DeepEqual(g, append([]string{}, tt.moved...)) {
+				t.Errorf("moved = %v, want %v", g, tt.moved)
+			}
+		})
+	}
+}
+
+func TestDiffSequences_MovePositions(t *testing.T) {
+	got := diffSequences(seq("a", "b", "c", "d"), seq("d", "a", "b", "c"))
+	if len(got.Moved) != 1 || got.Moved[0].From != 4 || got.Moved[0].To != 1 {
+		t.Errorf("unexpected moves %+v", got.Moved)
+	}
+}
+
+func TestLongestIncreasing(t *testing.T) {
+	tests := []struct {
+		values []int
+		want   []bool
+	}{
+		{nil, []bool{}},
+		{[]int{0, 1, 2}, []bool{true, true, true}},
+		{[]int{3, 0, 1, 2}, []bool{false, true, true, true}},
+		{[]int{1, 0}, []bool{false, true}},
+	}
+
+	for _, tt := range tests {
+		if got := longestIncreasing(tt.values); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("longestIncreasing(%v) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestDiffBackups(t *testing.T) {
+	dir := t.TempDir()
+	store := storage.NewStore(filepath.Join(dir, "data"), filepath.Join(dir, "backups"))
+
+	load := func(data map[string]interface{}) diffSource {
+		t.Helper()
+		backup, err := store.CreateBackup("full", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		source, err := loadDiffSource(store, backup.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return source
+	}
+
+	from := load(map[string]interface{}{
+		"saved_tracks": []spotify.SavedTrack{{FullTrack: restoreTestTrack("t1")}, {FullTrack: restoreTestTrack("t2")}},
+		"followed_artists": []spotify.FullArtist{
+			{SimpleArtist: spotify.SimpleArtist{ID: "a1", Name: "Artist 1"}},
+		},
+		"playlists": []playlistData{
+			{ID: "p1", Name: "Chill", Tracks: restoreTestItems("t1", "t2", "t3")},
+			{ID: "p2", Name: "Gone", Tracks: restoreTestItems("t1")},
+			{ID: "p3", Name: "Same", Tracks: restoreTestItems("t1")},
+		},
+	})
+	to := load(map[string]interface{}{
+		"saved_tracks": []spotify.SavedTrack{{FullTrack: restoreTestTrack("t3")}, {FullTrack: restoreTestTrack("t1")}},
+		"followed_artists": []spotify.FullArtist{
+			{SimpleArtist: spotify.SimpleArtist{ID: "a2", Name: "Artist 2"}},
+		},
+		"playlists": []playlistData{
+			{ID: "p1", Name: "Chill Out", Tracks: restoreTestItems("t3", "t1", "t4")},
+			{ID: "p3", Name: "Same", Tracks: restoreTestItems("t1")},
+			{ID: "p4", Name: "New", Tracks: restoreTestItems("t1", "t2")},
+		},
+	})
+
+	diff := diffBackups(from, to)
+
+	id := func(item diffItem) string { return item.ID }
+	if got := itemIDs(diff.SavedTracks.Added, id); !reflect.DeepEqual(got, []string{"t3"}) {
+		t.Errorf("saved tracks added = %v", got)
+	}
+	if got := itemIDs(diff.SavedTracks.Removed, id); !reflect.DeepEqual(got, []string{"t2"}) {
+		t.Errorf("saved tracks removed = %v", got)
+	}
+	if diff.SavedTracks.Added[0].Name != "Track t3" {
+		t.Errorf("saved track name = %q", diff.SavedTracks.Added[0].Name)
+	}
+	if got := itemIDs(diff.FollowedArtists.Added, id); !reflect.DeepEqual(got, []string{"a2"}) {
+		t.Errorf("artists followed = %v", got)
+	}
+	if got := itemIDs(diff.FollowedArtists.Removed, id); !reflect.DeepEqual(got, []string{"a1"}) {
+		t.Errorf("artists unfollowed = %v", got)
+	}
+
+	if len(diff.Playlists) != 3 {
+		t.Fatalf("expected 3 playlist changes, got %+v", diff.Playlists)
+	}
+	chill := diff.Playlists[0]
+	if chill.ID != "p1" || chill.Status != diffChanged || chill.PreviousName != "Chill" {
+		t.Errorf("unexpected playlist diff %+v", chill)
+	}
+	if len(chill.Added) != 1 || chill.Added[0].ID != "spotify:track:t4" || chill.Added[0].Position != 3 {
+		t.Errorf("unexpected additions %+v", chill.Added)
+	}
+	if len(chill.Removed) != 1 || chill.Removed[0].ID != "spotify:track:t2" || chill.Removed[0].Position != 2 {
+		t.Errorf("unexpected removals %+v", chill.Removed)
+	}
+	if len(chill.Moved) != 1 || chill.Moved[0].ID != "spotify:track:t3" || chill.Moved[0].From != 3 || chill.Moved[0].To != 1 {
+		t.Errorf("unexpected moves %+v", chill.Moved)
+	}
+
+	if p := diff.Playlists[1]; p.ID != "p4" || p.Status != diffAdded || p.Items != 2 {
+		t.Errorf("unexpected added playlist %+v", p)
+	}
+	if p := diff.Playlists[2]; p.ID != "p2" || p.Status != diffRemoved || p.Items != 1 {
+		t.Errorf("unexpected removed playlist %+v", p)
+	}
+
+	if len(diff.NotCompared) != 0 {
+		t.Errorf("full backups should be compared in full, got %v", diff.NotCompared)
+	}
+	if !diffBackups(to, to).empty() {
+		t.Error("diff of a backup with itself should be empty")
+	}
+
+	// JSON output always has arrays, even without changes
+	raw, err := json.Marshal(diffBackups(to, to))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["playlists"] == nil || decoded["saved_tracks"].(map[string]interface{})["added"] == nil {
+		t.Errorf("expected empty arrays in JSON, got %s", raw)
+	}
+}
+
+func TestDiffBackups_Partial(t *testing.T) {
+	dir := t.TempDir()
+	store := storage.NewStore(filepath.Join(dir, "data"), filepath.Join(dir, "backups"))
+
+	full, err := store.CreateBackup("full", map[string]interface{}{
+		"saved_tracks":     []spotify.SavedTrack{{FullTrack: restoreTestTrack("t1")}},
+		"followed_artists": []spotify.FullArtist{{SimpleArtist: spotify.SimpleArtist{ID: "a1", Name: "Artist 1"}}},
+		"playlists":        []playlistData{{ID: "p1", Name: "Chill", Tracks: restoreTestItems("t1")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial, err := store.CreateBackup("playlists", map[string]interface{}{
+		"playlists": []playlistData{{ID: "p1", Name: "Chill", Tracks: restoreTestItems("t1", "t2")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from, err := loadDiffSource(store, full.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := loadDiffSource(store, partial.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, diff := range []*backupDiff{diffBackups(from, to), diffBackups(to, from)} {
+		// Tracks and artists missing from the partial backup were not removed
+		if len(diff.SavedTracks.Added)+len(diff.SavedTracks.Removed)+len(diff.FollowedArtists.Added)+len(diff.FollowedArtists.Removed) != 0 {
+			t.Errorf("sections missing from one backup should not be diffed: %+v", diff)
+		}
+		if !reflect.DeepEqual(diff.NotCompared, []string{"saved_tracks", "followed_artists"}) {
+			t.Errorf("NotCompared = %v", diff.NotCompared)
+		}
+		if len(diff.Playlists) != 1 || diff.Playlists[0].Status != diffChanged {
+			t.Errorf("playlists should still be compared: %+v", diff.Playlists)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/term"
+
+	"github.com/bkataru/spotigo/internal/config"
+	"github.com/bkataru/spotigo/internal/crypto"
+	"github.com/bkataru/spotigo/internal/history"
+	"github.com/bkataru/spotigo/internal/jsonutil"
+	"github.com/bkataru/spotigo/internal/spotify"
+	"github.com/bkataru/spotigo/internal/storage"
+)
+
+// passphraseEnv supplies the passphrase without prompting, e.g. for the daemon
+const passphraseEnv = "SPOTIGO_PASSPHRASE"
+
+// newPassphraseEnv supplies the new passphrase when rotating a passphrase key
+const newPassphraseEnv = "SPOTIGO_NEW_PASSPHRASE"
+
+// tokenKeyEnv supplies the token key for spotify.token_key: env
+const tokenKeyEnv = "SPOTIGO_TOKEN_KEY"
+
+// keyringService names Spotigo's entries in the OS keyring
+const keyringService = "spotigo"
+
+// minPassphraseLength applies when a new passphrase is set
+const minPassphraseLength = 8
+
+// The storage key is unlocked once per process, so the passphrase is asked for at most once
+var (
+	encryptionMu     sync.Mutex
+	encryptionOpened bool
+	encryptionFor    string
+	encryption       *storage.Encryption
+)
+
+func init() {
+	jsonutil.SetFileDecoder(decodeDataFile)
+}
+
+// newStore opens the configured store, unlocking the storage key if encryption is set up
+func newStore(cfg *config.Config) (*storage.Store, error) {
+	enc, err := openEncryption(cfg)
+	if err != nil {
+		return nil, err
+	}
+	store := storage.NewStore(cfg.Storage.DataDir, cfg.Storage.BackupDir)
+	store.SetEncryption(enc)
+	return store, nil
+}
+
+// openEncryption unlocks the storage key for the configured backup directory
+func openEncryption(cfg *config.Config) (*storage.Encryption, error) {
+	encryptionMu.Lock()
+	defer encryptionMu.Unlock()
+
+	key := cfg.Storage.BackupDir + "\x00" + cfg.Storage.Encryption
+	if encryptionOpened && encryptionFor == key {
+		return encryption, nil
+	}
+
+	enc, err := storage.OpenEncryption(cfg.Storage.BackupDir, cfg.Storage.Encryption, readPassphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock storage: %w", err)
+	}
+	encryption, encryptionFor, encryptionOpened = enc, key, true
+	return enc, nil
+}
+
+// decodeDataFile decrypts data files read outside the store (stats, search, chat).
+// The key is only unlocked once an encrypted file is actually read.
+func decodeDataFile(data []byte) ([]byte, error) {
+	if !storage.IsEncrypted(data) {
+		return data, nil
+	}
+	cfg := GetConfig()
+	if cfg == nil {
+		return nil, storage.ErrEncrypted
+	}
+	enc, err := openEncryption(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return enc.Decode(data)
+}
+
+// tokenKeyProvider returns the key provider for a spotify.token_key setting.
+// Each profile has its own keyring entry.
+func tokenKeyProvider(source, keyFile, profile string) (crypto.KeyProvider, error) {
+	switch source {
+	case "", "machine":
+		return crypto.MachineKey{}, nil
+	case "passphrase":
+		return crypto.NewPassphraseKey(readPassphrase), nil
+	case "keyfile":
+		if keyFile == "" {
+			return nil, fmt.Errorf("token key source keyfile needs spotify.token_key_file")
+		}
+		return crypto.KeyFile{Path: keyFile}, nil
+	case "env":
+		return crypto.EnvKey{Var: tokenKeyEnv}, nil
+	case "keyring":
+		user := "token-key"
+		if profile != "" {
+			user += "/" + profile
+		}
+		return crypto.KeyringKey{Service: keyringService, User: user}, nil
+	default:
+		return nil, fmt.Errorf("unknown token key source %q (use machine, passphrase, keyfile, env or keyring)", source)
+	}
+}
+
+// readPassphrase returns the passphrase from SPOTIGO_PASSPHRASE or prompts
+// for it on the terminal. A new passphrase is asked for twice.
+func readPassphrase(confirm bool) (string, error) {
+	passphrase := os.Getenv(passphraseEnv)
+	if passphrase == "" {
+		fd := int(os.Stdin.Fd()) // #nosec G115 - file descriptors fit in an int
+		if !term.IsTerminal(fd) {
+			return "", fmt.Errorf("a passphrase is required; set %s or run interactively", passphraseEnv)
+		}
+
+		var err error
+		prompt := "Spotigo passphrase: "
+		if confirm {
+			prompt = "New Spotigo passphrase: "
+		}
+		if passphrase, err = promptPassphrase(fd, prompt); err != nil {
+			return "", err
+		}
+		if confirm {
+			again, err := promptPassphrase(fd, "Confirm passphrase: ")
+			if err != nil {
+				return "", err
+			}
+			if again != passphrase {
+				return "", fmt.Errorf("passphrases do not match")
+			}
+		}
+	}
+
+	if confirm && len(passphrase) < minPassphraseLength {
+		return "", fmt.Errorf("passphrase must be at least %d characters", minPassphraseLength)
+	}
+	return passphrase, nil
+}
+
+// readNewPassphrase returns the passphrase to rotate to, from
+// SPOTIGO_NEW_PASSPHRASE or the terminal
+func readNewPassphrase(bool) (string, error) {
+	if passphrase := os.Getenv(newPassphraseEnv); passphrase != "" {
+		if len(passphrase) < minPassphraseLength {
+			return "", fmt.Errorf("passphrase must be at least %d characters", minPassphraseLength)
+		}
+		return passphrase, nil
+	}
+	if os.Getenv(passphraseEnv) != "" {
+		return "", fmt.Errorf("set %s to the new passphrase", newPassphraseEnv)
+	}
+	return readPassphrase(true)
+}
+
+// rotateProtectedFiles re-encrypts the token and the other files in the data
+// directory from one key to another and returns how many were rewritten.
+// A plaintext token from an older version is encrypted too. Other profiles
+// keep their own keys, so their directories are left alone.
+func rotateProtectedFiles(cfg *config.Config, from, to crypto.KeyProvider) (int, error) {
+	files, err := crypto.FindEncryptedFiles(cfg.Storage.DataDir, profileSkipDirs(cfg)...)
+	if err != nil {
+		return 0, err
+	}
+
+	plaintextToken := false
+	token := filepath.Clean(cfg.Spotify.TokenFile)
+	if _, err := os.Stat(token); err == nil {
+		if !crypto.IsEncryptedFile(token) {
+			plaintextToken = true
+		} else if !containsFile(files, token) {
+			// Version 1 tokens have no header to be found by
+			files = append(files, token)
+		}
+	}
+
+	if err := crypto.RotateFiles(files, from, to); err != nil {
+		return 0, err
+	}
+	if plaintextToken {
+		if err := spotify.RekeyToken(token, from, to); err != nil {
+			return len(files), err
+		}
+		return len(files) + 1, nil
+	}
+	return len(files), nil
+}
+
+// rotateStorageKey re-encrypts the backups, data files and play log written
+// with storage.encryption with a new key for mode, and returns how many files
+// were rewritten. An interrupted rotation is finished.
+func rotateStorageKey(cfg *config.Config, mode string, passphrase storage.PassphraseFunc) (int, error) {
+	if err := history.MigrateLog(cfg.Storage.DataDir); err != nil {
+		return 0, err
+	}
+	old, err := storage.OpenRotatingEncryption(cfg.Storage.BackupDir, cfg.Storage.Encryption, readPassphrase)
+	if err != nil {
+		return 0, fmt.Errorf("failed to unlock storage: %w", err)
+	}
+	store := storage.NewStore(cfg.Storage.DataDir, cfg.Storage.BackupDir)
+	store.SetEncryption(old)
+
+	count, err := store.RotateEncryption(mode, passphrase, profileSkipDirs(cfg)...)
+
+	encryptionMu.Lock()
+	encryptionOpened = false
+	encryptionMu.Unlock()
+	return count, err
+}
+
+// rememberPassphrase asks for a passphrase once and reuses it, so a new
+// passphrase for both the token and the storage key is only entered once
+func rememberPassphrase(read storage.PassphraseFunc) storage.PassphraseFunc {
+	var passphrase string
+	return func(confirm bool) (string, error) {
+		if passphrase == "" {
+			p, err := read(confirm)
+			if err != nil {
+				return "", err
+			}
+			passphrase = p
+		}
+		return passphrase, nil
+	}
+}
+
+// profileSkipDirs returns the directories a key rotation must leave alone:
+// the default profile's data dir holds the other profiles, each with its own key
+func profileSkipDirs(cfg *config.Config) []string {
+	if cfg.Profile != "" {
+		return nil
+	}
+	return []string{cfg.ProfilesDir()}
+}
+
+// containsFile reports whether files includes path
+func containsFile(files []string, path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	for _, file := range files {
+		if other, err := filepath.Abs(file); err == nil && other == abs {
+			return true
+		}
+	}
+	return false
+}
+
+// promptPassphrase reads a passphrase from the terminal without echoing it
+func promptPassphrase(fd int, prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	raw, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return string(raw), nil
+}
@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bkataru/spotigo/internal/config"
+	"github.com/bkataru/spotigo/internal/crypto"
+	"github.com/bkataru/spotigo/internal/jsonutil"
+	"github.com/bkataru/spotigo/internal/storage"
+)
+
+func TestEncryptedDataFiles(t *testing.T) {
+	dir := t.TempDir()
+	testCfg := &config.Config{}
+	testCfg.Storage.DataDir = filepath.Join(dir, "data")
+	testCfg.Storage.BackupDir = filepath.Join(dir, "backups")
+	testCfg.Storage.Encryption = storage.EncryptionPassphrase
+
+	prev := cfg
+	cfg = testCfg
+	t.Cleanup(func() { cfg = prev })
+	t.Setenv(passphraseEnv, "correct horse battery")
+
+	store, err := newStore(testCfg)
+	if err != nil {
+		t.Fatalf("newStore() error = %v", err)
+	}
+	if !store.Encrypted() {
+		t.Fatal("expected an encrypting store")
+	}
+	tracks := []map[string]string{{"id": "t1", "name": "Secret Song"}}
+	if err := store.SaveJSON("saved_tracks.json", tracks); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(testCfg.Storage.DataDir, "saved_tracks.json")
+	raw, err := os.ReadFile(path) // #nosec G304 - test path
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "Secret Song") {
+		t.Error("data file was written in plaintext")
+	}
+
+	// Readers outside the store decrypt through the installed decoder
+	var loaded []map[string]string
+	if err := jsonutil.LoadJSONFile(path, &loaded); err != nil {
+		t.Fatalf("LoadJSONFile() error = %v", err)
+	}
+	if len(loaded) != 1 || loaded[0]["name"] != "Secret Song" {
+		t.Errorf("unexpected data %v", loaded)
+	}
+
+	// A fresh process with the wrong passphrase cannot unlock the store
+	encryptionMu.Lock()
+	encryptionOpened = false
+	encryptionMu.Unlock()
+	t.Setenv(passphraseEnv, "wrong horse battery")
+	if _, err := newStore(testCfg); !errors.Is(err, storage.ErrWrongPassphrase) {
+		t.Errorf("newStore() error = %v, want %v", err, storage.ErrWrongPassphrase)
+	}
+}
+
+func TestReadPassphrase(t *testing.T) {
+	t.Setenv(passphraseEnv, "short")
+	if got, err := readPassphrase(false); err != nil || got != "short" {
+		t.Errorf("readPassphrase(false) = %q, %v", got, err)
+	}
+	if _, err := readPassphrase(true); err == nil {
+		t.Error("expected a new passphrase that is too short to be rejected")
+	}
+}
+
+func TestTokenKeyProvider(t *testing.T) {
+	tests := []struct {
+		source  string
+		keyFile string
+		want    string
+		wantErr bool
+	}{
+		{"", "", "machine", false},
+		{"machine", "", "machine", false},
+		{"passphrase", "", "passphrase", false},
+		{"keyfile", "/tmp/token.key", "keyfile", false},
+		{"keyfile", "", "", true},
+		{"env", "", "env", false},
+		{"keyring", "", "keyring", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			provider, err := tokenKeyProvider(tt.source, tt.keyFile, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("tokenKeyProvider(%q) error = %v, wantErr %v", tt.source, err, tt.wantErr)
+			}
+			if !tt.wantErr && provider.Name() != tt.want {
+				t.Errorf("tokenKeyProvider(%q) = %s, want %s", tt.source, provider.Name(), tt.want)
+			}
+		})
+	}
+
+	// Profiles keep their keys in separate keyring entries
+	provider, err := tokenKeyProvider("keyring", "", "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, ok := provider.(crypto.KeyringKey); !ok || key.User != "token-key/work" {
+		t.Errorf("tokenKeyProvider(keyring, work) = %+v", provider)
+	}
+}
+
+func TestRotateProtectedFiles(t *testing.T) {
+	dir := t.TempDir()
+	testCfg := &config.Config{}
+	testCfg.Storage.DataDir = filepath.Join(dir, "data")
+	testCfg.Spotify.TokenFile = filepath.Join(dir, ".spotify_token")
+	keyFile := filepath.Join(dir, "token.key")
+	if err := os.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(tokenKeyEnv, "fedcba9876543210fedcba9876543210")
+
+	from, _ := tokenKeyProvider("env", "", "")
+	to, _ := tokenKeyProvider("keyfile", keyFile, "")
+
+	// A plaintext token from an older version and a protected cache file
+	if err := os.WriteFile(testCfg.Spotify.TokenFile, []byte(`{"access_token":"abc"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cache := filepath.Join(testCfg.Storage.DataDir, "cache.bin")
+	enc, _ := crypto.NewTokenEncryptorWithProvider(from)
+	if err := enc.SaveEncryptedFile(cache, []byte("cached")); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := rotateProtectedFiles(testCfg, from, to)
+	if err != nil {
+		t.Fatalf("rotateProtectedFiles() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("rotateProtectedFiles() = %d files, want 2", count)
+	}
+
+	reader, _ := crypto.NewTokenEncryptorWithProvider(to)
+	for _, path := range []string{testCfg.Spotify.TokenFile, cache} {
+		if _, err := reader.LoadEncryptedFile(path); err != nil {
+			t.Errorf("%s is not readable with the new key: %v", path, err)
+		}
+	}
+
+	// Rotating back finds the now encrypted token only once
+	if count, err := rotateProtectedFiles(testCfg, to, from); err != nil || count != 2 {
+		t.Errorf("rotateProtectedFiles() back = %d, %v", count, err)
+	}
+}
+
+func TestRotateProtectedFiles_Profiles(t *testing.T) {
+	dir := t.TempDir()
+	base := &config.Config{}
+	base.Storage.DataDir = filepath.Join(dir, "data")
+	base.Spotify.TokenFile = filepath.Join(base.Storage.DataDir, ".spotify_token")
+	alice, err := base.AddProfile("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := base.AddProfile("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyFile := filepath.Join(dir, "token.key")
+	if err := os.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(tokenKeyEnv, "fedcba9876543210fedcba9876543210")
+	from, _ := tokenKeyProvider("env", "", "")
+	to, _ := tokenKeyProvider("keyfile", keyFile, "")
+
+	// Every profile's token starts out with the same key
+	enc, _ := crypto.NewTokenEncryptorWithProvider(from)
+	for _, profile := range []*config.Config{base, alice, bob} {
+		if err := enc.SaveEncryptedFile(profile.Spotify.TokenFile, []byte(`{"access_token":"abc"}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Rotating the default profile leaves the named profiles alone
+	if count, err := rotateProtectedFiles(base, from, to); err != nil || count != 1 {
+		t.Fatalf("rotateProtectedFiles(default) = %d, %v; want 1 file", count, err)
+	}
+	// Rotating a named profile leaves the other one alone
+	if count, err := rotateProtectedFiles(alice, from, to); err != nil || count != 1 {
+		t.Fatalf("rotateProtectedFiles(alice) = %d, %v; want 1 file", count, err)
+	}
+
+	reader, _ := crypto.NewTokenEncryptorWithProvider(to)
+	for _, profile := range []*config.Config{base, alice} {
+		if _, err := reader.LoadEncryptedFile(profile.Spotify.TokenFile); err != nil {
+			t.Errorf("%s token is not readable with the new key: %v", profile.Profile, err)
+		}
+	}
+	if _, err := enc.LoadEncryptedFile(bob.Spotify.TokenFile); err != nil {
+		t.Errorf("bob's token should keep its key: %v", err)
+	}
+}
+
+func TestReadNewPassphrase(t *testing.T) {
+	t.Setenv(passphraseEnv, "old passphrase")
+	t.Setenv(newPassphraseEnv, "")
+	if _, err := readNewPassphrase(true); err == nil {
+		t.Error("expected the old passphrase not to be reused")
+	}
+	t.Setenv(newPassphraseEnv, "new passphrase")
+	if got, err := readNewPassphrase(true); err != nil || got != "new passphrase" {
+		t.Errorf("readNewPassphrase() = %q, %v", got, err)
+	}
+}
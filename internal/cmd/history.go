@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bkataru/spotigo/internal/config"
+	"github.com/bkataru/spotigo/internal/history"
+	"github.com/bkataru/spotigo/internal/jsonutil"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show your listening history",
+	Long: `Show a summary of your play history.
+
+Spotify's API only returns your last 50 plays. For your full history, request
+the "Extended streaming history" from your Spotify privacy settings
+(https://www.spotify.com/account/privacy/) and import it:
+
+  spotigo history import ~/Downloads/my_spotify_data.zip
+
+Imported plays are kept in play_history.json in the data directory, where
+'spotigo stats top --source plays', chat queries and 'spotigo search index'
+use them.
+Plays recorded by 'spotigo record' are included too.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runHistory()
+	},
+}
+
+var historyImportCmd = &cobra.Command{
+	Use:   "import <dir|zip>",
+	Short: "Import the extended streaming history from Spotify's data export",
+	Long: `Import the Streaming_History_Audio_*.json files from Spotify's
+"Download your data" export, given as the zip archive or the extracted
+directory. Plays already in the history are skipped, so importing a newer
+export again only adds what is new.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runHistoryImport(args[0])
+	},
+}
+
+func init() {
+	historyCmd.AddCommand(historyImportCmd)
+}
+
+func runHistory() {
+	cfg := GetConfig()
+	if cfg == nil {
+		fmt.Println("Error: Configuration not loaded")
+		return
+	}
+
+	plays, err := loadPlayHistory(cfg)
+	if err != nil {
+		fmt.Printf("Error loading play history: %v\n", err)
+		return
+	}
+	if len(plays) == 0 {
+		fmt.Println("No play history found.")
+		fmt.Println("Run 'spotigo history import <export>' to import Spotify's extended streaming history,")
+		fmt.Println("or 'spotigo record' to start recording it.")
+		return
+	}
+
+	fmt.Println("Listening History")
+	fmt.Println("=================")
+	fmt.Println()
+	printPlaySummary(plays)
+}
+
+func runHistoryImport(src string) {
+	cfg := GetConfig()
+	if cfg == nil {
+		fmt.Println("Error: Configuration not loaded")
+		return
+	}
+
+	plays, files, err := history.ReadExport(src)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("Read %d plays from %d history files\n", len(plays), files)
+
+	added, total, err := addPlays(cfg, plays)
+	if err != nil {
+		fmt.Printf("Error saving play history: %v\n", err)
+		return
+	}
+
+	fmt.Printf("✅ Imported %d new plays (%d already in the history)\n", added, len(plays)-added)
+	fmt.Printf("   History now holds %d plays\n", total)
+}
+
+// addPlays merges plays into the stored history and returns how many were
+// new and the size of the history
+func addPlays(cfg *config.Config, plays []history.Play) (int, int, error) {
+	store, err := newStore(cfg)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var log []history.Play
+	if store.Exists(history.FileName) {
+		if err := store.LoadJSON(history.FileName, &log); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	merged, added := history.Merge(log, plays)
+	if added == 0 {
+		return 0, len(merged), nil
+	}
+	if err := store.SaveJSON(history.FileName, merged); err != nil {
+		return 0, 0, err
+	}
+	return added, len(merged), nil
+}
+
+// loadPlayHistory returns the imported and recorded plays, oldest first,
+// or nil if there are none
+func loadPlayHistory(cfg *config.Config) ([]history.Play, error) {
+	var imported []history.Play
+	err := jsonutil.LoadJSONFile(filepath.Join(cfg.Storage.DataDir, history.FileName), &imported)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	log, err := openPlayLog(cfg, false)
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := log.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(imported) == 0 && len(recorded) == 0 {
+		return nil, nil
+	}
+	plays, _ := history.Merge(imported, recorded)
+	return plays, nil
+}
+
+// periodStart returns when a --period value starts, or the zero time for all
+func periodStart(period string, now time.Time) time.Time {
+	switch period {
+	case "week":
+		return now.AddDate(0, 0, -7)
+	case "month":
+		return now.AddDate(0, -1, 0)
+	case "year":
+		return now.AddDate(-1, 0, 0)
+	default:
+		return time.Time{}
+	}
+}
+
+// periodLabel describes a --period value for headings
+func periodLabel(period string) string {
+	switch period {
+	case "week", "month", "year":
+		return "last " + period
+	default:
+		return "all time"
+	}
+}
+
+// printPlaySummary prints the size, span and listening time of plays
+func printPlaySummary(plays []history.Play) {
+	counted := 0
+	var played int64
+	for _, p := range plays {
+		if p.Counted() {
+			counted++
+		}
+		played += p.MsPlayed
+	}
+
+	fmt.Printf("  Plays:          %d (%d listened for %s or more)\n", len(plays), counted, history.MinCountedPlay)
+	fmt.Printf("  Listening time: %.1f hours\n", time.Duration(played*int64(time.Millisecond)).Hours())
+	if len(plays) > 0 {
+		fmt.Printf("  From:           %s\n", plays[0].PlayedAt.Local().Format("2006-01-02"))
+		fmt.Printf("  To:             %s\n", plays[len(plays)-1].PlayedAt.Local().Format("2006-01-02"))
+	}
+	fmt.Println()
+}
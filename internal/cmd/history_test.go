@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bkataru/spotigo/internal/config"
+	"github.com/bkataru/spotigo/internal/history"
+)
+
+func TestAddPlays(t *testing.T) {
+	c := &config.Config{}
+	c.Storage.DataDir = t.TempDir()
+	c.Storage.BackupDir = t.TempDir()
+
+	if plays, err := loadPlayHistory(c); err != nil || plays != nil {
+		t.Fatalf("loadPlayHistory() without a history = %v, %v", plays, err)
+	}
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	plays := []history.Play{
+		{PlayedAt: at, Type: "track", URI: "spotify:track:a", Name: "A", Artist: "X", MsPlayed: 200000},
+		{PlayedAt: at.Add(time.Hour), Type: "track", URI: "spotify:track:b", Name: "B", Artist: "Y", MsPlayed: 200000},
+	}
+	added, total, err := addPlays(c, plays)
+	if err != nil || added != 2 || total != 2 {
+		t.Fatalf("addPlays() = %d, %d, %v, want 2, 2", added, total, err)
+	}
+
+	more := append(plays, history.Play{PlayedAt: at.Add(2 * time.Hour), Type: "track", URI: "spotify:track:a", Name: "A", Artist: "X"})
+	added, total, err = addPlays(c, more)
+	if err != nil || added != 1 || total != 3 {
+		t.Fatalf("second addPlays() = %d, %d, %v, want 1, 3", added, total, err)
+	}
+
+	stored, err := loadPlayHistory(c)
+	if err != nil || len(stored) != 3 || !stored[0].PlayedAt.Equal(at) {
+		t.Errorf("loadPlayHistory() = %v, %v", stored, err)
+	}
+
+	// Recorded plays are merged in, without repeating imported ones
+	log := history.NewLog(filepath.Join(c.Storage.DataDir, history.LogDir))
+	recorded := []history.Play{plays[1], {PlayedAt: at.Add(3 * time.Hour), Type: "track", URI: "spotify:track:c", Name: "C", Artist: "Z"}}
+	if err := log.Append(recorded); err != nil {
+		t.Fatal(err)
+	}
+	stored, err = loadPlayHistory(c)
+	if err != nil || len(stored) != 4 || stored[3].Name != "C" {
+		t.Errorf("loadPlayHistory() with recorded plays = %v, %v", stored, err)
+	}
+}
+
+func TestPeriodStart(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		period string
+		want   time.Time
+	}{
+		{"week", time.Date(2024, 3, 24, 12, 0, 0, 0, time.UTC)},
+		{"month", time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)},
+		{"year", time.Date(2023, 3, 31, 12, 0, 0, 0, time.UTC)},
+		{"all", time.Time{}},
+		{"", time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := periodStart(tt.period, now); !got.Equal(tt.want) {
+			t.Errorf("periodStart(%q) = %v, want %v", tt.period, got, tt.want)
+		}
+	}
+}
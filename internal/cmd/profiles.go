@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bkataru/spotigo/internal/config"
+	"github.com/bkataru/spotigo/internal/jsonutil"
+)
+
+var profilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "Manage profiles for multiple Spotify accounts",
+	Long: `Manage profiles, each with its own Spotify token, data, backups and
+search index. Select a profile with --profile, SPOTIGO_PROFILE or the
+profile setting in your config file:
+
+  spotigo profiles add alice
+  spotigo --profile alice auth
+  spotigo --profile alice backup
+
+A profile's files live in <data_dir>/profiles/<name>. Settings under
+profiles.<name> in the config file override the top-level ones, e.g. a
+separate spotify.client_id. The top-level settings are the "default" profile.`,
+	// Profiles are managed from the top-level settings, so a missing
+	// profile can still be added
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		if cfg, err = config.LoadBase(cfgFile); err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		return nil
+	},
+}
+
+var profilesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles",
+	Run: func(cmd *cobra.Command, args []string) {
+		listProfiles()
+	},
+}
+
+var profilesAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Create a profile",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		addProfile(args[0])
+	},
+}
+
+var profilesRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Delete a profile with its token, data and backups",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		removeProfile(args[0])
+	},
+}
+
+var profilesCompareCmd = &cobra.Command{
+	Use:   "compare <profile> <profile>",
+	Short: "Compare the libraries of two profiles",
+	Long: `Compare the saved tracks and artists of two profiles, showing how much
+they overlap and which artists they share. Use "default" for the top-level
+profile. Both profiles need a backup first.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		compareProfiles(args[0], args[1])
+	},
+}
+
+var (
+	profilesRemoveForce bool
+	profilesCompareTop  int
+)
+
+func init() {
+	profilesRemoveCmd.Flags().BoolVarP(&profilesRemoveForce, "force", "f", false, "delete without asking")
+	profilesCompareCmd.Flags().IntVar(&profilesCompareTop, "top", 10, "number of shared artists to show")
+
+	profilesCmd.AddCommand(profilesListCmd)
+	profilesCmd.AddCommand(profilesAddCmd)
+	profilesCmd.AddCommand(profilesRemoveCmd)
+	profilesCmd.AddCommand(profilesCompareCmd)
+}
+
+func listProfiles() {
+	cfg := GetConfig()
+	if cfg == nil {
+		fmt.Println("Error: Configuration not loaded")
+		return
+	}
+
+	names, err := cfg.ProfileNames()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	active := profileName
+	if active == "" {
+		active = cfg.DefaultProfile
+	}
+	if active == "" {
+		active = config.DefaultProfileName
+	}
+
+	fmt.Println("Profiles:")
+	for _, name := range append([]string{config.DefaultProfileName}, names...) {
+		profile, err := cfg.ForProfile(name)
+		if err != nil {
+			fmt.Printf("    %s (error: %v)\n", name, err)
+			continue
+		}
+		marker := " "
+		if name == active {
+			marker = "*"
+		}
+		status := "not authenticated"
+		if _, err := os.Stat(profile.Spotify.TokenFile); err == nil {
+			status = "authenticated"
+		}
+		fmt.Printf("  %s %-16s %-18s %s\n", marker, name, status, profile.Storage.DataDir)
+	}
+}
+
+func addProfile(name string) {
+	cfg := GetConfig()
+	if cfg == nil {
+		fmt.Println("Error: Configuration not loaded")
+		return
+	}
+	if cfg.HasProfile(name) {
+		fmt.Printf("Profile %s already exists\n", name)
+		return
+	}
+
+	profile, err := cfg.AddProfile(name)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	fmt.Printf("✅ Created profile %s\n", name)
+	fmt.Printf("  Data: %s\n", profile.Storage.DataDir)
+	fmt.Printf("  Token: %s\n", profile.Spotify.TokenFile)
+	fmt.Println()
+	fmt.Printf("Authenticate it with: spotigo --profile %s auth\n", name)
+}
+
+func removeProfile(name string) {
+	cfg := GetConfig()
+	if cfg == nil {
+		fmt.Println("Error: Configuration not loaded")
+		return
+	}
+
+	if !profilesRemoveForce {
+		prompt := fmt.Sprintf("Delete profile %s with its token, data and backups in %s? [y/N] ", name, cfg.ProfileDir(name))
+		if !confirm(os.Stdin, prompt) {
+			fmt.Println("Cancelled.")
+			return
+		}
+	}
+
+	if err := cfg.RemoveProfile(name); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("✅ Removed profile %s\n", name)
+	if _, ok := cfg.Profiles[name]; ok {
+		fmt.Printf("Its settings under profiles.%s remain in your config file.\n", name)
+	}
+}
+
+// profileLibrary is the part of a profile's library used for comparisons
+type profileLibrary struct {
+	// Tracks maps track IDs to display names
+	Tracks map[string]string
+	// Artists maps artist names to their number of saved tracks; followed
+	// artists without saved tracks count as 0
+	Artists map[string]int
+}
+
+// profileComparison describes how two libraries overlap
+type profileComparison struct {
+	SharedTracks  []string
+	SharedArtists []ArtistCount
+	TrackOverlap  float64
+	ArtistOverlap float64
+}
+
+func compareProfiles(first, second string) {
+	cfg := GetConfig()
+	if cfg == nil {
+		fmt.Println("Error: Configuration not loaded")
+		return
+	}
+
+	var libraries [2]*profileLibrary
+	for i, name := range []string{first, second} {
+		profile, err := cfg.ForProfile(name)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if libraries[i], err = loadProfileLibrary(profile); err != nil {
+			fmt.Printf("Error loading profile %s: %v\n", name, err)
+			fmt.Printf("Run 'spotigo --profile %s backup' first.\n", name)
+			return
+		}
+	}
+
+	result := compareLibraries(libraries[0], libraries[1])
+
+	fmt.Printf("Comparing %s and %s\n", first, second)
+	fmt.Println("==============================")
+	fmt.Printf("Saved tracks:   %d / %d\n", len(libraries[0].Tracks), len(libraries[1].Tracks))
+	fmt.Printf("Shared tracks:  %d (%.1f%% overlap)\n", len(result.SharedTracks), result.TrackOverlap*100)
+	fmt.Printf("Artists:        %d / %d\n", len(libraries[0].Artists), len(libraries[1].Artists))
+	fmt.Printf("Shared artists: %d (%.1f%% overlap)\n", len(result.SharedArtists), result.ArtistOverlap*100)
+
+	if len(result.SharedArtists) > 0 {
+		fmt.Println("\nTop shared artists:")
+		for i, artist := range result.SharedArtists {
+			if i >= profilesCompareTop {
+				break
+			}
+			fmt.Printf("  %2d. %s (%d tracks)\n", i+1, artist.Name, artist.Count)
+		}
+	}
+}
+
+// loadProfileLibrary reads a profile's saved tracks and followed artists
+func loadProfileLibrary(profile *config.Config) (*profileLibrary, error) {
+	store, err := newStore(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	var tracks []map[string]interface{}
+	if err := store.LoadJSON("saved_tracks.json", &tracks); err != nil {
+		return nil, err
+	}
+	library := &profileLibrary{Tracks: make(map[string]string), Artists: make(map[string]int)}
+	for _, track := range tracks {
+		data := track
+		if nested, ok := track["track"].(map[string]interface{}); ok {
+			data = nested
+		}
+		artists := jsonutil.GetTrackArtists(track)
+		if id := jsonutil.GetString(data, "id"); id != "" {
+			name := jsonutil.GetString(data, "name")
+			if len(artists) > 0 {
+				name += " - " + artists[0]
+			}
+			library.Tracks[id] = name
+		}
+		for _, artist := range artists {
+			library.Artists[artist]++
+		}
+	}
+
+	// Followed artists are optional
+	var followed []map[string]interface{}
+	if err := store.LoadJSON("followed_artists.json", &followed); err == nil {
+		for _, artist := range followed {
+			name := jsonutil.GetString(artist, "name")
+			if _, ok := library.Artists[name]; name != "" && !ok {
+				library.Artists[name] = 0
+			}
+		}
+	}
+	return library, nil
+}
+
+// compareLibraries computes the overlap of two libraries. Overlaps are the
+// Jaccard index: shared items divided by the items in either library.
+func compareLibraries(a, b *profileLibrary) profileComparison {
+	var result profileComparison
+
+	for id, name := range a.Tracks {
+		if _, ok := b.Tracks[id]; ok {
+			result.SharedTracks = append(result.SharedTracks, name)
+		}
+	}
+	sort.Strings(result.SharedTracks)
+
+	for name, count := range a.Artists {
+		if other, ok := b.Artists[name]; ok {
+			result.SharedArtists = append(result.SharedArtists, ArtistCount{Name: name, Count: count + other})
+		}
+	}
+	sort.Slice(result.SharedArtists, func(i, j int) bool {
+		if result.SharedArtists[i].Count != result.SharedArtists[j].Count {
+			return result.SharedArtists[i].Count > result.SharedArtists[j].Count
+		}
+		return result.SharedArtists[i].Name < result.SharedArtists[j].Name
+	})
+
+	result.TrackOverlap = jaccard(len(result.SharedTracks), len(a.Tracks), len(b.Tracks))
+	result.ArtistOverlap = jaccard(len(result.SharedArtists), len(a.Artists), len(b.Artists))
+	return result
+}
+
+// jaccard returns shared / (a + b - shared), or 0 for two empty sets
+func jaccard(shared, a, b int) float64 {
+	union := a + b - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
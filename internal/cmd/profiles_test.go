@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bkataru/spotigo/internal/config"
+)
+
+func TestCompareLibraries(t *testing.T) {
+	a := &profileLibrary{
+		Tracks:  map[string]string{"t1": "One - A", "t2": "Two - B", "t3": "Three - C"},
+		Artists: map[string]int{"A": 1, "B": 1, "C": 1, "D": 0},
+	}
+	b := &profileLibrary{
+		Tracks:  map[string]string{"t2": "Two - B", "t3": "Three - C", "t4": "Four - B"},
+		Artists: map[string]int{"B": 2, "C": 1, "E": 0},
+	}
+
+	result := compareLibraries(a, b)
+	if len(result.SharedTracks) != 2 || result.SharedTracks[0] != "Three - C" {
+		t.Errorf("SharedTracks = %v", result.SharedTracks)
+	}
+	if math.Abs(result.TrackOverlap-0.5) > 1e-9 {
+		t.Errorf("TrackOverlap = %v, want 0.5", result.TrackOverlap)
+	}
+	if len(result.SharedArtists) != 2 || result.SharedArtists[0] != (ArtistCount{Name: "B", Count: 3}) {
+		t.Errorf("SharedArtists = %v", result.SharedArtists)
+	}
+	if math.Abs(result.ArtistOverlap-2.0/5.0) > 1e-9 {
+		t.Errorf("ArtistOverlap = %v, want 0.4", result.ArtistOverlap)
+	}
+
+	empty := compareLibraries(&profileLibrary{}, &profileLibrary{})
+	if empty.TrackOverlap != 0 || empty.ArtistOverlap != 0 {
+		t.Errorf("empty libraries should not overlap: %+v", empty)
+	}
+}
+
+func TestLoadProfileLibrary(t *testing.T) {
+	dir := t.TempDir()
+	base := &config.Config{}
+	base.Storage.DataDir = dir
+	profile, err := base.AddProfile("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracks := `[{"added_at":"2024-01-01T00:00:00Z","id":"t1","name":"One","artists":[{"name":"A"}]},
+		{"track":{"id":"t2","name":"Two","artists":[{"name":"A"},{"name":"B"}]}}]`
+	artists := `[{"name":"A"},{"name":"Z"}]`
+	for name, data := range map[string]string{"saved_tracks.json": tracks, "followed_artists.json": artists} {
+		if err := os.WriteFile(filepath.Join(profile.Storage.DataDir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	library, err := loadProfileLibrary(profile)
+	if err != nil {
+		t.Fatalf("loadProfileLibrary() error = %v", err)
+	}
+	if len(library.Tracks) != 2 || library.Tracks["t2"] != "Two - A" {
+		t.Errorf("Tracks = %v", library.Tracks)
+	}
+	if library.Artists["A"] != 2 || library.Artists["B"] != 1 || library.Artists["Z"] != 0 || len(library.Artists) != 3 {
+		t.Errorf("Artists = %v", library.Artists)
+	}
+
+	// A profile without a backup reports an error
+	empty, _ := base.AddProfile("bob")
+	if _, err := loadProfileLibrary(empty); err == nil {
+		t.Error("expected an error without saved tracks")
+	}
+}
@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zmb3/spotify/v2"
+
+	"github.com/bkataru/spotigo/internal/config"
+	"github.com/bkataru/spotigo/internal/history"
+)
+
+// maxRecordPages bounds how many pages of recently played one poll reads
+const maxRecordPages = 20
+
+var (
+	recordInterval time.Duration
+	recordOnce     bool
+	recordMaxSize  int64
+	recordCmd      = &cobra.Command{
+		Use:   "record",
+		Short: "Record your listening history continuously",
+		Long: `Run in the foreground and poll Spotify's recently played list, adding
+each new play to a local log. Spotify only remembers your last 50 plays, so
+polling more often than that many tracks take to play keeps a complete history.
+
+Plays are appended to <data_dir>/plays/plays.ndjson, which is rotated into
+plays-<time>.ndjson once it reaches --max-size. The position reached is saved in
+<data_dir>/plays/cursor.json, so a restarted recorder carries on where it
+stopped. A log left in <data_dir>/history by an earlier version is moved there. Recorded plays are used by 'spotigo history', 'spotigo stats' and
+'spotigo search index' together with imported ones.
+
+Use --once to poll a single time, e.g. from cron. SIGINT or SIGTERM stops the
+recorder.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runRecord()
+		},
+	}
+)
+
+func init() {
+	recordCmd.Flags().DurationVar(&recordInterval, "interval", 15*time.Minute, "time between polls")
+	recordCmd.Flags().BoolVar(&recordOnce, "once", false, "poll once and exit")
+	recordCmd.Flags().Int64Var(&recordMaxSize, "max-size", history.DefaultMaxLogSize>>20, "rotate the log once it reaches this many MiB")
+}
+
+// recentlyPlayedSource returns plays after a cursor, oldest first
+type recentlyPlayedSource interface {
+	GetRecentlyPlayedAfter(ctx context.Context, after time.Time) ([]spotify.RecentlyPlayedItem, error)
+}
+
+// recorder appends new plays to the log and advances the cursor
+type recorder struct {
+	source recentlyPlayedSource
+	log    *history.Log
+	cursor time.Time
+}
+
+// newRecorder resumes from the saved cursor, or from the last play in the log
+// if the cursor was not saved after it was written
+func newRecorder(source recentlyPlayedSource, log *history.Log) (*recorder, error) {
+	cursor, err := log.LoadCursor()
+	if err != nil {
+		return nil, err
+	}
+	last, err := log.Last()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &recorder{source: source, log: log, cursor: cursor.After}
+	if last != nil && last.PlayedAt.After(r.cursor) {
+		r.cursor = last.PlayedAt
+	}
+	return r, nil
+}
+
+// poll records the plays since the cursor and returns how many were added
+func (r *recorder) poll(ctx context.Context) (int, error) {
+	added := 0
+	for page := 0; page < maxRecordPages; page++ {
+		items, err := r.source.GetRecentlyPlayedAfter(ctx, r.cursor)
+		if err != nil {
+			return added, err
+		}
+
+		var plays []history.Play
+		for _, item := range items {
+			if item.PlayedAt.After(r.cursor) {
+				plays = append(plays, playFromRecent(item))
+			}
+		}
+		if len(plays) == 0 {
+			return added, nil
+		}
+
+		if err := r.log.Append(plays); err != nil {
+			return added, err
+		}
+		added += len(plays)
+		r.cursor = plays[len(plays)-1].PlayedAt
+		if err := r.log.SaveCursor(history.Cursor{After: r.cursor, UpdatedAt: time.Now().UTC()}); err != nil {
+			return added, err
+		}
+
+		// A full page may mean more plays are waiting after the new cursor
+		if len(items) < 50 {
+			return added, nil
+		}
+	}
+	return added, nil
+}
+
+// playFromRecent converts a recently played item into a play. The API does
+// not say how long the track played for, so MsPlayed is left at zero.
+func playFromRecent(item spotify.RecentlyPlayedItem) history.Play {
+	track := item.Track
+	var artist string
+	if len(track.Artists) > 0 {
+		artist = track.Artists[0].Name
+	}
+	return history.Play{
+		PlayedAt: item.PlayedAt.UTC(),
+		Type:     "track",
+		ID:       string(track.ID),
+		URI:      string(track.URI),
+		Name:     track.Name,
+		Artist:   artist,
+		Album:    track.Album.Name,
+		Source:   history.SourceRecent,
+	}
+}
+
+// openPlayLog returns the recorder's log. With storage encryption enabled,
+// new lines are encrypted; the key is only unlocked for reading once an
+// encrypted line is found.
+func openPlayLog(cfg *config.Config, sealed bool) (*history.Log, error) {
+	if err := history.MigrateLog(cfg.Storage.DataDir); err != nil {
+		return nil, err
+	}
+	log := history.NewLog(filepath.Join(cfg.Storage.DataDir, history.LogDir))
+	log.Open = func(data []byte) ([]byte, error) {
+		enc, err := openEncryption(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return enc.Decode(data)
+	}
+	if sealed {
+		enc, err := openEncryption(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if enc.Encrypts() {
+			log.Seal = enc.Encode
+		}
+	}
+	return log, nil
+}
+
+func runRecord() {
+	cfg := GetConfig()
+	if cfg == nil {
+		fmt.Println("Error: Configuration not loaded")
+		return
+	}
+	if recordInterval < time.Minute {
+		fmt.Println("Error: --interval must be at least 1m")
+		return
+	}
+
+	client, err := newSpotifyClient(cfg)
+	if err != nil {
+		if errors.Is(err, errNotAuthenticated) {
+			fmt.Println("Not authenticated with Spotify")
+			fmt.Println("Run 'spotigo auth' to authenticate first.")
+			return
+		}
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	log, err := openPlayLog(cfg, true)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	log.MaxSize = recordMaxSize << 20
+
+	rec, err := newRecorder(client, log)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if !recordOnce {
+		fmt.Printf("Recording listening history every %s (Ctrl+C to stop)\n", recordInterval)
+		if !rec.cursor.IsZero() {
+			fmt.Printf("  Resuming after %s\n", rec.cursor.Local().Format("2006-01-02 15:04:05"))
+		}
+	}
+
+	for {
+		added, err := rec.poll(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			fmt.Printf("[%s] Error: %v\n", time.Now().Format("15:04:05"), err)
+		case added > 0:
+			fmt.Printf("[%s] Recorded %d new plays\n", time.Now().Format("15:04:05"), added)
+		case recordOnce:
+			fmt.Println("No new plays.")
+		}
+		if recordOnce {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			fmt.Println("Recorder stopped.")
+			return
+		case <-time.After(recordInterval):
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+
+	"github.com/bkataru/spotigo/internal/history"
+)
+
+// fakeRecentlyPlayed serves plays like the API: those after the cursor,
+// oldest first, at most pageSize at a time
+type fakeRecentlyPlayed struct {
+	plays    []spotify.RecentlyPlayedItem
+	pageSize int
+	calls    int
+	err      error
+}
+
+func (f *fakeRecentlyPlayed) GetRecentlyPlayedAfter(ctx context.Context, after time.Time) ([]spotify.RecentlyPlayedItem, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	var items []spotify.RecentlyPlayedItem
+	for _, item := range f.plays {
+		if item.PlayedAt.After(after) && len(items) < f.pageSize {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func recentItems(start time.Time, n int) []spotify.RecentlyPlayedItem {
+	items := make([]spotify.RecentlyPlayedItem, n)
+	for i := range items {
+		id := spotify.ID(string(rune('a' + i%26)))
+		items[i] = spotify.RecentlyPlayedItem{
+			PlayedAt: start.Add(time.Duration(i) * 3 * time.Minute),
+			Track: spotify.SimpleTrack{
+				ID:      id,
+				URI:     spotify.URI("spotify:track:" + id),
+				Name:    "Track " + string(id),
+				Artists: []spotify.SimpleArtist{{Name: "Artist"}},
+				Album:   spotify.SimpleAlbum{Name: "Album"},
+			},
+		}
+	}
+	return items
+}
+
+func TestRecorder_PollAndResume(t *testing.T) {
+	log := history.NewLog(filepath.Join(t.TempDir(), history.LogDir))
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	source := &fakeRecentlyPlayed{plays: recentItems(start, 120), pageSize: 50}
+
+	rec, err := newRecorder(source, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, err := rec.poll(context.Background())
+	if err != nil || added != 120 {
+		t.Fatalf("poll() = %d, %v, want 120 plays", added, err)
+	}
+	if source.calls != 3 {
+		t.Errorf("expected 3 pages to be read, got %d", source.calls)
+	}
+
+	// Nothing new: the cursor stops the same plays being recorded twice
+	if added, err := rec.poll(context.Background()); err != nil || added != 0 {
+		t.Errorf("second poll() = %d, %v, want 0", added, err)
+	}
+
+	// A restarted recorder resumes from the saved cursor
+	source.plays = append(source.plays, recentItems(start.Add(24*time.Hour), 2)...)
+	resumed, err := newRecorder(source, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resumed.cursor.Equal(source.plays[119].PlayedAt) {
+		t.Errorf("resumed cursor = %v, want %v", resumed.cursor, source.plays[119].PlayedAt)
+	}
+	if added, err := resumed.poll(context.Background()); err != nil || added != 2 {
+		t.Errorf("poll() after restart = %d, %v, want 2", added, err)
+	}
+
+	plays, err := log.ReadAll()
+	if err != nil || len(plays) != 122 {
+		t.Fatalf("log holds %d plays, %v, want 122", len(plays), err)
+	}
+	if p := plays[0]; p.Name != "Track a" || p.Artist != "Artist" || p.Album != "Album" || p.ID != "a" ||
+		p.URI != "spotify:track:a" || p.Source != history.SourceRecent {
+		t.Errorf("unexpected play %+v", p)
+	}
+}
+
+func TestRecorder_ResumesFromLogWithoutCursor(t *testing.T) {
+	log := history.NewLog(t.TempDir())
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	items := recentItems(start, 3)
+
+	// The plays were written but the recorder stopped before saving the cursor
+	if err := log.Append([]history.Play{playFromRecent(items[0]), playFromRecent(items[1])}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := newRecorder(&fakeRecentlyPlayed{plays: items, pageSize: 50}, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added, err := rec.poll(context.Background()); err != nil || added != 1 {
+		t.Errorf("poll() = %d, %v, want only the unrecorded play", added, err)
+	}
+}
+
+func TestRecorder_PollError(t *testing.T) {
+	log := history.NewLog(t.TempDir())
+	rec, err := newRecorder(&fakeRecentlyPlayed{err: errors.New("rate limited")}, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rec.poll(context.Background()); err == nil {
+		t.Error("expected poll error")
+	}
+	if cursor, _ := log.LoadCursor(); !cursor.After.IsZero() {
+		t.Errorf("cursor should not move on error, got %v", cursor.After)
+	}
+}
@@ -0,0 +1,545 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/zmb3/spotify/v2"
+
+	"github.com/bkataru/spotigo/internal/config"
+	spotifyclient "github.com/bkataru/spotigo/internal/spotify"
+	"github.com/bkataru/spotigo/internal/storage"
+)
+
+var (
+	restoreToSpotify bool
+	restoreDryRun    bool
+	restoreYes       bool
+	restoreOverwrite bool
+)
+
+// restorePreviewLimit caps how many items of each kind the restore preview lists
+const restorePreviewLimit = 10
+
+// restoreOptions controls how a backup is restored into Spotify
+type restoreOptions struct {
+	DryRun    bool
+	Yes       bool
+	Overwrite bool
+}
+
+// restoreSource holds the sections of a backup that can be written back to Spotify
+type restoreSource struct {
+	SavedTracks     []restoreSavedTrack `json:"saved_tracks"`
+	FollowedArtists []restoreArtist     `json:"followed_artists"`
+	Playlists       []restorePlaylist   `json:"playlists"`
+}
+
+// restoreSavedTrack is a saved track entry as stored in a backup
+type restoreSavedTrack struct {
+	Track restoreTrack `json:"track"`
+}
+
+// restoreTrack is the track of a saved track entry
+type restoreTrack struct {
+	ID      spotify.ID             `json:"id"`
+	Name    string                 `json:"name"`
+	Artists []spotify.SimpleArtist `json:"artists"`
+}
+
+// restoreArtist is a followed artist as stored in a backup
+type restoreArtist struct {
+	ID   spotify.ID `json:"id"`
+	Name string     `json:"name"`
+}
+
+// restorePlaylist is a playlist as stored in a backup
+type restorePlaylist struct {
+	ID      spotify.ID            `json:"id"`
+	Name    string                `json:"name"`
+	Owner   string                `json:"owner"`
+	OwnerID string                `json:"owner_id"`
+	Public  bool                  `json:"public"`
+	Tracks  []restorePlaylistItem `json:"tracks"`
+}
+
+// restorePlaylistItem is a playlist item as stored in a backup. The item union
+// is stored as {"Track":...,"Episode":...}; JSON keys match case-insensitively.
+type restorePlaylistItem struct {
+	IsLocal bool `json:"is_local"`
+	Track   struct {
+		Track   *restoreURI `json:"track"`
+		Episode *restoreURI `json:"episode"`
+	} `json:"track"`
+}
+
+// restoreURI identifies a playlist track or episode
+type restoreURI struct {
+	URI     spotify.URI            `json:"uri"`
+	Name    string                 `json:"name"`
+	Artists []spotify.SimpleArtist `json:"artists"`
+}
+
+// ref returns the item's track or episode, or nil if it is unavailable
+func (item restorePlaylistItem) ref() *restoreURI {
+	if item.Track.Track != nil {
+		return item.Track.Track
+	}
+	return item.Track.Episode
+}
+
+// uri returns the item's URI, or "" for local and unavailable items that cannot be restored
+func (item restorePlaylistItem) uri() spotify.URI {
+	ref := item.ref()
+	if item.IsLocal || ref == nil {
+		return ""
+	}
+	return ref.URI
+}
+
+// Playlist restore actions
+const (
+	playlistCreate    = "create"
+	playlistAppend    = "append"
+	playlistReplace   = "replace"
+	playlistFollow    = "follow"
+	playlistUnchanged = "unchanged"
+	playlistDiffers   = "differs"
+)
+
+// playlistRestore describes what a restore does with one backed-up playlist
+type playlistRestore struct {
+	Action   string
+	Name     string
+	SourceID spotify.ID
+	// TargetID is the existing playlist to modify or follow
+	TargetID spotify.ID
+	Public   bool
+	// URIs are the items to write: all items for create and replace, the missing tail for append
+	URIs []spotify.URI
+	// Existing is the number of items already in the target playlist
+	Existing int
+}
+
+// restorePlan lists the changes needed to bring a Spotify account in line with a backup
+type restorePlan struct {
+	BackupID       string
+	Tracks         []restoreTrack // oldest first, so the newest ends up on top
+	TracksPresent  int
+	Artists        []restoreArtist
+	ArtistsPresent int
+	Playlists      []playlistRestore
+	// SkippedItems counts local and unavailable playlist items that cannot be restored
+	SkippedItems int
+}
+
+// changes returns the number of write operations the plan performs
+func (p *restorePlan) changes() int {
+	n := len(p.Tracks) + len(p.Artists)
+	for _, pl := range p.Playlists {
+		switch pl.Action {
+		case playlistCreate, playlistAppend, playlistReplace, playlistFollow:
+			n++
+		}
+	}
+	return n
+}
+
+// runSpotifyRestore restores a backup into the authenticated Spotify account
+func runSpotifyRestore(cfg *config.Config, store *storage.Store, backup *storage.BackupMetadata) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := newSpotifyClient(cfg)
+	if err != nil {
+		if errors.Is(err, errNotAuthenticated) {
+			fmt.Println("Not authenticated with Spotify")
+			fmt.Println("Run 'spotigo auth --write' to authenticate first.")
+			return
+		}
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	opts := restoreOptions{DryRun: restoreDryRun, Yes: restoreYes, Overwrite: restoreOverwrite}
+	if err := restoreBackupToSpotify(ctx, client, store, backup.ID, opts, os.Stdin); err != nil {
+		fmt.Printf("Restore failed: %v\n", err)
+		if spotifyclient.IsForbidden(err) {
+			fmt.Println("Spotify refused the change. Run 'spotigo auth --write' to grant write access.")
+		}
+	}
+}
+
+// restoreBackupToSpotify previews the changes needed to restore a backup and applies them
+// once confirmed on in
+func restoreBackupToSpotify(ctx context.Context, client *spotifyclient.Client, store *storage.Store, backupID string, opts restoreOptions, in io.Reader) error {
+	var source restoreSource
+	if err := store.LoadBackupJSON(backupID, &source); err != nil {
+		return fmt.Errorf("failed to load backup: %w", err)
+	}
+
+	fmt.Println("Comparing backup with your Spotify account...")
+	plan, err := buildRestorePlan(ctx, client, source, opts.Overwrite)
+	if err != nil {
+		return err
+	}
+	plan.BackupID = backupID
+
+	fmt.Println()
+	printRestorePlan(plan)
+
+	if plan.changes() == 0 {
+		fmt.Println("\nYour Spotify account already matches this backup.")
+		return nil
+	}
+	if opts.DryRun {
+		fmt.Println("\nDry run: no changes made.")
+		return nil
+	}
+	if !opts.Yes && !confirm(in, fmt.Sprintf("\nApply %d changes to your Spotify account? [y/N] ", plan.changes())) {
+		fmt.Println("Restore cancelled.")
+		return nil
+	}
+
+	fmt.Println()
+	if err := applyRestorePlan(ctx, client, plan); err != nil {
+		return err
+	}
+	fmt.Println("\nRestore to Spotify completed successfully!")
+	return nil
+}
+
+// confirm prints prompt and reports whether the next line read from in is yes
+func confirm(in io.Reader, prompt string) bool {
+	fmt.Print(prompt)
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && line == "" {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+// buildRestorePlan compares a backup with the current state of the account
+func buildRestorePlan(ctx context.Context, client *spotifyclient.Client, source restoreSource, overwrite bool) (*restorePlan, error) {
+	user, err := client.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	plan := &restorePlan{}
+
+	// Saved tracks are backed up newest first; save the oldest first
+	tracks := make([]restoreTrack, len(source.SavedTracks))
+	for i, saved := range source.SavedTracks {
+		tracks[i] = saved.Track
+	}
+	tracks = uniqueByID(tracks, func(t restoreTrack) spotify.ID { return t.ID })
+	saved, err := client.CheckSavedTracks(ctx, ids(tracks, func(t restoreTrack) spotify.ID { return t.ID }))
+	if err != nil {
+		return nil, err
+	}
+	for i := len(tracks) - 1; i >= 0; i-- {
+		if saved[i] {
+			plan.TracksPresent++
+			continue
+		}
+		plan.Tracks = append(plan.Tracks, tracks[i])
+	}
+
+	artists := uniqueByID(source.FollowedArtists, func(a restoreArtist) spotify.ID { return a.ID })
+	following, err := client.CheckFollowedArtists(ctx, ids(artists, func(a restoreArtist) spotify.ID { return a.ID }))
+	if err != nil {
+		return nil, err
+	}
+	for i, artist := range artists {
+		if following[i] {
+			plan.ArtistsPresent++
+			continue
+		}
+		plan.Artists = append(plan.Artists, artist)
+	}
+
+	current, err := client.GetPlaylists(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matcher := &playlistMatcher{client: client, userID: user.ID, current: current, claimed: make(map[spotify.ID]bool)}
+	for _, playlist := range source.Playlists {
+		action, err := matcher.plan(ctx, playlist, isOwnPlaylist(playlist, user), overwrite)
+		if err != nil {
+			return nil, err
+		}
+		plan.SkippedItems += len(playlist.Tracks) - len(playlistURIs(playlist))
+		plan.Playlists = append(plan.Playlists, action)
+	}
+
+	return plan, nil
+}
+
+// isOwnPlaylist reports whether a backed-up playlist belongs to user. Display
+// names are not unique, so the owner's ID decides; backups from before it was
+// recorded only have the display name to go by.
+func isOwnPlaylist(playlist restorePlaylist, user *spotify.PrivateUser) bool {
+	if playlist.OwnerID != "" {
+		return playlist.OwnerID == user.ID
+	}
+	return playlist.Owner == user.DisplayName || playlist.Owner == user.ID
+}
+
+// uniqueByID drops items with an empty or repeated ID, keeping order
+func uniqueByID[T any](items []T, id func(T) spotify.ID) []T {
+	seen := make(map[spotify.ID]bool, len(items))
+	out := make([]T, 0, len(items))
+	for _, item := range items {
+		key := id(item)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, item)
+	}
+	return out
+}
+
+// ids returns the IDs of items
+func ids[T any](items []T, id func(T) spotify.ID) []spotify.ID {
+	out := make([]spotify.ID, len(items))
+	for i, item := range items {
+		out[i] = id(item)
+	}
+	return out
+}
+
+// playlistURIs returns the restorable item URIs of a backed-up playlist, in order
+func playlistURIs(playlist restorePlaylist) []spotify.URI {
+	uris := make([]spotify.URI, 0, len(playlist.Tracks))
+	for _, item := range playlist.Tracks {
+		if uri := item.uri(); uri != "" {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
+}
+
+// playlistMatcher pairs backed-up playlists with the account's current playlists
+type playlistMatcher struct {
+	client  *spotifyclient.Client
+	userID  string
+	current []spotify.SimplePlaylist
+	// claimed holds current playlists already matched, so same-named backups map to distinct playlists
+	claimed map[spotify.ID]bool
+	items   map[spotify.ID][]spotify.URI
+}
+
+// plan decides how to restore one playlist
+func (m *playlistMatcher) plan(ctx context.Context, playlist restorePlaylist, own, overwrite bool) (playlistRestore, error) {
+	action := playlistRestore{Name: playlist.Name, SourceID: playlist.ID, Public: playlist.Public}
+
+	if !own {
+		action.TargetID = playlist.ID
+		action.Action = playlistFollow
+		for _, p := range m.current {
+			if p.ID == playlist.ID {
+				action.Action = playlistUnchanged
+				break
+			}
+		}
+		return action, nil
+	}
+
+	uris := playlistURIs(playlist)
+
+	// Prefer the same playlist, then same-named playlists that already match or only lack items
+	var candidates []spotify.SimplePlaylist
+	for _, p := range m.current {
+		if p.Owner.ID != m.userID || m.claimed[p.ID] {
+			continue
+		}
+		if p.ID == playlist.ID {
+			candidates = append([]spotify.SimplePlaylist{p}, candidates...)
+		} else if p.Name == playlist.Name {
+			candidates = append(candidates, p)
+		}
+	}
+
+	if len(candidates) == 0 {
+		action.Action = playlistCreate
+		action.URIs = uris
+		return action, nil
+	}
+
+	best, bestRank := -1, 3
+	var bestItems []spotify.URI
+	for i, candidate := range candidates {
+		existing, err := m.playlistItems(ctx, candidate.ID)
+		if err != nil {
+			return action, err
+		}
+		rank := 2
+		switch {
+		case slices.Equal(existing, uris):
+			rank = 0
+		case len(existing) < len(uris) && slices.Equal(existing, uris[:len(existing)]):
+			rank = 1
+		}
+		if rank < bestRank {
+			best, bestRank, bestItems = i, rank, existing
+		}
+		if rank == 0 {
+			break
+		}
+	}
+
+	target := candidates[best]
+	m.claimed[target.ID] = true
+	action.TargetID = target.ID
+	action.Existing = len(bestItems)
+
+	switch {
+	case bestRank == 0:
+		action.Action = playlistUnchanged
+	case bestRank == 1:
+		action.Action = playlistAppend
+		action.URIs = uris[len(bestItems):]
+	case overwrite:
+		action.Action = playlistReplace
+		action.URIs = uris
+	default:
+		action.Action = playlistDiffers
+	}
+	return action, nil
+}
+
+// playlistItems returns the restorable item URIs of a current playlist
+func (m *playlistMatcher) playlistItems(ctx context.Context, id spotify.ID) ([]spotify.URI, error) {
+	if uris, ok := m.items[id]; ok {
+		return uris, nil
+	}
+
+	items, err := m.client.GetPlaylistTracks(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	uris := make([]spotify.URI, 0, len(items))
+	for _, item := range items {
+		switch {
+		case item.IsLocal:
+		case item.Track.Track != nil:
+			uris = append(uris, item.Track.Track.URI)
+		case item.Track.Episode != nil:
+			uris = append(uris, item.Track.Episode.URI)
+		}
+	}
+
+	if m.items == nil {
+		m.items = make(map[spotify.ID][]spotify.URI)
+	}
+	m.items[id] = uris
+	return uris, nil
+}
+
+// printRestorePlan prints a diff-style preview of a restore plan
+func printRestorePlan(plan *restorePlan) {
+	fmt.Printf("Restore preview for backup %s:\n", plan.BackupID)
+	fmt.Println()
+
+	fmt.Printf("Saved tracks: %d to save, %d already saved\n", len(plan.Tracks), plan.TracksPresent)
+	for i, track := range plan.Tracks {
+		if i == restorePreviewLimit {
+			fmt.Printf("  ... and %d more\n", len(plan.Tracks)-i)
+			break
+		}
+		fmt.Printf("  + %s\n", diffItem{Name: track.Name, Artists: artistNames(track.Artists)}.displayName())
+	}
+
+	fmt.Printf("Followed artists: %d to follow, %d already followed\n", len(plan.Artists), plan.ArtistsPresent)
+	for i, artist := range plan.Artists {
+		if i == restorePreviewLimit {
+			fmt.Printf("  ... and %d more\n", len(plan.Artists)-i)
+			break
+		}
+		fmt.Printf("  + %s\n", artist.Name)
+	}
+
+	fmt.Printf("Playlists: %d\n", len(plan.Playlists))
+	for _, p := range plan.Playlists {
+		switch p.Action {
+		case playlistCreate:
+			fmt.Printf("  + create    %q (%d items)\n", p.Name, len(p.URIs))
+		case playlistAppend:
+			fmt.Printf("  ~ append    %q (+%d items to %d existing)\n", p.Name, len(p.URIs), p.Existing)
+		case playlistReplace:
+			fmt.Printf("  ! replace   %q (%d items replace %d)\n", p.Name, len(p.URIs), p.Existing)
+		case playlistFollow:
+			fmt.Printf("  + follow    %q\n", p.Name)
+		case playlistDiffers:
+			fmt.Printf("  ! differs   %q (use --overwrite to replace its %d items)\n", p.Name, p.Existing)
+		case playlistUnchanged:
+			fmt.Printf("  = unchanged %q\n", p.Name)
+		}
+	}
+
+	if plan.SkippedItems > 0 {
+		fmt.Printf("\nSkipping %d local or unavailable playlist items\n", plan.SkippedItems)
+	}
+}
+
+// applyRestorePlan writes a restore plan to the account
+func applyRestorePlan(ctx context.Context, client *spotifyclient.Client, plan *restorePlan) error {
+	if len(plan.Tracks) > 0 {
+		if err := client.SaveTracks(ctx, ids(plan.Tracks, func(t restoreTrack) spotify.ID { return t.ID })); err != nil {
+			return err
+		}
+		fmt.Printf("  Saved %d tracks\n", len(plan.Tracks))
+	}
+
+	if len(plan.Artists) > 0 {
+		if err := client.FollowArtists(ctx, ids(plan.Artists, func(a restoreArtist) spotify.ID { return a.ID })); err != nil {
+			return err
+		}
+		fmt.Printf("  Followed %d artists\n", len(plan.Artists))
+	}
+
+	for _, p := range plan.Playlists {
+		switch p.Action {
+		case playlistCreate:
+			description := fmt.Sprintf("Restored by Spotigo from backup %s", plan.BackupID)
+			created, err := client.CreatePlaylist(ctx, p.Name, description, p.Public)
+			if err != nil {
+				return err
+			}
+			if err := client.AddPlaylistItems(ctx, created.ID, p.URIs); err != nil {
+				return fmt.Errorf("playlist %q: %w", p.Name, err)
+			}
+			fmt.Printf("  Created playlist %q (%d items)\n", p.Name, len(p.URIs))
+		case playlistAppend:
+			if err := client.AddPlaylistItems(ctx, p.TargetID, p.URIs); err != nil {
+				return fmt.Errorf("playlist %q: %w", p.Name, err)
+			}
+			fmt.Printf("  Added %d items to playlist %q\n", len(p.URIs), p.Name)
+		case playlistReplace:
+			if err := client.ReplacePlaylistItems(ctx, p.TargetID, p.URIs); err != nil {
+				return fmt.Errorf("playlist %q: %w", p.Name, err)
+			}
+			fmt.Printf("  Replaced items of playlist %q (%d items)\n", p.Name, len(p.URIs))
+		case playlistFollow:
+			if err := client.FollowPlaylist(ctx, p.TargetID); err != nil {
+				return fmt.Errorf("playlist %q: %w", p.Name, err)
+			}
+			fmt.Printf("  Followed playlist %q\n", p.Name)
+		}
+	}
+
+	return nil
+}
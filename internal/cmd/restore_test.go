@@ -0,0 +1,350 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+	"golang.org/x/oauth2"
+
+	spotifyclient "github.com/bkataru/spotigo/internal/spotify"
+	"github.com/bkataru/spotigo/internal/storage"
+)
+
+// mockPlaylist is a playlist held by mockSpotify
+type mockPlaylist struct {
+	ID      string
+	Name    string
+	OwnerID string
+	Items   []string
+}
+
+// mockSpotify is a stateful fake of the Spotify Web API endpoints used by restore
+type mockSpotify struct {
+	mu        sync.Mutex
+	saved     map[string]bool
+	savedLog  []string
+	following map[string]bool
+	playlists []*mockPlaylist
+	writes    int
+}
+
+func (m *mockSpotify) playlist(id string) *mockPlaylist {
+	for _, p := range m.playlists {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+func (m *mockSpotify) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := strings.Split(r.URL.Query().Get("ids"), ",")
+	contains := func(set map[string]bool) {
+		result := make([]bool, len(ids))
+		for i, id := range ids {
+			result[i] = set[id]
+		}
+		_ = json.NewEncoder(w).Encode(result)
+	}
+	var body struct {
+		Name string   `json:"name"`
+		URIs []string `json:"uris"`
+	}
+	if r.Method != http.MethodGet {
+		m.writes++
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/me":
+		_, _ = w.Write([]byte(`{"id":"me","display_name":"Me"}`))
+	case r.URL.Path == "/me/tracks/contains":
+		contains(m.saved)
+	case r.URL.Path == "/me/tracks" && r.Method == http.MethodPut:
+		for _, id := range ids {
+			m.saved[id] = true
+			m.savedLog = append(m.savedLog, id)
+		}
+	case r.URL.Path == "/me/following/contains":
+		contains(m.following)
+	case r.URL.Path == "/me/following" && r.Method == http.MethodPut:
+		for _, id := range ids {
+			m.following[id] = true
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == "/me/playlists":
+		items := make([]map[string]interface{}, len(m.playlists))
+		for i, p := range m.playlists {
+			items[i] = map[string]interface{}{
+				"id": p.ID, "name": p.Name,
+				"owner":  map[string]string{"id": p.OwnerID},
+				"tracks": map[string]int{"total": len(p.Items)},
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"items": items, "total": len(items)})
+	case r.URL.Path == "/users/me/playlists" && r.Method == http.MethodPost:
+		p := &mockPlaylist{ID: "new" + body.Name, Name: body.Name, OwnerID: "me"}
+		m.playlists = append(m.playlists, p)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]string{"id": p.ID, "name": p.Name})
+	case len(parts) == 3 && parts[0] == "playlists" && parts[2] == "followers":
+		m.playlists = append(m.playlists, &mockPlaylist{ID: parts[1], Name: "Followed", OwnerID: "spotify"})
+	case len(parts) == 3 && parts[0] == "playlists" && parts[2] == "tracks":
+		p := m.playlist(parts[1])
+		if p == nil {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			items := make([]map[string]interface{}, len(p.Items))
+			for i, uri := range p.Items {
+				typ := strings.Split(uri, ":")[1]
+				items[i] = map[string]interface{}{"track": map[string]string{"type": typ, "uri": uri}}
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"items": items, "total": len(items)})
+		case http.MethodPost:
+			p.Items = append(p.Items, body.URIs...)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"snapshot_id":"s"}`))
+		case http.MethodPut:
+			p.Items = append([]string{}, body.URIs...)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"snapshot_id":"s"}`))
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func restoreTestTrack(id string) spotify.FullTrack {
+	return spotify.FullTrack{SimpleTrack: spotify.SimpleTrack{
+		ID: spotify.ID(id), Name: "Track " + id, URI: spotify.URI("spotify:track:" + id),
+	}}
+}
+
+func restoreTestItems(ids ...string) []spotify.PlaylistItem {
+	items := make([]spotify.PlaylistItem, len(ids))
+	for i, id := range ids {
+		track := restoreTestTrack(id)
+		items[i] = spotify.PlaylistItem{Track: spotify.PlaylistItemTrack{Track: &track}}
+	}
+	return items
+}
+
+// setupRestoreTest creates a backup and a client pointed at a mock Spotify account
+func setupRestoreTest(t *testing.T) (*mockSpotify, *spotifyclient.Client, *storage.Store, string) {
+	t.Helper()
+
+	roadTrip := restoreTestItems("t1")
+	roadTrip = append(roadTrip,
+		spotify.PlaylistItem{Track: spotify.PlaylistItemTrack{Episode: &spotify.EpisodePage{ID: "e1", URI: "spotify:episode:e1"}}},
+		spotify.PlaylistItem{IsLocal: true, Track: spotify.PlaylistItemTrack{Track: &spotify.FullTrack{SimpleTrack: spotify.SimpleTrack{URI: "spotify:local:a:b:c:1"}}}},
+		spotify.PlaylistItem{},
+	)
+
+	data := map[string]interface{}{
+		"saved_tracks": []spotify.SavedTrack{
+			{FullTrack: restoreTestTrack("t3")},
+			{FullTrack: restoreTestTrack("t2")},
+			{FullTrack: restoreTestTrack("t1")},
+		},
+		"followed_artists": []spotify.FullArtist{
+			{SimpleArtist: spotify.SimpleArtist{ID: "a1", Name: "Artist 1"}},
+			{SimpleArtist: spotify.SimpleArtist{ID: "a2", Name: "Artist 2"}},
+		},
+		"playlists": []playlistData{
+			{ID: "proad", Name: "Road Trip", Owner: "Me", OwnerID: "me", Tracks: roadTrip},
+			{ID: "pchill", Name: "Chill", Owner: "Me", OwnerID: "me", Tracks: restoreTestItems("t1", "t2", "t3")},
+			// Backups from before owner IDs were recorded
+			{ID: "pold", Name: "Mixed", Owner: "Me", Tracks: restoreTestItems("t1", "t2")},
+			{ID: "pdisc", Name: "Discover Weekly", Owner: "Spotify", OwnerID: "spotify", Tracks: restoreTestItems("t9")},
+		},
+	}
+
+	dir := t.TempDir()
+	store := storage.NewStore(filepath.Join(dir, "data"), filepath.Join(dir, "backups"))
+	backup, err := store.CreateBackup("full", data)
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+
+	mock := &mockSpotify{
+		saved:     map[string]bool{"t2": true},
+		following: map[string]bool{"a1": true},
+		playlists: []*mockPlaylist{
+			{ID: "pchill", Name: "Chill", OwnerID: "me", Items: []string{"spotify:track:t1"}},
+			{ID: "pmixed", Name: "Mixed", OwnerID: "me", Items: []string{"spotify:track:t3"}},
+		},
+	}
+	server := httptest.NewServer(mock)
+	t.Cleanup(server.Close)
+
+	token, err := json.Marshal(&oauth2.Token{AccessToken: "token", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenFile := filepath.Join(dir, "token.json")
+	if err := os.WriteFile(tokenFile, token, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := spotifyclient.NewClient(spotifyclient.Config{
+		ClientID:   "id",
+		TokenFile:  tokenFile,
+		APIBaseURL: server.URL + "/",
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if !client.IsAuthenticated() {
+		t.Fatal("client not authenticated with test token")
+	}
+
+	return mock, client, store, backup.ID
+}
+
+func TestRestoreBackupToSpotify(t *testing.T) {
+	mock, client, store, backupID := setupRestoreTest(t)
+	ctx := context.Background()
+
+	if err := restoreBackupToSpotify(ctx, client, store, backupID, restoreOptions{}, strings.NewReader("y\n")); err != nil {
+		t.Fatalf("restoreBackupToSpotify() error = %v", err)
+	}
+
+	// Missing tracks are saved oldest first
+	if !slices.Equal(mock.savedLog, []string{"t1", "t3"}) {
+		t.Errorf("saved tracks = %v", mock.savedLog)
+	}
+	if !mock.following["a2"] {
+		t.Error("artist a2 not followed")
+	}
+
+	wantItems := map[string][]string{
+		"newRoad Trip": {"spotify:track:t1", "spotify:episode:e1"},
+		"pchill":       {"spotify:track:t1", "spotify:track:t2", "spotify:track:t3"},
+		"pmixed":       {"spotify:track:t3"},
+		"pdisc":        nil,
+	}
+	for id, want := range wantItems {
+		p := mock.playlist(id)
+		if p == nil {
+			t.Errorf("playlist %s missing", id)
+			continue
+		}
+		if !slices.Equal(p.Items, want) {
+			t.Errorf("playlist %s items = %v, want %v", id, p.Items, want)
+		}
+	}
+
+	// A second run finds nothing left to do
+	var source restoreSource
+	if err := store.LoadBackupJSON(backupID, &source); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := buildRestorePlan(ctx, client, source, false)
+	if err != nil {
+		t.Fatalf("buildRestorePlan() error = %v", err)
+	}
+	if plan.changes() != 0 {
+		t.Errorf("second run plans %d changes: %+v", plan.changes(), plan)
+	}
+	if plan.TracksPresent != 3 || plan.ArtistsPresent != 2 || plan.SkippedItems != 2 {
+		t.Errorf("unexpected plan counts: %+v", plan)
+	}
+	if got := plan.Playlists[2].Action; got != playlistDiffers {
+		t.Errorf("Mixed action = %s, want %s", got, playlistDiffers)
+	}
+
+	// --overwrite replaces the playlist that differs
+	if err := restoreBackupToSpotify(ctx, client, store, backupID, restoreOptions{Yes: true, Overwrite: true}, nil); err != nil {
+		t.Fatalf("restoreBackupToSpotify(overwrite) error = %v", err)
+	}
+	if items := mock.playlist("pmixed").Items; !slices.Equal(items, []string{"spotify:track:t1", "spotify:track:t2"}) {
+		t.Errorf("Mixed items after overwrite = %v", items)
+	}
+	if len(mock.playlists) != 4 {
+		t.Errorf("expected 4 playlists, got %d", len(mock.playlists))
+	}
+}
+
+func TestRestoreBackupToSpotify_NoWrites(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  restoreOptions
+		input string
+	}{
+		{"dry run", restoreOptions{DryRun: true}, "y\n"},
+		{"declined", restoreOptions{}, "n\n"},
+		{"no input", restoreOptions{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, client, store, backupID := setupRestoreTest(t)
+			if err := restoreBackupToSpotify(context.Background(), client, store, backupID, tt.opts, strings.NewReader(tt.input)); err != nil {
+				t.Fatalf("restoreBackupToSpotify() error = %v", err)
+			}
+			if mock.writes != 0 {
+				t.Errorf("expected no writes, got %d", mock.writes)
+			}
+		})
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{"yes", true},
+		{"n\n", false},
+		{"\n", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := confirm(strings.NewReader(tt.input), ""); got != tt.want {
+			t.Errorf("confirm(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestIsOwnPlaylist(t *testing.T) {
+	user := &spotify.PrivateUser{User: spotify.User{ID: "me", DisplayName: "Alex"}}
+	tests := []struct {
+		name     string
+		playlist restorePlaylist
+		want     bool
+	}{
+		{"owner ID", restorePlaylist{Owner: "Alex", OwnerID: "me"}, true},
+		{"renamed owner", restorePlaylist{Owner: "Old Name", OwnerID: "me"}, true},
+		{"same display name, other user", restorePlaylist{Owner: "Alex", OwnerID: "someone"}, false},
+		{"legacy display name", restorePlaylist{Owner: "Alex"}, true},
+		{"legacy other user", restorePlaylist{Owner: "Spotify"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isOwnPlaylist(tt.playlist, user); got != tt.want {
+				t.Errorf("isOwnPlaylist() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
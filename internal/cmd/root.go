@@ -13,9 +13,10 @@ import (
 )
 
 var (
-	cfgFile string
-	tuiMode bool
-	cfg     *config.Config
+	cfgFile     string
+	profileName string
+	tuiMode     bool
+	cfg         *config.Config
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -58,6 +59,7 @@ func Execute() error {
 func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.spotigo.yaml)")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "profile to use for a separate Spotify account (default from SPOTIGO_PROFILE or the profile setting)")
 	rootCmd.PersistentFlags().BoolVar(&tuiMode, "tui", false, "launch in TUI mode")
 	rootCmd.PersistentFlags().Bool("verbose", false, "enable verbose output")
 
@@ -73,11 +75,15 @@ func init() {
 	rootCmd.AddCommand(statsCmd)
 	rootCmd.AddCommand(authCmd)
 	rootCmd.AddCommand(modelsCmd)
+	rootCmd.AddCommand(daemonCmd)
+	rootCmd.AddCommand(profilesCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(recordCmd)
 }
 
 func initConfig() error {
 	var err error
-	cfg, err = config.Load(cfgFile)
+	cfg, err = config.LoadProfile(cfgFile, profileName)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
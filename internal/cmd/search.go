@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -10,6 +11,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/bkataru/spotigo/internal/config"
+	"github.com/bkataru/spotigo/internal/history"
 	"github.com/bkataru/spotigo/internal/jsonutil"
 	"github.com/bkataru/spotigo/internal/ollama"
 	"github.com/bkataru/spotigo/internal/rag"
@@ -37,12 +39,29 @@ Run 'spotigo search index' first to build the search index from your backups.`,
 
 var searchIndexCmd = &cobra.Command{
 	Use:   "index",
-	Short: "Build or rebuild the search index from backup data",
+	Short: "Build or update the search index from backup data",
+	Long: `Build the search index from your backups, or bring it up to date.
+
+Only items that are new or whose text has changed since the last run are
+embedded; items that are no longer in the backups are removed from the index.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		runSearchIndex()
 	},
 }
 
+var searchReindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Re-embed the search index with another model",
+	Long: `Re-embed the documents in the search index whose embeddings came from
+another model than --model, or are missing. Documents already embedded by
+the model are kept, so an interrupted reindex carries on where it stopped.
+
+  spotigo search reindex --model mxbai-embed-large`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runSearchReindex()
+	},
+}
+
 var searchStatusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show search index status",
@@ -51,6 +70,9 @@ var searchStatusCmd = &cobra.Command{
 	},
 }
 
+// defaultEmbeddingModel embeds the search index unless --model says otherwise
+const defaultEmbeddingModel = "nomic-embed-text"
+
 var (
 	searchLimit  int
 	searchType   string
@@ -60,11 +82,12 @@ var (
 
 func init() {
 	searchCmd.Flags().IntVar(&searchLimit, "limit", 10, "maximum number of results")
-	searchCmd.Flags().StringVar(&searchType, "type", "all", "search type: all, tracks, artists, playlists")
+	searchCmd.Flags().StringVar(&searchType, "type", "all", "search type: all, tracks, artists, playlists, albums, shows, episodes, played")
 	searchCmd.Flags().StringVar(&searchFormat, "format", "table", "output format: table, json")
-	searchCmd.Flags().StringVar(&searchModel, "model", "nomic-embed-text", "embedding model to use")
+	searchCmd.PersistentFlags().StringVar(&searchModel, "model", defaultEmbeddingModel, "embedding model to use")
 
 	searchCmd.AddCommand(searchIndexCmd)
+	searchCmd.AddCommand(searchReindexCmd)
 	searchCmd.AddCommand(searchStatusCmd)
 }
 
@@ -76,7 +99,7 @@ func runSearch(query string) {
 	}
 
 	// Create Ollama client
-	client := ollama.NewClient(cfg.Ollama.Host, time.Duration(cfg.Ollama.Timeout)*time.Second)
+	client := newEmbedClient(cfg)
 
 	// Check if Ollama is available
 	ctx := context.Background()
@@ -89,8 +112,8 @@ func runSearch(query string) {
 	}
 
 	// Load the vector store
-	storePath := filepath.Join(cfg.Storage.EmbeddingsDir, "vectors.json")
-	store := rag.NewStore(client, searchModel, storePath)
+	store := newSearchStore(cfg, client, searchModel)
+	defer store.Close()
 
 	if err := store.Load(); err != nil {
 		fmt.Printf("Error loading search index: %v\n", err)
@@ -112,16 +135,30 @@ func runSearch(query string) {
 
 	// Map type flag to document type
 	docType := searchType
-	if docType == "tracks" {
+	switch docType {
+	case "tracks":
 		docType = "track"
-	} else if docType == "artists" {
+	case "artists":
 		docType = "artist"
-	} else if docType == "playlists" {
+	case "playlists":
 		docType = "playlist"
+	case "albums":
+		docType = "album"
+	case "shows":
+		docType = "show"
+	case "episodes":
+		docType = "episode"
 	}
 
 	// Perform search
 	results, err := store.Search(ctx, query, searchLimit, docType)
+	if errors.Is(err, rag.ErrModelMismatch) || errors.Is(err, rag.ErrDimensionMismatch) {
+		fmt.Printf("Error: the search index was built with %s, not %s\n", store.Model(), searchModel)
+		fmt.Println()
+		fmt.Printf("Search with --model %s, or run 'spotigo search reindex --model %s'\n", store.Model(), searchModel)
+		fmt.Println("to re-embed the index with the new model.")
+		return
+	}
 	if err != nil {
 		fmt.Printf("Error searching: %v\n", err)
 		return
@@ -166,6 +203,25 @@ func displaySearchResult(rank int, result rag.SearchResult) {
 			fmt.Printf("         %s\n", desc)
 		}
 		fmt.Printf("         %s tracks\n", doc.Metadata["track_count"])
+	case "album":
+		fmt.Printf("%2d. [%.0f%%] 💿 %s\n", rank, similarity, doc.Metadata["name"])
+		fmt.Printf("         by %s\n", doc.Metadata["artists"])
+	case "show":
+		fmt.Printf("%2d. [%.0f%%] 🎙️ %s\n", rank, similarity, doc.Metadata["name"])
+		if doc.Metadata["publisher"] != "" {
+			fmt.Printf("         by %s\n", doc.Metadata["publisher"])
+		}
+	case "episode":
+		fmt.Printf("%2d. [%.0f%%] 📻 %s\n", rank, similarity, doc.Metadata["name"])
+		if doc.Metadata["show"] != "" {
+			fmt.Printf("         from %s\n", doc.Metadata["show"])
+		}
+	case "played":
+		fmt.Printf("%2d. [%.0f%%] 🎧 %s\n", rank, similarity, doc.Metadata["name"])
+		if doc.Metadata["artists"] != "" {
+			fmt.Printf("         by %s\n", doc.Metadata["artists"])
+		}
+		fmt.Printf("         played %s times, last on %s\n", doc.Metadata["plays"], doc.Metadata["last_played"])
 	default:
 		fmt.Printf("%2d. [%.0f%%] %s: %s\n", rank, similarity, doc.Type, doc.Content)
 	}
@@ -180,7 +236,7 @@ func runSearchIndex() {
 	}
 
 	// Create Ollama client
-	client := ollama.NewClient(cfg.Ollama.Host, time.Duration(cfg.Ollama.Timeout)*time.Second)
+	client := newEmbedClient(cfg)
 
 	// Check if Ollama is available
 	ctx := context.Background()
@@ -197,9 +253,16 @@ func runSearchIndex() {
 	fmt.Printf("  Using model: %s\n", searchModel)
 	fmt.Println()
 
-	// Load the vector store
-	storePath := filepath.Join(cfg.Storage.EmbeddingsDir, "vectors.json")
-	store := rag.NewStore(client, searchModel, storePath)
+	// Load the vector store, so only new and changed items are embedded
+	store := newSearchStore(cfg, client, searchModel)
+	defer store.Close()
+	if err := store.Load(); err != nil {
+		fmt.Printf("  Warning: rebuilding the search index: %v\n", err)
+		store.Clear()
+	}
+	if previous := store.Model(); previous != "" && previous != searchModel {
+		fmt.Printf("  The index was built with %s; every item will be re-embedded\n", previous)
+	}
 
 	// Load backup data and create documents
 	docs := loadBackupDocuments(cfg)
@@ -212,29 +275,16 @@ func runSearchIndex() {
 	fmt.Printf("Found %d items to index\n", len(docs))
 	fmt.Println()
 
-	// Add documents with progress
-	for i, doc := range docs {
-		if err := store.Add(ctx, doc); err != nil {
-			fmt.Printf("  Warning: failed to index %s: %v\n", doc.ID, err)
-			continue
-		}
-
-		// Show progress every 10 items
-		if (i+1)%10 == 0 || i+1 == len(docs) {
-			fmt.Printf("  Indexed %d/%d items...\r", i+1, len(docs))
-		}
-	}
-	fmt.Println()
-
-	// Save the store
-	if err := store.Save(); err != nil {
+	result, err := updateSearchIndex(ctx, store, docs)
+	if err != nil {
 		fmt.Printf("Error saving index: %v\n", err)
 		return
 	}
 
 	fmt.Println()
 	fmt.Printf("✅ Search index built successfully!\n")
-	fmt.Printf("   Indexed %d items\n", store.Count())
+	fmt.Printf("   Indexed %d items (%d new, %d changed, %d unchanged, %d removed)\n",
+		store.Count(), result.Added, result.Updated, result.Unchanged, result.Removed)
 
 	counts := store.CountByType()
 	for typ, count := range counts {
@@ -242,6 +292,32 @@ func runSearchIndex() {
 	}
 }
 
+// indexConcurrency is how many items are embedded at once
+const indexConcurrency = 4
+
+// updateSearchIndex makes the store hold docs, embedding only new and
+// changed ones, and saves it. Items that fail to embed are reported and
+// left for the next run.
+func updateSearchIndex(ctx context.Context, store *rag.Store, docs []rag.Document) (rag.UpdateResult, error) {
+	progress := func(done, total int) {
+		if done%10 == 0 || done == total {
+			fmt.Printf("  Embedded %d/%d items...\r", done, total)
+		}
+	}
+	result, err := store.Update(ctx, docs, indexConcurrency, progress)
+	if result.Embedded() > 0 {
+		fmt.Println()
+	}
+	if err != nil {
+		fmt.Printf("  Warning: %v\n", err)
+	}
+
+	if err := store.Save(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
 func loadBackupDocuments(cfg *config.Config) []rag.Document {
 	var docs []rag.Document
 
@@ -272,6 +348,49 @@ func loadBackupDocuments(cfg *config.Config) []rag.Document {
 		}
 	}
 
+	// Load saved albums
+	albumsPath := filepath.Join(cfg.Storage.DataDir, "saved_albums.json")
+	albums, err := loadAlbumsFromFile(albumsPath)
+	if err == nil {
+		for _, album := range albums {
+			docs = append(docs, rag.AlbumToDocument(album))
+		}
+	}
+
+	// Load followed podcasts
+	showsPath := filepath.Join(cfg.Storage.DataDir, "saved_shows.json")
+	shows, err := loadShowsFromFile(showsPath)
+	if err == nil {
+		for _, show := range shows {
+			docs = append(docs, rag.ShowToDocument(show))
+		}
+	}
+
+	// Load saved episodes
+	episodesPath := filepath.Join(cfg.Storage.DataDir, "saved_episodes.json")
+	episodes, err := loadEpisodesFromFile(episodesPath)
+	if err == nil {
+		for _, episode := range episodes {
+			docs = append(docs, rag.EpisodeToDocument(episode))
+		}
+	}
+
+	// Load listening history, one document per played item
+	if plays, err := loadPlayHistory(cfg); err == nil {
+		for _, played := range history.Summarize(plays) {
+			docs = append(docs, rag.PlayedToDocument(rag.PlayedData{
+				ID:          played.ID,
+				Type:        played.Type,
+				Name:        played.Name,
+				Artist:      played.Artist,
+				Album:       played.Album,
+				Plays:       played.Plays,
+				FirstPlayed: played.FirstPlayed,
+				LastPlayed:  played.LastPlayed,
+			}))
+		}
+	}
+
 	return docs
 }
 
@@ -368,6 +487,93 @@ func loadPlaylistsFromFile(path string) ([]rag.PlaylistData, error) {
 	return playlists, nil
 }
 
+func loadAlbumsFromFile(path string) ([]rag.AlbumData, error) {
+	var rawAlbums []map[string]interface{}
+	if err := jsonutil.LoadJSONFile(path, &rawAlbums); err != nil {
+		return nil, err
+	}
+
+	var albums []rag.AlbumData
+	for _, raw := range rawAlbums {
+		// spotify.SavedAlbum nests the album under "album"
+		albumData := raw
+		if a, ok := raw["album"].(map[string]interface{}); ok {
+			albumData = a
+		}
+
+		album := rag.AlbumData{
+			ID:          jsonutil.GetString(albumData, "id"),
+			Name:        jsonutil.GetString(albumData, "name"),
+			Artists:     jsonutil.GetArtistNames(albumData),
+			Genres:      jsonutil.GetStringSlice(albumData, "genres"),
+			ReleaseDate: jsonutil.GetString(albumData, "release_date"),
+		}
+
+		if album.ID != "" && album.Name != "" {
+			albums = append(albums, album)
+		}
+	}
+
+	return albums, nil
+}
+
+func loadShowsFromFile(path string) ([]rag.ShowData, error) {
+	var rawShows []map[string]interface{}
+	if err := jsonutil.LoadJSONFile(path, &rawShows); err != nil {
+		return nil, err
+	}
+
+	var shows []rag.ShowData
+	for _, raw := range rawShows {
+		// spotify.SavedShow nests the show under "show"
+		showData := raw
+		if s, ok := raw["show"].(map[string]interface{}); ok {
+			showData = s
+		}
+
+		show := rag.ShowData{
+			ID:          jsonutil.GetString(showData, "id"),
+			Name:        jsonutil.GetString(showData, "name"),
+			Publisher:   jsonutil.GetString(showData, "publisher"),
+			Description: jsonutil.GetString(showData, "description"),
+		}
+
+		if show.ID != "" && show.Name != "" {
+			shows = append(shows, show)
+		}
+	}
+
+	return shows, nil
+}
+
+func loadEpisodesFromFile(path string) ([]rag.EpisodeData, error) {
+	var rawEpisodes []map[string]interface{}
+	if err := jsonutil.LoadJSONFile(path, &rawEpisodes); err != nil {
+		return nil, err
+	}
+
+	var episodes []rag.EpisodeData
+	for _, raw := range rawEpisodes {
+		episodeData := raw
+		if e, ok := raw["episode"].(map[string]interface{}); ok {
+			episodeData = e
+		}
+
+		episode := rag.EpisodeData{
+			ID:          jsonutil.GetString(episodeData, "id"),
+			Name:        jsonutil.GetString(episodeData, "name"),
+			Show:        jsonutil.GetNestedString(episodeData, "show", "name"),
+			Description: jsonutil.GetString(episodeData, "description"),
+		}
+
+		if episode.ID != "" && episode.Name != "" {
+			episodes = append(episodes, episode)
+		}
+	}
+
+	return episodes, nil
+}
+
 func runSearchStatus() {
 	cfg := GetConfig()
 	if cfg == nil {
@@ -375,8 +581,8 @@ func runSearchStatus() {
 		return
 	}
 
-	storePath := filepath.Join(cfg.Storage.EmbeddingsDir, "vectors.json")
-	store := rag.NewStore(nil, "", storePath)
+	store := newSearchStore(cfg, nil, "")
+	defer store.Close()
 
 	if err := store.Load(); err != nil {
 		fmt.Printf("Error loading search index: %v\n", err)
@@ -404,5 +610,91 @@ func runSearchStatus() {
 	}
 	fmt.Println()
 
-	fmt.Printf("Index location: %s\n", storePath)
+	if store.Model() != "" {
+		fmt.Printf("Embedding model: %s (%d dimensions)\n", store.Model(), store.Dimensions())
+	}
+	if stale := len(store.StaleDocuments()); stale > 0 {
+		fmt.Printf("Stale documents: %d (run 'spotigo search reindex' to re-embed them)\n", stale)
+	}
+	fmt.Printf("Index location: %s\n", searchIndexPath(cfg))
+}
+
+func runSearchReindex() {
+	cfg := GetConfig()
+	if cfg == nil {
+		fmt.Println("Error: Configuration not loaded")
+		return
+	}
+
+	client := newEmbedClient(cfg)
+	ctx := context.Background()
+	if err := client.Ping(ctx); err != nil {
+		fmt.Println("Error: Ollama is not available")
+		fmt.Printf("  %v\n", err)
+		return
+	}
+
+	store := newSearchStore(cfg, client, searchModel)
+	defer store.Close()
+	if err := store.Load(); err != nil {
+		fmt.Printf("Error loading search index: %v\n", err)
+		return
+	}
+	if store.Count() == 0 {
+		fmt.Println("Search index is empty.")
+		fmt.Println("Run 'spotigo search index' to build it.")
+		return
+	}
+
+	if previous := store.Model(); previous != "" && previous != searchModel {
+		fmt.Printf("Re-embedding the search index from %s to %s...\n", previous, searchModel)
+	} else {
+		fmt.Printf("Re-embedding stale documents with %s...\n", searchModel)
+	}
+
+	n, err := store.Reindex(ctx, 4)
+	if err != nil {
+		fmt.Printf("  Warning: %v\n", err)
+	}
+	if n == 0 {
+		fmt.Println("✅ Search index is up to date")
+		return
+	}
+
+	if err := store.Save(); err != nil {
+		fmt.Printf("Error saving index: %v\n", err)
+		return
+	}
+	fmt.Printf("✅ Re-embedded %d of %d documents\n", n-len(store.StaleDocuments()), store.Count())
+}
+
+// searchIndexModel returns the model the search index was built with, or
+// the default model if there is no index yet
+func searchIndexModel(cfg *config.Config) string {
+	store := newSearchStore(cfg, nil, "")
+	defer store.Close()
+	if err := store.Load(); err != nil || store.Model() == "" {
+		return defaultEmbeddingModel
+	}
+	return store.Model()
+}
+
+// newEmbedClient returns an Ollama client for embedding the search index
+func newEmbedClient(cfg *config.Config) *ollama.Client {
+	client := ollama.NewClient(cfg.Ollama.Host, time.Duration(cfg.Ollama.Timeout)*time.Second)
+	client.SetEmbedBatchSize(cfg.Ollama.EmbedBatchSize)
+	return client
+}
+
+// searchIndexPath returns where the search index is stored
+func searchIndexPath(cfg *config.Config) string {
+	return filepath.Join(cfg.Storage.EmbeddingsDir, "vectors.bin")
+}
+
+// newSearchStore returns the search index store. Its Load migrates the
+// vectors.json written by earlier versions, which the next Save replaces.
+func newSearchStore(cfg *config.Config, client *ollama.Client, model string) *rag.Store {
+	store := rag.NewStore(client, model, searchIndexPath(cfg))
+	store.SetQuantize(cfg.Storage.QuantizeEmbeddings)
+	return store
 }
@@ -5,10 +5,12 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/bkataru/spotigo/internal/config"
+	"github.com/bkataru/spotigo/internal/history"
 	"github.com/bkataru/spotigo/internal/jsonutil"
 )
 
@@ -18,13 +20,17 @@ var statsCmd = &cobra.Command{
 	Long: `View detailed statistics about your music library.
 
 Available statistics:
-  - Library overview (tracks, playlists, artists)
+  - Library overview (tracks, playlists, artists, albums, podcasts)
   - Top artists by track count
   - Genre distribution
   - Playlist analysis
 
 Statistics are calculated from your backup data.
-Run 'spotigo backup' first to generate statistics.`,
+Run 'spotigo backup' first to generate statistics.
+
+With a play history imported by 'spotigo history import' or recorded by
+'spotigo record', 'stats top --source plays' ranks artists, tracks and
+albums by plays within --period instead.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		runStats()
 	},
@@ -33,11 +39,20 @@ Run 'spotigo backup' first to generate statistics.`,
 var (
 	statsPeriod string
 	statsTop    int
+	statsSource string
+)
+
+// Sources of the rankings shown by stats top
+const (
+	statsSourceLibrary = "library"
+	statsSourcePlays   = "plays"
 )
 
 func init() {
-	statsCmd.Flags().StringVar(&statsPeriod, "period", "all", "time period: all, year, month, week")
-	statsCmd.Flags().IntVar(&statsTop, "top", 10, "number of top items to show")
+	statsCmd.PersistentFlags().StringVar(&statsPeriod, "period", "all", "time period: all, year, month, week")
+	statsCmd.PersistentFlags().IntVar(&statsTop, "top", 10, "number of top items to show")
+
+	statsTopCmd.Flags().StringVar(&statsSource, "source", statsSourceLibrary, "rank by: library (saved tracks and Spotify's top items) or plays (play history)")
 
 	// Subcommands for specific stats
 	statsCmd.AddCommand(statsTopCmd)
@@ -80,6 +95,9 @@ type LibraryStats struct {
 	TotalPlaylists int
 	TotalArtists   int
 	UniqueAlbums   int
+	SavedAlbums    int
+	SavedShows     int
+	SavedEpisodes  int
 	TopArtists     []ArtistCount
 	TopGenres      []GenreCount
 	PlaylistStats  []PlaylistStat
@@ -127,6 +145,9 @@ func runStats() {
 	fmt.Printf("  Total Playlists: %d\n", stats.TotalPlaylists)
 	fmt.Printf("  Unique Artists:  %d\n", stats.TotalArtists)
 	fmt.Printf("  Unique Albums:   %d\n", stats.UniqueAlbums)
+	fmt.Printf("  Saved Albums:    %d\n", stats.SavedAlbums)
+	fmt.Printf("  Podcasts:        %d\n", stats.SavedShows)
+	fmt.Printf("  Saved Episodes:  %d\n", stats.SavedEpisodes)
 	fmt.Println()
 
 	if len(stats.TopArtists) > 0 {
@@ -153,6 +174,20 @@ func runStats() {
 		fmt.Println()
 	}
 
+	if plays, err := loadPlayHistory(cfg); err == nil && len(plays) > 0 {
+		recent := history.Since(plays, periodStart(statsPeriod, time.Now()))
+		fmt.Printf("Listening History (%s):\n", periodLabel(statsPeriod))
+		printPlaySummary(recent)
+
+		if top := history.Top(recent, playArtist); len(top) > 0 {
+			fmt.Println("Top 5 Artists (by plays):")
+			for i, artist := range top[:jsonutil.Min(5, len(top))] {
+				fmt.Printf("  %d. %s (%d plays)\n", i+1, artist.Name, artist.Plays)
+			}
+			fmt.Println()
+		}
+	}
+
 	fmt.Println("Run 'spotigo stats top' for detailed rankings.")
 	fmt.Println("Run 'spotigo stats genres' for full genre breakdown.")
 	fmt.Println("Run 'spotigo stats playlists' for playlist analysis.")
@@ -165,6 +200,29 @@ func runStatsTop(itemType string) {
 		return
 	}
 
+	switch statsSource {
+	case statsSourcePlays:
+		plays, err := loadPlayHistory(cfg)
+		if err != nil {
+			fmt.Printf("Error reading play history: %v\n", err)
+			return
+		}
+		if len(plays) == 0 {
+			fmt.Println("No play history found.")
+			fmt.Println("Import it with 'spotigo history import' or record it with 'spotigo record'.")
+			return
+		}
+		fmt.Printf("Source: play history (%d plays)\n\n", len(plays))
+		runStatsTopPlays(plays, itemType)
+		return
+	case statsSourceLibrary:
+		fmt.Println("Source: library backup (use --source plays to rank by play history)")
+		fmt.Println()
+	default:
+		fmt.Printf("Error: unknown source %q (use library or plays)\n", statsSource)
+		return
+	}
+
 	stats, err := computeLibraryStats(cfg)
 	if err != nil {
 		fmt.Println("Error computing statistics:", err)
@@ -192,8 +250,20 @@ func runStatsTop(itemType string) {
 		}
 
 	case "tracks":
-		fmt.Println("Top tracks by play count is not available from backup data.")
-		fmt.Println("Spotify's 'Top Tracks' feature requires real-time API access.")
+		timeRange := topRangeForPeriod(statsPeriod)
+		tracks, err := loadTopTrackNames(cfg, timeRange)
+		if err != nil || len(tracks) == 0 {
+			fmt.Println("No top track data available.")
+			fmt.Println("Run 'spotigo backup --type top' to capture your top tracks.")
+			return
+		}
+
+		fmt.Printf("Top %d Tracks (%s term, from Spotify):\n", jsonutil.Min(statsTop, len(tracks)), timeRange)
+		fmt.Println()
+
+		for i, name := range tracks[:jsonutil.Min(statsTop, len(tracks))] {
+			fmt.Printf("%3d. %s\n", i+1, name)
+		}
 
 	case "albums":
 		fmt.Printf("Top %d Albums (by saved tracks):\n", statsTop)
@@ -221,6 +291,62 @@ func runStatsTop(itemType string) {
 	}
 }
 
+// runStatsTopPlays ranks artists, tracks or albums by plays within --period
+func runStatsTopPlays(plays []history.Play, itemType string) {
+	var key func(history.Play) string
+	switch itemType {
+	case "artists":
+		key = playArtist
+	case "tracks":
+		key = playTrack
+	case "albums":
+		key = playAlbum
+	default:
+		fmt.Printf("Unknown type: %s\n", itemType)
+		fmt.Println("Available types: artists, tracks, albums")
+		return
+	}
+
+	label := periodLabel(statsPeriod)
+	top := history.Top(history.Since(plays, periodStart(statsPeriod, time.Now())), key)
+	if len(top) == 0 {
+		fmt.Printf("No plays found (%s).\n", label)
+		return
+	}
+
+	limit := jsonutil.Min(statsTop, len(top))
+	title := strings.ToUpper(itemType[:1]) + itemType[1:]
+	fmt.Printf("Top %d %s (by plays, %s):\n", limit, title, label)
+	fmt.Println()
+
+	for i, item := range top[:limit] {
+		hours := time.Duration(item.MsPlayed * int64(time.Millisecond)).Hours()
+		fmt.Printf("%3d. %-40s %5d plays %6.1fh\n", i+1, jsonutil.Truncate(item.Name, 40), item.Plays, hours)
+	}
+}
+
+// playArtist, playTrack and playAlbum group track plays for rankings
+func playArtist(p history.Play) string {
+	if p.Type != "track" {
+		return ""
+	}
+	return p.Artist
+}
+
+func playTrack(p history.Play) string {
+	if p.Type != "track" || p.Artist == "" {
+		return ""
+	}
+	return p.Name + " - " + p.Artist
+}
+
+func playAlbum(p history.Play) string {
+	if p.Type != "track" {
+		return ""
+	}
+	return p.Album
+}
+
 func runStatsGenres() {
 	cfg := GetConfig()
 	if cfg == nil {
@@ -396,8 +522,20 @@ func computeLibraryStats(cfg *config.Config) (*LibraryStats, error) {
 		}
 	}
 
+	// Count saved albums, podcasts and episodes
+	if albums, err := loadRawItems(filepath.Join(cfg.Storage.DataDir, "saved_albums.json")); err == nil {
+		stats.SavedAlbums = len(albums)
+	}
+	if shows, err := loadRawItems(filepath.Join(cfg.Storage.DataDir, "saved_shows.json")); err == nil {
+		stats.SavedShows = len(shows)
+	}
+	if episodes, err := loadRawItems(filepath.Join(cfg.Storage.DataDir, "saved_episodes.json")); err == nil {
+		stats.SavedEpisodes = len(episodes)
+	}
+
 	// Check if we have any data
-	if stats.TotalTracks == 0 && stats.TotalPlaylists == 0 {
+	if stats.TotalTracks == 0 && stats.TotalPlaylists == 0 &&
+		stats.SavedAlbums == 0 && stats.SavedShows == 0 && stats.SavedEpisodes == 0 {
 		return nil, fmt.Errorf("no backup data found")
 	}
 
@@ -454,3 +592,46 @@ func loadRawPlaylists(path string) ([]map[string]interface{}, error) {
 	}
 	return playlists, nil
 }
+
+func loadRawItems(path string) ([]map[string]interface{}, error) {
+	var items []map[string]interface{}
+	if err := jsonutil.LoadJSONFile(path, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// topRangeForPeriod maps a --period value to the closest Spotify time range
+func topRangeForPeriod(period string) string {
+	switch period {
+	case "week", "month":
+		return "short"
+	case "year":
+		return "medium"
+	default:
+		return "long"
+	}
+}
+
+// loadTopTrackNames returns "name - artists" entries from the latest top items snapshot
+func loadTopTrackNames(cfg *config.Config, timeRange string) ([]string, error) {
+	var top struct {
+		Tracks map[string][]map[string]interface{} `json:"tracks"`
+	}
+	if err := jsonutil.LoadJSONFile(filepath.Join(cfg.Storage.DataDir, "top_items.json"), &top); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(top.Tracks[timeRange]))
+	for _, track := range top.Tracks[timeRange] {
+		name := jsonutil.GetString(track, "name")
+		if name == "" {
+			continue
+		}
+		if artists := jsonutil.GetArtistNames(track); len(artists) > 0 {
+			name += " - " + strings.Join(artists, ", ")
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
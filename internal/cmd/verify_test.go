@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bkataru/spotigo/internal/config"
+	"github.com/bkataru/spotigo/internal/storage"
+)
+
+func TestVerifyBackups(t *testing.T) {
+	dir := t.TempDir()
+	testCfg := &config.Config{}
+	testCfg.Storage.DataDir = filepath.Join(dir, "data")
+	testCfg.Storage.BackupDir = filepath.Join(dir, "backups")
+
+	prev := cfg
+	cfg = testCfg
+	t.Cleanup(func() { cfg = prev })
+
+	store := storage.NewStore(testCfg.Storage.DataDir, testCfg.Storage.BackupDir)
+	backup, err := store.CreateBackup("all", map[string]interface{}{"saved_tracks": []map[string]string{{"id": "t1"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verifyBackups(nil); err != nil {
+		t.Fatalf("verifyBackups() error = %v", err)
+	}
+	if err := verifyBackups([]string{"latest"}); err != nil {
+		t.Fatalf("verifyBackups(latest) error = %v", err)
+	}
+
+	manifest, err := store.LoadManifest(backup.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := filepath.Join(testCfg.Storage.BackupDir, "objects")
+	hash := manifest.Sections["saved_tracks"].Items[0]
+	if err := os.WriteFile(filepath.Join(objects, hash[:2], hash+".json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verifyBackups(nil); !errors.Is(err, errBackupsCorrupted) {
+		t.Errorf("verifyBackups() error = %v, want %v", err, errBackupsCorrupted)
+	}
+}
@@ -26,6 +26,18 @@ type Config struct {
 
 	// App settings
 	App AppConfig `mapstructure:"app"`
+
+	// Profiles are named accounts, each with its own token and data
+	Profiles map[string]ProfileConfig `mapstructure:"profiles"`
+
+	// DefaultProfile is used when no profile is selected on the command line
+	DefaultProfile string `mapstructure:"profile"`
+
+	// Profile is the active profile, empty for the top-level settings
+	Profile string `mapstructure:"-"`
+
+	// base is the top-level configuration a profile was derived from
+	base *Config
 }
 
 // SpotifyConfig holds Spotify API credentials
@@ -34,12 +46,23 @@ type SpotifyConfig struct {
 	ClientSecret string `mapstructure:"client_secret"`
 	RedirectURI  string `mapstructure:"redirect_uri"`
 	TokenFile    string `mapstructure:"token_file"`
+	// TokenKey selects how the token file is encrypted: machine, passphrase,
+	// keyfile (TokenKeyFile), env (SPOTIGO_TOKEN_KEY) or keyring
+	TokenKey     string `mapstructure:"token_key"`
+	TokenKeyFile string `mapstructure:"token_key_file"`
+
+	// MaxRetries is how often a rate-limited or failed API request is retried
+	MaxRetries int `mapstructure:"max_retries"`
+	// RequestBudget caps the API requests made per run (0 = unlimited)
+	RequestBudget int `mapstructure:"request_budget"`
 }
 
 // OllamaConfig holds Ollama connection settings
 type OllamaConfig struct {
 	Host    string `mapstructure:"host"`
 	Timeout int    `mapstructure:"timeout"`
+	// EmbedBatchSize is how many texts are embedded per request when indexing
+	EmbedBatchSize int `mapstructure:"embed_batch_size"`
 }
 
 // StorageConfig holds data storage settings
@@ -47,6 +70,14 @@ type StorageConfig struct {
 	DataDir       string `mapstructure:"data_dir"`
 	BackupDir     string `mapstructure:"backup_dir"`
 	EmbeddingsDir string `mapstructure:"embeddings_dir"`
+
+	// Encryption protects backups and data files at rest: none, machine
+	// (key derived from this machine) or passphrase (portable, scrypt-derived)
+	Encryption string `mapstructure:"encryption"`
+
+	// QuantizeEmbeddings stores the search index's embeddings as int8 rather
+	// than float32, a quarter of the size at a small cost in accuracy
+	QuantizeEmbeddings bool `mapstructure:"quantize_embeddings"`
 }
 
 // BackupConfig holds backup settings
@@ -54,6 +85,11 @@ type BackupConfig struct {
 	Schedule   string `mapstructure:"schedule"`
 	RetainDays int    `mapstructure:"retain_days"`
 	Format     string `mapstructure:"format"`
+	// KeepDaily, KeepWeekly and KeepMonthly keep the newest backup of each of
+	// the last N days, weeks and months in addition to RetainDays (0 = disabled)
+	KeepDaily   int `mapstructure:"keep_daily"`
+	KeepWeekly  int `mapstructure:"keep_weekly"`
+	KeepMonthly int `mapstructure:"keep_monthly"`
 }
 
 // AppConfig holds general app settings
@@ -62,8 +98,35 @@ type AppConfig struct {
 	Theme   string `mapstructure:"theme"`
 }
 
-// Load reads configuration from file and environment
+// Load reads configuration from file and environment and applies the
+// default profile, if one is set
 func Load(cfgFile string) (*Config, error) {
+	return LoadProfile(cfgFile, "")
+}
+
+// LoadProfile is like Load but applies the named profile; an empty name
+// uses the default profile
+func LoadProfile(cfgFile, profile string) (*Config, error) {
+	cfg, err := LoadBase(cfgFile)
+	if err != nil {
+		return nil, err
+	}
+	if profile == "" {
+		profile = cfg.DefaultProfile
+	}
+
+	active, err := cfg.ForProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureDirectories(active); err != nil {
+		return nil, err
+	}
+	return active, nil
+}
+
+// LoadBase reads the top-level configuration without applying a profile
+func LoadBase(cfgFile string) (*Config, error) {
 	if cfgFile != "" {
 		viper.SetConfigFile(cfgFile)
 	} else {
@@ -125,24 +188,36 @@ func setDefaults() {
 	// Spotify defaults
 	viper.SetDefault("spotify.redirect_uri", "http://127.0.0.1:8888/callback")
 	viper.SetDefault("spotify.token_file", ".spotify_token")
+	viper.SetDefault("spotify.token_key", "machine")
+	viper.SetDefault("spotify.max_retries", 5)
+	viper.SetDefault("spotify.request_budget", 0)
 
 	// Ollama defaults
 	viper.SetDefault("ollama.host", "http://localhost:11434")
 	viper.SetDefault("ollama.timeout", 30)
+	viper.SetDefault("ollama.embed_batch_size", 32)
 
 	// Storage defaults
 	viper.SetDefault("storage.data_dir", "./data")
 	viper.SetDefault("storage.backup_dir", "./data/backups")
 	viper.SetDefault("storage.embeddings_dir", "./data/embeddings")
+	viper.SetDefault("storage.encryption", "none")
+	viper.SetDefault("storage.quantize_embeddings", false)
 
 	// Backup defaults
 	viper.SetDefault("backup.schedule", "daily")
 	viper.SetDefault("backup.retain_days", 30)
 	viper.SetDefault("backup.format", "json")
+	viper.SetDefault("backup.keep_daily", 0)
+	viper.SetDefault("backup.keep_weekly", 0)
+	viper.SetDefault("backup.keep_monthly", 0)
 
 	// App defaults
 	viper.SetDefault("app.verbose", false)
 	viper.SetDefault("app.theme", "dark")
+
+	// Profile defaults (SPOTIGO_PROFILE selects one)
+	viper.SetDefault("profile", "")
 }
 
 func ensureDirectories(cfg *Config) error {
@@ -41,6 +41,9 @@ func TestLoad_Defaults(t *testing.T) {
 	if cfg.Spotify.TokenFile != ".spotify_token" {
 		t.Errorf("expected default token file, got '%s'", cfg.Spotify.TokenFile)
 	}
+	if cfg.Spotify.TokenKey != "machine" {
+		t.Errorf("expected default token key 'machine', got '%s'", cfg.Spotify.TokenKey)
+	}
 
 	// Check Ollama defaults
 	if cfg.Ollama.Host != "http://localhost:11434" {
@@ -60,6 +63,16 @@ func TestLoad_Defaults(t *testing.T) {
 	if cfg.Storage.EmbeddingsDir != "./data/embeddings" {
 		t.Errorf("expected default embeddings dir, got '%s'", cfg.Storage.EmbeddingsDir)
 	}
+	if cfg.Storage.Encryption != "none" {
+		t.Errorf("expected default encryption 'none', got '%s'", cfg.Storage.Encryption)
+	}
+
+	if cfg.Spotify.MaxRetries != 5 {
+		t.Errorf("expected default max retries 5, got %d", cfg.Spotify.MaxRetries)
+	}
+	if cfg.Spotify.RequestBudget != 0 {
+		t.Errorf("expected default request budget 0, got %d", cfg.Spotify.RequestBudget)
+	}
 
 	// Check Backup defaults
 	if cfg.Backup.Schedule != "daily" {
@@ -71,6 +84,9 @@ func TestLoad_Defaults(t *testing.T) {
 	if cfg.Backup.Format != "json" {
 		t.Errorf("expected default format 'json', got '%s'", cfg.Backup.Format)
 	}
+	if cfg.Backup.KeepDaily != 0 || cfg.Backup.KeepWeekly != 0 || cfg.Backup.KeepMonthly != 0 {
+		t.Error("expected GFS retention to be disabled by default")
+	}
 
 	// Check App defaults
 	if cfg.App.Verbose != false {
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// DefaultProfileName refers to the top-level settings, e.g. in comparisons
+const DefaultProfileName = "default"
+
+// ErrProfileNotFound is returned for profiles that are neither configured nor created
+var ErrProfileNotFound = errors.New("profile not found")
+
+// profileNamePattern limits profile names to safe directory names
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// ProfileConfig overrides settings for a named profile. Unset fields fall
+// back to the top-level settings, except that the token file and storage
+// directories default to the profile's own directory.
+type ProfileConfig struct {
+	Spotify SpotifyConfig `mapstructure:"spotify"`
+	Storage StorageConfig `mapstructure:"storage"`
+}
+
+// ValidateProfileName checks that name can be used as a profile name
+func ValidateProfileName(name string) error {
+	if name == DefaultProfileName {
+		return fmt.Errorf("profile name %q is reserved for the top-level settings", name)
+	}
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use letters, digits, '-' and '_'", name)
+	}
+	return nil
+}
+
+// root returns the top-level configuration this one was derived from
+func (c *Config) root() *Config {
+	if c.base != nil {
+		return c.base
+	}
+	return c
+}
+
+// ProfilesDir returns the directory holding each profile's data
+func (c *Config) ProfilesDir() string {
+	return filepath.Join(c.root().Storage.DataDir, "profiles")
+}
+
+// ProfileDir returns the directory of the named profile
+func (c *Config) ProfileDir(name string) string {
+	return filepath.Join(c.ProfilesDir(), name)
+}
+
+// HasProfile reports whether name is configured or has been created
+func (c *Config) HasProfile(name string) bool {
+	if _, ok := c.root().Profiles[name]; ok {
+		return true
+	}
+	info, err := os.Stat(c.ProfileDir(name))
+	return err == nil && info.IsDir()
+}
+
+// ProfileNames returns the configured and created profiles, sorted
+func (c *Config) ProfileNames() ([]string, error) {
+	seen := make(map[string]bool)
+	for name := range c.root().Profiles {
+		seen[name] = true
+	}
+
+	entries, err := os.ReadDir(c.ProfilesDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to list profiles: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && ValidateProfileName(entry.Name()) == nil {
+			seen[entry.Name()] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ForProfile returns the configuration of the named profile. An empty name
+// or DefaultProfileName returns the top-level configuration.
+func (c *Config) ForProfile(name string) (*Config, error) {
+	root := c.root()
+	if name == "" || name == DefaultProfileName {
+		return root, nil
+	}
+	if err := ValidateProfileName(name); err != nil {
+		return nil, err
+	}
+	if !root.HasProfile(name) {
+		return nil, fmt.Errorf("%w: %s (create it with 'spotigo profiles add %s')", ErrProfileNotFound, name, name)
+	}
+
+	override := root.Profiles[name]
+	profile := *root
+	profile.base = root
+	profile.Profile = name
+
+	storage := &profile.Storage
+	storage.DataDir = firstSet(override.Storage.DataDir, root.ProfileDir(name))
+	storage.BackupDir = firstSet(override.Storage.BackupDir, filepath.Join(storage.DataDir, "backups"))
+	storage.EmbeddingsDir = firstSet(override.Storage.EmbeddingsDir, filepath.Join(storage.DataDir, "embeddings"))
+	storage.Encryption = firstSet(override.Storage.Encryption, storage.Encryption)
+
+	spotify := &profile.Spotify
+	spotify.TokenFile = firstSet(override.Spotify.TokenFile, filepath.Join(storage.DataDir, ".spotify_token"))
+	spotify.ClientID = firstSet(override.Spotify.ClientID, spotify.ClientID)
+	spotify.ClientSecret = firstSet(override.Spotify.ClientSecret, spotify.ClientSecret)
+	spotify.RedirectURI = firstSet(override.Spotify.RedirectURI, spotify.RedirectURI)
+	spotify.TokenKey = firstSet(override.Spotify.TokenKey, spotify.TokenKey)
+	spotify.TokenKeyFile = firstSet(override.Spotify.TokenKeyFile, spotify.TokenKeyFile)
+
+	return &profile, nil
+}
+
+// AddProfile creates the directories of a new profile and returns its configuration
+func (c *Config) AddProfile(name string) (*Config, error) {
+	if err := ValidateProfileName(name); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(c.ProfileDir(name), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create profile %s: %w", name, err)
+	}
+	profile, err := c.ForProfile(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureDirectories(profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// RemoveProfile deletes the profile directory with its token, data and
+// backups. Settings under profiles.<name> in the config file are kept.
+func (c *Config) RemoveProfile(name string) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+	dir := c.ProfileDir(name)
+	if _, err := os.Stat(dir); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+		}
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove profile %s: %w", name, err)
+	}
+	return nil
+}
+
+// firstSet returns value, or fallback when value is empty
+func firstSet(value, fallback string) string {
+	if value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func writeProfileConfig(t *testing.T) string {
+	t.Helper()
+	viper.Reset()
+	tmpDir := t.TempDir()
+
+	configContent := `
+spotify:
+  client_id: "shared-client-id"
+  client_secret: "shared-secret"
+
+storage:
+  data_dir: "` + filepath.Join(tmpDir, "data") + `"
+  encryption: "machine"
+
+profiles:
+  alice:
+    spotify:
+      client_id: "alice-client-id"
+  bob:
+    storage:
+      data_dir: "` + filepath.Join(tmpDir, "bob") + `"
+`
+	configPath := filepath.Join(tmpDir, "spotigo.yaml")
+	if err := os.WriteFile(configPath, []byte(configContent), 0600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return configPath
+}
+
+func TestLoadProfile(t *testing.T) {
+	configPath := writeProfileConfig(t)
+	dataDir := filepath.Join(filepath.Dir(configPath), "data")
+
+	cfg, err := LoadProfile(configPath, "alice")
+	if err != nil {
+		t.Fatalf("LoadProfile() error = %v", err)
+	}
+	aliceDir := filepath.Join(dataDir, "profiles", "alice")
+	if cfg.Profile != "alice" {
+		t.Errorf("Profile = %q, want alice", cfg.Profile)
+	}
+	if cfg.Spotify.ClientID != "alice-client-id" || cfg.Spotify.ClientSecret != "shared-secret" {
+		t.Errorf("Spotify = %+v, want alice's client ID with the shared secret", cfg.Spotify)
+	}
+	if cfg.Spotify.TokenFile != filepath.Join(aliceDir, ".spotify_token") {
+		t.Errorf("TokenFile = %q", cfg.Spotify.TokenFile)
+	}
+	if cfg.Storage.DataDir != aliceDir || cfg.Storage.BackupDir != filepath.Join(aliceDir, "backups") ||
+		cfg.Storage.EmbeddingsDir != filepath.Join(aliceDir, "embeddings") {
+		t.Errorf("Storage = %+v", cfg.Storage)
+	}
+	if cfg.Storage.Encryption != "machine" {
+		t.Errorf("Encryption = %q, want the top-level setting", cfg.Storage.Encryption)
+	}
+	if info, err := os.Stat(cfg.Storage.BackupDir); err != nil || !info.IsDir() {
+		t.Errorf("profile backup dir was not created: %v", err)
+	}
+
+	// Storage overrides move the derived directories along
+	bob, err := cfg.ForProfile("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobDir := filepath.Join(filepath.Dir(configPath), "bob")
+	if bob.Storage.BackupDir != filepath.Join(bobDir, "backups") || bob.Spotify.ClientID != "shared-client-id" {
+		t.Errorf("bob = %+v", bob)
+	}
+
+	// The default profile is the top-level configuration
+	root, err := bob.ForProfile(DefaultProfileName)
+	if err != nil || root.Profile != "" || root.Storage.DataDir != dataDir {
+		t.Errorf("ForProfile(default) = %+v, %v", root, err)
+	}
+}
+
+func TestLoadProfile_Selection(t *testing.T) {
+	configPath := writeProfileConfig(t)
+
+	t.Setenv("SPOTIGO_PROFILE", "bob")
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Profile != "bob" {
+		t.Errorf("Profile = %q, want bob from SPOTIGO_PROFILE", cfg.Profile)
+	}
+
+	viper.Reset()
+	if _, err := LoadProfile(configPath, "carol"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("LoadProfile(carol) error = %v, want %v", err, ErrProfileNotFound)
+	}
+	viper.Reset()
+	if _, err := LoadProfile(configPath, "../alice"); err == nil {
+		t.Error("expected an invalid profile name to be rejected")
+	}
+}
+
+func TestAddRemoveProfile(t *testing.T) {
+	configPath := writeProfileConfig(t)
+	cfg, err := LoadBase(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	carol, err := cfg.AddProfile("carol")
+	if err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	if _, err := os.Stat(carol.Storage.EmbeddingsDir); err != nil {
+		t.Errorf("profile directories were not created: %v", err)
+	}
+	if _, err := cfg.AddProfile(DefaultProfileName); err == nil {
+		t.Error("expected the reserved name to be rejected")
+	}
+
+	names, err := cfg.ProfileNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 3 || names[0] != "alice" || names[1] != "bob" || names[2] != "carol" {
+		t.Errorf("ProfileNames() = %v", names)
+	}
+
+	if err := cfg.RemoveProfile("carol"); err != nil {
+		t.Fatalf("RemoveProfile() error = %v", err)
+	}
+	if cfg.HasProfile("carol") {
+		t.Error("carol should be gone")
+	}
+	if err := cfg.RemoveProfile("carol"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("RemoveProfile() twice error = %v", err)
+	}
+}
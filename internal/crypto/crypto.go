@@ -2,8 +2,7 @@
 package crypto
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
+	"bytes"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
@@ -17,6 +16,10 @@ import (
 // TokenEncryptor handles encryption and decryption of OAuth tokens
 type TokenEncryptor struct {
 	key []byte
+	// provider supplies key on first use, and the keys of files with a key header
+	provider KeyProvider
+	// params describe how key was derived, written to file headers
+	params *KeyParams
 }
 
 // NewTokenEncryptor creates a new encryptor using machine-specific key derivation
@@ -26,7 +29,7 @@ func NewTokenEncryptor() (*TokenEncryptor, error) {
 		return nil, fmt.Errorf("failed to derive encryption key: %w", err)
 	}
 
-	return &TokenEncryptor{key: key}, nil
+	return &TokenEncryptor{key: key, params: &KeyParams{KDF: KDFMachine}}, nil
 }
 
 // NewTokenEncryptorWithKey creates a new encryptor with a custom key
@@ -37,19 +40,77 @@ func NewTokenEncryptorWithKey(key []byte) (*TokenEncryptor, error) {
 
 	// Hash the key to ensure it's exactly 32 bytes for AES-256
 	hash := sha256.Sum256(key)
-	return &TokenEncryptor{key: hash[:]}, nil
+	return &TokenEncryptor{key: hash[:], params: &KeyParams{KDF: KDFSecret}}, nil
+}
+
+// NewTokenEncryptorWithProvider creates an encryptor whose key comes from provider.
+// The key is requested when it is first needed.
+func NewTokenEncryptorWithProvider(provider KeyProvider) (*TokenEncryptor, error) {
+	if provider == nil {
+		return nil, fmt.Errorf("key provider is required")
+	}
+	return &TokenEncryptor{provider: provider}, nil
+}
+
+// resolveKey returns the encryption key, requesting it from the provider if needed
+func (e *TokenEncryptor) resolveKey() ([]byte, error) {
+	if e.key != nil {
+		return e.key, nil
+	}
+	if e.provider == nil {
+		return nil, fmt.Errorf("no encryption key")
+	}
+	key, params, err := e.provider.Key(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s key: %w", e.provider.Name(), err)
+	}
+	e.key, e.params = key, params
+	return key, nil
+}
+
+// keyFor returns the key for a file with the given derivation parameters
+func (e *TokenEncryptor) keyFor(params *KeyParams) ([]byte, error) {
+	if e.key != nil && e.params.equal(params) {
+		return e.key, nil
+	}
+	if e.provider == nil {
+		if e.params != nil {
+			if err := checkKDF(params, e.params.KDF); err != nil {
+				return nil, err
+			}
+		}
+		return nil, fmt.Errorf("file was encrypted with different key parameters")
+	}
+	key, _, err := e.provider.Key(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s key: %w", e.provider.Name(), err)
+	}
+	return key, nil
 }
 
 // Encrypt encrypts plaintext using AES-256-GCM
 func (e *TokenEncryptor) Encrypt(plaintext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(e.key)
+	key, err := e.resolveKey()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
+		return nil, err
 	}
+	return seal(key, plaintext)
+}
 
-	gcm, err := cipher.NewGCM(block)
+// Decrypt decrypts ciphertext using AES-256-GCM
+func (e *TokenEncryptor) Decrypt(ciphertext []byte) ([]byte, error) {
+	key, err := e.resolveKey()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
+		return nil, err
+	}
+	return open(key, ciphertext)
+}
+
+// seal encrypts plaintext with key, prepending the nonce
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create a nonce
@@ -63,16 +124,11 @@ func (e *TokenEncryptor) Encrypt(plaintext []byte) ([]byte, error) {
 	return ciphertext, nil
 }
 
-// Decrypt decrypts ciphertext using AES-256-GCM
-func (e *TokenEncryptor) Decrypt(ciphertext []byte) ([]byte, error) {
-	block, err := aes.NewCipher(e.key)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
-	}
-
-	gcm, err := cipher.NewGCM(block)
+// open decrypts a nonce-prefixed ciphertext with key
+func open(key, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
+		return nil, err
 	}
 
 	nonceSize := gcm.NonceSize()
@@ -145,9 +201,19 @@ func deriveKey() ([]byte, error) {
 	return hash[:], nil
 }
 
-// SaveEncryptedFile encrypts data and saves to file
+// SaveEncryptedFile encrypts data and saves it in the versioned envelope
+// format, whose header records how the key was derived. The file is
+// replaced atomically so an interrupted write never loses the old one.
 func (e *TokenEncryptor) SaveEncryptedFile(filename string, data []byte) error {
-	encrypted, err := e.Encrypt(data)
+	key, err := e.resolveKey()
+	if err != nil {
+		return err
+	}
+	params := e.params
+	if params == nil {
+		params = &KeyParams{KDF: KDFSecret}
+	}
+	encrypted, err := sealEnvelope(key, params, data)
 	if err != nil {
 		return fmt.Errorf("failed to encrypt: %w", err)
 	}
@@ -161,14 +227,29 @@ func (e *TokenEncryptor) SaveEncryptedFile(filename string, data []byte) error {
 	}
 
 	// Write with restrictive permissions
-	if err := os.WriteFile(cleanPath, encrypted, 0600); err != nil { // #nosec G304 - path is sanitized with filepath.Clean
+	tmp, err := os.CreateTemp(filepath.Dir(cleanPath), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if _, err := tmp.Write(encrypted); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), cleanPath); err != nil {
+		_ = os.Remove(tmp.Name())
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
 	return nil
 }
 
-// LoadEncryptedFile reads and decrypts data from file
+// LoadEncryptedFile reads and decrypts data from file. Envelope files and
+// the headerless version 1 layout are both supported.
 func (e *TokenEncryptor) LoadEncryptedFile(filename string) ([]byte, error) {
 	// Clean path to prevent traversal attacks
 	cleanPath := filepath.Clean(filename)
@@ -178,11 +259,27 @@ func (e *TokenEncryptor) LoadEncryptedFile(filename string) ([]byte, error) {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	decrypted, err := e.Decrypt(encrypted)
+	env, err := parseEnvelope(encrypted)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt: %w", err)
+		return nil, err
+	}
+	if env.params == nil {
+		decrypted, err := e.Decrypt(env.ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt: %w", err)
+		}
+		return decrypted, nil
 	}
 
+	// The key was derived with the parameters in the header
+	key, err := e.keyFor(env.params)
+	if err != nil {
+		return nil, err
+	}
+	decrypted, err := openEnvelope(key, env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
 	return decrypted, nil
 }
 
@@ -197,7 +294,11 @@ func IsEncryptedFile(filename string) bool {
 		return false
 	}
 
-	// Check for JSON indicators (plaintext token file)
+	if bytes.HasPrefix(data, envelopeMagic) || bytes.HasPrefix(data, passphraseMagic) {
+		return true
+	}
+
+	// Version 1 files have no header; check for JSON indicators (plaintext token file)
 	if len(data) > 0 && (data[0] == '{' || data[0] == '[') {
 		return false
 	}
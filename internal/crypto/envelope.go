@@ -0,0 +1,257 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// KDF identifies how the key of an encrypted file was derived
+type KDF byte
+
+// Key derivation functions recorded in the envelope header
+const (
+	// KDFMachine derives the key from the current user and machine
+	KDFMachine KDF = 1
+	// KDFScrypt derives the key from a passphrase, salt and scrypt costs
+	KDFScrypt KDF = 2
+	// KDFSecret hashes a secret such as a key file or environment variable
+	KDFSecret KDF = 3
+)
+
+// String returns the KDF name
+func (k KDF) String() string {
+	switch k {
+	case KDFMachine:
+		return "machine"
+	case KDFScrypt:
+		return "scrypt"
+	case KDFSecret:
+		return "secret"
+	default:
+		return fmt.Sprintf("kdf(%d)", byte(k))
+	}
+}
+
+// ErrKeyMismatch is returned when a file was encrypted with a different kind of key
+var ErrKeyMismatch = errors.New("file was encrypted with a different key source")
+
+// checkKDF verifies that params, read from a file, were derived with want
+func checkKDF(params *KeyParams, want KDF) error {
+	if params != nil && params.KDF != want {
+		return fmt.Errorf("%w (file uses %s key derivation, not %s)", ErrKeyMismatch, params.KDF, want)
+	}
+	return nil
+}
+
+// Encrypted file versions
+const (
+	// version1 files are the bare nonce and ciphertext, without metadata
+	version1 = 1
+	// version2 files start with the envelope header
+	version2 = 2
+)
+
+// envelopeMagic starts version 2 files. The header continues with:
+//
+//	version    1 byte
+//	kdf id     1 byte
+//	kdf params log2 N, r and p, one byte each (scrypt only)
+//	salt       1 byte length, then the salt
+//	nonce      1 byte length, then the nonce
+//
+// and is followed by the ciphertext. The whole header is authenticated as
+// GCM additional data, so it cannot be altered without failing decryption.
+var envelopeMagic = []byte("SPGOENC")
+
+// passphraseMagic starts the passphrase files written before the envelope
+// format. It is followed by the scrypt costs (log2 N, r and p, one byte each),
+// the salt length and the salt, then the nonce and ciphertext.
+var passphraseMagic = []byte("SPGO-PW")
+
+// envelope is a parsed encrypted file
+type envelope struct {
+	version    int
+	params     *KeyParams
+	header     []byte // authenticated header, empty before version 2
+	nonce      []byte // nil when the nonce prefixes ciphertext
+	ciphertext []byte
+}
+
+// sealEnvelope encrypts plaintext into a version 2 file
+func sealEnvelope(key []byte, params *KeyParams, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	header := append([]byte{}, envelopeMagic...)
+	header = append(header, version2, byte(params.KDF))
+	if params.KDF == KDFScrypt {
+		costs, err := encodeScrypt(params.Scrypt)
+		if err != nil {
+			return nil, err
+		}
+		header = append(header, costs...)
+	}
+	if len(params.Salt) > 255 {
+		return nil, fmt.Errorf("salt too long for file header")
+	}
+	header = append(header, byte(len(params.Salt)))
+	header = append(header, params.Salt...)
+	header = append(header, byte(len(nonce)))
+	header = append(header, nonce...)
+
+	return gcm.Seal(header, nonce, plaintext, header), nil
+}
+
+// openEnvelope decrypts a parsed file with key
+func openEnvelope(key []byte, env *envelope) ([]byte, error) {
+	if env.nonce == nil {
+		return open(key, env.ciphertext)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce size %d", len(env.nonce))
+	}
+	plaintext, err := gcm.Open(nil, env.nonce, env.ciphertext, env.header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+// parseEnvelope reads the header of an encrypted file. Files without a
+// header are version 1 and yield nil parameters.
+func parseEnvelope(data []byte) (*envelope, error) {
+	switch {
+	case bytes.HasPrefix(data, envelopeMagic):
+		return parseVersion2(data)
+	case bytes.HasPrefix(data, passphraseMagic):
+		params, body, err := parseKeyHeader(data)
+		if err != nil {
+			return nil, err
+		}
+		return &envelope{version: version1, params: params, ciphertext: body}, nil
+	default:
+		return &envelope{version: version1, ciphertext: data}, nil
+	}
+}
+
+// parseVersion2 reads a version 2 envelope header
+func parseVersion2(data []byte) (*envelope, error) {
+	r := headerReader{data: data, pos: len(envelopeMagic)}
+	version := r.byte()
+	if r.err == nil && version != version2 {
+		return nil, fmt.Errorf("unsupported encrypted file version %d", version)
+	}
+
+	params := &KeyParams{KDF: KDF(r.byte())}
+	switch params.KDF {
+	case KDFMachine, KDFSecret:
+	case KDFScrypt:
+		params.Scrypt = decodeScrypt(r.bytes(3))
+	default:
+		if r.err == nil {
+			return nil, fmt.Errorf("unknown key derivation %s", params.KDF)
+		}
+	}
+	if salt := r.bytes(int(r.byte())); len(salt) > 0 {
+		params.Salt = append([]byte{}, salt...)
+	}
+	nonce := r.bytes(int(r.byte()))
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return &envelope{
+		version:    version2,
+		params:     params,
+		header:     data[:r.pos],
+		nonce:      nonce,
+		ciphertext: data[r.pos:],
+	}, nil
+}
+
+// parseKeyHeader splits a legacy passphrase file into its key parameters and body.
+// Files without the header yield nil parameters.
+func parseKeyHeader(data []byte) (*KeyParams, []byte, error) {
+	if !bytes.HasPrefix(data, passphraseMagic) {
+		return nil, data, nil
+	}
+	r := headerReader{data: data, pos: len(passphraseMagic)}
+	params := &KeyParams{KDF: KDFScrypt, Scrypt: decodeScrypt(r.bytes(3))}
+	params.Salt = append([]byte{}, r.bytes(int(r.byte()))...)
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+	return params, data[r.pos:], nil
+}
+
+// headerReader reads header fields, remembering the first error
+type headerReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *headerReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.data)-r.pos < n {
+		r.err = fmt.Errorf("invalid key header")
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *headerReader) byte() byte {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+// encodeScrypt packs scrypt costs into three bytes
+func encodeScrypt(p ScryptParams) ([]byte, error) {
+	if p.N < 2 || p.N&(p.N-1) != 0 || p.R < 1 || p.R > 255 || p.P < 1 || p.P > 255 {
+		return nil, fmt.Errorf("key parameters cannot be stored in a file header")
+	}
+	return []byte{byte(bits.TrailingZeros(uint(p.N))), byte(p.R), byte(p.P)}, nil
+}
+
+// decodeScrypt unpacks costs written by encodeScrypt
+func decodeScrypt(b []byte) ScryptParams {
+	if len(b) != 3 || b[0] >= 63 {
+		return ScryptParams{}
+	}
+	return ScryptParams{N: 1 << b[0], R: int(b[1]), P: int(b[2])}
+}
+
+// newGCM creates an AES-GCM cipher for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
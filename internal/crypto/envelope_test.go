@@ -0,0 +1,158 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	tests := []struct {
+		name   string
+		params *KeyParams
+	}{
+		{"machine", &KeyParams{KDF: KDFMachine}},
+		{"secret", &KeyParams{KDF: KDFSecret}},
+		{"scrypt", &KeyParams{KDF: KDFScrypt, Salt: []byte("0123456789abcdef"), Scrypt: DefaultScryptParams}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := sealEnvelope(key, tt.params, []byte("payload"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			env, err := parseEnvelope(data)
+			if err != nil {
+				t.Fatalf("parseEnvelope() error = %v", err)
+			}
+			if env.version != version2 || !env.params.equal(tt.params) {
+				t.Errorf("parseEnvelope() = version %d, %+v, want %+v", env.version, env.params, tt.params)
+			}
+			plaintext, err := openEnvelope(key, env)
+			if err != nil || string(plaintext) != "payload" {
+				t.Errorf("openEnvelope() = %q, %v", plaintext, err)
+			}
+		})
+	}
+}
+
+func TestEnvelope_HeaderIsAuthenticated(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	data, err := sealEnvelope(key, &KeyParams{KDF: KDFScrypt, Salt: []byte("0123456789abcdef"), Scrypt: DefaultScryptParams}, []byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a bit in the salt
+	tampered := append([]byte{}, data...)
+	tampered[len(envelopeMagic)+6] ^= 1
+	env, err := parseEnvelope(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openEnvelope(key, env); err == nil {
+		t.Error("expected a modified header to fail decryption")
+	}
+}
+
+func TestParseEnvelope_Invalid(t *testing.T) {
+	header := func(b ...byte) []byte { return append(append([]byte{}, envelopeMagic...), b...) }
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated", header(version2)},
+		{"future version", header(3, byte(KDFMachine), 0, 12)},
+		{"unknown kdf", header(version2, 9, 0, 12)},
+		{"short nonce", header(version2, byte(KDFMachine), 0, 12, 1, 2)},
+		{"truncated legacy header", append([]byte{}, passphraseMagic...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseEnvelope(tt.data); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	env, err := parseEnvelope([]byte("plain"))
+	if err != nil || env.version != version1 || env.params != nil || string(env.ciphertext) != "plain" {
+		t.Errorf("headerless data should parse as version 1, got %+v, %v", env, err)
+	}
+}
+
+func TestLoadEncryptedFile_OlderVersions(t *testing.T) {
+	dir := t.TempDir()
+	plaintext := []byte(`{"access_token":"abc123"}`)
+
+	// Version 1: bare nonce and ciphertext under the machine key
+	machine, err := NewTokenEncryptor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1, err := machine.Encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1Path := filepath.Join(dir, "v1")
+	if err := os.WriteFile(v1Path, v1, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Passphrase files with the pre-envelope SPGO-PW header
+	salt := []byte("0123456789abcdef")
+	pw, err := NewPassphraseEncryptor("correct horse", salt, testScryptParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := pw.Encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := append(append([]byte{}, passphraseMagic...), 10, 8, 1, byte(len(salt)))
+	legacy = append(append(legacy, salt...), body...)
+	pwPath := filepath.Join(dir, "pw")
+	if err := os.WriteFile(pwPath, legacy, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		provider KeyProvider
+	}{
+		{"version 1", v1Path, MachineKey{}},
+		{"legacy passphrase header", pwPath, testPassphraseKey("correct horse", nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !IsEncryptedFile(tt.path) {
+				t.Error("file should be detected as encrypted")
+			}
+			enc, _ := NewTokenEncryptorWithProvider(tt.provider)
+			got, err := enc.LoadEncryptedFile(tt.path)
+			if err != nil || !bytes.Equal(got, plaintext) {
+				t.Errorf("LoadEncryptedFile() = %q, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestLoadEncryptedFile_KeyMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	machine, _ := NewTokenEncryptor()
+	if err := machine.SaveEncryptedFile(path, []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+
+	keyed, _ := NewTokenEncryptorWithKey([]byte("0123456789abcdef"))
+	if _, err := keyed.LoadEncryptedFile(path); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("LoadEncryptedFile() error = %v, want %v", err, ErrKeyMismatch)
+	}
+}
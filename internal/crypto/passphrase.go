@@ -0,0 +1,61 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// SaltSize is the length of salts returned by NewSalt
+const SaltSize = 16
+
+// ScryptParams are the cost parameters used to derive a key from a passphrase
+type ScryptParams struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+}
+
+// DefaultScryptParams are the recommended parameters for interactive use
+var DefaultScryptParams = ScryptParams{N: 1 << 15, R: 8, P: 1}
+
+// NewSalt returns a random salt for passphrase key derivation
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return salt, nil
+}
+
+// NewPassphraseEncryptor creates an encryptor with a key derived from a
+// passphrase using scrypt. The same passphrase, salt and parameters always
+// yield the same key, so data can be decrypted on any machine.
+func NewPassphraseEncryptor(passphrase string, salt []byte, params ScryptParams) (*TokenEncryptor, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase must not be empty")
+	}
+	if len(salt) < 8 {
+		return nil, fmt.Errorf("salt must be at least 8 bytes")
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key from passphrase: %w", err)
+	}
+	keyParams := &KeyParams{KDF: KDFScrypt, Salt: append([]byte{}, salt...), Scrypt: params}
+	return &TokenEncryptor{key: key, params: keyParams}, nil
+}
+
+// KeyedHash returns an HMAC-SHA256 of data under a subkey of the encryption
+// key. Unlike a plain hash it cannot be used to confirm guesses of the data.
+func (e *TokenEncryptor) KeyedHash(data []byte) []byte {
+	subkey := sha256.Sum256(append([]byte("spotigo-keyed-hash-v1"), e.key...))
+	mac := hmac.New(sha256.New, subkey[:])
+	mac.Write(data)
+	return mac.Sum(nil)
+}
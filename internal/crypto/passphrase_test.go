@@ -0,0 +1,84 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+// testScryptParams keeps key derivation fast in tests
+var testScryptParams = ScryptParams{N: 1 << 10, R: 8, P: 1}
+
+func TestNewPassphraseEncryptor(t *testing.T) {
+	salt, err := NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(salt) != SaltSize {
+		t.Errorf("expected %d-byte salt, got %d", SaltSize, len(salt))
+	}
+
+	enc, err := NewPassphraseEncryptor("correct horse", salt, testScryptParams)
+	if err != nil {
+		t.Fatalf("failed to create encryptor: %v", err)
+	}
+	ciphertext, err := enc.Encrypt([]byte("listening history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Same passphrase and salt derive the same key
+	same, _ := NewPassphraseEncryptor("correct horse", salt, testScryptParams)
+	plaintext, err := same.Decrypt(ciphertext)
+	if err != nil || string(plaintext) != "listening history" {
+		t.Errorf("expected round trip, got %q, %v", plaintext, err)
+	}
+
+	// A different passphrase or salt does not
+	wrong, _ := NewPassphraseEncryptor("wrong horse", salt, testScryptParams)
+	if _, err := wrong.Decrypt(ciphertext); err == nil {
+		t.Error("expected decryption with the wrong passphrase to fail")
+	}
+	otherSalt, _ := NewSalt()
+	salted, _ := NewPassphraseEncryptor("correct horse", otherSalt, testScryptParams)
+	if _, err := salted.Decrypt(ciphertext); err == nil {
+		t.Error("expected decryption with a different salt to fail")
+	}
+}
+
+func TestNewPassphraseEncryptor_Invalid(t *testing.T) {
+	salt, _ := NewSalt()
+	tests := []struct {
+		name       string
+		passphrase string
+		salt       []byte
+		params     ScryptParams
+	}{
+		{"empty passphrase", "", salt, testScryptParams},
+		{"short salt", "secret", []byte("abc"), testScryptParams},
+		{"invalid cost", "secret", salt, ScryptParams{N: 3, R: 8, P: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPassphraseEncryptor(tt.passphrase, tt.salt, tt.params); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestKeyedHash(t *testing.T) {
+	a, _ := NewTokenEncryptorWithKey([]byte("this-is-a-test-key-for-spotigo!"))
+	b, _ := NewTokenEncryptorWithKey([]byte("this-is-another-key-for-spotigo"))
+
+	data := []byte(`{"id":"track"}`)
+	if !bytes.Equal(a.KeyedHash(data), a.KeyedHash(data)) {
+		t.Error("keyed hash should be deterministic")
+	}
+	if bytes.Equal(a.KeyedHash(data), b.KeyedHash(data)) {
+		t.Error("keyed hash should depend on the key")
+	}
+	if bytes.Equal(a.KeyedHash(data), a.KeyedHash([]byte(`{"id":"other"}`))) {
+		t.Error("keyed hash should depend on the data")
+	}
+}
@@ -0,0 +1,217 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/zalando/go-keyring"
+)
+
+// KeyProvider supplies the key for a TokenEncryptor
+type KeyProvider interface {
+	// Name identifies the key source in messages, e.g. "passphrase"
+	Name() string
+
+	// Key returns a 32-byte AES key and how it was derived. params is nil when
+	// encrypting new data; the returned parameters, such as a fresh salt, are
+	// stored in the file header. When decrypting a file with a header, params
+	// holds the parameters read from it, and a provider whose KDF does not
+	// match returns ErrKeyMismatch.
+	Key(params *KeyParams) ([]byte, *KeyParams, error)
+}
+
+// KeyParams are the key derivation parameters stored in a file header
+type KeyParams struct {
+	KDF    KDF
+	Salt   []byte
+	Scrypt ScryptParams
+}
+
+// equal reports whether p and o derive the same key from the same secret
+func (p *KeyParams) equal(o *KeyParams) bool {
+	if p == nil || o == nil {
+		return p == o
+	}
+	return p.KDF == o.KDF && bytes.Equal(p.Salt, o.Salt) && p.Scrypt == o.Scrypt
+}
+
+// minSecretSize is the minimum length of key file and environment secrets
+const minSecretSize = 16
+
+// MachineKey derives the key from the current user and machine. It is the
+// default, but the key changes when the user or home directory does.
+type MachineKey struct{}
+
+// Name returns "machine"
+func (MachineKey) Name() string { return "machine" }
+
+// Key returns the machine-derived key
+func (MachineKey) Key(params *KeyParams) ([]byte, *KeyParams, error) {
+	if err := checkKDF(params, KDFMachine); err != nil {
+		return nil, nil, err
+	}
+	key, err := deriveKey()
+	return key, &KeyParams{KDF: KDFMachine}, err
+}
+
+// PassphraseKey derives keys from a passphrase with scrypt. Each file it
+// encrypts gets a fresh salt, stored with the costs in the file header.
+type PassphraseKey struct {
+	passphrase func(confirm bool) (string, error)
+	params     ScryptParams
+
+	mu     sync.Mutex
+	secret string
+}
+
+// NewPassphraseKey creates a passphrase key provider. passphrase is called
+// once, when a key is first needed; confirm is set when encrypting new data
+// so interactive callers can ask twice.
+func NewPassphraseKey(passphrase func(confirm bool) (string, error)) *PassphraseKey {
+	return &PassphraseKey{passphrase: passphrase, params: DefaultScryptParams}
+}
+
+// Name returns "passphrase"
+func (p *PassphraseKey) Name() string { return "passphrase" }
+
+// Key derives the key for params, or for a new salt when params is nil
+func (p *PassphraseKey) Key(params *KeyParams) ([]byte, *KeyParams, error) {
+	if err := checkKDF(params, KDFScrypt); err != nil {
+		return nil, nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.secret == "" {
+		secret, err := p.passphrase(params == nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		p.secret = secret
+	}
+
+	if params == nil {
+		salt, err := NewSalt()
+		if err != nil {
+			return nil, nil, err
+		}
+		params = &KeyParams{KDF: KDFScrypt, Salt: salt, Scrypt: p.params}
+	}
+	enc, err := NewPassphraseEncryptor(p.secret, params.Salt, params.Scrypt)
+	if err != nil {
+		return nil, nil, err
+	}
+	return enc.key, params, nil
+}
+
+// KeyFile reads the key from a file holding at least 16 bytes of secret
+// material, such as the output of `openssl rand -base64 32`
+type KeyFile struct {
+	Path string
+}
+
+// Name returns "keyfile"
+func (k KeyFile) Name() string { return "keyfile" }
+
+// Key reads and hashes the key file
+func (k KeyFile) Key(params *KeyParams) ([]byte, *KeyParams, error) {
+	if err := checkKDF(params, KDFSecret); err != nil {
+		return nil, nil, err
+	}
+	if k.Path == "" {
+		return nil, nil, fmt.Errorf("no key file configured")
+	}
+	secret, err := os.ReadFile(filepath.Clean(k.Path)) // #nosec G304 - path is sanitized with filepath.Clean
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	key, err := keyFromSecret(secret)
+	if err != nil {
+		return nil, nil, fmt.Errorf("key file %s: %w", k.Path, err)
+	}
+	return key, &KeyParams{KDF: KDFSecret}, nil
+}
+
+// EnvKey reads the key from an environment variable holding at least 16 bytes
+type EnvKey struct {
+	Var string
+}
+
+// Name returns "env"
+func (k EnvKey) Name() string { return "env" }
+
+// Key reads and hashes the environment variable
+func (k EnvKey) Key(params *KeyParams) ([]byte, *KeyParams, error) {
+	if err := checkKDF(params, KDFSecret); err != nil {
+		return nil, nil, err
+	}
+	secret := os.Getenv(k.Var)
+	if secret == "" {
+		return nil, nil, fmt.Errorf("%s is not set", k.Var)
+	}
+	key, err := keyFromSecret([]byte(secret))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", k.Var, err)
+	}
+	return key, &KeyParams{KDF: KDFSecret}, nil
+}
+
+// KeyringKey keeps a random secret in the OS keyring: the macOS Keychain,
+// the Windows Credential Manager or the Secret Service on Linux. The secret is
+// created when data is first encrypted with it, and stays on this machine.
+type KeyringKey struct {
+	// Service and User name the keyring entry
+	Service string
+	User    string
+}
+
+// Name returns "keyring"
+func (k KeyringKey) Name() string { return "keyring" }
+
+// Key reads the secret from the keyring and hashes it. When encrypting new
+// data and the keyring has no secret yet, a new one is stored.
+func (k KeyringKey) Key(params *KeyParams) ([]byte, *KeyParams, error) {
+	if err := checkKDF(params, KDFSecret); err != nil {
+		return nil, nil, err
+	}
+
+	secret, err := keyring.Get(k.Service, k.User)
+	if errors.Is(err, keyring.ErrNotFound) && params == nil {
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate keyring secret: %w", err)
+		}
+		secret = base64.StdEncoding.EncodeToString(raw)
+		if err := keyring.Set(k.Service, k.User, secret); err != nil {
+			return nil, nil, fmt.Errorf("failed to store key in the keyring: %w", err)
+		}
+	} else if errors.Is(err, keyring.ErrNotFound) {
+		return nil, nil, fmt.Errorf("the keyring has no key for %s/%s", k.Service, k.User)
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to read key from the keyring: %w", err)
+	}
+
+	key, err := keyFromSecret([]byte(secret))
+	if err != nil {
+		return nil, nil, fmt.Errorf("keyring secret %s/%s: %w", k.Service, k.User, err)
+	}
+	return key, &KeyParams{KDF: KDFSecret}, nil
+}
+
+// keyFromSecret hashes secret material into a 32-byte key
+func keyFromSecret(secret []byte) ([]byte, error) {
+	secret = bytes.TrimSpace(secret)
+	if len(secret) < minSecretSize {
+		return nil, fmt.Errorf("key must be at least %d bytes", minSecretSize)
+	}
+	hash := sha256.Sum256(secret)
+	return hash[:], nil
+}
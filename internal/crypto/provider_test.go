@@ -0,0 +1,161 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+// testPassphraseKey returns a passphrase provider with cheap scrypt costs
+func testPassphraseKey(secret string, calls *int) *PassphraseKey {
+	p := NewPassphraseKey(func(bool) (string, error) {
+		if calls != nil {
+			*calls++
+		}
+		return secret, nil
+	})
+	p.params = testScryptParams
+	return p
+}
+
+func TestKeyProviders_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "token.key")
+	if err := os.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SPOTIGO_TEST_KEY", "fedcba9876543210fedcba9876543210")
+	keyring.MockInit()
+
+	tests := []struct {
+		name     string
+		provider func() KeyProvider
+		kdf      KDF
+	}{
+		{"machine", func() KeyProvider { return MachineKey{} }, KDFMachine},
+		{"passphrase", func() KeyProvider { return testPassphraseKey("correct horse", nil) }, KDFScrypt},
+		{"keyfile", func() KeyProvider { return KeyFile{Path: keyFile} }, KDFSecret},
+		{"env", func() KeyProvider { return EnvKey{Var: "SPOTIGO_TEST_KEY"} }, KDFSecret},
+		{"keyring", func() KeyProvider { return KeyringKey{Service: "spotigo-test", User: "token"} }, KDFSecret},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".token")
+			original := []byte(`{"access_token":"abc123"}`)
+
+			enc, err := NewTokenEncryptorWithProvider(tt.provider())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := enc.SaveEncryptedFile(path, original); err != nil {
+				t.Fatalf("save failed: %v", err)
+			}
+
+			raw, _ := os.ReadFile(path)
+			env, err := parseEnvelope(raw)
+			if err != nil || env.version != version2 || env.params.KDF != tt.kdf {
+				t.Errorf("parseEnvelope() = %+v, %v, want version 2 with %s", env, err, tt.kdf)
+			}
+			if !IsEncryptedFile(path) {
+				t.Error("file should be detected as encrypted")
+			}
+
+			// A fresh encryptor from the same source reads it back
+			fresh, _ := NewTokenEncryptorWithProvider(tt.provider())
+			loaded, err := fresh.LoadEncryptedFile(path)
+			if err != nil {
+				t.Fatalf("load failed: %v", err)
+			}
+			if !bytes.Equal(loaded, original) {
+				t.Errorf("loaded %q, want %q", loaded, original)
+			}
+		})
+	}
+}
+
+func TestPassphraseKey_HeaderAndCaching(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	calls := 0
+	provider := testPassphraseKey("correct horse", &calls)
+
+	enc, _ := NewTokenEncryptorWithProvider(provider)
+	if err := enc.SaveEncryptedFile(path, []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enc.LoadEncryptedFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("passphrase requested %d times, want 1", calls)
+	}
+
+	raw, _ := os.ReadFile(path)
+	env, err := parseEnvelope(raw)
+	if err != nil || env.params == nil {
+		t.Fatalf("parseEnvelope() = %v, %v", env, err)
+	}
+	if params := env.params; params.Scrypt != testScryptParams || len(params.Salt) != SaltSize {
+		t.Errorf("unexpected header params %+v", params)
+	}
+
+	// The costs come from the header, not the provider's defaults
+	reader := NewPassphraseKey(func(bool) (string, error) { return "correct horse", nil })
+	dec, _ := NewTokenEncryptorWithProvider(reader)
+	if _, err := dec.LoadEncryptedFile(path); err != nil {
+		t.Errorf("load with default costs failed: %v", err)
+	}
+
+	wrong, _ := NewTokenEncryptorWithProvider(testPassphraseKey("wrong horse", nil))
+	if _, err := wrong.LoadEncryptedFile(path); err == nil {
+		t.Error("expected wrong passphrase to fail")
+	}
+	machine, _ := NewTokenEncryptor()
+	if _, err := machine.LoadEncryptedFile(path); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("machine key on a passphrase file: error = %v, want %v", err, ErrKeyMismatch)
+	}
+	keyed, _ := NewTokenEncryptorWithProvider(EnvKey{Var: "SPOTIGO_TEST_KEY"})
+	if _, err := keyed.LoadEncryptedFile(path); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("env key on a passphrase file: error = %v, want %v", err, ErrKeyMismatch)
+	}
+}
+
+func TestKeyProviders_Errors(t *testing.T) {
+	dir := t.TempDir()
+	short := filepath.Join(dir, "short.key")
+	if err := os.WriteFile(short, []byte("too short"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SPOTIGO_TEST_EMPTY", "")
+	keyring.MockInit()
+
+	tests := []struct {
+		name     string
+		provider KeyProvider
+		params   *KeyParams
+	}{
+		{"missing key file", KeyFile{Path: filepath.Join(dir, "missing")}, nil},
+		{"short key file", KeyFile{Path: short}, nil},
+		{"unset env", EnvKey{Var: "SPOTIGO_TEST_EMPTY"}, nil},
+		{"machine with header", MachineKey{}, &KeyParams{}},
+		{"env with header", EnvKey{Var: "SPOTIGO_TEST_EMPTY"}, &KeyParams{}},
+		{"empty keyring", KeyringKey{Service: "spotigo-test", User: "missing"}, &KeyParams{KDF: KDFSecret}},
+		{"passphrase error", NewPassphraseKey(func(bool) (string, error) { return "", errors.New("cancelled") }), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := tt.provider.Key(tt.params); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	if _, err := NewTokenEncryptorWithProvider(nil); err == nil {
+		t.Error("expected error for nil provider")
+	}
+}
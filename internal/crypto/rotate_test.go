@@ -0,0 +1,61 @@
+package crypto
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotateFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SPOTIGO_TEST_KEY", "fedcba9876543210fedcba9876543210")
+	from := testPassphraseKey("correct horse", nil)
+	to := EnvKey{Var: "SPOTIGO_TEST_KEY"}
+
+	enc, _ := NewTokenEncryptorWithProvider(from)
+	files := map[string][]byte{
+		filepath.Join(dir, "token"):           []byte("token"),
+		filepath.Join(dir, "nested", "cache"): []byte("cache"),
+	}
+	for path, data := range files {
+		if err := enc.SaveEncryptedFile(path, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Plaintext and headerless files are not picked up
+	if err := os.WriteFile(filepath.Join(dir, "tracks.json"), []byte(`[]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := FindEncryptedFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != len(files) {
+		t.Fatalf("FindEncryptedFiles() = %v, want %d files", found, len(files))
+	}
+
+	// The wrong key fails before anything is rewritten
+	if err := RotateFiles(found, testPassphraseKey("wrong horse", nil), to); err == nil {
+		t.Fatal("expected rotation with the wrong key to fail")
+	}
+	if err := RotateFiles(found, from, to); err != nil {
+		t.Fatalf("RotateFiles() error = %v", err)
+	}
+
+	reader, _ := NewTokenEncryptorWithProvider(to)
+	for path, want := range files {
+		got, err := reader.LoadEncryptedFile(path)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%s after rotation = %q, %v", path, got, err)
+		}
+	}
+
+	if found, err := FindEncryptedFiles(dir, filepath.Join(dir, "nested")); err != nil || len(found) != 1 {
+		t.Errorf("FindEncryptedFiles() skipping nested = %v, %v", found, err)
+	}
+	if found, err := FindEncryptedFiles(filepath.Join(dir, "missing")); err != nil || len(found) != 0 {
+		t.Errorf("FindEncryptedFiles() on a missing dir = %v, %v", found, err)
+	}
+}
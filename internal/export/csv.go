@@ -0,0 +1,47 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+)
+
+// csvExporter writes tables as CSV with a header row
+type csvExporter struct{}
+
+func init() {
+	Register("csv", csvExporter{})
+}
+
+// Extension implements Exporter
+func (csvExporter) Extension() string { return "csv" }
+
+// Write implements Exporter
+func (csvExporter) Write(w io.Writer, table *Table) error {
+	buf := bufio.NewWriter(w)
+	cw := csv.NewWriter(buf)
+
+	header := make([]string, len(table.Columns))
+	for i, col := range table.Columns {
+		header[i] = col.Name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(table.Columns))
+	for _, row := range table.Rows {
+		for i := range record {
+			record[i] = formatValue(row[i])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
@@ -0,0 +1,128 @@
+// Package export writes backup data as flat tables in formats other tools can load
+package export
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// ColumnType is the type of the values in a table column
+type ColumnType int
+
+const (
+	// String columns hold string values
+	String ColumnType = iota
+	// Int columns hold int64 values
+	Int
+	// Bool columns hold bool values
+	Bool
+)
+
+// Column describes one column of a table
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Table is a flattened backup section, one row per item.
+// Row values must match their column type: string, int64 or bool.
+type Table struct {
+	Name    string
+	Columns []Column
+	Rows    [][]interface{}
+}
+
+// AddRow appends a row to the table
+func (t *Table) AddRow(values ...interface{}) {
+	t.Rows = append(t.Rows, values)
+}
+
+// Exporter writes tables in a particular file format
+type Exporter interface {
+	// Extension is the file extension used for this format, without the dot
+	Extension() string
+	// Write encodes a table to w
+	Write(w io.Writer, table *Table) error
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Exporter)
+)
+
+// Register makes an exporter available under the given format name
+func Register(format string, exporter Exporter) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[format] = exporter
+}
+
+// Get returns the exporter registered for a format
+func Get(format string) (Exporter, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	exporter, ok := registry[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown export format %q (available: %v)", format, formatsLocked())
+	}
+	return exporter, nil
+}
+
+// Formats returns the registered format names, sorted
+func Formats() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return formatsLocked()
+}
+
+func formatsLocked() []string {
+	formats := make([]string, 0, len(registry))
+	for format := range registry {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// WriteFile writes a table to <dir>/<table name>.<extension> and returns the path
+func WriteFile(dir string, exporter Exporter, table *Table) (string, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	path := filepath.Join(dir, filepath.Base(table.Name)+"."+exporter.Extension())
+	file, err := os.Create(path) // #nosec G304 - path is built from the export dir and a known section name
+	if err != nil {
+		return "", fmt.Errorf("failed to create export file: %w", err)
+	}
+
+	if err := exporter.Write(file, table); err != nil {
+		_ = file.Close()
+		return "", fmt.Errorf("failed to export %s: %w", table.Name, err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to close export file: %w", err)
+	}
+	return path, nil
+}
+
+// formatValue renders a value as text for text-based formats
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
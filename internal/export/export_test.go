@@ -0,0 +1,245 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+func sampleTable() *Table {
+	table := &Table{
+		Name:    "sample",
+		Columns: []Column{{"name", String}, {"plays", Int}, {"liked", Bool}},
+	}
+	table.AddRow("Karma Police", int64(42), true)
+	table.AddRow(`Say "Hi", Bye`, int64(0), false)
+	return table
+}
+
+func TestRegistry(t *testing.T) {
+	formats := Formats()
+	for _, want := range []string{"csv", "ndjson", "parquet"} {
+		found := false
+		for _, f := range formats {
+			found = found || f == want
+		}
+		if !found {
+			t.Errorf("format %q not registered (have %v)", want, formats)
+		}
+	}
+
+	if _, err := Get("xlsx"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestCSVExporter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (csvExporter{}).Write(&buf, sampleTable()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	want := "name,plays,liked\nKarma Police,42,true\n\"Say \"\"Hi\"\", Bye\",0,false\n"
+	if buf.String() != want {
+		t.Errorf("unexpected CSV:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestNDJSONExporter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (ndjsonExporter{}).Write(&buf, sampleTable()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	if lines[0] != `{"name":"Karma Police","plays":42,"liked":true}` {
+		t.Errorf("unexpected first line: %s", lines[0])
+	}
+
+	var row map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &row); err != nil {
+		t.Fatalf("line is not valid JSON: %v", err)
+	}
+	if row["name"] != `Say "Hi", Bye` {
+		t.Errorf("unexpected name: %v", row["name"])
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "exports")
+	exporter, err := Get("csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := WriteFile(dir, exporter, sampleTable())
+	if err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if filepath.Base(path) != "sample.csv" {
+		t.Errorf("unexpected path %s", path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("export file missing: %v", err)
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) json.RawMessage {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestSectionTable_SavedTracks(t *testing.T) {
+	tracks := []spotify.SavedTrack{{
+		AddedAt: "2024-01-01T00:00:00Z",
+		FullTrack: spotify.FullTrack{
+			SimpleTrack: spotify.SimpleTrack{
+				ID:       "t1",
+				Name:     "Paranoid Android",
+				Artists:  []spotify.SimpleArtist{{Name: "Radiohead"}, {Name: "Guest"}},
+				Duration: 387000,
+				Explicit: true,
+			},
+			Album:       spotify.SimpleAlbum{Name: "OK Computer", ReleaseDate: "1997-05-21"},
+			Popularity:  80,
+			ExternalIDs: map[string]string{"isrc": "GBAYE9700104"},
+		},
+	}}
+
+	table, err := SectionTable("saved_tracks", mustJSON(t, tracks))
+	if err != nil {
+		t.Fatalf("SectionTable() error = %v", err)
+	}
+
+	want := []interface{}{"2024-01-01T00:00:00Z", "t1", "Paranoid Android", "Radiohead; Guest",
+		"OK Computer", "1997-05-21", int64(387000), int64(80), true, "GBAYE9700104", ""}
+	if len(table.Rows) != 1 || !reflect.DeepEqual(table.Rows[0], want) {
+		t.Errorf("unexpected rows: %v", table.Rows)
+	}
+}
+
+func TestSectionTable_Playlists(t *testing.T) {
+	// Playlists are stored with zmb3 playlist items, whose union type marshals as {"Track":..,"Episode":..}
+	playlists := []map[string]interface{}{{
+		"id":    "p1",
+		"name":  "Road Trip",
+		"owner": "me",
+		"tracks": []spotify.PlaylistItem{
+			{AddedAt: "2024-02-01T00:00:00Z", Track: spotify.PlaylistItemTrack{Track: &spotify.FullTrack{
+				SimpleTrack: spotify.SimpleTrack{ID: "t1", Name: "Song", Artists: []spotify.SimpleArtist{{Name: "Band"}}},
+				Album:       spotify.SimpleAlbum{Name: "Record"},
+			}}},
+			{AddedAt: "2024-02-02T00:00:00Z", Track: spotify.PlaylistItemTrack{Episode: &spotify.EpisodePage{
+				ID: "e1", Name: "Episode 1", Show: spotify.SimpleShow{Name: "The Show", Publisher: "Pub"},
+			}}},
+			{AddedAt: "2024-02-03T00:00:00Z"},
+		},
+	}}
+
+	table, err := SectionTable("playlists", mustJSON(t, playlists))
+	if err != nil {
+		t.Fatalf("SectionTable() error = %v", err)
+	}
+	if len(table.Rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(table.Rows))
+	}
+
+	col := func(name string) int {
+		for i, c := range table.Columns {
+			if c.Name == name {
+				return i
+			}
+		}
+		t.Fatalf("no column %s", name)
+		return -1
+	}
+
+	tests := []struct {
+		row                     int
+		itemType, name, artists string
+		album                   string
+		position                int64
+	}{
+		{0, "track", "Song", "Band", "Record", 1},
+		{1, "episode", "Episode 1", "Pub", "The Show", 2},
+		{2, "unavailable", "", "", "", 3},
+	}
+	for _, tt := range tests {
+		row := table.Rows[tt.row]
+		if row[col("item_type")] != tt.itemType || row[col("name")] != tt.name ||
+			row[col("artists")] != tt.artists || row[col("album")] != tt.album ||
+			row[col("position")] != tt.position || row[col("playlist_name")] != "Road Trip" {
+			t.Errorf("row %d = %v", tt.row, row)
+		}
+	}
+}
+
+func TestSectionTable_TopItems(t *testing.T) {
+	top := map[string]interface{}{
+		"captured_at": "2024-03-01T00:00:00Z",
+		"tracks": map[string][]spotify.FullTrack{
+			"long":  {{SimpleTrack: spotify.SimpleTrack{ID: "t2"}}},
+			"short": {{SimpleTrack: spotify.SimpleTrack{ID: "t1"}}, {SimpleTrack: spotify.SimpleTrack{ID: "t3"}}},
+		},
+		"artists": map[string][]spotify.FullArtist{
+			"short": {{SimpleArtist: spotify.SimpleArtist{ID: "a1", Name: "Artist"}, Genres: []string{"rock", "indie"}}},
+		},
+	}
+
+	table, err := SectionTable("top_items", mustJSON(t, top))
+	if err != nil {
+		t.Fatalf("SectionTable() error = %v", err)
+	}
+	if len(table.Rows) != 4 {
+		t.Fatalf("expected 4 rows, got %d", len(table.Rows))
+	}
+
+	// Short range comes first, ranked in order
+	if table.Rows[0][1] != "short" || table.Rows[0][3] != int64(1) || table.Rows[1][4] != "t3" {
+		t.Errorf("unexpected ordering: %v", table.Rows)
+	}
+	if last := table.Rows[3]; last[2] != "artist" || last[7] != "rock; indie" {
+		t.Errorf("unexpected artist row: %v", last)
+	}
+}
+
+func TestSectionTable_AllSections(t *testing.T) {
+	empty := map[string]string{
+		"saved_tracks":     `[]`,
+		"playlists":        `[]`,
+		"followed_artists": `[]`,
+		"saved_albums":     `[]`,
+		"saved_shows":      `[]`,
+		"saved_episodes":   `[]`,
+		"top_items":        `{}`,
+		"recently_played":  `{"items":[]}`,
+	}
+
+	for section, data := range empty {
+		table, err := SectionTable(section, json.RawMessage(data))
+		if err != nil {
+			t.Errorf("SectionTable(%s) error = %v", section, err)
+			continue
+		}
+		if table.Name != section || len(table.Columns) == 0 || len(table.Rows) != 0 {
+			t.Errorf("SectionTable(%s) = %+v", section, table)
+		}
+	}
+
+	if _, err := SectionTable("unknown", json.RawMessage(`[]`)); err == nil {
+		t.Error("expected error for unknown section")
+	}
+}
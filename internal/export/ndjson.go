@@ -0,0 +1,62 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// ndjsonExporter writes tables as newline-delimited JSON, one object per row
+type ndjsonExporter struct{}
+
+func init() {
+	Register("ndjson", ndjsonExporter{})
+}
+
+// Extension implements Exporter
+func (ndjsonExporter) Extension() string { return "ndjson" }
+
+// Write implements Exporter. Keys are written in column order.
+func (ndjsonExporter) Write(w io.Writer, table *Table) error {
+	buf := bufio.NewWriter(w)
+
+	keys := make([][]byte, len(table.Columns))
+	for i, col := range table.Columns {
+		key, err := json.Marshal(col.Name)
+		if err != nil {
+			return err
+		}
+		keys[i] = key
+	}
+
+	for _, row := range table.Rows {
+		if err := buf.WriteByte('{'); err != nil {
+			return err
+		}
+		for i, key := range keys {
+			if i > 0 {
+				if err := buf.WriteByte(','); err != nil {
+					return err
+				}
+			}
+			value, err := json.Marshal(row[i])
+			if err != nil {
+				return err
+			}
+			if _, err := buf.Write(key); err != nil {
+				return err
+			}
+			if err := buf.WriteByte(':'); err != nil {
+				return err
+			}
+			if _, err := buf.Write(value); err != nil {
+				return err
+			}
+		}
+		if _, err := buf.WriteString("}\n"); err != nil {
+			return err
+		}
+	}
+
+	return buf.Flush()
+}
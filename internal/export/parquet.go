@@ -0,0 +1,297 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// parquetExporter writes tables as Apache Parquet files that DuckDB, pandas and
+// Spark can read directly. Files hold a single row group with one uncompressed,
+// PLAIN-encoded data page per column; all columns are required.
+type parquetExporter struct{}
+
+func init() {
+	Register("parquet", parquetExporter{})
+}
+
+// Parquet format constants, from parquet.thrift
+const (
+	parquetMagic = "PAR1"
+
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetRequired      = 0
+	parquetConvertedUTF8 = 0
+	parquetPageData      = 0
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+	parquetCodecNone     = 0
+)
+
+// Extension implements Exporter
+func (parquetExporter) Extension() string { return "parquet" }
+
+// parquetChunk records where a column's data page was written
+type parquetChunk struct {
+	offset int64
+	size   int64
+}
+
+// Write implements Exporter
+func (parquetExporter) Write(w io.Writer, table *Table) error {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	numRows := int64(len(table.Rows))
+	chunks := make([]parquetChunk, len(table.Columns))
+	if numRows > 0 {
+		for i, col := range table.Columns {
+			values, err := encodeParquetColumn(table, i)
+			if err != nil {
+				return fmt.Errorf("column %s: %w", col.Name, err)
+			}
+
+			header := &compactWriter{}
+			header.i32Field(1, parquetPageData)
+			header.i32Field(2, int32(len(values))) // #nosec G115 - pages are far below 2GB
+			header.i32Field(3, int32(len(values))) // #nosec G115
+			header.structBegin(5)
+			header.i32Field(1, int32(numRows)) // #nosec G115
+			header.i32Field(2, parquetEncodingPlain)
+			header.i32Field(3, parquetEncodingRLE)
+			header.i32Field(4, parquetEncodingRLE)
+			header.structEnd()
+			header.stop()
+
+			chunks[i] = parquetChunk{
+				offset: int64(file.Len()),
+				size:   int64(header.buf.Len() + len(values)),
+			}
+			file.Write(header.buf.Bytes())
+			file.Write(values)
+		}
+	}
+
+	footer := encodeParquetFooter(table, numRows, chunks)
+	file.Write(footer)
+	if err := binary.Write(&file, binary.LittleEndian, uint32(len(footer))); err != nil { // #nosec G115
+		return err
+	}
+	file.WriteString(parquetMagic)
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// encodeParquetColumn PLAIN-encodes the values of one column
+func encodeParquetColumn(table *Table, col int) ([]byte, error) {
+	var buf bytes.Buffer
+	switch table.Columns[col].Type {
+	case String:
+		var length [4]byte
+		for _, row := range table.Rows {
+			s, _ := row[col].(string)
+			binary.LittleEndian.PutUint32(length[:], uint32(len(s))) // #nosec G115
+			buf.Write(length[:])
+			buf.WriteString(s)
+		}
+	case Int:
+		var value [8]byte
+		for _, row := range table.Rows {
+			n, _ := row[col].(int64)
+			binary.LittleEndian.PutUint64(value[:], uint64(n)) // #nosec G115
+			buf.Write(value[:])
+		}
+	case Bool:
+		// Bit-packed, least significant bit first
+		packed := make([]byte, (len(table.Rows)+7)/8)
+		for i, row := range table.Rows {
+			if b, _ := row[col].(bool); b {
+				packed[i/8] |= 1 << uint(i%8)
+			}
+		}
+		buf.Write(packed)
+	default:
+		return nil, fmt.Errorf("unsupported column type %d", table.Columns[col].Type)
+	}
+	return buf.Bytes(), nil
+}
+
+// parquetPhysicalType maps a column type to its Parquet physical type
+func parquetPhysicalType(t ColumnType) int32 {
+	switch t {
+	case Int:
+		return parquetInt64
+	case Bool:
+		return parquetBoolean
+	default:
+		return parquetByteArray
+	}
+}
+
+// encodeParquetFooter encodes the FileMetaData struct
+func encodeParquetFooter(table *Table, numRows int64, chunks []parquetChunk) []byte {
+	w := &compactWriter{}
+	w.i32Field(1, 1) // version
+
+	// Schema: a root element followed by one element per column
+	w.listBegin(2, compactStruct, len(table.Columns)+1)
+	w.elemBegin()
+	w.stringField(4, "schema")
+	w.i32Field(5, int32(len(table.Columns))) // #nosec G115
+	w.elemEnd()
+	for _, col := range table.Columns {
+		w.elemBegin()
+		w.i32Field(1, parquetPhysicalType(col.Type))
+		w.i32Field(3, parquetRequired)
+		w.stringField(4, col.Name)
+		if col.Type == String {
+			w.i32Field(6, parquetConvertedUTF8)
+		}
+		w.elemEnd()
+	}
+
+	w.i64Field(3, numRows)
+
+	if numRows == 0 {
+		w.listBegin(4, compactStruct, 0)
+	} else {
+		var totalSize int64
+		for _, chunk := range chunks {
+			totalSize += chunk.size
+		}
+
+		w.listBegin(4, compactStruct, 1)
+		w.elemBegin()
+		w.listBegin(1, compactStruct, len(table.Columns))
+		for i, col := range table.Columns {
+			chunk := chunks[i]
+			w.elemBegin()
+			w.i64Field(2, chunk.offset)
+			w.structBegin(3)
+			w.i32Field(1, parquetPhysicalType(col.Type))
+			w.listBegin(2, compactI32, 1)
+			w.i32(parquetEncodingPlain)
+			w.listBegin(3, compactBinary, 1)
+			w.binary(col.Name)
+			w.i32Field(4, parquetCodecNone)
+			w.i64Field(5, numRows)
+			w.i64Field(6, chunk.size)
+			w.i64Field(7, chunk.size)
+			w.i64Field(9, chunk.offset)
+			w.structEnd()
+			w.elemEnd()
+		}
+		w.i64Field(2, totalSize)
+		w.i64Field(3, numRows)
+		w.elemEnd()
+	}
+
+	w.stringField(6, "spotigo")
+	w.stop()
+	return w.buf.Bytes()
+}
+
+// Thrift compact protocol type codes
+const (
+	compactI32    = 5
+	compactI64    = 6
+	compactBinary = 8
+	compactList   = 9
+	compactStruct = 12
+)
+
+// compactWriter encodes Thrift structs using the compact protocol, as required by Parquet metadata
+type compactWriter struct {
+	buf       bytes.Buffer
+	lastField int16
+	stack     []int16
+}
+
+func (w *compactWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - w.lastField; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(uint64((int64(id) << 1) ^ (int64(id) >> 63))) // #nosec G115 - zigzag encoding
+	}
+	w.lastField = id
+}
+
+func (w *compactWriter) varint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	w.buf.Write(tmp[:n])
+}
+
+func (w *compactWriter) i32(v int32) {
+	w.varint(uint64(uint32((v << 1) ^ (v >> 31)))) // #nosec G115 - zigzag encoding
+}
+
+func (w *compactWriter) i64(v int64) {
+	w.varint(uint64((v << 1) ^ (v >> 63))) // #nosec G115 - zigzag encoding
+}
+
+func (w *compactWriter) binary(s string) {
+	w.varint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *compactWriter) i32Field(id int16, v int32) {
+	w.fieldHeader(id, compactI32)
+	w.i32(v)
+}
+
+func (w *compactWriter) i64Field(id int16, v int64) {
+	w.fieldHeader(id, compactI64)
+	w.i64(v)
+}
+
+func (w *compactWriter) stringField(id int16, s string) {
+	w.fieldHeader(id, compactBinary)
+	w.binary(s)
+}
+
+// structBegin starts a nested struct field
+func (w *compactWriter) structBegin(id int16) {
+	w.fieldHeader(id, compactStruct)
+	w.elemBegin()
+}
+
+// structEnd ends a nested struct field
+func (w *compactWriter) structEnd() {
+	w.elemEnd()
+}
+
+// listBegin starts a list field; elements are written directly after it
+func (w *compactWriter) listBegin(id int16, elemType byte, size int) {
+	w.fieldHeader(id, compactList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elemType)
+		return
+	}
+	w.buf.WriteByte(0xF0 | elemType)
+	w.varint(uint64(size))
+}
+
+// elemBegin starts a struct, either a list element or a nested field
+func (w *compactWriter) elemBegin() {
+	w.stack = append(w.stack, w.lastField)
+	w.lastField = 0
+}
+
+// elemEnd terminates the current struct
+func (w *compactWriter) elemEnd() {
+	w.stop()
+	w.lastField = w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+}
+
+// stop writes a struct terminator
+func (w *compactWriter) stop() {
+	w.buf.WriteByte(0)
+}
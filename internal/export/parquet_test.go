@@ -0,0 +1,185 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// compactReader decodes Thrift compact structs into maps keyed by field ID
+type compactReader struct {
+	r *bytes.Reader
+}
+
+func (c *compactReader) varint() int64 {
+	v, err := binary.ReadUvarint(c.r)
+	if err != nil {
+		panic(err)
+	}
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (c *compactReader) value(typ byte) interface{} {
+	switch typ {
+	case 1:
+		return true
+	case 2:
+		return false
+	case compactI32, compactI64:
+		return c.varint()
+	case compactBinary:
+		n, err := binary.ReadUvarint(c.r)
+		if err != nil {
+			panic(err)
+		}
+		buf := make([]byte, n)
+		if _, err := c.r.Read(buf); err != nil && n > 0 {
+			panic(err)
+		}
+		return string(buf)
+	case compactList:
+		header, _ := c.r.ReadByte()
+		size := int(header >> 4)
+		if size == 15 {
+			n, _ := binary.ReadUvarint(c.r)
+			size = int(n)
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = c.value(header & 0x0f)
+		}
+		return list
+	case compactStruct:
+		return c.structValue()
+	default:
+		panic("unexpected compact type")
+	}
+}
+
+func (c *compactReader) structValue() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var last int16
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			panic(err)
+		}
+		if b == 0 {
+			return fields
+		}
+		id := last + int16(b>>4)
+		if b>>4 == 0 {
+			id = int16(c.varint())
+		}
+		fields[id] = c.value(b & 0x0f)
+		last = id
+	}
+}
+
+func readParquetColumn(t *testing.T, file []byte, offset int64, typ ColumnType, rows int) []interface{} {
+	t.Helper()
+	r := bytes.NewReader(file[offset:])
+	header := (&compactReader{r: r}).structValue()
+	if header[1] != int64(parquetPageData) {
+		t.Fatalf("expected data page, got %v", header[1])
+	}
+	size := header[3].(int64)
+	if dp := header[5].(map[int16]interface{}); dp[1] != int64(rows) {
+		t.Fatalf("page num_values = %v, want %d", dp[1], rows)
+	}
+
+	data := make([]byte, size)
+	if _, err := r.Read(data); err != nil {
+		t.Fatal(err)
+	}
+
+	values := make([]interface{}, 0, rows)
+	for i := 0; i < rows; i++ {
+		switch typ {
+		case String:
+			n := binary.LittleEndian.Uint32(data)
+			values = append(values, string(data[4:4+n]))
+			data = data[4+n:]
+		case Int:
+			values = append(values, int64(binary.LittleEndian.Uint64(data)))
+			data = data[8:]
+		case Bool:
+			values = append(values, data[i/8]&(1<<uint(i%8)) != 0)
+		}
+	}
+	return values
+}
+
+func TestParquetExporter(t *testing.T) {
+	table := sampleTable()
+	// Enough rows to need a multi-byte boolean page and a long list header
+	for i := 0; i < 20; i++ {
+		table.AddRow("filler", int64(-i), i%3 == 0)
+	}
+
+	var buf bytes.Buffer
+	if err := (parquetExporter{}).Write(&buf, table); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	file := buf.Bytes()
+
+	if string(file[:4]) != parquetMagic || string(file[len(file)-4:]) != parquetMagic {
+		t.Fatal("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footer := file[len(file)-8-footerLen : len(file)-8]
+
+	meta := (&compactReader{r: bytes.NewReader(footer)}).structValue()
+	if meta[3] != int64(len(table.Rows)) {
+		t.Errorf("num_rows = %v, want %d", meta[3], len(table.Rows))
+	}
+
+	schema := meta[2].([]interface{})
+	if len(schema) != len(table.Columns)+1 {
+		t.Fatalf("expected %d schema elements, got %d", len(table.Columns)+1, len(schema))
+	}
+	for i, col := range table.Columns {
+		elem := schema[i+1].(map[int16]interface{})
+		if elem[4] != col.Name || elem[1] != int64(parquetPhysicalType(col.Type)) || elem[3] != int64(parquetRequired) {
+			t.Errorf("schema element %d = %v", i, elem)
+		}
+	}
+
+	rowGroups := meta[4].([]interface{})
+	if len(rowGroups) != 1 {
+		t.Fatalf("expected 1 row group, got %d", len(rowGroups))
+	}
+	chunks := rowGroups[0].(map[int16]interface{})[1].([]interface{})
+	for i, col := range table.Columns {
+		md := chunks[i].(map[int16]interface{})[3].(map[int16]interface{})
+		if path := md[3].([]interface{}); len(path) != 1 || path[0] != col.Name {
+			t.Errorf("path_in_schema = %v", path)
+		}
+
+		got := readParquetColumn(t, file, md[9].(int64), col.Type, len(table.Rows))
+		want := make([]interface{}, len(table.Rows))
+		for r, row := range table.Rows {
+			want[r] = row[i]
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("column %s = %v, want %v", col.Name, got, want)
+		}
+	}
+}
+
+func TestParquetExporter_Empty(t *testing.T) {
+	table := &Table{Name: "empty", Columns: []Column{{"id", String}}}
+
+	var buf bytes.Buffer
+	if err := (parquetExporter{}).Write(&buf, table); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	file := buf.Bytes()
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	meta := (&compactReader{r: bytes.NewReader(file[len(file)-8-footerLen : len(file)-8])}).structValue()
+
+	if meta[3] != int64(0) || len(meta[4].([]interface{})) != 0 {
+		t.Errorf("unexpected metadata for empty table: %v", meta)
+	}
+}
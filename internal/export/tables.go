@@ -0,0 +1,384 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// listSeparator joins multi-valued fields such as artists and genres
+const listSeparator = "; "
+
+// sectionTables maps backup sections to the functions that flatten them
+var sectionTables = map[string]func(json.RawMessage) (*Table, error){
+	"saved_tracks":     savedTracksTable,
+	"playlists":        playlistsTable,
+	"followed_artists": followedArtistsTable,
+	"saved_albums":     savedAlbumsTable,
+	"saved_shows":      savedShowsTable,
+	"saved_episodes":   savedEpisodesTable,
+	"top_items":        topItemsTable,
+	"recently_played":  recentlyPlayedTable,
+}
+
+// SectionTable flattens a backup section, as stored in <section>.json, into a table
+func SectionTable(section string, data json.RawMessage) (*Table, error) {
+	flatten, ok := sectionTables[section]
+	if !ok {
+		return nil, fmt.Errorf("unsupported backup section %q", section)
+	}
+	table, err := flatten(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to flatten %s: %w", section, err)
+	}
+	return table, nil
+}
+
+// number decodes JSON numbers written as either integers or floats
+type number int64
+
+// UnmarshalJSON implements json.Unmarshaler
+func (n *number) UnmarshalJSON(b []byte) error {
+	var f float64
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	*n = number(f)
+	return nil
+}
+
+type namedRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type trackRecord struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Artists     []namedRef        `json:"artists"`
+	Album       albumRef          `json:"album"`
+	DurationMS  number            `json:"duration_ms"`
+	Popularity  number            `json:"popularity"`
+	Explicit    bool              `json:"explicit"`
+	ExternalIDs map[string]string `json:"external_ids"`
+	URI         string            `json:"uri"`
+}
+
+type albumRef struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	ReleaseDate string `json:"release_date"`
+}
+
+type albumRecord struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Artists     []namedRef `json:"artists"`
+	AlbumType   string     `json:"album_type"`
+	ReleaseDate string     `json:"release_date"`
+	TotalTracks number     `json:"total_tracks"`
+	Popularity  number     `json:"popularity"`
+	URI         string     `json:"uri"`
+}
+
+type artistRecord struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Genres     []string `json:"genres"`
+	Popularity number   `json:"popularity"`
+	Followers  struct {
+		Total number `json:"total"`
+	} `json:"followers"`
+	URI string `json:"uri"`
+}
+
+type showRecord struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Publisher string `json:"publisher"`
+	MediaType string `json:"media_type"`
+	Explicit  bool   `json:"explicit"`
+	URI       string `json:"uri"`
+}
+
+type episodeRecord struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Show        showRecord `json:"show"`
+	ReleaseDate string     `json:"release_date"`
+	DurationMS  number     `json:"duration_ms"`
+	ResumePoint struct {
+		FullyPlayed bool `json:"fully_played"`
+	} `json:"resume_point"`
+	URI string `json:"uri"`
+}
+
+func artistNames(artists []namedRef) string {
+	names := make([]string, 0, len(artists))
+	for _, a := range artists {
+		names = append(names, a.Name)
+	}
+	return strings.Join(names, listSeparator)
+}
+
+func savedTracksTable(data json.RawMessage) (*Table, error) {
+	var items []struct {
+		AddedAt string      `json:"added_at"`
+		Track   trackRecord `json:"track"`
+	}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+
+	table := &Table{
+		Name: "saved_tracks",
+		Columns: []Column{
+			{"added_at", String}, {"track_id", String}, {"name", String}, {"artists", String},
+			{"album", String}, {"release_date", String}, {"duration_ms", Int}, {"popularity", Int},
+			{"explicit", Bool}, {"isrc", String}, {"uri", String},
+		},
+	}
+	for _, item := range items {
+		t := item.Track
+		table.AddRow(item.AddedAt, t.ID, t.Name, artistNames(t.Artists),
+			t.Album.Name, t.Album.ReleaseDate, int64(t.DurationMS), int64(t.Popularity),
+			t.Explicit, t.ExternalIDs["isrc"], t.URI)
+	}
+	return table, nil
+}
+
+// playlistsTable writes one row per playlist item. For podcast episodes the
+// album column holds the show name and the artists column its publisher.
+func playlistsTable(data json.RawMessage) (*Table, error) {
+	var playlists []struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Owner  string `json:"owner"`
+		Tracks []struct {
+			AddedAt string `json:"added_at"`
+			AddedBy struct {
+				ID string `json:"id"`
+			} `json:"added_by"`
+			IsLocal bool `json:"is_local"`
+			// Stored as {"Track": ..., "Episode": ...}; field matching is case-insensitive
+			Item struct {
+				Track   *trackRecord   `json:"track"`
+				Episode *episodeRecord `json:"episode"`
+			} `json:"track"`
+		} `json:"tracks"`
+	}
+	if err := json.Unmarshal(data, &playlists); err != nil {
+		return nil, err
+	}
+
+	table := &Table{
+		Name: "playlists",
+		Columns: []Column{
+			{"playlist_id", String}, {"playlist_name", String}, {"owner", String}, {"position", Int},
+			{"added_at", String}, {"added_by", String}, {"is_local", Bool}, {"item_type", String},
+			{"item_id", String}, {"name", String}, {"artists", String}, {"album", String},
+			{"duration_ms", Int}, {"uri", String},
+		},
+	}
+	for _, p := range playlists {
+		for i, item := range p.Tracks {
+			itemType, id, name, artists, album, uri := "unavailable", "", "", "", "", ""
+			var duration int64
+			switch {
+			case item.Item.Track != nil:
+				t := item.Item.Track
+				itemType, id, name, uri = "track", t.ID, t.Name, t.URI
+				artists, album, duration = artistNames(t.Artists), t.Album.Name, int64(t.DurationMS)
+			case item.Item.Episode != nil:
+				e := item.Item.Episode
+				itemType, id, name, uri = "episode", e.ID, e.Name, e.URI
+				artists, album, duration = e.Show.Publisher, e.Show.Name, int64(e.DurationMS)
+			}
+			table.AddRow(p.ID, p.Name, p.Owner, int64(i+1),
+				item.AddedAt, item.AddedBy.ID, item.IsLocal, itemType,
+				id, name, artists, album, duration, uri)
+		}
+	}
+	return table, nil
+}
+
+func followedArtistsTable(data json.RawMessage) (*Table, error) {
+	var artists []artistRecord
+	if err := json.Unmarshal(data, &artists); err != nil {
+		return nil, err
+	}
+
+	table := &Table{
+		Name: "followed_artists",
+		Columns: []Column{
+			{"artist_id", String}, {"name", String}, {"genres", String},
+			{"popularity", Int}, {"followers", Int}, {"uri", String},
+		},
+	}
+	for _, a := range artists {
+		table.AddRow(a.ID, a.Name, strings.Join(a.Genres, listSeparator),
+			int64(a.Popularity), int64(a.Followers.Total), a.URI)
+	}
+	return table, nil
+}
+
+func savedAlbumsTable(data json.RawMessage) (*Table, error) {
+	var items []struct {
+		AddedAt string      `json:"added_at"`
+		Album   albumRecord `json:"album"`
+	}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+
+	table := &Table{
+		Name: "saved_albums",
+		Columns: []Column{
+			{"added_at", String}, {"album_id", String}, {"name", String}, {"artists", String},
+			{"album_type", String}, {"release_date", String}, {"total_tracks", Int},
+			{"popularity", Int}, {"uri", String},
+		},
+	}
+	for _, item := range items {
+		a := item.Album
+		table.AddRow(item.AddedAt, a.ID, a.Name, artistNames(a.Artists),
+			a.AlbumType, a.ReleaseDate, int64(a.TotalTracks), int64(a.Popularity), a.URI)
+	}
+	return table, nil
+}
+
+func savedShowsTable(data json.RawMessage) (*Table, error) {
+	var items []struct {
+		AddedAt string     `json:"added_at"`
+		Show    showRecord `json:"show"`
+	}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+
+	table := &Table{
+		Name: "saved_shows",
+		Columns: []Column{
+			{"added_at", String}, {"show_id", String}, {"name", String}, {"publisher", String},
+			{"media_type", String}, {"explicit", Bool}, {"uri", String},
+		},
+	}
+	for _, item := range items {
+		s := item.Show
+		table.AddRow(item.AddedAt, s.ID, s.Name, s.Publisher, s.MediaType, s.Explicit, s.URI)
+	}
+	return table, nil
+}
+
+func savedEpisodesTable(data json.RawMessage) (*Table, error) {
+	var items []struct {
+		AddedAt string        `json:"added_at"`
+		Episode episodeRecord `json:"episode"`
+	}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+
+	table := &Table{
+		Name: "saved_episodes",
+		Columns: []Column{
+			{"added_at", String}, {"episode_id", String}, {"name", String}, {"show", String},
+			{"release_date", String}, {"duration_ms", Int}, {"fully_played", Bool}, {"uri", String},
+		},
+	}
+	for _, item := range items {
+		e := item.Episode
+		table.AddRow(item.AddedAt, e.ID, e.Name, e.Show.Name,
+			e.ReleaseDate, int64(e.DurationMS), e.ResumePoint.FullyPlayed, e.URI)
+	}
+	return table, nil
+}
+
+// topRangeOrder lists the known time ranges in display order
+var topRangeOrder = []string{"short", "medium", "long"}
+
+// orderedRanges returns the keys of a time-range map, known ranges first
+func orderedRanges[T any](ranges map[string]T) []string {
+	keys := make([]string, 0, len(ranges))
+	for _, r := range topRangeOrder {
+		if _, ok := ranges[r]; ok {
+			keys = append(keys, r)
+		}
+	}
+	var others []string
+	for r := range ranges {
+		known := false
+		for _, k := range topRangeOrder {
+			known = known || r == k
+		}
+		if !known {
+			others = append(others, r)
+		}
+	}
+	sort.Strings(others)
+	return append(keys, others...)
+}
+
+func topItemsTable(data json.RawMessage) (*Table, error) {
+	var top struct {
+		CapturedAt string                    `json:"captured_at"`
+		Tracks     map[string][]trackRecord  `json:"tracks"`
+		Artists    map[string][]artistRecord `json:"artists"`
+	}
+	if err := json.Unmarshal(data, &top); err != nil {
+		return nil, err
+	}
+
+	table := &Table{
+		Name: "top_items",
+		Columns: []Column{
+			{"captured_at", String}, {"time_range", String}, {"kind", String}, {"rank", Int},
+			{"id", String}, {"name", String}, {"artists", String}, {"genres", String},
+			{"popularity", Int}, {"uri", String},
+		},
+	}
+	for _, r := range orderedRanges(top.Tracks) {
+		for i, t := range top.Tracks[r] {
+			table.AddRow(top.CapturedAt, r, "track", int64(i+1),
+				t.ID, t.Name, artistNames(t.Artists), "", int64(t.Popularity), t.URI)
+		}
+	}
+	for _, r := range orderedRanges(top.Artists) {
+		for i, a := range top.Artists[r] {
+			table.AddRow(top.CapturedAt, r, "artist", int64(i+1),
+				a.ID, a.Name, a.Name, strings.Join(a.Genres, listSeparator), int64(a.Popularity), a.URI)
+		}
+	}
+	return table, nil
+}
+
+func recentlyPlayedTable(data json.RawMessage) (*Table, error) {
+	var recent struct {
+		Items []struct {
+			PlayedAt string      `json:"played_at"`
+			Track    trackRecord `json:"track"`
+			Context  struct {
+				Type string `json:"type"`
+				URI  string `json:"uri"`
+			} `json:"context"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &recent); err != nil {
+		return nil, err
+	}
+
+	table := &Table{
+		Name: "recently_played",
+		Columns: []Column{
+			{"played_at", String}, {"track_id", String}, {"name", String}, {"artists", String},
+			{"duration_ms", Int}, {"context_type", String}, {"context_uri", String}, {"uri", String},
+		},
+	}
+	for _, item := range recent.Items {
+		t := item.Track
+		table.AddRow(item.PlayedAt, t.ID, t.Name, artistNames(t.Artists),
+			int64(t.DurationMS), item.Context.Type, item.Context.URI, t.URI)
+	}
+	return table, nil
+}
@@ -0,0 +1,153 @@
+package history
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// exportEntry is one play in Spotify's extended streaming history. Fields
+// such as the IP address and user agent are deliberately not read.
+type exportEntry struct {
+	Timestamp   string `json:"ts"`
+	Platform    string `json:"platform"`
+	MsPlayed    int64  `json:"ms_played"`
+	TrackName   string `json:"master_metadata_track_name"`
+	ArtistName  string `json:"master_metadata_album_artist_name"`
+	AlbumName   string `json:"master_metadata_album_album_name"`
+	TrackURI    string `json:"spotify_track_uri"`
+	EpisodeName string `json:"episode_name"`
+	ShowName    string `json:"episode_show_name"`
+	EpisodeURI  string `json:"spotify_episode_uri"`
+	ReasonStart string `json:"reason_start"`
+	ReasonEnd   string `json:"reason_end"`
+	Shuffle     *bool  `json:"shuffle"`
+	Skipped     *bool  `json:"skipped"`
+}
+
+// IsExportFile reports whether name is an extended streaming history file:
+// Streaming_History_Audio_*.json, or endsong_*.json in older exports
+func IsExportFile(name string) bool {
+	base := path.Base(filepath.ToSlash(name))
+	if !strings.HasSuffix(base, ".json") {
+		return false
+	}
+	return strings.HasPrefix(base, "Streaming_History_Audio_") || strings.HasPrefix(base, "endsong_")
+}
+
+// ParseExport reads the plays from one extended streaming history file.
+// Entries that are neither a track nor an episode, such as audiobook
+// chapters or plays with their metadata removed, are skipped.
+func ParseExport(r io.Reader) ([]Play, error) {
+	var entries []exportEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to decode streaming history: %w", err)
+	}
+
+	plays := make([]Play, 0, len(entries))
+	for _, e := range entries {
+		playedAt, err := time.Parse(time.RFC3339, e.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid play time %q: %w", e.Timestamp, err)
+		}
+
+		play := Play{
+			PlayedAt:    playedAt.UTC(),
+			MsPlayed:    e.MsPlayed,
+			Platform:    e.Platform,
+			ReasonStart: e.ReasonStart,
+			ReasonEnd:   e.ReasonEnd,
+			Shuffle:     e.Shuffle != nil && *e.Shuffle,
+			Skipped:     e.Skipped != nil && *e.Skipped,
+			Source:      SourceExport,
+		}
+		switch {
+		case e.TrackName != "":
+			play.Type = "track"
+			play.URI = e.TrackURI
+			play.Name = e.TrackName
+			play.Artist = e.ArtistName
+			play.Album = e.AlbumName
+		case e.EpisodeName != "":
+			play.Type = "episode"
+			play.URI = e.EpisodeURI
+			play.Name = e.EpisodeName
+			play.Artist = e.ShowName
+		default:
+			continue
+		}
+		play.ID = idFromURI(play.URI)
+		plays = append(plays, play)
+	}
+	return plays, nil
+}
+
+// ReadExport reads every extended streaming history file in a directory,
+// searched recursively, or in the zip archive Spotify sends
+func ReadExport(src string) ([]Play, int, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open export: %w", err)
+	}
+	if info.IsDir() {
+		return readExportFS(os.DirFS(src))
+	}
+
+	archive, err := zip.OpenReader(src)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open export archive: %w", err)
+	}
+	defer func() { _ = archive.Close() }()
+	return readExportFS(archive)
+}
+
+// readExportFS reads the history files in fsys and returns the plays with
+// the number of files read
+func readExportFS(fsys fs.FS) ([]Play, int, error) {
+	var plays []Play
+	files := 0
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !IsExportFile(name) {
+			return nil
+		}
+
+		f, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+
+		filePlays, err := ParseExport(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		plays = append(plays, filePlays...)
+		files++
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read export: %w", err)
+	}
+	if files == 0 {
+		return nil, 0, fmt.Errorf("no Streaming_History_Audio_*.json files found; request your extended streaming history from Spotify")
+	}
+	return plays, files, nil
+}
+
+// idFromURI returns the ID part of a spotify:track:<id> style URI
+func idFromURI(uri string) string {
+	if i := strings.LastIndex(uri, ":"); i >= 0 {
+		return uri[i+1:]
+	}
+	return ""
+}
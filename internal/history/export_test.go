@@ -0,0 +1,136 @@
+package history
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const exportSample = `[
+  {"ts": "2023-05-01T08:30:00Z", "username": "someone", "platform": "android", "ms_played": 215000,
+   "conn_country": "GB", "ip_addr_decrypted": "192.0.2.1",
+   "master_metadata_track_name": "Karma Police", "master_metadata_album_artist_name": "Radiohead",
+   "master_metadata_album_album_name": "OK Computer", "spotify_track_uri": "spotify:track:63OQupATfueTdZMWTxW03A",
+   "episode_name": null, "episode_show_name": null, "spotify_episode_uri": null,
+   "reason_start": "trackdone", "reason_end": "trackdone", "shuffle": true, "skipped": null},
+  {"ts": "2023-05-01T09:00:00Z", "platform": "web_player", "ms_played": 1200000,
+   "master_metadata_track_name": null, "spotify_track_uri": null,
+   "episode_name": "Episode 12", "episode_show_name": "A Podcast", "spotify_episode_uri": "spotify:episode:ep12",
+   "reason_start": "clickrow", "reason_end": "endplay", "shuffle": false, "skipped": false},
+  {"ts": "2023-05-01T09:30:00Z", "ms_played": 3000, "master_metadata_track_name": null, "episode_name": null,
+   "reason_start": "fwdbtn", "reason_end": "fwdbtn", "skipped": true}
+]`
+
+func TestParseExport(t *testing.T) {
+	plays, err := ParseExport(strings.NewReader(exportSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plays) != 2 {
+		t.Fatalf("ParseExport() returned %d plays, want 2", len(plays))
+	}
+
+	track := plays[0]
+	if track.Type != "track" || track.ID != "63OQupATfueTdZMWTxW03A" || track.Name != "Karma Police" ||
+		track.Artist != "Radiohead" || track.Album != "OK Computer" || track.MsPlayed != 215000 ||
+		!track.Shuffle || track.Skipped || track.Source != SourceExport {
+		t.Errorf("track play = %+v", track)
+	}
+	if track.PlayedAt.Format("2006-01-02 15:04") != "2023-05-01 08:30" {
+		t.Errorf("PlayedAt = %v", track.PlayedAt)
+	}
+
+	episode := plays[1]
+	if episode.Type != "episode" || episode.Name != "Episode 12" || episode.Artist != "A Podcast" || episode.ID != "ep12" {
+		t.Errorf("episode play = %+v", episode)
+	}
+
+	if _, err := ParseExport(strings.NewReader(`[{"ts": "yesterday"}]`)); err == nil {
+		t.Error("expected error for an invalid timestamp")
+	}
+	if _, err := ParseExport(strings.NewReader(`{"not": "a list"}`)); err == nil {
+		t.Error("expected error for a non-list file")
+	}
+}
+
+func TestIsExportFile(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"Streaming_History_Audio_2023_4.json", true},
+		{"Spotify Extended Streaming History/Streaming_History_Audio_2019-2021_0.json", true},
+		{"MyData/endsong_0.json", true},
+		{"Streaming_History_Video_2023.json", false},
+		{"StreamingHistory_music_0.json", false},
+		{"Streaming_History_Audio_2023_4.pdf", false},
+	}
+	for _, tt := range tests {
+		if got := IsExportFile(tt.name); got != tt.want {
+			t.Errorf("IsExportFile(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadExport(t *testing.T) {
+	dir := t.TempDir()
+	exportDir := filepath.Join(dir, "Spotify Extended Streaming History")
+	if err := os.MkdirAll(exportDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"Streaming_History_Audio_2023_0.json":      exportSample,
+		"Streaming_History_Video_2023.json":        `[{"ts": "invalid"}]`,
+		"ReadMeFirst_ExtendedStreamingHistory.pdf": "not json",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(exportDir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plays, count, err := ReadExport(dir)
+	if err != nil {
+		t.Fatalf("ReadExport(dir) error = %v", err)
+	}
+	if count != 1 || len(plays) != 2 {
+		t.Errorf("ReadExport(dir) = %d plays from %d files, want 2 from 1", len(plays), count)
+	}
+
+	// The same files inside the zip archive Spotify sends
+	archive := filepath.Join(dir, "my_spotify_data.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, data := range files {
+		w, err := zw.Create("Spotify Extended Streaming History/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	plays, count, err = ReadExport(archive)
+	if err != nil {
+		t.Fatalf("ReadExport(zip) error = %v", err)
+	}
+	if count != 1 || len(plays) != 2 {
+		t.Errorf("ReadExport(zip) = %d plays from %d files, want 2 from 1", len(plays), count)
+	}
+
+	if _, _, err := ReadExport(t.TempDir()); err == nil {
+		t.Error("expected error for a directory without history files")
+	}
+}
@@ -0,0 +1,189 @@
+// Package history keeps a log of individual plays, imported from Spotify's
+// data export or recorded from the recently played API
+package history
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileName is the data file holding the play log
+const FileName = "play_history.json"
+
+// MinCountedPlay is how long a track must play to count as a listen,
+// matching the threshold Spotify uses for stream counts
+const MinCountedPlay = 30 * time.Second
+
+// Play sources
+const (
+	// SourceExport marks plays imported from the "Download your data" export
+	SourceExport = "export"
+	// SourceRecent marks plays read from the recently played API
+	SourceRecent = "recent"
+)
+
+// Play is a single play of a track or podcast episode
+type Play struct {
+	PlayedAt    time.Time `json:"played_at"`
+	Type        string    `json:"type"` // track or episode
+	ID          string    `json:"id,omitempty"`
+	URI         string    `json:"uri,omitempty"`
+	Name        string    `json:"name"`
+	Artist      string    `json:"artist,omitempty"` // artist, or the show for episodes
+	Album       string    `json:"album,omitempty"`
+	MsPlayed    int64     `json:"ms_played"`
+	Platform    string    `json:"platform,omitempty"`
+	ReasonStart string    `json:"reason_start,omitempty"`
+	ReasonEnd   string    `json:"reason_end,omitempty"`
+	Shuffle     bool      `json:"shuffle,omitempty"`
+	Skipped     bool      `json:"skipped,omitempty"`
+	Source      string    `json:"source"`
+}
+
+// Key identifies a play across imports. Spotify reports the end of a play to
+// the second, so the time and the item are enough to spot duplicates.
+func (p Play) Key() string {
+	item := p.URI
+	if item == "" {
+		item = strings.ToLower(p.Name + "\x00" + p.Artist)
+	}
+	return p.PlayedAt.UTC().Format(time.RFC3339) + "\x00" + item
+}
+
+// Counted reports whether the play lasted long enough to count as a listen.
+// Plays without a duration, as from the recently played API, always count.
+func (p Play) Counted() bool {
+	return p.MsPlayed == 0 || p.MsPlayed >= MinCountedPlay.Milliseconds()
+}
+
+// Merge adds plays to a log, skipping ones it already has, and returns the
+// log sorted by time with the number of plays added
+func Merge(log, plays []Play) ([]Play, int) {
+	seen := make(map[string]bool, len(log))
+	for _, p := range log {
+		seen[p.Key()] = true
+	}
+
+	merged := append([]Play{}, log...)
+	added := 0
+	for _, p := range plays {
+		key := p.Key()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		merged = append(merged, p)
+		added++
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].PlayedAt.Before(merged[j].PlayedAt)
+	})
+	return merged, added
+}
+
+// Since returns the plays at or after t. A zero t returns every play.
+func Since(plays []Play, t time.Time) []Play {
+	if t.IsZero() {
+		return plays
+	}
+	var recent []Play
+	for _, p := range plays {
+		if !p.PlayedAt.Before(t) {
+			recent = append(recent, p)
+		}
+	}
+	return recent
+}
+
+// Count is a ranked item with its number of listens and time played
+type Count struct {
+	Name     string
+	Plays    int
+	MsPlayed int64
+}
+
+// Top ranks the counted plays by key, e.g. the artist, most played first.
+// Plays with an empty key are ignored.
+func Top(plays []Play, key func(Play) string) []Count {
+	index := make(map[string]int)
+	var counts []Count
+	for _, p := range plays {
+		name := key(p)
+		if name == "" || !p.Counted() {
+			continue
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(counts)
+			index[name] = i
+			counts = append(counts, Count{Name: name})
+		}
+		counts[i].Plays++
+		counts[i].MsPlayed += p.MsPlayed
+	}
+
+	sort.SliceStable(counts, func(i, j int) bool {
+		if counts[i].Plays != counts[j].Plays {
+			return counts[i].Plays > counts[j].Plays
+		}
+		return counts[i].Name < counts[j].Name
+	})
+	return counts
+}
+
+// TrackSummary aggregates the plays of one track or episode
+type TrackSummary struct {
+	Type        string
+	ID          string
+	Name        string
+	Artist      string
+	Album       string
+	Plays       int
+	MsPlayed    int64
+	FirstPlayed time.Time
+	LastPlayed  time.Time
+}
+
+// Summarize groups counted plays by item, most played first
+func Summarize(plays []Play) []TrackSummary {
+	index := make(map[string]int)
+	var summaries []TrackSummary
+	for _, p := range plays {
+		if !p.Counted() {
+			continue
+		}
+		key := p.URI
+		if key == "" {
+			key = strings.ToLower(p.Name + "\x00" + p.Artist)
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(summaries)
+			index[key] = i
+			summaries = append(summaries, TrackSummary{
+				Type:        p.Type,
+				ID:          p.ID,
+				Name:        p.Name,
+				Artist:      p.Artist,
+				Album:       p.Album,
+				FirstPlayed: p.PlayedAt,
+			})
+		}
+		s := &summaries[i]
+		s.Plays++
+		s.MsPlayed += p.MsPlayed
+		if p.PlayedAt.Before(s.FirstPlayed) {
+			s.FirstPlayed = p.PlayedAt
+		}
+		if p.PlayedAt.After(s.LastPlayed) {
+			s.LastPlayed = p.PlayedAt
+		}
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].Plays > summaries[j].Plays
+	})
+	return summaries
+}
@@ -0,0 +1,93 @@
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+func play(at, uri, name, artist string, ms int64) Play {
+	t, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		panic(err)
+	}
+	return Play{PlayedAt: t, Type: "track", URI: uri, ID: idFromURI(uri), Name: name, Artist: artist, MsPlayed: ms}
+}
+
+func TestMerge(t *testing.T) {
+	log := []Play{
+		play("2024-01-02T10:00:00Z", "spotify:track:b", "B", "Y", 200000),
+		play("2024-01-01T10:00:00Z", "spotify:track:a", "A", "X", 200000),
+	}
+	plays := []Play{
+		play("2024-01-01T10:00:00Z", "spotify:track:a", "A", "X", 200000), // already in the log
+		play("2024-01-03T10:00:00Z", "spotify:track:a", "A", "X", 200000),
+		play("2024-01-03T10:00:00Z", "spotify:track:a", "A", "X", 200000), // duplicated within the import
+		play("2023-12-31T10:00:00Z", "", "Local file", "Z", 100000),
+	}
+
+	merged, added := Merge(log, plays)
+	if added != 2 {
+		t.Errorf("added = %d, want 2", added)
+	}
+	if len(merged) != 4 {
+		t.Fatalf("len(merged) = %d, want 4", len(merged))
+	}
+	for i := 1; i < len(merged); i++ {
+		if merged[i].PlayedAt.Before(merged[i-1].PlayedAt) {
+			t.Errorf("merged log not sorted at %d: %v", i, merged)
+		}
+	}
+	if merged[0].Name != "Local file" {
+		t.Errorf("first play = %q, want the oldest", merged[0].Name)
+	}
+
+	if _, added := Merge(merged, plays); added != 0 {
+		t.Errorf("re-merging added %d plays, want 0", added)
+	}
+}
+
+func TestSince(t *testing.T) {
+	plays := []Play{
+		play("2024-01-01T00:00:00Z", "spotify:track:a", "A", "X", 0),
+		play("2024-02-01T00:00:00Z", "spotify:track:b", "B", "X", 0),
+		play("2024-03-01T00:00:00Z", "spotify:track:c", "C", "X", 0),
+	}
+
+	cutoff, _ := time.Parse(time.RFC3339, "2024-02-01T00:00:00Z")
+	if got := Since(plays, cutoff); len(got) != 2 || got[0].Name != "B" {
+		t.Errorf("Since() = %v, want B and C", got)
+	}
+	if got := Since(plays, time.Time{}); len(got) != 3 {
+		t.Errorf("Since(zero) returned %d plays, want 3", len(got))
+	}
+}
+
+func TestTopAndSummarize(t *testing.T) {
+	plays := []Play{
+		play("2024-01-01T00:00:00Z", "spotify:track:a", "A", "X", 200000),
+		play("2024-01-02T00:00:00Z", "spotify:track:a", "A", "X", 200000),
+		play("2024-01-03T00:00:00Z", "spotify:track:b", "B", "Y", 180000),
+		play("2024-01-04T00:00:00Z", "spotify:track:c", "C", "Y", 5000), // skipped after 5s
+		play("2024-01-05T00:00:00Z", "spotify:track:d", "D", "Y", 0),    // no duration recorded
+	}
+
+	top := Top(plays, func(p Play) string { return p.Artist })
+	if len(top) != 2 {
+		t.Fatalf("Top() = %v, want 2 artists", top)
+	}
+	if top[0] != (Count{Name: "X", Plays: 2, MsPlayed: 400000}) {
+		t.Errorf("top[0] = %+v", top[0])
+	}
+	if top[1] != (Count{Name: "Y", Plays: 2, MsPlayed: 180000}) {
+		t.Errorf("top[1] = %+v", top[1])
+	}
+
+	summaries := Summarize(plays)
+	if len(summaries) != 3 {
+		t.Fatalf("Summarize() returned %d items, want 3", len(summaries))
+	}
+	a := summaries[0]
+	if a.ID != "a" || a.Plays != 2 || !a.FirstPlayed.Before(a.LastPlayed) {
+		t.Errorf("summary for A = %+v", a)
+	}
+}
@@ -0,0 +1,409 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LogDir is the directory in the data dir holding the recorder's log
+const LogDir = "plays"
+
+// legacyLogDir held the log in earlier versions. Backups keep their history
+// snapshots there too, so the log moved to a directory of its own.
+const legacyLogDir = "history"
+
+// DefaultMaxLogSize is the size at which the current log segment is rotated
+const DefaultMaxLogSize = 5 << 20
+
+const (
+	currentSegment = "plays.ndjson"
+	segmentPrefix  = "plays-"
+	segmentSuffix  = ".ndjson"
+	cursorFile     = "cursor.json"
+
+	// segmentTimeFormat sorts rotated segments by the time they were closed
+	segmentTimeFormat = "20060102-150405.000"
+)
+
+// Log is an append-only log of plays, one per line. Lines are only ever
+// added, and the current segment is closed and renamed once it reaches
+// MaxSize, so a crash can at worst lose a partly written last line.
+type Log struct {
+	Dir     string
+	MaxSize int64
+
+	// Seal, if set, encodes each line before it is written, e.g. to encrypt
+	// it, and Open reverses it. Sealed lines are stored base64 encoded.
+	Seal func([]byte) ([]byte, error)
+	Open func([]byte) ([]byte, error)
+}
+
+// Cursor records how far the recorder has read the recently played list
+type Cursor struct {
+	After     time.Time `json:"after"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewLog returns a log in dir with the default segment size
+func NewLog(dir string) *Log {
+	return &Log{Dir: dir, MaxSize: DefaultMaxLogSize}
+}
+
+// MigrateLog moves a log left in the legacy directory of dataDir to LogDir.
+// Files already present in LogDir are not overwritten, so it is safe to run
+// every time the log is opened.
+func MigrateLog(dataDir string) error {
+	legacy := NewLog(filepath.Join(dataDir, legacyLogDir))
+	segments, err := legacy.Segments()
+	if err != nil {
+		return err
+	}
+	cursor := filepath.Join(legacy.Dir, cursorFile)
+	if _, err := os.Stat(cursor); err == nil {
+		segments = append(segments, cursor)
+	}
+	if len(segments) == 0 {
+		return nil
+	}
+
+	dir := filepath.Join(dataDir, LogDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	for _, path := range segments {
+		target := filepath.Join(dir, filepath.Base(path))
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+		if err := os.Rename(path, target); err != nil {
+			return fmt.Errorf("failed to move history log: %w", err)
+		}
+	}
+	return nil
+}
+
+// Append adds plays to the end of the log, rotating the current segment
+// first if it is full
+func (l *Log) Append(plays []Play) error {
+	if len(plays) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(l.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	if err := l.rotateIfFull(time.Now()); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, p := range plays {
+		line, err := l.encodeLine(p)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	path := filepath.Join(l.Dir, currentSegment)
+	if err := repairTail(path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600) // #nosec G304 - path is sanitized with filepath.Clean
+	if err != nil {
+		return fmt.Errorf("failed to open history log: %w", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to append to history log: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to sync history log: %w", err)
+	}
+	return f.Close()
+}
+
+// repairTail drops a partly written last line, left by a crash mid-write,
+// so that the next line starts on a line of its own
+func repairTail(path string) error {
+	data, err := os.ReadFile(filepath.Clean(path)) // #nosec G304 - path is sanitized with filepath.Clean
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read history log: %w", err)
+	}
+	if len(data) == 0 || data[len(data)-1] == '\n' {
+		return nil
+	}
+	if err := os.Truncate(path, int64(bytes.LastIndexByte(data, '\n')+1)); err != nil {
+		return fmt.Errorf("failed to repair history log: %w", err)
+	}
+	return nil
+}
+
+// rotateIfFull renames the current segment once it has reached MaxSize
+func (l *Log) rotateIfFull(now time.Time) error {
+	if l.MaxSize <= 0 {
+		return nil
+	}
+	path := filepath.Join(l.Dir, currentSegment)
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() < l.MaxSize {
+		return nil
+	}
+
+	// Segment names must stay unique and in order, even for rotations
+	// within the same millisecond
+	rotated := filepath.Join(l.Dir, segmentPrefix+now.UTC().Format(segmentTimeFormat)+segmentSuffix)
+	for {
+		if _, err := os.Stat(rotated); err != nil {
+			break
+		}
+		now = now.Add(time.Millisecond)
+		rotated = filepath.Join(l.Dir, segmentPrefix+now.UTC().Format(segmentTimeFormat)+segmentSuffix)
+	}
+	if err := os.Rename(path, rotated); err != nil {
+		return fmt.Errorf("failed to rotate history log: %w", err)
+	}
+	return nil
+}
+
+// Segments returns the log files oldest first: the rotated segments, then
+// the current one
+func (l *Log) Segments() ([]string, error) {
+	entries, err := os.ReadDir(l.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history directory: %w", err)
+	}
+
+	var segments []string
+	hasCurrent := false
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case name == currentSegment:
+			hasCurrent = true
+		case strings.HasPrefix(name, segmentPrefix) && strings.HasSuffix(name, segmentSuffix):
+			segments = append(segments, filepath.Join(l.Dir, name))
+		}
+	}
+	sort.Strings(segments)
+	if hasCurrent {
+		segments = append(segments, filepath.Join(l.Dir, currentSegment))
+	}
+	return segments, nil
+}
+
+// ReadAll returns every play in the log in the order it was recorded
+func (l *Log) ReadAll() ([]Play, error) {
+	segments, err := l.Segments()
+	if err != nil {
+		return nil, err
+	}
+	var plays []Play
+	for _, segment := range segments {
+		segmentPlays, err := l.readSegment(segment)
+		if err != nil {
+			return nil, err
+		}
+		plays = append(plays, segmentPlays...)
+	}
+	return plays, nil
+}
+
+// Last returns the most recently recorded play, or nil if the log is empty
+func (l *Log) Last() (*Play, error) {
+	segments, err := l.Segments()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(segments) - 1; i >= 0; i-- {
+		plays, err := l.readSegment(segments[i])
+		if err != nil {
+			return nil, err
+		}
+		if len(plays) > 0 {
+			return &plays[len(plays)-1], nil
+		}
+	}
+	return nil, nil
+}
+
+// ReadSealed returns the plays in each segment holding sealed lines, by path,
+// read with Open. Re-encoding them with Encode lets the log change key, e.g.
+// after the storage key changed.
+func (l *Log) ReadSealed() (map[string][]Play, error) {
+	segments, err := l.Segments()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := make(map[string][]Play)
+	for _, segment := range segments {
+		data, err := os.ReadFile(filepath.Clean(segment)) // #nosec G304 - path is sanitized with filepath.Clean
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history log: %w", err)
+		}
+		if !hasSealedLine(data) {
+			continue
+		}
+		if sealed[segment], err = l.readSegment(segment); err != nil {
+			return nil, err
+		}
+	}
+	return sealed, nil
+}
+
+// Encode returns the content of a segment holding plays, one line each,
+// sealed with Seal if set
+func (l *Log) Encode(plays []Play) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, p := range plays {
+		line, err := l.encodeLine(p)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// hasSealedLine reports whether a segment holds any sealed line
+func hasSealedLine(data []byte) bool {
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		if line = bytes.TrimSpace(line); len(line) > 0 && line[0] != '{' {
+			return true
+		}
+	}
+	return false
+}
+
+// readSegment reads the plays in one log file. A truncated last line, left
+// by a crash mid-write, is ignored.
+func (l *Log) readSegment(path string) ([]Play, error) {
+	data, err := os.ReadFile(filepath.Clean(path)) // #nosec G304 - path is sanitized with filepath.Clean
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history log: %w", err)
+	}
+
+	var plays []Play
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		play, err := l.decodeLine(line)
+		if err != nil {
+			if data[len(data)-1] != '\n' && bytes.HasSuffix(data, line) {
+				break
+			}
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		plays = append(plays, play)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history log: %w", err)
+	}
+	return plays, nil
+}
+
+// encodeLine encodes a play as one log line, without the newline
+func (l *Log) encodeLine(p Play) ([]byte, error) {
+	line, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode play: %w", err)
+	}
+	if l.Seal == nil {
+		return line, nil
+	}
+	sealed, err := l.Seal(line)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal play: %w", err)
+	}
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(sealed)))
+	base64.StdEncoding.Encode(encoded, sealed)
+	return encoded, nil
+}
+
+// decodeLine reads a line written by encodeLine. Plain JSON lines are read
+// as is, so a log started before sealing was enabled stays readable.
+func (l *Log) decodeLine(line []byte) (Play, error) {
+	var p Play
+	if line[0] != '{' {
+		sealed, err := base64.StdEncoding.DecodeString(string(line))
+		if err != nil {
+			return p, fmt.Errorf("invalid log line: %w", err)
+		}
+		if l.Open == nil {
+			return p, fmt.Errorf("log line is sealed but no key is available")
+		}
+		if line, err = l.Open(sealed); err != nil {
+			return p, err
+		}
+	}
+	if err := json.Unmarshal(line, &p); err != nil {
+		return p, fmt.Errorf("invalid log line: %w", err)
+	}
+	return p, nil
+}
+
+// LoadCursor returns the saved cursor, or a zero cursor if there is none
+func (l *Log) LoadCursor() (Cursor, error) {
+	var cursor Cursor
+	data, err := os.ReadFile(filepath.Join(l.Dir, cursorFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return cursor, nil
+	}
+	if err != nil {
+		return cursor, fmt.Errorf("failed to read cursor: %w", err)
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, fmt.Errorf("failed to decode cursor: %w", err)
+	}
+	return cursor, nil
+}
+
+// SaveCursor writes the cursor atomically
+func (l *Log) SaveCursor(cursor Cursor) error {
+	if err := os.MkdirAll(l.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	data, err := json.MarshalIndent(cursor, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	path := filepath.Join(l.Dir, cursorFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write cursor: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write cursor: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPlays(start time.Time, n int) []Play {
+	plays := make([]Play, n)
+	for i := range plays {
+		plays[i] = Play{
+			PlayedAt: start.Add(time.Duration(i) * time.Minute),
+			Type:     "track",
+			URI:      "spotify:track:t" + string(rune('a'+i%26)),
+			Name:     "Track",
+			Source:   SourceRecent,
+		}
+	}
+	return plays
+}
+
+func TestLog_AppendAndRotate(t *testing.T) {
+	log := &Log{Dir: t.TempDir(), MaxSize: 300}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	plays := testPlays(start, 9)
+
+	for i := 0; i < len(plays); i += 3 {
+		if err := log.Append(plays[i : i+3]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	segments, err := log.Segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) < 2 || filepath.Base(segments[len(segments)-1]) != currentSegment {
+		t.Errorf("expected rotated segments followed by the current one, got %v", segments)
+	}
+
+	got, err := log.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(plays) {
+		t.Fatalf("ReadAll() returned %d plays, want %d", len(got), len(plays))
+	}
+	for i := range got {
+		if !got[i].PlayedAt.Equal(plays[i].PlayedAt) {
+			t.Errorf("play %d at %v, want %v", i, got[i].PlayedAt, plays[i].PlayedAt)
+		}
+	}
+
+	last, err := log.Last()
+	if err != nil || last == nil || !last.PlayedAt.Equal(plays[8].PlayedAt) {
+		t.Errorf("Last() = %v, %v", last, err)
+	}
+}
+
+func TestLog_TornWrite(t *testing.T) {
+	log := NewLog(t.TempDir())
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	plays := testPlays(start, 3)
+	if err := log.Append(plays[:2]); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash halfway through writing a line
+	path := filepath.Join(log.Dir, currentSegment)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"played_at":"2024-05-01T12:0`); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	if got, err := log.ReadAll(); err != nil || len(got) != 2 {
+		t.Fatalf("ReadAll() with a torn line = %d plays, %v", len(got), err)
+	}
+
+	if err := log.Append(plays[2:]); err != nil {
+		t.Fatal(err)
+	}
+	got, err := log.ReadAll()
+	if err != nil || len(got) != 3 {
+		t.Errorf("ReadAll() after repair = %d plays, %v, want 3", len(got), err)
+	}
+}
+
+func TestLog_Sealed(t *testing.T) {
+	dir := t.TempDir()
+	plain := NewLog(dir)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	plays := testPlays(start, 2)
+	if err := plain.Append(plays[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	// A toy cipher is enough to show lines pass through Seal and Open
+	flip := func(b []byte) ([]byte, error) {
+		out := make([]byte, len(b))
+		for i := range b {
+			out[i] = b[i] ^ 0x5a
+		}
+		return out, nil
+	}
+	sealed := NewLog(dir)
+	sealed.Seal, sealed.Open = flip, flip
+	if err := sealed.Append(plays[1:]); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := os.ReadFile(filepath.Join(dir, currentSegment))
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "{") || strings.Contains(lines[1], "spotify:track") {
+		t.Errorf("expected a plain line then a sealed one, got %q", raw)
+	}
+
+	got, err := sealed.ReadAll()
+	if err != nil || len(got) != 2 || got[1].URI != plays[1].URI {
+		t.Errorf("ReadAll() = %v, %v", got, err)
+	}
+
+	if _, err := plain.ReadAll(); err == nil {
+		t.Error("expected error reading sealed lines without Open")
+	}
+	plain.Open = func([]byte) ([]byte, error) { return nil, errors.New("wrong key") }
+	if _, err := plain.ReadAll(); err == nil {
+		t.Error("expected error when Open fails")
+	}
+}
+
+func TestLog_ReadSealed(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	plays := testPlays(start, 3)
+
+	xor := func(key byte) func([]byte) ([]byte, error) {
+		return func(b []byte) ([]byte, error) {
+			out := make([]byte, len(b))
+			for i := range b {
+				out[i] = b[i] ^ key
+			}
+			return out, nil
+		}
+	}
+	if err := NewLog(dir).Append(plays[:1]); err != nil {
+		t.Fatal(err)
+	}
+	old := NewLog(dir)
+	old.Seal, old.Open = xor(0x5a), xor(0x5a)
+	if err := old.Append(plays[1:]); err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := old.ReadSealed()
+	if err != nil || len(sealed) != 1 {
+		t.Fatalf("ReadSealed() = %v, %v", sealed, err)
+	}
+	rekeyed := NewLog(dir)
+	rekeyed.Seal, rekeyed.Open = xor(0x33), xor(0x33)
+	for segment, segmentPlays := range sealed {
+		data, err := rekeyed.Encode(segmentPlays)
+		if err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+		if strings.Contains(string(data), "spotify:track") {
+			t.Errorf("resealed segment holds plaintext: %q", data)
+		}
+		if err := os.WriteFile(segment, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := rekeyed.ReadAll()
+	if err != nil || len(got) != 3 || got[2].URI != plays[2].URI {
+		t.Errorf("ReadAll() with the new key = %v, %v", got, err)
+	}
+	if _, err := old.ReadAll(); err == nil {
+		t.Error("expected the old key to no longer open the log")
+	}
+
+	// A log without sealed lines has nothing to reseal
+	plainDir := t.TempDir()
+	if err := NewLog(plainDir).Append(plays); err != nil {
+		t.Fatal(err)
+	}
+	if sealed, err := NewLog(plainDir).ReadSealed(); err != nil || len(sealed) != 0 {
+		t.Errorf("ReadSealed() of a plain log = %v, %v", sealed, err)
+	}
+}
+
+func TestMigrateLog(t *testing.T) {
+	dataDir := t.TempDir()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	plays := testPlays(start, 3)
+
+	// A log written by an earlier version, next to the backup snapshots
+	legacy := NewLog(filepath.Join(dataDir, legacyLogDir))
+	legacy.MaxSize = 1
+	for _, p := range plays {
+		if err := legacy.Append([]Play{p}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := legacy.SaveCursor(Cursor{After: start}); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := filepath.Join(dataDir, legacyLogDir, "top_items", "top_items-20240501-120000.json")
+	if err := os.MkdirAll(filepath.Dir(snapshot), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(snapshot, []byte(`{}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := MigrateLog(dataDir); err != nil {
+			t.Fatalf("MigrateLog() error = %v", err)
+		}
+	}
+
+	log := NewLog(filepath.Join(dataDir, LogDir))
+	got, err := log.ReadAll()
+	if err != nil || len(got) != 3 || got[2].URI != plays[2].URI {
+		t.Errorf("ReadAll() after migration = %v, %v", got, err)
+	}
+	if cursor, err := log.LoadCursor(); err != nil || !cursor.After.Equal(start) {
+		t.Errorf("LoadCursor() after migration = %v, %v", cursor, err)
+	}
+	if segments, _ := legacy.Segments(); len(segments) != 0 {
+		t.Errorf("legacy segments left behind: %v", segments)
+	}
+	if _, err := os.Stat(snapshot); err != nil {
+		t.Errorf("snapshots should stay where they are: %v", err)
+	}
+}
+
+func TestLog_Cursor(t *testing.T) {
+	log := NewLog(filepath.Join(t.TempDir(), "history"))
+
+	cursor, err := log.LoadCursor()
+	if err != nil || !cursor.After.IsZero() {
+		t.Fatalf("LoadCursor() without a file = %v, %v", cursor, err)
+	}
+
+	want := Cursor{After: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), UpdatedAt: time.Now().UTC().Truncate(time.Second)}
+	if err := log.SaveCursor(want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := log.LoadCursor()
+	if err != nil || !got.After.Equal(want.After) || !got.UpdatedAt.Equal(want.UpdatedAt) {
+		t.Errorf("LoadCursor() = %v, %v, want %v", got, err, want)
+	}
+
+	if err := os.WriteFile(filepath.Join(log.Dir, cursorFile), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := log.LoadCursor(); err == nil {
+		t.Error("expected error for a corrupt cursor")
+	}
+	if _, err := os.Stat(filepath.Join(log.Dir, cursorFile+".tmp")); err == nil {
+		t.Error("temporary cursor file left behind")
+	}
+}
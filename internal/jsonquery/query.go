@@ -6,12 +6,13 @@ package jsonquery
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/bkataru/spotigo/internal/jsonutil"
 )
 
 // QueryResult represents the result of a query operation
@@ -130,7 +131,7 @@ func (e *Engine) loadData(source string) ([]interface{}, error) {
 	cleanPath := filepath.Clean(filePath)
 
 	// Read file
-	file, err := os.ReadFile(cleanPath) // #nosec G304 - path is sanitized with filepath.Clean
+	file, err := jsonutil.ReadFile(cleanPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
@@ -808,14 +809,27 @@ func (m *MusicQueryHelper) GetLibraryStats() QueryResult {
 		Operation: "count",
 	})
 
+	data := map[string]interface{}{
+		"saved_tracks":     tracksResult.Count,
+		"playlists":        playlistsResult.Count,
+		"followed_artists": artistsResult.Count,
+	}
+	summary := fmt.Sprintf("Library: %d tracks, %d playlists, %d followed artists",
+		tracksResult.Count, playlistsResult.Count, artistsResult.Count)
+
+	// The play history only exists once it has been imported
+	playsResult := m.Engine.Execute(Query{
+		Source:    "play_history.json",
+		Operation: "count",
+	})
+	if playsResult.Error == "" && playsResult.Count > 0 {
+		data["plays"] = playsResult.Count
+		summary += fmt.Sprintf(", %d plays in the listening history", playsResult.Count)
+	}
+
 	return QueryResult{
-		Data: map[string]interface{}{
-			"saved_tracks":     tracksResult.Count,
-			"playlists":        playlistsResult.Count,
-			"followed_artists": artistsResult.Count,
-		},
-		Summary: fmt.Sprintf("Library: %d tracks, %d playlists, %d followed artists",
-			tracksResult.Count, playlistsResult.Count, artistsResult.Count),
+		Data:    data,
+		Summary: summary,
 	}
 }
 
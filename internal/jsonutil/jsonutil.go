@@ -8,11 +8,35 @@ import (
 	"path/filepath"
 )
 
-// LoadJSONFile reads and unmarshals a JSON file into the target interface.
-func LoadJSONFile(path string, target interface{}) error {
+// FileDecoder transforms the raw contents of a data file before it is parsed,
+// e.g. to decrypt it.
+type FileDecoder func(data []byte) ([]byte, error)
+
+var fileDecoder FileDecoder
+
+// SetFileDecoder installs the decoder applied by ReadFile and LoadJSONFile.
+// Passing nil reads files as they are.
+func SetFileDecoder(decoder FileDecoder) {
+	fileDecoder = decoder
+}
+
+// ReadFile reads a data file and applies the installed decoder.
+func ReadFile(path string) ([]byte, error) {
 	// Clean path to prevent traversal attacks
 	cleanPath := filepath.Clean(path)
-	data, err := os.ReadFile(cleanPath)
+	data, err := os.ReadFile(cleanPath) // #nosec G304 - path is sanitized with filepath.Clean
+	if err != nil {
+		return nil, err
+	}
+	if fileDecoder != nil {
+		return fileDecoder(data)
+	}
+	return data, nil
+}
+
+// LoadJSONFile reads and unmarshals a JSON file into the target interface.
+func LoadJSONFile(path string, target interface{}) error {
+	data, err := ReadFile(path)
 	if err != nil {
 		return err
 	}
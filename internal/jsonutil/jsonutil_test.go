@@ -1,6 +1,8 @@
 package jsonutil
 
 import (
+	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -42,6 +44,31 @@ func TestLoadJSONFile_NotFound(t *testing.T) {
 	}
 }
 
+func TestLoadJSONFile_Decoder(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "test.json")
+	if err := os.WriteFile(testPath, []byte(`garbled{"name": "test"}`), 0600); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	SetFileDecoder(func(data []byte) ([]byte, error) {
+		return bytes.TrimPrefix(data, []byte("garbled")), nil
+	})
+	defer SetFileDecoder(nil)
+
+	var result map[string]interface{}
+	if err := LoadJSONFile(testPath, &result); err != nil {
+		t.Fatalf("LoadJSONFile failed: %v", err)
+	}
+	if result["name"] != "test" {
+		t.Errorf("expected name 'test', got '%v'", result["name"])
+	}
+
+	SetFileDecoder(func([]byte) ([]byte, error) { return nil, errors.New("locked") })
+	if _, err := ReadFile(testPath); err == nil || err.Error() != "locked" {
+		t.Errorf("expected decoder error, got %v", err)
+	}
+}
+
 func TestGetString(t *testing.T) {
 	m := map[string]interface{}{
 		"name":   "John",
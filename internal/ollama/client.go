@@ -11,10 +11,14 @@ import (
 	"time"
 )
 
+// DefaultEmbedBatchSize is how many inputs EmbedBatch sends per request
+const DefaultEmbedBatchSize = 32
+
 // Client is an Ollama API client
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
+	baseURL        string
+	httpClient     *http.Client
+	embedBatchSize int
 }
 
 // NewClient creates a new Ollama client
@@ -24,7 +28,22 @@ func NewClient(host string, timeout time.Duration) *Client {
 		httpClient: &http.Client{
 			Timeout: timeout,
 		},
+		embedBatchSize: DefaultEmbedBatchSize,
+	}
+}
+
+// SetEmbedBatchSize sets how many inputs EmbedBatch sends per request;
+// values below 1 restore the default
+func (c *Client) SetEmbedBatchSize(n int) {
+	if n < 1 {
+		n = DefaultEmbedBatchSize
 	}
+	c.embedBatchSize = n
+}
+
+// EmbedBatchSize returns how many inputs EmbedBatch sends per request
+func (c *Client) EmbedBatchSize() int {
+	return c.embedBatchSize
 }
 
 // ChatRequest represents a chat completion request
@@ -91,6 +110,12 @@ type EmbedRequest struct {
 	Input string `json:"input"`
 }
 
+// EmbedBatchRequest represents an embedding request for several inputs
+type EmbedBatchRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
 // EmbedResponse represents an embedding response
 type EmbedResponse struct {
 	Model      string      `json:"model"`
@@ -148,11 +173,46 @@ func (c *Client) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, erro
 
 // Embed generates embeddings for the given input
 func (c *Client) Embed(ctx context.Context, model string, input string) ([]float64, error) {
-	req := EmbedRequest{
+	embedResp, err := c.embed(ctx, EmbedRequest{
 		Model: model,
 		Input: input,
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	if len(embedResp.Embeddings) == 0 {
+		return nil, fmt.Errorf("no embeddings returned")
+	}
+
+	return embedResp.Embeddings[0], nil
+}
+
+// EmbedBatch generates embeddings for several inputs, sending them in
+// requests of up to EmbedBatchSize inputs. The embeddings are returned in
+// the order of the inputs.
+func (c *Client) EmbedBatch(ctx context.Context, model string, inputs []string) ([][]float64, error) {
+	embeddings := make([][]float64, 0, len(inputs))
+	for start := 0; start < len(inputs); start += c.embedBatchSize {
+		batch := inputs[start:min(start+c.embedBatchSize, len(inputs))]
+
+		embedResp, err := c.embed(ctx, EmbedBatchRequest{
+			Model: model,
+			Input: batch,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(embedResp.Embeddings) != len(batch) {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", len(batch), len(embedResp.Embeddings))
+		}
+		embeddings = append(embeddings, embedResp.Embeddings...)
+	}
+	return embeddings, nil
+}
+
+// embed posts an embedding request
+func (c *Client) embed(ctx context.Context, req interface{}) (*EmbedResponse, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -183,11 +243,7 @@ func (c *Client) Embed(ctx context.Context, model string, input string) ([]float
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	if len(embedResp.Embeddings) == 0 {
-		return nil, fmt.Errorf("no embeddings returned")
-	}
-
-	return embedResp.Embeddings[0], nil
+	return &embedResp, nil
 }
 
 // ListModels returns a list of available models
@@ -215,6 +215,101 @@ func TestClient_Embed_NoEmbeddings(t *testing.T) {
 	}
 }
 
+func TestClient_EmbedBatch(t *testing.T) {
+	var batches [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embed" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+
+		var req EmbedBatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("request input should be an array: %v", err)
+		}
+		batches = append(batches, req.Input)
+
+		// Embed each input as its length, so order can be checked
+		resp := EmbedResponse{Model: req.Model}
+		for _, input := range req.Input {
+			resp.Embeddings = append(resp.Embeddings, []float64{float64(len(input))})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, 10*time.Second)
+	client.SetEmbedBatchSize(2)
+
+	inputs := []string{"a", "bb", "ccc", "dddd", "eeeee"}
+	embeddings, err := client.EmbedBatch(context.Background(), "nomic-embed-text", inputs)
+	if err != nil {
+		t.Fatalf("EmbedBatch failed: %v", err)
+	}
+
+	if len(batches) != 3 || len(batches[0]) != 2 || len(batches[2]) != 1 {
+		t.Errorf("expected batches of 2, 2 and 1 inputs, got %v", batches)
+	}
+	if len(embeddings) != len(inputs) {
+		t.Fatalf("expected %d embeddings, got %d", len(inputs), len(embeddings))
+	}
+	for i, embedding := range embeddings {
+		if embedding[0] != float64(len(inputs[i])) {
+			t.Errorf("embedding %d is for the wrong input: %v", i, embedding)
+		}
+	}
+}
+
+func TestClient_EmbedBatch_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("model not found"))
+			},
+		},
+		{
+			name: "missing embeddings",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(EmbedResponse{Embeddings: [][]float64{{0.1}}})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			client := NewClient(server.URL, 10*time.Second)
+			_, err := client.EmbedBatch(context.Background(), "test-model", []string{"one", "two"})
+			if err == nil {
+				t.Error("EmbedBatch should fail")
+			}
+		})
+	}
+}
+
+func TestClient_SetEmbedBatchSize(t *testing.T) {
+	client := NewClient("http://localhost:11434", 10*time.Second)
+	if client.EmbedBatchSize() != DefaultEmbedBatchSize {
+		t.Errorf("expected default batch size %d, got %d", DefaultEmbedBatchSize, client.EmbedBatchSize())
+	}
+
+	client.SetEmbedBatchSize(8)
+	if client.EmbedBatchSize() != 8 {
+		t.Errorf("expected batch size 8, got %d", client.EmbedBatchSize())
+	}
+
+	client.SetEmbedBatchSize(0)
+	if client.EmbedBatchSize() != DefaultEmbedBatchSize {
+		t.Errorf("expected batch size 0 to restore the default, got %d", client.EmbedBatchSize())
+	}
+}
+
 func TestClient_ListModels(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/api/tags" {
@@ -3,6 +3,7 @@ package rag
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
 // TrackData represents track information for indexing
@@ -109,3 +110,151 @@ func PlaylistToDocument(playlist PlaylistData) Document {
 		},
 	}
 }
+
+// AlbumData represents saved album information for indexing
+type AlbumData struct {
+	ID          string
+	Name        string
+	Artists     []string
+	Genres      []string
+	ReleaseDate string
+}
+
+// ShowData represents podcast (show) information for indexing
+type ShowData struct {
+	ID          string
+	Name        string
+	Publisher   string
+	Description string
+}
+
+// EpisodeData represents podcast episode information for indexing
+type EpisodeData struct {
+	ID          string
+	Name        string
+	Show        string
+	Description string
+}
+
+// AlbumToDocument converts album data to a searchable document
+func AlbumToDocument(album AlbumData) Document {
+	artists := strings.Join(album.Artists, ", ")
+	genres := strings.Join(album.Genres, ", ")
+
+	content := fmt.Sprintf("Album: %s by %s", album.Name, artists)
+	if album.ReleaseDate != "" {
+		content += fmt.Sprintf(", released %s", album.ReleaseDate)
+	}
+	if genres != "" {
+		content += fmt.Sprintf(". Genres: %s", genres)
+	}
+
+	return Document{
+		ID:      fmt.Sprintf("album:%s", album.ID),
+		Type:    "album",
+		Content: content,
+		Metadata: map[string]string{
+			"id":           album.ID,
+			"name":         album.Name,
+			"artists":      artists,
+			"genres":       genres,
+			"release_date": album.ReleaseDate,
+		},
+	}
+}
+
+// ShowToDocument converts podcast data to a searchable document
+func ShowToDocument(show ShowData) Document {
+	content := fmt.Sprintf("Podcast: %s", show.Name)
+	if show.Publisher != "" {
+		content += fmt.Sprintf(" by %s", show.Publisher)
+	}
+	if show.Description != "" {
+		content += fmt.Sprintf(". %s", show.Description)
+	}
+
+	return Document{
+		ID:      fmt.Sprintf("show:%s", show.ID),
+		Type:    "show",
+		Content: content,
+		Metadata: map[string]string{
+			"id":          show.ID,
+			"name":        show.Name,
+			"publisher":   show.Publisher,
+			"description": show.Description,
+		},
+	}
+}
+
+// EpisodeToDocument converts podcast episode data to a searchable document
+func EpisodeToDocument(episode EpisodeData) Document {
+	content := fmt.Sprintf("Podcast episode: %s", episode.Name)
+	if episode.Show != "" {
+		content += fmt.Sprintf(" from %s", episode.Show)
+	}
+	if episode.Description != "" {
+		content += fmt.Sprintf(". %s", episode.Description)
+	}
+
+	return Document{
+		ID:      fmt.Sprintf("episode:%s", episode.ID),
+		Type:    "episode",
+		Content: content,
+		Metadata: map[string]string{
+			"id":          episode.ID,
+			"name":        episode.Name,
+			"show":        episode.Show,
+			"description": episode.Description,
+		},
+	}
+}
+
+// PlayedData represents an item from the listening history for indexing
+type PlayedData struct {
+	ID          string
+	Type        string // track or episode
+	Name        string
+	Artist      string
+	Album       string
+	Plays       int
+	FirstPlayed time.Time
+	LastPlayed  time.Time
+}
+
+// PlayedToDocument converts listening history for one item to a searchable document
+func PlayedToDocument(played PlayedData) Document {
+	content := played.Name
+	if played.Artist != "" {
+		if played.Type == "episode" {
+			content += fmt.Sprintf(" from %s", played.Artist)
+		} else {
+			content += fmt.Sprintf(" by %s", played.Artist)
+		}
+	}
+	if played.Album != "" {
+		content += fmt.Sprintf(" from album %s", played.Album)
+	}
+	content += fmt.Sprintf(". Played %d times between %s and %s",
+		played.Plays, played.FirstPlayed.Format("January 2006"), played.LastPlayed.Format("January 2006"))
+
+	id := played.ID
+	if id == "" {
+		id = strings.ToLower(played.Name + " - " + played.Artist)
+	}
+
+	return Document{
+		ID:      fmt.Sprintf("played:%s", id),
+		Type:    "played",
+		Content: content,
+		Metadata: map[string]string{
+			"id":           played.ID,
+			"item_type":    played.Type,
+			"name":         played.Name,
+			"artists":      played.Artist,
+			"album":        played.Album,
+			"plays":        fmt.Sprintf("%d", played.Plays),
+			"first_played": played.FirstPlayed.Format("2006-01-02"),
+			"last_played":  played.LastPlayed.Format("2006-01-02"),
+		},
+	}
+}
@@ -3,6 +3,7 @@ package rag
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestTrackToDocument(t *testing.T) {
@@ -298,3 +299,107 @@ func TestDocumentMetadataIntegrity(t *testing.T) {
 		})
 	}
 }
+
+func TestAlbumToDocument(t *testing.T) {
+	album := AlbumData{
+		ID:          "album123",
+		Name:        "OK Computer",
+		Artists:     []string{"Radiohead"},
+		Genres:      []string{"alternative rock"},
+		ReleaseDate: "1997-05-21",
+	}
+
+	doc := AlbumToDocument(album)
+
+	if doc.ID != "album:album123" {
+		t.Errorf("expected ID 'album:album123', got '%s'", doc.ID)
+	}
+	if doc.Type != "album" {
+		t.Errorf("expected Type 'album', got '%s'", doc.Type)
+	}
+	if !strings.Contains(doc.Content, "OK Computer") || !strings.Contains(doc.Content, "Radiohead") {
+		t.Errorf("Content should contain album and artist name, got '%s'", doc.Content)
+	}
+	if !strings.Contains(doc.Content, "1997-05-21") {
+		t.Error("Content should contain release date")
+	}
+	if doc.Metadata["artists"] != "Radiohead" {
+		t.Errorf("expected metadata artists 'Radiohead', got '%s'", doc.Metadata["artists"])
+	}
+}
+
+func TestShowToDocument(t *testing.T) {
+	show := ShowData{
+		ID:          "show123",
+		Name:        "Song Exploder",
+		Publisher:   "Hrishikesh Hirway",
+		Description: "Musicians take apart their songs",
+	}
+
+	doc := ShowToDocument(show)
+
+	if doc.ID != "show:show123" {
+		t.Errorf("expected ID 'show:show123', got '%s'", doc.ID)
+	}
+	if doc.Type != "show" {
+		t.Errorf("expected Type 'show', got '%s'", doc.Type)
+	}
+	if !strings.Contains(doc.Content, "Song Exploder") || !strings.Contains(doc.Content, "Hrishikesh Hirway") {
+		t.Errorf("Content should contain name and publisher, got '%s'", doc.Content)
+	}
+	if doc.Metadata["publisher"] != "Hrishikesh Hirway" {
+		t.Errorf("expected metadata publisher, got '%s'", doc.Metadata["publisher"])
+	}
+}
+
+func TestEpisodeToDocument(t *testing.T) {
+	episode := EpisodeData{
+		ID:   "ep123",
+		Name: "Björk - Hidden Place",
+		Show: "Song Exploder",
+	}
+
+	doc := EpisodeToDocument(episode)
+
+	if doc.ID != "episode:ep123" {
+		t.Errorf("expected ID 'episode:ep123', got '%s'", doc.ID)
+	}
+	if doc.Type != "episode" {
+		t.Errorf("expected Type 'episode', got '%s'", doc.Type)
+	}
+	if !strings.Contains(doc.Content, "Hidden Place") || !strings.Contains(doc.Content, "Song Exploder") {
+		t.Errorf("Content should contain episode and show name, got '%s'", doc.Content)
+	}
+}
+
+func TestPlayedToDocument(t *testing.T) {
+	played := PlayedData{
+		ID:          "track123",
+		Type:        "track",
+		Name:        "Karma Police",
+		Artist:      "Radiohead",
+		Album:       "OK Computer",
+		Plays:       42,
+		FirstPlayed: time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC),
+		LastPlayed:  time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	doc := PlayedToDocument(played)
+	if doc.ID != "played:track123" || doc.Type != "played" {
+		t.Errorf("unexpected ID %q or type %q", doc.ID, doc.Type)
+	}
+	for _, want := range []string{"Karma Police by Radiohead", "OK Computer", "42 times", "March 2019", "May 2024"} {
+		if !strings.Contains(doc.Content, want) {
+			t.Errorf("content %q should contain %q", doc.Content, want)
+		}
+	}
+	if doc.Metadata["plays"] != "42" || doc.Metadata["last_played"] != "2024-05-01" {
+		t.Errorf("unexpected metadata %v", doc.Metadata)
+	}
+
+	// Local files have no Spotify ID
+	local := PlayedToDocument(PlayedData{Type: "track", Name: "Demo", Artist: "Me", Plays: 1})
+	if local.ID != "played:demo - me" {
+		t.Errorf("local file ID = %q", local.ID)
+	}
+}
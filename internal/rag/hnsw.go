@@ -0,0 +1,348 @@
+package rag
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// HNSW parameters. Layer 0 keeps twice as many neighbours as the upper layers.
+const (
+	hnswM              = 16
+	hnswEfConstruction = 200
+	hnswEfSearch       = 64
+	hnswSeed           = 42
+)
+
+// hnswNode is one vector in the graph
+type hnswNode struct {
+	id      string
+	vector  []float32 // normalised, so the dot product is the cosine similarity
+	friends [][]uint32
+	deleted bool
+}
+
+// hnswIndex is a Hierarchical Navigable Small World graph for approximate
+// nearest-neighbour search by cosine similarity (Malkov & Yashunin, 2016).
+// Nodes are addressed by slot; deleted nodes stay in the graph as tombstones,
+// so searches can still route through them, until the index is compacted.
+type hnswIndex struct {
+	dims     int
+	nodes    []*hnswNode
+	slots    map[string]uint32
+	entry    int // -1 when empty
+	maxLevel int
+	deleted  int
+	rng      *rand.Rand
+}
+
+// newHNSWIndex creates an empty index
+func newHNSWIndex() *hnswIndex {
+	return &hnswIndex{
+		slots: make(map[string]uint32),
+		entry: -1,
+		rng:   rand.New(rand.NewSource(hnswSeed)), // #nosec G404 - levels only need to be well spread
+	}
+}
+
+// Len returns the number of live vectors
+func (h *hnswIndex) Len() int {
+	return len(h.slots)
+}
+
+// Has reports whether id is in the index
+func (h *hnswIndex) Has(id string) bool {
+	_, ok := h.slots[id]
+	return ok
+}
+
+// Vector returns the normalised vector stored for id
+func (h *hnswIndex) Vector(id string) []float32 {
+	slot, ok := h.slots[id]
+	if !ok {
+		return nil
+	}
+	return h.nodes[slot].vector
+}
+
+// Insert adds a vector, replacing any previous vector for id. The vector must
+// be normalised and, unless the index is empty, have the index's dimensions.
+func (h *hnswIndex) Insert(id string, vector []float32) {
+	h.Delete(id)
+	if h.dims == 0 {
+		h.dims = len(vector)
+	}
+
+	level := h.randomLevel()
+	slot := uint32(len(h.nodes)) // #nosec G115 - an index never holds 2^32 vectors
+	node := &hnswNode{id: id, vector: vector, friends: make([][]uint32, level+1)}
+	h.nodes = append(h.nodes, node)
+	h.slots[id] = slot
+
+	if h.entry < 0 {
+		h.entry = int(slot)
+		h.maxLevel = level
+		return
+	}
+
+	// Descend greedily through the layers above the node's own
+	entry := uint32(h.entry) // #nosec G115 - entry is a valid slot here
+	for l := h.maxLevel; l > level; l-- {
+		entry = h.greedyClosest(vector, entry, l)
+	}
+
+	entries := []uint32{entry}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		candidates := h.searchLayer(vector, entries, hnswEfConstruction, l)
+		neighbours := h.selectNeighbours(vector, candidates, hnswM)
+		node.friends[l] = neighbours
+		for _, n := range neighbours {
+			h.link(n, slot, l)
+		}
+		entries = make([]uint32, len(candidates))
+		for i, c := range candidates {
+			entries[i] = c.slot
+		}
+	}
+
+	if level > h.maxLevel {
+		h.maxLevel = level
+		h.entry = int(slot)
+	}
+}
+
+// Delete marks id as deleted. Its node keeps routing searches until Compact.
+func (h *hnswIndex) Delete(id string) bool {
+	slot, ok := h.slots[id]
+	if !ok {
+		return false
+	}
+	h.nodes[slot].deleted = true
+	delete(h.slots, id)
+	h.deleted++
+	return true
+}
+
+// NeedsCompaction reports whether enough of the graph is tombstones that
+// rebuilding it would be worthwhile
+func (h *hnswIndex) NeedsCompaction() bool {
+	return h.deleted > 0 && h.deleted*4 > len(h.nodes)
+}
+
+// Compact rebuilds the graph from its live vectors, dropping tombstones
+func (h *hnswIndex) Compact() {
+	nodes := h.nodes
+	*h = *newHNSWIndex()
+	for _, node := range nodes {
+		if !node.deleted {
+			h.Insert(node.id, node.vector)
+		}
+	}
+}
+
+// Search returns up to k live nodes most similar to the normalised query,
+// most similar first. A nil accept admits every node.
+func (h *hnswIndex) Search(query []float32, k, ef int, accept func(id string) bool) []hnswResult {
+	if h.entry < 0 || k <= 0 || len(query) != h.dims {
+		return nil
+	}
+	ef = max(ef, k)
+
+	entry := uint32(h.entry) // #nosec G115 - entry is a valid slot here
+	for l := h.maxLevel; l > 0; l-- {
+		entry = h.greedyClosest(query, entry, l)
+	}
+
+	var results []hnswResult
+	for _, c := range h.searchLayer(query, []uint32{entry}, ef, 0) {
+		node := h.nodes[c.slot]
+		if node.deleted || (accept != nil && !accept(node.id)) {
+			continue
+		}
+		results = append(results, c)
+		if len(results) == k {
+			break
+		}
+	}
+	return results
+}
+
+// hnswResult is a node found by a search with its similarity to the query
+type hnswResult struct {
+	slot       uint32
+	similarity float64
+}
+
+// randomLevel draws a node level from the exponentially decaying distribution
+func (h *hnswIndex) randomLevel() int {
+	mult := 1 / math.Log(hnswM)
+	return int(-math.Log(1-h.rng.Float64()) * mult)
+}
+
+// greedyClosest walks layer l from entry towards the node closest to query
+func (h *hnswIndex) greedyClosest(query []float32, entry uint32, l int) uint32 {
+	best := entry
+	bestSim := dot(query, h.nodes[entry].vector)
+	for changed := true; changed; {
+		changed = false
+		for _, n := range h.nodes[best].friends[l] {
+			if sim := dot(query, h.nodes[n].vector); sim > bestSim {
+				best, bestSim, changed = n, sim, true
+			}
+		}
+	}
+	return best
+}
+
+// searchLayer returns the ef nodes closest to query on layer l, most similar first
+func (h *hnswIndex) searchLayer(query []float32, entries []uint32, ef, l int) []hnswResult {
+	visited := make(map[uint32]bool, ef*4)
+	candidates := &resultHeap{}          // closest first
+	found := &resultHeap{farthest: true} // farthest first, capped at ef
+
+	for _, e := range entries {
+		if visited[e] {
+			continue
+		}
+		visited[e] = true
+		r := hnswResult{slot: e, similarity: dot(query, h.nodes[e].vector)}
+		heap.Push(candidates, r)
+		heap.Push(found, r)
+	}
+	for found.Len() > ef {
+		heap.Pop(found)
+	}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(hnswResult)
+		if found.Len() >= ef && c.similarity < found.items[0].similarity {
+			break
+		}
+		for _, n := range h.nodes[c.slot].friends[l] {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+			sim := dot(query, h.nodes[n].vector)
+			if found.Len() < ef || sim > found.items[0].similarity {
+				r := hnswResult{slot: n, similarity: sim}
+				heap.Push(candidates, r)
+				heap.Push(found, r)
+				if found.Len() > ef {
+					heap.Pop(found)
+				}
+			}
+		}
+	}
+
+	results := found.items
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].similarity > results[j].similarity
+	})
+	return results
+}
+
+// selectNeighbours picks up to m of the candidates, sorted most similar
+// first, preferring ones that are not already well connected to each other
+// so the graph keeps links between clusters
+func (h *hnswIndex) selectNeighbours(vector []float32, candidates []hnswResult, m int) []uint32 {
+	selected := make([]uint32, 0, m)
+	var skipped []uint32
+	for _, c := range candidates {
+		if len(selected) == m {
+			break
+		}
+		diverse := true
+		for _, s := range selected {
+			if dot(h.nodes[c.slot].vector, h.nodes[s].vector) > c.similarity {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c.slot)
+		} else {
+			skipped = append(skipped, c.slot)
+		}
+	}
+	for _, s := range skipped {
+		if len(selected) == m {
+			break
+		}
+		selected = append(selected, s)
+	}
+	return selected
+}
+
+// link adds an edge from slot to friend on layer l, pruning slot's
+// neighbours if it now has too many
+func (h *hnswIndex) link(slot, friend uint32, l int) {
+	node := h.nodes[slot]
+	node.friends[l] = append(node.friends[l], friend)
+
+	limit := hnswM
+	if l == 0 {
+		limit = 2 * hnswM
+	}
+	if len(node.friends[l]) <= limit {
+		return
+	}
+
+	candidates := make([]hnswResult, len(node.friends[l]))
+	for i, f := range node.friends[l] {
+		candidates[i] = hnswResult{slot: f, similarity: dot(node.vector, h.nodes[f].vector)}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].similarity > candidates[j].similarity
+	})
+	node.friends[l] = h.selectNeighbours(node.vector, candidates, limit)
+}
+
+// resultHeap orders search results by similarity, closest or farthest first
+type resultHeap struct {
+	items    []hnswResult
+	farthest bool
+}
+
+func (r *resultHeap) Len() int { return len(r.items) }
+func (r *resultHeap) Less(i, j int) bool {
+	if r.farthest {
+		return r.items[i].similarity < r.items[j].similarity
+	}
+	return r.items[i].similarity > r.items[j].similarity
+}
+func (r *resultHeap) Swap(i, j int) { r.items[i], r.items[j] = r.items[j], r.items[i] }
+func (r *resultHeap) Push(x any)    { r.items = append(r.items, x.(hnswResult)) }
+func (r *resultHeap) Pop() any {
+	last := r.items[len(r.items)-1]
+	r.items = r.items[:len(r.items)-1]
+	return last
+}
+
+// normalize returns v as a unit-length float32 vector, or nil for a zero vector
+func normalize(v []float64) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += x * x
+	}
+	if norm == 0 {
+		return nil
+	}
+	norm = math.Sqrt(norm)
+
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = float32(x / norm)
+	}
+	return out
+}
+
+// dot returns the dot product of two vectors of equal length
+func dot(a, b []float32) float64 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return float64(sum)
+}
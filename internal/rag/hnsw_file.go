@@ -0,0 +1,195 @@
+package rag
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// hnswMagic starts a saved graph. The file continues with:
+//
+//	version     1 byte
+//	dims        uvarint
+//	max level   uvarint
+//	entry slot  varint, -1 when empty
+//	node count  uvarint
+//
+// then one record per node, in slot order: the ID (uvarint length, then the
+// bytes), a flags byte, the vector as little-endian float32s for deleted
+// nodes only, and for each layer the neighbour count and slots as uvarints.
+// Live vectors are stored with the documents, so the graph only adds the links.
+var hnswMagic = []byte("SPGOHNSW")
+
+const (
+	hnswVersion     = 1
+	hnswFlagDeleted = 1
+
+	// maxDimensions bounds the vector size read from files
+	maxDimensions = 1 << 16
+)
+
+// errStaleGraph means a saved graph does not match the documents it indexes
+var errStaleGraph = errors.New("search graph does not match the stored vectors")
+
+// writeHNSW writes the graph in the binary format above
+func writeHNSW(w io.Writer, h *hnswIndex) error {
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, binary.MaxVarintLen64)
+	putUvarint := func(v uint64) {
+		n := binary.PutUvarint(buf, v)
+		_, _ = bw.Write(buf[:n])
+	}
+
+	_, _ = bw.Write(hnswMagic)
+	_ = bw.WriteByte(hnswVersion)
+	putUvarint(uint64(h.dims))     // #nosec G115 - non-negative
+	putUvarint(uint64(h.maxLevel)) // #nosec G115 - non-negative
+	n := binary.PutVarint(buf, int64(h.entry))
+	_, _ = bw.Write(buf[:n])
+	putUvarint(uint64(len(h.nodes)))
+
+	for _, node := range h.nodes {
+		putUvarint(uint64(len(node.id)))
+		_, _ = bw.WriteString(node.id)
+		if node.deleted {
+			_ = bw.WriteByte(hnswFlagDeleted)
+			for _, x := range node.vector {
+				binary.LittleEndian.PutUint32(buf, math.Float32bits(x))
+				_, _ = bw.Write(buf[:4])
+			}
+		} else {
+			_ = bw.WriteByte(0)
+		}
+		putUvarint(uint64(len(node.friends)))
+		for _, friends := range node.friends {
+			putUvarint(uint64(len(friends)))
+			for _, f := range friends {
+				putUvarint(uint64(f))
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// readHNSW reads a graph written by writeHNSW. Live nodes take their vectors
+// from vector, which returns nil for unknown IDs; a graph naming a document
+// that no longer exists, or with other dimensions, yields errStaleGraph.
+func readHNSW(data []byte, vector func(id string) []float32) (*hnswIndex, error) {
+	if !bytes.HasPrefix(data, hnswMagic) {
+		return nil, fmt.Errorf("not a search graph file")
+	}
+	r := bytes.NewReader(data[len(hnswMagic):])
+	if v, err := r.ReadByte(); err != nil || v != hnswVersion {
+		return nil, fmt.Errorf("unsupported search graph version")
+	}
+
+	var err error
+	uvarint := func() int {
+		if err != nil {
+			return 0
+		}
+		var v uint64
+		v, err = binary.ReadUvarint(r)
+		if err == nil && v > math.MaxInt32 {
+			err = fmt.Errorf("invalid search graph")
+		}
+		return int(v) // #nosec G115 - bounded above
+	}
+	// length reads a count of items that each take at least a byte, so a
+	// corrupt file cannot make us allocate more than its own size
+	length := func() int {
+		n := uvarint()
+		if err == nil && n > r.Len() {
+			err = fmt.Errorf("invalid search graph")
+		}
+		return n
+	}
+
+	h := newHNSWIndex()
+	h.dims = uvarint()
+	h.maxLevel = uvarint()
+	entry, entryErr := binary.ReadVarint(r)
+	count := length()
+	if err == nil {
+		err = entryErr
+	}
+	if err == nil && h.dims > maxDimensions {
+		err = fmt.Errorf("too many dimensions")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid search graph: %w", err)
+	}
+	if entry < -1 || entry >= int64(count) {
+		return nil, fmt.Errorf("invalid search graph entry point")
+	}
+	h.entry = int(entry)
+
+	h.nodes = make([]*hnswNode, 0, count)
+	for i := 0; i < count; i++ {
+		id := make([]byte, length())
+		if err == nil {
+			_, err = io.ReadFull(r, id)
+		}
+		var flags byte
+		if err == nil {
+			flags, err = r.ReadByte()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid search graph: %w", err)
+		}
+
+		node := &hnswNode{id: string(id), deleted: flags&hnswFlagDeleted != 0}
+		if node.deleted {
+			raw := make([]byte, 4*h.dims)
+			if _, err := io.ReadFull(r, raw); err != nil {
+				return nil, fmt.Errorf("invalid search graph: %w", err)
+			}
+			node.vector = make([]float32, h.dims)
+			for j := range node.vector {
+				node.vector[j] = math.Float32frombits(binary.LittleEndian.Uint32(raw[4*j:]))
+			}
+			h.deleted++
+		} else {
+			node.vector = vector(node.id)
+			if len(node.vector) != h.dims || h.Has(node.id) {
+				return nil, errStaleGraph
+			}
+			h.slots[node.id] = uint32(i) // #nosec G115 - count is bounded by MaxInt32
+		}
+
+		node.friends = make([][]uint32, length())
+		for l := range node.friends {
+			node.friends[l] = make([]uint32, length())
+			for j := range node.friends[l] {
+				f := uvarint()
+				if f >= count {
+					err = fmt.Errorf("neighbour out of range")
+				}
+				node.friends[l][j] = uint32(f) // #nosec G115 - checked against count
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid search graph: %w", err)
+		}
+		h.nodes = append(h.nodes, node)
+	}
+
+	// Searches index friends[l] of every node they reach on layer l
+	if count > 0 && (h.entry < 0 || len(h.nodes[h.entry].friends) <= h.maxLevel) {
+		return nil, fmt.Errorf("invalid search graph entry point")
+	}
+	for _, node := range h.nodes {
+		for l, friends := range node.friends {
+			for _, f := range friends {
+				if len(h.nodes[f].friends) <= l {
+					return nil, fmt.Errorf("invalid search graph link")
+				}
+			}
+		}
+	}
+	return h, nil
+}
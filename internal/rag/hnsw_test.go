@@ -0,0 +1,333 @@
+package rag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// randomVectors returns n normalised vectors from a fixed seed
+func randomVectors(n, dims int) [][]float32 {
+	rng := rand.New(rand.NewSource(1))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		v := make([]float64, dims)
+		for j := range v {
+			v[j] = rng.NormFloat64()
+		}
+		vectors[i] = normalize(v)
+	}
+	return vectors
+}
+
+// exactNeighbours returns the IDs of the k vectors most similar to query
+func exactNeighbours(vectors [][]float32, query []float32, k int) []string {
+	ids := make([]int, len(vectors))
+	for i := range ids {
+		ids[i] = i
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return dot(query, vectors[ids[i]]) > dot(query, vectors[ids[j]])
+	})
+	out := make([]string, k)
+	for i := range out {
+		out[i] = fmt.Sprintf("v%d", ids[i])
+	}
+	return out
+}
+
+// recall returns the share of the exact top k found by the index
+func recall(t *testing.T, h *hnswIndex, vectors [][]float32, queries [][]float32, k int) float64 {
+	t.Helper()
+	hits := 0
+	for _, q := range queries {
+		found := make(map[string]bool)
+		for _, r := range h.Search(q, k, hnswEfSearch, nil) {
+			found[h.nodes[r.slot].id] = true
+		}
+		for _, id := range exactNeighbours(vectors, q, k) {
+			if found[id] {
+				hits++
+			}
+		}
+	}
+	return float64(hits) / float64(len(queries)*k)
+}
+
+func TestHNSWIndex_Recall(t *testing.T) {
+	vectors := randomVectors(2000, 32)
+	h := newHNSWIndex()
+	for i, v := range vectors {
+		h.Insert(fmt.Sprintf("v%d", i), v)
+	}
+
+	if got := recall(t, h, vectors, randomVectors(50, 32), 10); got < 0.9 {
+		t.Errorf("recall@10 = %.2f, want at least 0.9", got)
+	}
+}
+
+func TestHNSWIndex_InsertReplaces(t *testing.T) {
+	h := newHNSWIndex()
+	h.Insert("a", normalize([]float64{1, 0}))
+	h.Insert("b", normalize([]float64{0, 1}))
+	h.Insert("a", normalize([]float64{0, 1}))
+
+	if h.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", h.Len())
+	}
+	results := h.Search(normalize([]float64{1, 0}), 5, hnswEfSearch, nil)
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	if results[0].similarity > 0.01 {
+		t.Errorf("old vector for a still found, similarity %.2f", results[0].similarity)
+	}
+}
+
+func TestHNSWIndex_DeleteAndCompact(t *testing.T) {
+	vectors := randomVectors(400, 16)
+	h := newHNSWIndex()
+	for i, v := range vectors {
+		h.Insert(fmt.Sprintf("v%d", i), v)
+	}
+
+	for i := 0; i < 200; i++ {
+		if !h.Delete(fmt.Sprintf("v%d", i)) {
+			t.Fatalf("Delete(v%d) = false", i)
+		}
+	}
+	if h.Delete("v0") {
+		t.Error("deleting twice should report false")
+	}
+	if h.Len() != 200 {
+		t.Errorf("Len() = %d, want 200", h.Len())
+	}
+
+	for _, r := range h.Search(vectors[0], 20, hnswEfSearch, nil) {
+		if h.nodes[r.slot].deleted {
+			t.Fatalf("search returned deleted node %s", h.nodes[r.slot].id)
+		}
+	}
+
+	if !h.NeedsCompaction() {
+		t.Fatal("expected half-deleted index to need compaction")
+	}
+	h.Compact()
+	if len(h.nodes) != 200 || h.deleted != 0 {
+		t.Errorf("after Compact: %d nodes, %d deleted; want 200, 0", len(h.nodes), h.deleted)
+	}
+	for i := 200; i < 220; i++ {
+		results := h.Search(vectors[i], 1, hnswEfSearch, nil)
+		if len(results) != 1 || h.nodes[results[0].slot].id != fmt.Sprintf("v%d", i) {
+			t.Errorf("v%d not found after compaction", i)
+		}
+	}
+}
+
+func TestHNSWIndex_SearchAccept(t *testing.T) {
+	vectors := randomVectors(300, 8)
+	h := newHNSWIndex()
+	for i, v := range vectors {
+		h.Insert(fmt.Sprintf("v%d", i), v)
+	}
+
+	even := func(id string) bool {
+		var n int
+		_, _ = fmt.Sscanf(id, "v%d", &n)
+		return n%2 == 0
+	}
+	results := h.Search(vectors[1], 10, 300, even)
+	if len(results) != 10 {
+		t.Fatalf("got %d results, want 10", len(results))
+	}
+	for _, r := range results {
+		if !even(h.nodes[r.slot].id) {
+			t.Errorf("result %s was not accepted", h.nodes[r.slot].id)
+		}
+	}
+}
+
+func TestHNSWFile_RoundTrip(t *testing.T) {
+	vectors := randomVectors(300, 16)
+	h := newHNSWIndex()
+	for i, v := range vectors {
+		h.Insert(fmt.Sprintf("v%d", i), v)
+	}
+	h.Delete("v7")
+
+	var buf bytes.Buffer
+	if err := writeHNSW(&buf, h); err != nil {
+		t.Fatalf("writeHNSW failed: %v", err)
+	}
+
+	lookup := func(id string) []float32 {
+		var n int
+		if _, err := fmt.Sscanf(id, "v%d", &n); err != nil || n == 7 {
+			return nil
+		}
+		return vectors[n]
+	}
+	loaded, err := readHNSW(buf.Bytes(), lookup)
+	if err != nil {
+		t.Fatalf("readHNSW failed: %v", err)
+	}
+
+	if loaded.Len() != h.Len() || loaded.deleted != 1 || loaded.entry != h.entry || loaded.maxLevel != h.maxLevel {
+		t.Fatalf("loaded index differs: len %d deleted %d entry %d level %d",
+			loaded.Len(), loaded.deleted, loaded.entry, loaded.maxLevel)
+	}
+	for _, q := range vectors[:20] {
+		want := h.Search(q, 5, hnswEfSearch, nil)
+		got := loaded.Search(q, 5, hnswEfSearch, nil)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("loaded index searches differently: got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestHNSWFile_Invalid(t *testing.T) {
+	h := newHNSWIndex()
+	h.Insert("a", normalize([]float64{1, 0}))
+	h.Insert("b", normalize([]float64{0, 1}))
+	var buf bytes.Buffer
+	if err := writeHNSW(&buf, h); err != nil {
+		t.Fatalf("writeHNSW failed: %v", err)
+	}
+	data := buf.Bytes()
+
+	vectors := map[string][]float32{"a": h.Vector("a"), "b": h.Vector("b")}
+	lookup := func(id string) []float32 { return vectors[id] }
+
+	tests := []struct {
+		name   string
+		data   []byte
+		lookup func(string) []float32
+	}{
+		{"empty", nil, lookup},
+		{"bad magic", append([]byte("NOTHNSW!"), data[8:]...), lookup},
+		{"truncated", data[:len(data)-3], lookup},
+		{"missing document", data, func(id string) []float32 {
+			if id == "b" {
+				return nil
+			}
+			return vectors[id]
+		}},
+		{"other dimensions", data, func(string) []float32 { return []float32{1, 0, 0} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readHNSW(tt.data, tt.lookup); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestStore_SearchVector(t *testing.T) {
+	vectors := randomVectors(exactSearchLimit+500, 16)
+	store := NewStore(nil, "", "")
+	for i, v := range vectors {
+		docType := "track"
+		if i%10 == 0 {
+			docType = "artist"
+		}
+		embedding := make([]float64, len(v))
+		for j, x := range v {
+			embedding[j] = float64(x)
+		}
+		store.put(Document{ID: fmt.Sprintf("v%d", i), Type: docType, Embedding: embedding})
+	}
+
+	query := toFloat64(vectors[3])
+	results, err := store.SearchVector(query, 5, "")
+	if err != nil {
+		t.Fatalf("SearchVector failed: %v", err)
+	}
+	if len(results) != 5 || results[0].Document.ID != "v3" {
+		t.Fatalf("expected v3 first in 5 results, got %v", results)
+	}
+	if results[0].Document.Embedding != nil {
+		t.Error("search results should not carry embeddings")
+	}
+
+	artists, err := store.SearchVector(query, 5, "artist")
+	if err != nil {
+		t.Fatalf("SearchVector failed: %v", err)
+	}
+	if len(artists) != 5 {
+		t.Fatalf("got %d artist results, want 5", len(artists))
+	}
+	for _, r := range artists {
+		if r.Document.Type != "artist" {
+			t.Errorf("got %s result for artist search", r.Document.Type)
+		}
+	}
+	if _, err := store.SearchVector([]float64{1, 0}, 5, ""); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("expected ErrDimensionMismatch for a query with other dimensions, got %v", err)
+	}
+}
+
+func TestStore_Delete(t *testing.T) {
+	store := NewStore(nil, "", "")
+	store.put(Document{ID: "a", Type: "track", Embedding: []float64{1, 0}})
+	store.put(Document{ID: "b", Type: "track", Embedding: []float64{0, 1}})
+
+	if !store.Delete("a") {
+		t.Fatal("Delete(a) = false")
+	}
+	if store.Delete("a") {
+		t.Error("deleting twice should report false")
+	}
+	results, err := store.SearchVector([]float64{1, 0}, 5, "")
+	if err != nil {
+		t.Fatalf("SearchVector failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Document.ID != "b" {
+		t.Errorf("expected only b after delete, got %v", results)
+	}
+}
+
+func TestStore_LoadRebuildsGraph(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "vectors.json")
+	store := NewStore(nil, "", storePath)
+	for i, v := range randomVectors(50, 8) {
+		store.put(Document{ID: fmt.Sprintf("v%d", i), Type: "track", Embedding: toFloat64(v)})
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := os.Stat(store.graphPath()); err != nil {
+		t.Fatalf("search graph not saved: %v", err)
+	}
+
+	for _, graph := range [][]byte{nil, []byte("garbage")} {
+		if graph != nil {
+			if err := os.WriteFile(store.graphPath(), graph, 0600); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := os.Remove(store.graphPath()); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded := NewStore(nil, "", storePath)
+		if err := loaded.Load(); err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if loaded.index.Len() != 50 {
+			t.Fatalf("rebuilt index holds %d vectors, want 50", loaded.index.Len())
+		}
+		results, err := loaded.SearchVector(toFloat64(store.index.Vector("v9")), 1, "")
+		if err != nil {
+			t.Fatalf("SearchVector failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Document.ID != "v9" {
+			t.Errorf("expected v9 from rebuilt index, got %v", results)
+		}
+	}
+}
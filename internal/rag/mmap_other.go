@@ -0,0 +1,18 @@
+//go:build !unix
+
+package rag
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// mapFile reads a file into memory; memory mapping is only used on Unix.
+// release is a no-op.
+func mapFile(path string) (data []byte, release func() error, err error) {
+	data, err = os.ReadFile(filepath.Clean(path)) // #nosec G304 - path is sanitized with filepath.Clean
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,38 @@
+//go:build unix
+
+package rag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// mapFile maps a file read-only into memory. The data must not be used
+// after release is called.
+func mapFile(path string) (data []byte, release func() error, err error) {
+	f, err := os.Open(filepath.Clean(path)) // #nosec G304 - path is sanitized with filepath.Clean
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return nil, func() error { return nil }, nil
+	}
+	if int64(int(size)) != size {
+		return nil, nil, fmt.Errorf("%s is too large to map", path)
+	}
+
+	data, err = syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED) // #nosec G115 - file descriptors fit in an int
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to map %s: %w", path, err)
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
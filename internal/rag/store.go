@@ -4,12 +4,17 @@ package rag
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/bkataru/spotigo/internal/ollama"
@@ -19,11 +24,34 @@ import (
 
 // Document represents a searchable item in the vector store
 type Document struct {
-	ID        string            `json:"id"`
-	Type      string            `json:"type"` // track, artist, album, playlist
-	Content   string            `json:"content"`
-	Metadata  map[string]string `json:"metadata"`
-	Embedding []float64         `json:"embedding,omitempty"`
+	ID       string            `json:"id"`
+	Type     string            `json:"type"` // track, artist, album, playlist
+	Content  string            `json:"content"`
+	Metadata map[string]string `json:"metadata"`
+	// Embedding is generated from Content if empty when the document is added.
+	// The store keeps it in its search index, so documents it returns leave it unset.
+	Embedding []float64 `json:"embedding,omitempty"`
+
+	// Model, Dimensions and ContentHash describe the embedding: the model
+	// that produced it, its size and the hash of the content it was made from.
+	// The store sets them when the document is added.
+	Model       string `json:"model,omitempty"`
+	Dimensions  int    `json:"dimensions,omitempty"`
+	ContentHash string `json:"content_hash,omitempty"`
+}
+
+// ErrModelMismatch is returned when a store is queried with another model
+// than the one its embeddings came from
+var ErrModelMismatch = errors.New("embedding model does not match the index")
+
+// ErrDimensionMismatch is returned for a query embedding whose size differs
+// from the indexed embeddings
+var ErrDimensionMismatch = errors.New("embedding dimensions do not match the index")
+
+// ContentHash returns the hash recorded for a document's content
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
 }
 
 // SearchResult represents a search result with similarity score
@@ -32,25 +60,63 @@ type SearchResult struct {
 	Similarity float64  `json:"similarity"`
 }
 
-// Store is an in-memory vector store with persistence
+// exactSearchLimit is the number of candidate documents up to which a search
+// compares the query with every one instead of walking the HNSW graph
+const exactSearchLimit = 1000
+
+// Store is an in-memory vector store with persistence. Embeddings are kept
+// in an HNSW graph for approximate nearest-neighbour search, saved next to
+// the store file with a .hnsw extension.
 type Store struct {
 	mu        sync.RWMutex
 	documents map[string]Document
-	client    *ollama.Client
-	model     string
-	storePath string
+	index     *hnswIndex
+	// indexModel is the model the indexed embeddings came from
+	indexModel string
+	client     *ollama.Client
+	model      string
+	storePath  string
+	quantize   bool
+
+	// release unmaps the loaded store file, whose vectors the index uses
+	release func() error
 }
 
 // NewStore creates a new vector store
 func NewStore(client *ollama.Client, model string, storePath string) *Store {
 	return &Store{
 		documents: make(map[string]Document),
+		index:     newHNSWIndex(),
 		client:    client,
 		model:     model,
 		storePath: storePath,
 	}
 }
 
+// SetQuantize selects whether Save stores embeddings as int8 instead of
+// float32, which makes the file about four times smaller at a small cost in
+// search accuracy
+func (s *Store) SetQuantize(quantize bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quantize = quantize
+}
+
+// Model returns the model the indexed embeddings came from, or "" if it is
+// not known, as for stores saved before models were recorded
+func (s *Store) Model() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.indexModel
+}
+
+// Dimensions returns the size of the indexed embeddings, or 0 if there are none
+func (s *Store) Dimensions() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index.dims
+}
+
 // Add adds a document to the store with automatic embedding generation
 func (s *Store) Add(ctx context.Context, doc Document) error {
 	// Generate embedding if not provided
@@ -66,19 +132,150 @@ func (s *Store) Add(ctx context.Context, doc Document) error {
 	}
 
 	s.mu.Lock()
-	s.documents[doc.ID] = doc
+	s.put(doc)
 	s.mu.Unlock()
 
 	return nil
 }
 
+// put stores doc, moving its embedding into the search index and recording
+// it as made by the store's model. If the index holds embeddings from another
+// model or of another size, it is rebuilt for the store's model, and the old
+// embeddings stay on their documents, unsearched, until they are re-embedded.
+// The caller must hold s.mu.
+func (s *Store) put(doc Document) {
+	doc.Model, doc.Dimensions, doc.ContentHash = "", 0, ""
+	vector := normalize(doc.Embedding)
+	if vector != nil {
+		doc.Model = s.model
+		doc.Dimensions = len(vector)
+		doc.ContentHash = ContentHash(doc.Content)
+
+		if s.index.Len() > 0 && s.model != "" && (s.model != s.indexModel || len(vector) != s.index.dims) {
+			s.rebuildIndex(s.model, len(vector))
+		}
+	}
+
+	if vector != nil && s.indexVector(doc.ID, doc.Model, vector) {
+		doc.Embedding = nil
+	} else {
+		s.index.Delete(doc.ID)
+	}
+	s.documents[doc.ID] = doc
+}
+
+// indexVector adds a normalised vector to the search index and reports
+// whether it fit the index's model and dimensions. The caller must hold s.mu.
+func (s *Store) indexVector(id, model string, vector []float32) bool {
+	if s.index.Len() == 0 && (s.index.dims != len(vector) || s.indexModel != model) {
+		// Only tombstones remain, so the index can take on a new model
+		s.index = newHNSWIndex()
+		s.indexModel = model
+	}
+	if len(vector) != s.index.dims && s.index.dims != 0 || model != s.indexModel {
+		return false
+	}
+	s.index.Insert(id, vector)
+	return true
+}
+
+// rebuildIndex replaces the index with one for embeddings from model with
+// the given dimensions. Embeddings leaving the index are kept on their
+// documents. The caller must hold s.mu.
+func (s *Store) rebuildIndex(model string, dims int) {
+	old := s.index
+	s.index = newHNSWIndex()
+	s.indexModel = model
+
+	ids := make([]string, 0, len(s.documents))
+	for id := range s.documents {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		doc := s.documents[id]
+		if vector := old.Vector(id); vector != nil {
+			doc.Embedding = toFloat64(vector)
+		}
+		if doc.Model == model && doc.Dimensions == dims && len(doc.Embedding) == dims {
+			s.indexVector(id, model, normalize(doc.Embedding))
+			doc.Embedding = nil
+		}
+		s.documents[id] = doc
+	}
+}
+
+// Delete removes a document from the store and reports whether it was present
+func (s *Store) Delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.documents[id]; !ok {
+		return false
+	}
+	delete(s.documents, id)
+	s.index.Delete(id)
+	if s.index.NeedsCompaction() {
+		s.index.Compact()
+	}
+	return true
+}
+
+// StaleDocuments returns the documents whose embedding is missing, out of
+// date with their content or from another model than the store's, sorted
+// by ID and without embeddings. A store created without a model checks
+// against the indexed model.
+func (s *Store) StaleDocuments() []Document {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	model := s.model
+	if model == "" {
+		model = s.indexModel
+	}
+	var stale []Document
+	for id, doc := range s.documents {
+		if s.index.Has(id) && doc.Model == model && doc.ContentHash == ContentHash(doc.Content) {
+			continue
+		}
+		doc.Embedding = nil
+		stale = append(stale, doc)
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].ID < stale[j].ID })
+	return stale
+}
+
+// Reindex re-embeds the stale documents with the store's model and returns
+// how many there were. Documents already embedded by the model are kept, so
+// switching models or resuming an interrupted reindex only embeds what is
+// left.
+func (s *Store) Reindex(ctx context.Context, concurrency int) (int, error) {
+	if s.client == nil || s.model == "" {
+		return 0, fmt.Errorf("reindexing needs an embedding client and model")
+	}
+	stale := s.StaleDocuments()
+	if len(stale) == 0 {
+		return 0, nil
+	}
+	return len(stale), s.AddBatchParallel(ctx, stale, concurrency)
+}
+
 // AddBatch adds multiple documents efficiently with parallel embedding generation
 func (s *Store) AddBatch(ctx context.Context, docs []Document) error {
 	return s.AddBatchParallel(ctx, docs, 4) // Default concurrency of 4
 }
 
+// ProgressFunc is called as documents are embedded, with the number done so
+// far and the number to embed
+type ProgressFunc func(done, total int)
+
 // AddBatchParallel adds multiple documents with configurable parallelism
 func (s *Store) AddBatchParallel(ctx context.Context, docs []Document, concurrency int) error {
+	return s.addBatch(ctx, docs, concurrency, nil)
+}
+
+// addBatch implements AddBatchParallel, reporting progress if progress is set
+func (s *Store) addBatch(ctx context.Context, docs []Document, concurrency int, progress ProgressFunc) error {
 	if concurrency < 1 {
 		concurrency = 1
 	}
@@ -99,12 +296,19 @@ func (s *Store) AddBatchParallel(ctx context.Context, docs []Document, concurren
 		// No embeddings needed, just add documents
 		s.mu.Lock()
 		for _, doc := range docsCopy {
-			s.documents[doc.ID] = doc
+			s.put(doc)
 		}
 		s.mu.Unlock()
 		return nil
 	}
 
+	// Split the documents into batches, each embedded with one request
+	batchSize := s.client.EmbedBatchSize()
+	var batches [][]int
+	for start := 0; start < len(needsEmbedding); start += batchSize {
+		batches = append(batches, needsEmbedding[start:min(start+batchSize, len(needsEmbedding))])
+	}
+
 	// Create worker pool for parallel embedding generation
 	type embeddingResult struct {
 		index     int
@@ -112,7 +316,7 @@ func (s *Store) AddBatchParallel(ctx context.Context, docs []Document, concurren
 		err       error
 	}
 
-	jobs := make(chan int, len(needsEmbedding))
+	jobs := make(chan []int, len(batches))
 	results := make(chan embeddingResult, len(needsEmbedding))
 
 	// Start workers
@@ -121,23 +325,42 @@ func (s *Store) AddBatchParallel(ctx context.Context, docs []Document, concurren
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for idx := range jobs {
+			for batch := range jobs {
 				select {
 				case <-ctx.Done():
-					results <- embeddingResult{index: idx, err: ctx.Err()}
+					for _, idx := range batch {
+						results <- embeddingResult{index: idx, err: ctx.Err()}
+					}
 					return
 				default:
 					// Make a local copy of the content to avoid race conditions
-					content := docsCopy[idx].Content
-					embedding, err := s.client.Embed(ctx, s.model, content)
-					results <- embeddingResult{index: idx, embedding: embedding, err: err}
+					inputs := make([]string, len(batch))
+					for i, idx := range batch {
+						inputs[i] = docsCopy[idx].Content
+					}
+					embeddings, err := s.client.EmbedBatch(ctx, s.model, inputs)
+					if err != nil && len(batch) > 1 && ctx.Err() == nil {
+						// Embed one at a time, so one bad input only fails itself
+						for _, idx := range batch {
+							embedding, err := s.client.Embed(ctx, s.model, docsCopy[idx].Content)
+							results <- embeddingResult{index: idx, embedding: embedding, err: err}
+						}
+						continue
+					}
+					for i, idx := range batch {
+						if err != nil {
+							results <- embeddingResult{index: idx, err: err}
+							continue
+						}
+						results <- embeddingResult{index: idx, embedding: embeddings[i]}
+					}
 				}
 			}
 		}()
 	}
 
 	// Send jobs with context cancellation check
-	for _, idx := range needsEmbedding {
+	for _, batch := range batches {
 		select {
 		case <-ctx.Done():
 			// Context canceled, stop sending jobs
@@ -145,7 +368,7 @@ func (s *Store) AddBatchParallel(ctx context.Context, docs []Document, concurren
 			wg.Wait()
 			close(results)
 			return ctx.Err()
-		case jobs <- idx:
+		case jobs <- batch:
 			// Job sent successfully
 		}
 	}
@@ -160,7 +383,12 @@ func (s *Store) AddBatchParallel(ctx context.Context, docs []Document, concurren
 	// Collect results - store embeddings in a separate map to avoid races
 	embeddingsMap := make(map[int][]float64)
 	var errors []error
+	done := 0
 	for result := range results {
+		done++
+		if progress != nil {
+			progress(done, len(needsEmbedding))
+		}
 		if result.err != nil {
 			errors = append(errors, fmt.Errorf("doc %s: %w", docsCopy[result.index].ID, result.err))
 			continue
@@ -180,7 +408,7 @@ func (s *Store) AddBatchParallel(ctx context.Context, docs []Document, concurren
 	// Add all documents to store (even if some embeddings failed)
 	s.mu.Lock()
 	for _, doc := range docsCopy {
-		s.documents[doc.ID] = doc
+		s.put(doc)
 	}
 	s.mu.Unlock()
 
@@ -192,33 +420,149 @@ func (s *Store) AddBatchParallel(ctx context.Context, docs []Document, concurren
 	return nil
 }
 
-// Search performs semantic search and returns the most similar documents
+// UpdateResult counts what Update did with the documents it was given
+type UpdateResult struct {
+	Added     int
+	Updated   int
+	Unchanged int
+	Removed   int
+}
+
+// Embedded returns how many documents were embedded
+func (r UpdateResult) Embedded() int {
+	return r.Added + r.Updated
+}
+
+// Update makes the store hold exactly docs. Documents whose content and
+// model match what is stored keep their embedding, with their type and
+// metadata refreshed; new and changed documents are embedded in parallel,
+// reporting to progress if it is set; stored documents not in docs are
+// removed.
+func (s *Store) Update(ctx context.Context, docs []Document, concurrency int, progress ProgressFunc) (UpdateResult, error) {
+	var result UpdateResult
+
+	latest := make(map[string]Document, len(docs))
+	for _, doc := range docs {
+		latest[doc.ID] = doc
+	}
+
+	var embed []Document
+	s.mu.Lock()
+	for id, doc := range latest {
+		stored, ok := s.documents[id]
+		switch {
+		case !ok:
+			result.Added++
+			embed = append(embed, doc)
+		case len(doc.Embedding) > 0 || !s.index.Has(id) || stored.Model != s.model || stored.ContentHash != ContentHash(doc.Content):
+			result.Updated++
+			embed = append(embed, doc)
+		default:
+			result.Unchanged++
+			stored.Type = doc.Type
+			stored.Metadata = doc.Metadata
+			s.documents[id] = stored
+		}
+	}
+	for id := range s.documents {
+		if _, ok := latest[id]; !ok {
+			delete(s.documents, id)
+			s.index.Delete(id)
+			result.Removed++
+		}
+	}
+	if s.index.NeedsCompaction() {
+		s.index.Compact()
+	}
+	s.mu.Unlock()
+
+	sort.Slice(embed, func(i, j int) bool { return embed[i].ID < embed[j].ID })
+	return result, s.addBatch(ctx, embed, concurrency, progress)
+}
+
+// Search performs semantic search and returns the most similar documents.
+// It returns ErrModelMismatch if the index was built with another model.
 func (s *Store) Search(ctx context.Context, query string, limit int, docType string) ([]SearchResult, error) {
+	if model := s.Model(); model != "" && s.model != "" && model != s.model {
+		return nil, fmt.Errorf("%w: the index was built with %s, not %s", ErrModelMismatch, model, s.model)
+	}
+
 	// Generate embedding for query
 	queryEmbedding, err := s.client.Embed(ctx, s.model, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
 	}
 
+	return s.SearchVector(queryEmbedding, limit, docType)
+}
+
+// SearchVector returns the documents most similar to an embedding. Large
+// stores are searched through the HNSW graph, so results are approximate;
+// small stores and rare document types are compared exhaustively.
+// It returns ErrDimensionMismatch if the embedding's size differs from the
+// indexed embeddings.
+func (s *Store) SearchVector(embedding []float64, limit int, docType string) ([]SearchResult, error) {
+	query := normalize(embedding)
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	results := make([]SearchResult, 0, len(s.documents))
-	for _, doc := range s.documents {
-		// Filter by type if specified
-		if docType != "" && docType != "all" && doc.Type != docType {
-			continue
+	if s.index.Len() == 0 || query == nil {
+		return []SearchResult{}, nil
+	}
+	if len(query) != s.index.dims {
+		return nil, fmt.Errorf("%w: the query has %d dimensions, the index %d", ErrDimensionMismatch, len(query), s.index.dims)
+	}
+
+	filtered := docType != "" && docType != "all"
+	accept := func(id string) bool {
+		return !filtered || s.documents[id].Type == docType
+	}
+
+	candidates := s.index.Len()
+	if filtered {
+		candidates = 0
+		for id := range s.index.slots {
+			if accept(id) {
+				candidates++
+			}
 		}
+	}
+	if limit <= 0 || candidates <= exactSearchLimit {
+		return s.searchExact(query, limit, accept), nil
+	}
 
-		// Skip documents without embeddings
-		if len(doc.Embedding) == 0 {
-			continue
+	// Widen the search when filtering, so enough matches of the type are found
+	ef := max(hnswEfSearch, limit)
+	if filtered {
+		ef = min(ef*s.index.Len()/candidates, s.index.Len())
+	}
+	found := s.index.Search(query, limit, ef, accept)
+	if len(found) < limit && len(found) < candidates {
+		return s.searchExact(query, limit, accept), nil
+	}
+
+	results := make([]SearchResult, len(found))
+	for i, r := range found {
+		results[i] = SearchResult{
+			Document:   s.documents[s.index.nodes[r.slot].id],
+			Similarity: r.similarity,
 		}
+	}
+	return results, nil
+}
 
-		similarity := cosineSimilarity(queryEmbedding, doc.Embedding)
+// searchExact compares the query with every accepted document. The caller
+// must hold s.mu.
+func (s *Store) searchExact(query []float32, limit int, accept func(id string) bool) []SearchResult {
+	results := make([]SearchResult, 0, s.index.Len())
+	for id, slot := range s.index.slots {
+		if !accept(id) {
+			continue
+		}
 		results = append(results, SearchResult{
-			Document:   doc,
-			Similarity: similarity,
+			Document:   s.documents[id],
+			Similarity: dot(query, s.index.nodes[slot].vector),
 		})
 	}
 
@@ -232,7 +576,7 @@ func (s *Store) Search(ctx context.Context, query string, limit int, docType str
 		results = results[:limit]
 	}
 
-	return results, nil
+	return results
 }
 
 // Count returns the number of documents in the store
@@ -254,10 +598,12 @@ func (s *Store) CountByType() map[string]int {
 	return counts
 }
 
-// Save persists the store to disk
+// Save persists the store to disk in the binary format of vectors_file.go.
+// A JSON store that Load migrated from is removed once the binary one is
+// written.
 func (s *Store) Save() error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	if s.storePath == "" {
 		return fmt.Errorf("no store path configured")
@@ -269,25 +615,56 @@ func (s *Store) Save() error {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	// Convert to slice for serialization
-	docs := make([]Document, 0, len(s.documents))
-	for _, doc := range s.documents {
-		docs = append(docs, doc)
+	// Indexed documents come first, each matching a vector, in a stable order
+	ids := make([]string, 0, len(s.documents))
+	for id := range s.documents {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if a, b := s.index.Has(ids[i]), s.index.Has(ids[j]); a != b {
+			return a
+		}
+		return ids[i] < ids[j]
+	})
+	file := vectorFile{model: s.indexModel, docs: make([]Document, len(ids))}
+	for i, id := range ids {
+		file.docs[i] = s.documents[id]
+		if vector := s.index.Vector(id); vector != nil {
+			file.vectors = append(file.vectors, vector)
+		}
 	}
 
-	data, err := json.MarshalIndent(docs, "", "  ")
+	err := writeFileAtomic(s.storePath, func(w io.Writer) error {
+		return writeVectorFile(w, file, s.quantize)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to marshal documents: %w", err)
+		return fmt.Errorf("failed to write store: %w", err)
 	}
 
-	if err := os.WriteFile(s.storePath, data, 0600); err != nil {
-		return fmt.Errorf("failed to write store: %w", err)
+	if s.index.NeedsCompaction() {
+		s.index.Compact()
+	}
+	err = writeFileAtomic(s.graphPath(), func(w io.Writer) error {
+		return writeHNSW(w, s.index)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write search graph: %w", err)
+	}
+
+	if legacy := s.legacyPath(); legacy != s.storePath {
+		if err := os.Remove(legacy); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove old store: %w", err)
+		}
 	}
 
 	return nil
 }
 
-// Load reads the store from disk
+// Load reads the store from disk. The store file is memory-mapped where
+// possible, so loading does not copy float32 embeddings. If the store file
+// does not exist, a JSON store from before the binary format is read from
+// the same path with a .json extension. The saved search graph is reused
+// when it matches the documents, and rebuilt otherwise.
 func (s *Store) Load() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -296,7 +673,11 @@ func (s *Store) Load() error {
 		return fmt.Errorf("no store path configured")
 	}
 
-	data, err := os.ReadFile(s.storePath)
+	data, release, err := mapFile(s.storePath)
+	if os.IsNotExist(err) {
+		data, err = os.ReadFile(s.legacyPath())
+		release = func() error { return nil }
+	}
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil // Empty store is OK
@@ -304,17 +685,115 @@ func (s *Store) Load() error {
 		return fmt.Errorf("failed to read store: %w", err)
 	}
 
+	var file vectorFile
+	if isVectorFile(data) {
+		file, err = readVectorFile(data)
+	} else {
+		file, err = readJSONStore(data)
+	}
+	if err != nil {
+		_ = release()
+		return err
+	}
+
+	s.documents = make(map[string]Document, len(file.docs))
+	vectors := make(map[string][]float32, len(file.vectors))
+	for i, doc := range file.docs {
+		if i < len(file.vectors) {
+			vectors[doc.ID] = file.vectors[i]
+			// Stores saved before documents recorded their model
+			if doc.Model == "" {
+				doc.Model = file.model
+				doc.Dimensions = len(file.vectors[i])
+			}
+		}
+		s.documents[doc.ID] = doc
+	}
+
+	s.index = newHNSWIndex()
+	s.indexModel = file.model
+	if graph, err := os.ReadFile(s.graphPath()); err == nil {
+		if index, err := readHNSW(graph, func(id string) []float32 { return vectors[id] }); err == nil {
+			s.index = index
+		}
+	}
+
+	// Index documents the saved graph does not cover, in a stable order
+	ids := make([]string, 0, len(vectors))
+	for id := range vectors {
+		if !s.index.Has(id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if !s.indexVector(id, s.documents[id].Model, vectors[id]) {
+			doc := s.documents[id]
+			doc.Embedding = toFloat64(vectors[id])
+			s.documents[id] = doc
+		}
+	}
+
+	// The previous file's vectors are no longer referenced
+	if s.release != nil {
+		_ = s.release()
+	}
+	s.release = release
+
+	return nil
+}
+
+// readJSONStore reads a store saved as a JSON array of documents with their
+// embeddings, as Save wrote it before the binary format
+func readJSONStore(data []byte) (vectorFile, error) {
+	var file vectorFile
 	var docs []Document
 	if err := json.Unmarshal(data, &docs); err != nil {
-		return fmt.Errorf("failed to unmarshal documents: %w", err)
+		return file, fmt.Errorf("failed to unmarshal documents: %w", err)
 	}
 
-	s.documents = make(map[string]Document, len(docs))
+	// Put the documents with embeddings first, to match the binary layout
+	sort.SliceStable(docs, func(i, j int) bool {
+		return len(docs[i].Embedding) > 0 && len(docs[j].Embedding) == 0
+	})
 	for _, doc := range docs {
-		s.documents[doc.ID] = doc
+		vector := normalize(doc.Embedding)
+		if vector == nil {
+			break
+		}
+		file.vectors = append(file.vectors, vector)
+	}
+	for i := range file.vectors {
+		docs[i].Embedding = nil
 	}
+	file.docs = docs
+	return file, nil
+}
 
-	return nil
+// Close releases the memory-mapped store file. The store is empty afterwards.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.documents = make(map[string]Document)
+	s.index = newHNSWIndex()
+	s.indexModel = ""
+	if s.release == nil {
+		return nil
+	}
+	err := s.release()
+	s.release = nil
+	return err
+}
+
+// graphPath returns where the search graph is saved, next to the store file
+func (s *Store) graphPath() string {
+	return strings.TrimSuffix(s.storePath, filepath.Ext(s.storePath)) + ".hnsw"
+}
+
+// legacyPath returns where a JSON store from before the binary format would be
+func (s *Store) legacyPath() string {
+	return strings.TrimSuffix(s.storePath, filepath.Ext(s.storePath)) + ".json"
 }
 
 // Clear removes all documents from the store
@@ -322,6 +801,36 @@ func (s *Store) Clear() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.documents = make(map[string]Document)
+	s.index = newHNSWIndex()
+	s.indexModel = ""
+}
+
+// writeFileAtomic writes a file through a temporary file renamed over path
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(filepath.Clean(tmp), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600) // #nosec G304 - path is sanitized with filepath.Clean
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// toFloat64 widens a vector
+func toFloat64(v []float32) []float64 {
+	out := make([]float64, len(v))
+	for i, x := range v {
+		out[i] = float64(x)
+	}
+	return out
 }
 
 // cosineSimilarity calculates the cosine similarity between two vectors
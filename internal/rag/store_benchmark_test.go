@@ -58,13 +58,32 @@ func BenchmarkStore_SearchSimilarity(b *testing.B) {
 			_ = store.AddBatch(context.Background(), docs)
 
 			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _ = store.SearchVector(queryEmbedding, 0, "")
+			}
+		})
+	}
+}
+
+// BenchmarkStore_SearchVector compares the HNSW search with an exhaustive one
+func BenchmarkStore_SearchVector(b *testing.B) {
+	sizes := []int{2000, 10000}
+	queryEmbedding := normalize(generateRandomEmbedding(128))
+
+	for _, size := range sizes {
+		store := NewStore(nil, "", "")
+		docs := generateDocuments(size, 128)
+		_ = store.AddBatch(context.Background(), docs)
+
+		b.Run(fmt.Sprintf("hnsw/size=%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _ = store.SearchVector(toFloat64(queryEmbedding), 10, "")
+			}
+		})
+		b.Run(fmt.Sprintf("exact/size=%d", size), func(b *testing.B) {
 			for i := 0; i < b.N; i++ {
 				store.mu.RLock()
-				for _, doc := range store.documents {
-					if len(doc.Embedding) > 0 {
-						_ = cosineSimilarity(queryEmbedding, doc.Embedding)
-					}
-				}
+				_ = store.searchExact(queryEmbedding, 10, func(string) bool { return true })
 				store.mu.RUnlock()
 			}
 		})
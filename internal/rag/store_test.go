@@ -2,9 +2,17 @@ package rag
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/bkataru/spotigo/internal/ollama"
 )
 
 func TestNewStore(t *testing.T) {
@@ -325,3 +333,228 @@ func TestStore_SearchWithEmbeddings(t *testing.T) {
 		t.Errorf("expected 3 documents, got %d", store.Count())
 	}
 }
+
+// embedCounts counts the requests and inputs an embedding server received
+type embedCounts struct {
+	requests int32
+	inputs   int32
+}
+
+func (c *embedCounts) reset() {
+	atomic.StoreInt32(&c.requests, 0)
+	atomic.StoreInt32(&c.inputs, 0)
+}
+
+// newEmbedServer returns an Ollama client whose embeddings have two
+// dimensions for model "small" and three for any other
+func newEmbedServer(t *testing.T) (*ollama.Client, *embedCounts) {
+	t.Helper()
+	counts := &embedCounts{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string          `json:"model"`
+			Input json.RawMessage `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var inputs []string
+		if err := json.Unmarshal(req.Input, &inputs); err != nil {
+			var input string
+			if err := json.Unmarshal(req.Input, &input); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			inputs = []string{input}
+		}
+		atomic.AddInt32(&counts.requests, 1)
+		atomic.AddInt32(&counts.inputs, int32(len(inputs)))
+
+		var resp ollama.EmbedResponse
+		for _, input := range inputs {
+			embedding := []float64{1, float64(len(input))}
+			if req.Model != "small" {
+				embedding = append(embedding, 1)
+			}
+			resp.Embeddings = append(resp.Embeddings, embedding)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return ollama.NewClient(server.URL, 5*time.Second), counts
+}
+
+func TestStore_RecordsEmbeddingModel(t *testing.T) {
+	client, _ := newEmbedServer(t)
+	store := NewStore(client, "small", "")
+	if err := store.Add(context.Background(), Document{ID: "t1", Type: "track", Content: "Song"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	doc := store.documents["t1"]
+	if doc.Model != "small" || doc.Dimensions != 2 || doc.ContentHash != ContentHash("Song") {
+		t.Errorf("got model %q, %d dimensions, hash %q", doc.Model, doc.Dimensions, doc.ContentHash)
+	}
+	if store.Model() != "small" || store.Dimensions() != 2 {
+		t.Errorf("store reports model %q with %d dimensions", store.Model(), store.Dimensions())
+	}
+}
+
+func TestStore_SearchModelMismatch(t *testing.T) {
+	client, counts := newEmbedServer(t)
+	storePath := filepath.Join(t.TempDir(), "vectors.bin")
+
+	store := NewStore(client, "small", storePath)
+	if err := store.Add(context.Background(), Document{ID: "t1", Type: "track", Content: "Song"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	other := NewStore(client, "large", storePath)
+	if err := other.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	defer other.Close()
+
+	counts.reset()
+	if _, err := other.Search(context.Background(), "query", 5, ""); !errors.Is(err, ErrModelMismatch) {
+		t.Errorf("expected ErrModelMismatch, got %v", err)
+	}
+	if counts.inputs != 0 {
+		t.Error("a mismatched query should not be embedded")
+	}
+
+	if _, err := other.SearchVector([]float64{1, 2, 3}, 5, ""); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("expected ErrDimensionMismatch, got %v", err)
+	}
+}
+
+func TestStore_Reindex(t *testing.T) {
+	client, counts := newEmbedServer(t)
+	ctx := context.Background()
+
+	store := NewStore(client, "small", "")
+	docs := []Document{
+		{ID: "a", Type: "track", Content: "A"},
+		{ID: "b", Type: "track", Content: "BB"},
+		{ID: "c", Type: "track", Content: "CCC"},
+	}
+	if err := store.AddBatch(ctx, docs); err != nil {
+		t.Fatalf("AddBatch failed: %v", err)
+	}
+
+	// Switch models: one document is embedded by the new model already
+	store.model = "large"
+	if err := store.Add(ctx, Document{ID: "b", Type: "track", Content: "BB"}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if store.Model() != "large" || store.Dimensions() != 3 {
+		t.Fatalf("index should switch to the store's model, got %q with %d dimensions", store.Model(), store.Dimensions())
+	}
+
+	stale := store.StaleDocuments()
+	if len(stale) != 2 || stale[0].ID != "a" || stale[1].ID != "c" {
+		t.Fatalf("expected a and c to be stale, got %v", stale)
+	}
+	if stale[0].Embedding != nil {
+		t.Error("stale documents should not carry embeddings")
+	}
+
+	counts.reset()
+	n, err := store.Reindex(ctx, 2)
+	if err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	if n != 2 || counts.inputs != 2 {
+		t.Errorf("Reindex re-embedded %d documents from %d inputs, want 2 and 2", n, counts.inputs)
+	}
+	if stale := store.StaleDocuments(); len(stale) != 0 {
+		t.Errorf("expected no stale documents after Reindex, got %v", stale)
+	}
+	results, err := store.SearchVector([]float64{1, 2, 1}, 3, "")
+	if err != nil || len(results) != 3 {
+		t.Errorf("expected all 3 documents searchable, got %v, %v", results, err)
+	}
+}
+
+func TestStore_Update(t *testing.T) {
+	client, counts := newEmbedServer(t)
+	ctx := context.Background()
+	store := NewStore(client, "small", "")
+
+	var lastDone, lastTotal int
+	progress := func(done, total int) { lastDone, lastTotal = done, total }
+
+	result, err := store.Update(ctx, []Document{
+		{ID: "a", Type: "track", Content: "A"},
+		{ID: "b", Type: "track", Content: "B"},
+		{ID: "c", Type: "track", Content: "C"},
+	}, 2, progress)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if result != (UpdateResult{Added: 3}) || counts.inputs != 3 {
+		t.Errorf("first update: got %+v with %d inputs embedded", result, counts.inputs)
+	}
+	if lastDone != 3 || lastTotal != 3 {
+		t.Errorf("progress ended at %d/%d, want 3/3", lastDone, lastTotal)
+	}
+
+	counts.reset()
+	result, err = store.Update(ctx, []Document{
+		{ID: "a", Type: "track", Content: "A", Metadata: map[string]string{"name": "renamed"}},
+		{ID: "b", Type: "track", Content: "B changed"},
+		{ID: "d", Type: "track", Content: "D"},
+	}, 2, nil)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	want := UpdateResult{Added: 1, Updated: 1, Unchanged: 1, Removed: 1}
+	if result != want || counts.inputs != 2 {
+		t.Errorf("second update: got %+v with %d inputs embedded, want %+v with 2", result, counts.inputs, want)
+	}
+	if result.Embedded() != 2 {
+		t.Errorf("Embedded() = %d, want 2", result.Embedded())
+	}
+
+	if store.Count() != 3 {
+		t.Errorf("expected 3 documents, got %d", store.Count())
+	}
+	if _, ok := store.documents["c"]; ok {
+		t.Error("vanished document c was not removed")
+	}
+	if a := store.documents["a"]; a.Metadata["name"] != "renamed" || !store.index.Has("a") {
+		t.Errorf("unchanged document a should keep its embedding and take new metadata, got %+v", a)
+	}
+	if b := store.documents["b"]; b.ContentHash != ContentHash("B changed") {
+		t.Error("changed document b was not re-embedded")
+	}
+}
+
+func TestStore_AddBatchUsesBatchEmbedding(t *testing.T) {
+	client, counts := newEmbedServer(t)
+	client.SetEmbedBatchSize(2)
+	store := NewStore(client, "small", "")
+
+	var docs []Document
+	for _, content := range []string{"a", "bb", "ccc", "dddd", "eeeee"} {
+		docs = append(docs, Document{ID: content, Type: "track", Content: content})
+	}
+	if err := store.AddBatchParallel(context.Background(), docs, 2); err != nil {
+		t.Fatalf("AddBatchParallel failed: %v", err)
+	}
+
+	if counts.requests != 3 || counts.inputs != 5 {
+		t.Errorf("expected 5 inputs in 3 requests, got %d in %d", counts.inputs, counts.requests)
+	}
+	for _, doc := range docs {
+		vector := store.index.Vector(doc.ID)
+		want := normalize([]float64{1, float64(len(doc.Content))})
+		if vector == nil || vector[1] != want[1] {
+			t.Errorf("document %s got the wrong embedding: %v", doc.ID, vector)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package rag
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"unsafe"
+)
+
+// vectorMagic starts a binary store file. The file continues with a fixed
+// little-endian header:
+//
+//	version     uint8
+//	encoding    uint8, vectorFloat32 or vectorInt8
+//	reserved    uint16
+//	dims        uint32
+//	count       uint32, the number of vectors
+//	model size  uint32
+//	docs size   uint64
+//
+// then the embedding model name, the documents as a JSON array, zero padding
+// to a multiple of vectorAlign bytes, and the vectors. Vector i belongs to
+// document i; documents after the last vector keep their embedding, if any,
+// in the JSON. Each float32 vector is dims little-endian float32s, so it can
+// be used straight from a memory-mapped file. Each int8 vector is a float32
+// scale followed by dims signed bytes, component j being byte j * scale.
+var vectorMagic = []byte("SPGOVECS")
+
+const (
+	vectorVersion    = 1
+	vectorHeaderSize = 32
+	vectorAlign      = 64
+
+	vectorFloat32 = 0
+	vectorInt8    = 1
+)
+
+// littleEndian reports whether float32 vectors in a file can be used in place
+var littleEndian = binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+
+// vectorFile is the content of a binary store file
+type vectorFile struct {
+	model   string
+	docs    []Document
+	vectors [][]float32 // normalised, one per leading document
+}
+
+// isVectorFile reports whether data starts like a binary store file
+func isVectorFile(data []byte) bool {
+	return bytes.HasPrefix(data, vectorMagic)
+}
+
+// writeVectorFile writes f in the binary format above, quantizing the vectors
+// to int8 if quantize is set
+func writeVectorFile(w io.Writer, f vectorFile, quantize bool) error {
+	dims := 0
+	if len(f.vectors) > 0 {
+		dims = len(f.vectors[0])
+	}
+	docs, err := json.Marshal(f.docs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal documents: %w", err)
+	}
+
+	encoding := byte(vectorFloat32)
+	if quantize {
+		encoding = vectorInt8
+	}
+	header := make([]byte, vectorHeaderSize)
+	copy(header, vectorMagic)
+	header[8] = vectorVersion
+	header[9] = encoding
+	binary.LittleEndian.PutUint32(header[12:], uint32(dims))           // #nosec G115 - bounded by maxDimensions
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(f.vectors))) // #nosec G115 - a store never holds 2^32 vectors
+	binary.LittleEndian.PutUint32(header[20:], uint32(len(f.model)))   // #nosec G115 - model names are short
+	binary.LittleEndian.PutUint64(header[24:], uint64(len(docs)))
+
+	bw := bufio.NewWriter(w)
+	_, _ = bw.Write(header)
+	_, _ = bw.WriteString(f.model)
+	_, _ = bw.Write(docs)
+	written := vectorHeaderSize + len(f.model) + len(docs)
+	_, _ = bw.Write(make([]byte, (vectorAlign-written%vectorAlign)%vectorAlign))
+
+	buf := make([]byte, 4)
+	for _, v := range f.vectors {
+		if len(v) != dims {
+			return fmt.Errorf("vectors have different dimensions")
+		}
+		if !quantize {
+			for _, x := range v {
+				binary.LittleEndian.PutUint32(buf, math.Float32bits(x))
+				_, _ = bw.Write(buf)
+			}
+			continue
+		}
+
+		var peak float32
+		for _, x := range v {
+			peak = max(peak, x, -x)
+		}
+		scale := peak / 127
+		binary.LittleEndian.PutUint32(buf, math.Float32bits(scale))
+		_, _ = bw.Write(buf)
+		for _, x := range v {
+			var q int8
+			if scale > 0 {
+				q = int8(math.Round(float64(x / scale))) // #nosec G115 - |x| <= peak, so |x/scale| <= 127
+			}
+			_ = bw.WriteByte(byte(q))
+		}
+	}
+	return bw.Flush()
+}
+
+// readVectorFile reads a file written by writeVectorFile. Float32 vectors
+// point into data where the host allows it, so data must stay valid while
+// they are used; int8 vectors are expanded into new memory.
+func readVectorFile(data []byte) (vectorFile, error) {
+	var f vectorFile
+	if !isVectorFile(data) || len(data) < vectorHeaderSize {
+		return f, fmt.Errorf("not a vector store file")
+	}
+	if data[8] != vectorVersion {
+		return f, fmt.Errorf("unsupported vector store version %d", data[8])
+	}
+	encoding := data[9]
+	dims := uint64(binary.LittleEndian.Uint32(data[12:]))
+	count := uint64(binary.LittleEndian.Uint32(data[16:]))
+	modelSize := uint64(binary.LittleEndian.Uint32(data[20:]))
+	docsSize := binary.LittleEndian.Uint64(data[24:])
+
+	var recordSize uint64
+	switch encoding {
+	case vectorFloat32:
+		recordSize = 4 * dims
+	case vectorInt8:
+		recordSize = 4 + dims
+	default:
+		return f, fmt.Errorf("unsupported vector encoding %d", encoding)
+	}
+	if dims > maxDimensions || (count > 0 && dims == 0) {
+		return f, fmt.Errorf("invalid vector dimensions %d", dims)
+	}
+
+	rest := uint64(len(data) - vectorHeaderSize)
+	if modelSize > rest || docsSize > rest-modelSize {
+		return f, fmt.Errorf("vector store file is truncated")
+	}
+	offset := vectorHeaderSize + modelSize
+	f.model = string(data[vectorHeaderSize:offset])
+	if err := json.Unmarshal(data[offset:offset+docsSize], &f.docs); err != nil {
+		return f, fmt.Errorf("failed to unmarshal documents: %w", err)
+	}
+	offset += docsSize
+	offset += (vectorAlign - offset%vectorAlign) % vectorAlign
+
+	if count > uint64(len(f.docs)) || offset > uint64(len(data)) || count*recordSize > uint64(len(data))-offset {
+		return f, fmt.Errorf("vector store file is truncated")
+	}
+
+	f.vectors = make([][]float32, count)
+	for i := range f.vectors {
+		record := data[offset : offset+recordSize]
+		offset += recordSize
+		if encoding == vectorFloat32 {
+			f.vectors[i] = float32s(record)
+			continue
+		}
+
+		scale := math.Float32frombits(binary.LittleEndian.Uint32(record))
+		v := make([]float64, dims)
+		for j := range v {
+			v[j] = float64(int8(record[4+j])) * float64(scale) // #nosec G115 - reinterprets the stored byte
+		}
+		f.vectors[i] = normalize(v)
+		if f.vectors[i] == nil {
+			return f, fmt.Errorf("vector %d is zero", i)
+		}
+	}
+	return f, nil
+}
+
+// float32s returns little-endian float32 data as a slice, sharing b's memory
+// when it is suitably aligned
+func float32s(b []byte) []float32 {
+	if len(b) == 0 {
+		return []float32{}
+	}
+	if littleEndian && uintptr(unsafe.Pointer(&b[0]))%4 == 0 {
+		return unsafe.Slice((*float32)(unsafe.Pointer(&b[0])), len(b)/4) // #nosec G103 - aligned, and the format is little-endian
+	}
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
@@ -0,0 +1,205 @@
+package rag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVectorFile_RoundTrip(t *testing.T) {
+	vectors := randomVectors(20, 24)
+	file := vectorFile{model: "nomic-embed-text"}
+	for i := range vectors {
+		file.docs = append(file.docs, Document{ID: fmt.Sprintf("v%d", i), Type: "track", Content: "content"})
+	}
+	file.docs = append(file.docs, Document{ID: "other", Type: "track", Embedding: []float64{1, 2}})
+	file.vectors = vectors
+
+	tests := []struct {
+		name      string
+		quantize  bool
+		tolerance float64
+	}{
+		{"float32", false, 0},
+		{"int8", true, 0.02},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeVectorFile(&buf, file, tt.quantize); err != nil {
+				t.Fatalf("writeVectorFile failed: %v", err)
+			}
+			got, err := readVectorFile(buf.Bytes())
+			if err != nil {
+				t.Fatalf("readVectorFile failed: %v", err)
+			}
+
+			if got.model != file.model {
+				t.Errorf("model = %q, want %q", got.model, file.model)
+			}
+			if len(got.docs) != len(file.docs) || got.docs[0].Content != "content" {
+				t.Fatalf("documents not preserved: %v", got.docs)
+			}
+			if last := got.docs[len(got.docs)-1]; len(last.Embedding) != 2 {
+				t.Errorf("unindexed document lost its embedding: %v", last)
+			}
+			if len(got.vectors) != len(vectors) {
+				t.Fatalf("got %d vectors, want %d", len(got.vectors), len(vectors))
+			}
+			for i, v := range got.vectors {
+				for j := range v {
+					if diff := math.Abs(float64(v[j] - vectors[i][j])); diff > tt.tolerance {
+						t.Fatalf("vector %d[%d] = %f, want %f", i, j, v[j], vectors[i][j])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestVectorFile_Size(t *testing.T) {
+	file := vectorFile{model: "nomic-embed-text", vectors: randomVectors(100, 256)}
+	for i := range file.vectors {
+		file.docs = append(file.docs, Document{ID: fmt.Sprintf("v%d", i)})
+	}
+
+	var float32s, int8s bytes.Buffer
+	if err := writeVectorFile(&float32s, file, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeVectorFile(&int8s, file, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if vectorsSize := 100 * 256 * 4; float32s.Len() > vectorsSize+vectorsSize/10 {
+		t.Errorf("float32 file is %d bytes for %d bytes of vectors", float32s.Len(), vectorsSize)
+	}
+	if int8s.Len()*3 > float32s.Len() {
+		t.Errorf("int8 file is %d bytes, float32 file %d", int8s.Len(), float32s.Len())
+	}
+}
+
+func TestVectorFile_Invalid(t *testing.T) {
+	file := vectorFile{
+		model:   "m",
+		docs:    []Document{{ID: "a"}, {ID: "b"}},
+		vectors: randomVectors(2, 8),
+	}
+	var buf bytes.Buffer
+	if err := writeVectorFile(&buf, file, false); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	withByte := func(i int, b byte) []byte {
+		out := bytes.Clone(data)
+		out[i] = b
+		return out
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"header only", data[:vectorHeaderSize-1]},
+		{"version", withByte(8, 9)},
+		{"encoding", withByte(9, 7)},
+		{"too many dimensions", withByte(14, 0xff)},
+		{"truncated vectors", data[:len(data)-4]},
+		{"too many vectors", withByte(16, 3)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readVectorFile(tt.data); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestStore_MigratesJSON(t *testing.T) {
+	dir := t.TempDir()
+	docs := []Document{
+		{ID: "rock", Type: "track", Content: "Rock Song", Embedding: []float64{0.9, 0.1, 0.0}},
+		{ID: "jazz", Type: "track", Content: "Jazz Song", Embedding: []float64{0.0, 0.9, 0.1}},
+		{ID: "bare", Type: "artist", Content: "No embedding"},
+	}
+	data, err := json.MarshalIndent(docs, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := filepath.Join(dir, "vectors.json")
+	if err := os.WriteFile(legacy, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(nil, "", filepath.Join(dir, "vectors.bin"))
+	if err := store.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if store.Count() != 3 {
+		t.Fatalf("expected 3 documents from the JSON store, got %d", store.Count())
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Error("JSON store should be removed after migration")
+	}
+
+	loaded := NewStore(nil, "", filepath.Join(dir, "vectors.bin"))
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	defer loaded.Close()
+
+	if loaded.Count() != 3 || loaded.Dimensions() != 3 {
+		t.Fatalf("migrated store has %d documents of %d dimensions, want 3 and 3", loaded.Count(), loaded.Dimensions())
+	}
+	results, err := loaded.SearchVector([]float64{1, 0, 0}, 1, "")
+	if err != nil {
+		t.Fatalf("SearchVector failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Document.ID != "rock" {
+		t.Errorf("expected rock from migrated store, got %v", results)
+	}
+}
+
+func TestStore_SaveQuantized(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "vectors.bin")
+	store := NewStore(nil, "nomic-embed-text", storePath)
+	store.SetQuantize(true)
+	vectors := randomVectors(100, 32)
+	for i, v := range vectors {
+		store.put(Document{ID: fmt.Sprintf("v%d", i), Type: "track", Embedding: toFloat64(v)})
+	}
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded := NewStore(nil, "", storePath)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	defer loaded.Close()
+
+	if loaded.Model() != "nomic-embed-text" {
+		t.Errorf("Model() = %q, want the model from the file", loaded.Model())
+	}
+	for i := 0; i < 10; i++ {
+		results, err := loaded.SearchVector(toFloat64(vectors[i]), 1, "")
+		if err != nil {
+			t.Fatalf("SearchVector failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Document.ID != fmt.Sprintf("v%d", i) {
+			t.Errorf("v%d not found after quantization, got %v", i, results)
+		}
+	}
+}
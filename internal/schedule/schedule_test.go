@@ -0,0 +1,78 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_Next(t *testing.T) {
+	// Wednesday
+	base := time.Date(2024, 5, 15, 10, 30, 45, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"hourly", base, time.Date(2024, 5, 15, 11, 0, 0, 0, time.UTC)},
+		{"daily", base, time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC)},
+		{"@daily", base, time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC)},
+		{"weekly", base, time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)},
+		{"monthly", base, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"yearly", base, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 6h", base, time.Date(2024, 5, 15, 16, 30, 45, 0, time.UTC)},
+		{"30 3 * * *", base, time.Date(2024, 5, 16, 3, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", base, time.Date(2024, 5, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 9-17 * * 1-5", base, time.Date(2024, 5, 15, 11, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2024, 5, 17, 10, 0, 0, 0, time.UTC), time.Date(2024, 5, 20, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", base, time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", base, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: the 1st of the month or any Monday
+		{"0 0 1 * 1", base, time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", base, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			sched, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.spec, err)
+			}
+			if got := sched.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	specs := []string{
+		"",
+		"sometimes",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"0 0 0 * *",
+		"0 0 * 13 *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"@every soon",
+		"@every 10s",
+	}
+
+	for _, spec := range specs {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) expected error", spec)
+		}
+	}
+}
+
+func TestCron_NeverMatches(t *testing.T) {
+	sched, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if next := sched.Next(time.Now()); !next.IsZero() {
+		t.Errorf("expected zero time for impossible schedule, got %v", next)
+	}
+}
@@ -2,12 +2,20 @@
 package spotify
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/zmb3/spotify/v2"
 	spotifyauth "github.com/zmb3/spotify/v2/auth"
@@ -24,13 +32,51 @@ var Scopes = []string{
 	spotifyauth.ScopeUserTopRead,
 	spotifyauth.ScopeUserReadRecentlyPlayed,
 	spotifyauth.ScopeUserFollowRead,
+	scopeUserReadPlaybackPosition,
 }
 
+// WriteScopes are requested in addition to Scopes when write access is enabled,
+// allowing backups to be restored into the account
+var WriteScopes = []string{
+	spotifyauth.ScopeUserLibraryModify,
+	spotifyauth.ScopeUserFollowModify,
+	spotifyauth.ScopePlaylistModifyPublic,
+	spotifyauth.ScopePlaylistModifyPrivate,
+}
+
+// scopeUserReadPlaybackPosition grants access to saved episodes and their resume points
+const scopeUserReadPlaybackPosition = "user-read-playback-position"
+
+// defaultAPIBaseURL is the Spotify Web API base URL
+const defaultAPIBaseURL = "https://api.spotify.com/v1/"
+
 // Client wraps the Spotify client with additional functionality
 type Client struct {
-	client *spotify.Client
-	auth   *spotifyauth.Authenticator
-	token  *oauth2.Token
+	client     *spotify.Client
+	httpClient *http.Client
+	auth       *oauth2.Config
+	// verifier is the PKCE code verifier, set when there is no client secret
+	verifier string
+	token    *oauth2.Token
+	retry    *retryTransport
+	baseURL  string
+	tokenKey crypto.KeyProvider
+	// tokenErr records why a saved token could not be loaded
+	tokenErr error
+
+	// tokenMu guards the token and its metadata, which change on refresh
+	tokenMu        sync.Mutex
+	tokenFile      string
+	tokenScope     string
+	tokenRefreshed time.Time
+	// refresh exchanges an expired token for a new one (replaced in tests)
+	refresh func(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error)
+}
+
+// SavedEpisode is a podcast episode saved to the user's library
+type SavedEpisode struct {
+	AddedAt string              `json:"added_at"`
+	Episode spotify.EpisodePage `json:"episode"`
 }
 
 // Config holds Spotify client configuration
@@ -39,59 +85,192 @@ type Config struct {
 	ClientSecret string
 	RedirectURI  string
 	TokenFile    string
+
+	// Retry configures rate-limit and transient error handling.
+	// The zero value uses DefaultRetryConfig.
+	Retry RetryConfig
+
+	// APIBaseURL overrides the Spotify Web API base URL (used for testing)
+	APIBaseURL string
+
+	// AccountsURL overrides the Spotify Accounts service base URL (used for testing)
+	AccountsURL string
+
+	// WriteAccess requests WriteScopes during authentication
+	WriteAccess bool
+
+	// TokenKey supplies the key the token file is encrypted with.
+	// nil uses the machine-derived key.
+	TokenKey crypto.KeyProvider
 }
 
 // NewClient creates a new Spotify client
 func NewClient(cfg Config) (*Client, error) {
-	auth := spotifyauth.New(
-		spotifyauth.WithClientID(cfg.ClientID),
-		spotifyauth.WithClientSecret(cfg.ClientSecret),
-		spotifyauth.WithRedirectURL(cfg.RedirectURI),
-		spotifyauth.WithScopes(Scopes...),
-	)
+	scopes := Scopes
+	if cfg.WriteAccess {
+		scopes = append(append([]string{}, Scopes...), WriteScopes...)
+	}
+
+	auth := &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURI,
+		Scopes:       scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  spotifyauth.AuthURL,
+			TokenURL: spotifyauth.TokenURL,
+		},
+	}
+	if cfg.AccountsURL != "" {
+		base := strings.TrimSuffix(cfg.AccountsURL, "/")
+		auth.Endpoint.AuthURL, auth.Endpoint.TokenURL = base+"/authorize", base+"/api/token"
+	}
+
+	// Without a client secret the client is public and proves itself with
+	// PKCE instead; the client ID is then sent in the request body
+	var verifier string
+	if cfg.ClientSecret == "" {
+		verifier = oauth2.GenerateVerifier()
+		auth.Endpoint.AuthStyle = oauth2.AuthStyleInParams
+	}
+
+	retryCfg := cfg.Retry
+	if retryCfg == (RetryConfig{}) {
+		retryCfg = DefaultRetryConfig()
+	}
 
 	c := &Client{
-		auth: auth,
+		auth:      auth,
+		verifier:  verifier,
+		retry:     newRetryTransport(nil, retryCfg),
+		baseURL:   cfg.APIBaseURL,
+		tokenKey:  cfg.TokenKey,
+		tokenFile: cfg.TokenFile,
 	}
 
 	// Try to load existing token
 	if cfg.TokenFile != "" {
-		if token, err := c.loadToken(cfg.TokenFile); err == nil {
-			c.token = token
-			httpClient := auth.Client(context.Background(), token)
-			c.client = spotify.New(httpClient)
+		if stored, err := c.loadToken(cfg.TokenFile); err == nil {
+			c.token = &stored.Token
+			c.tokenScope, c.tokenRefreshed = stored.Scope, stored.RefreshedAt
+			c.attachToken()
+		} else if !os.IsNotExist(err) {
+			c.tokenErr = err
 		}
 	}
 
 	return c, nil
 }
 
+// attach routes httpClient through the retry transport and builds the API client on it
+func (c *Client) attach(httpClient *http.Client) {
+	if c.retry == nil {
+		c.retry = newRetryTransport(nil, DefaultRetryConfig())
+	}
+	c.retry.base = httpClient.Transport
+	if c.retry.base == nil {
+		c.retry.base = http.DefaultTransport
+	}
+	httpClient.Transport = c.retry
+	c.httpClient = httpClient
+
+	var opts []spotify.ClientOption
+	if c.baseURL != "" {
+		opts = append(opts, spotify.WithBaseURL(c.baseURL))
+	}
+	c.client = spotify.New(httpClient, opts...)
+}
+
+// RequestCount returns the number of HTTP requests sent to Spotify, including retries
+func (c *Client) RequestCount() int {
+	if c.retry == nil {
+		return 0
+	}
+	return c.retry.Requests()
+}
+
 // IsAuthenticated returns true if the client has a valid token
 func (c *Client) IsAuthenticated() bool {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
 	return c.token != nil && c.client != nil
 }
 
+// TokenError returns why an existing token file could not be loaded, if it could not
+func (c *Client) TokenError() error {
+	return c.tokenErr
+}
+
+// keyProvider returns the token key provider, defaulting to the machine key
+func (c *Client) keyProvider() crypto.KeyProvider {
+	if c.tokenKey == nil {
+		return crypto.MachineKey{}
+	}
+	return c.tokenKey
+}
+
+// UsesPKCE reports whether the client authenticates with PKCE rather than a client secret
+func (c *Client) UsesPKCE() bool {
+	return c.verifier != ""
+}
+
 // GetAuthURL returns the URL for OAuth authentication
 func (c *Client) GetAuthURL(state string) string {
-	return c.auth.AuthURL(state)
+	if c.verifier != "" {
+		return c.auth.AuthCodeURL(state, oauth2.S256ChallengeOption(c.verifier))
+	}
+	return c.auth.AuthCodeURL(state)
 }
 
 // HandleCallback processes the OAuth callback
 func (c *Client) HandleCallback(ctx context.Context, state string, r *http.Request, redirectURI string) error {
-	token, err := c.auth.Token(ctx, state, r, oauth2.SetAuthURLParam("redirect_uri", redirectURI))
+	values := r.URL.Query()
+	if reason := values.Get("error"); reason != "" {
+		return fmt.Errorf("failed to get token: authorization failed: %s", reason)
+	}
+	if values.Get("state") != state {
+		return fmt.Errorf("failed to get token: redirect state parameter doesn't match")
+	}
+	return c.ExchangeCode(ctx, values.Get("code"), redirectURI)
+}
+
+// ExchangeCode exchanges an authorization code for a token. It completes the
+// OAuth flow when the callback request was not received by a local server,
+// e.g. when the code is pasted in by the user.
+func (c *Client) ExchangeCode(ctx context.Context, code string, redirectURI string) error {
+	if code == "" {
+		return fmt.Errorf("authorization code is empty")
+	}
+	opts := []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("redirect_uri", redirectURI)}
+	if c.verifier != "" {
+		opts = append(opts, oauth2.VerifierOption(c.verifier))
+	}
+	token, err := c.auth.Exchange(ctx, code, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to get token: %w", err)
 	}
 
-	c.token = token
-	httpClient := c.auth.Client(ctx, token)
-	c.client = spotify.New(httpClient)
-
+	c.useToken(token)
 	return nil
 }
 
+// useToken switches the client to a newly issued token
+func (c *Client) useToken(token *oauth2.Token) {
+	c.tokenMu.Lock()
+	c.setTokenLocked(token, time.Now())
+	c.tokenMu.Unlock()
+	c.attachToken()
+}
+
 // SaveToken saves the current token to a file (encrypted)
 func (c *Client) SaveToken(filename string) error {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	return c.saveTokenLocked(filename)
+}
+
+// saveTokenLocked writes the token file; the caller holds tokenMu
+func (c *Client) saveTokenLocked(filename string) error {
 	if c.token == nil {
 		return fmt.Errorf("no token to save")
 	}
@@ -99,31 +278,33 @@ func (c *Client) SaveToken(filename string) error {
 	// Clean path to prevent traversal attacks
 	cleanPath := filepath.Clean(filename)
 
-	data, err := json.Marshal(c.token)
+	data, err := json.Marshal(storedToken{Token: *c.token, Scope: c.tokenScope, RefreshedAt: c.tokenRefreshed})
 	if err != nil {
 		return fmt.Errorf("failed to marshal token: %w", err)
 	}
 
-	// Try to encrypt the token
-	encryptor, err := crypto.NewTokenEncryptor()
+	provider := c.keyProvider()
+	encryptor, err := crypto.NewTokenEncryptorWithProvider(provider)
 	if err != nil {
-		// Fall back to plaintext if encryption fails (with warning)
-		fmt.Printf("Warning: Could not encrypt token, saving in plaintext: %v\n", err)
-		if err := os.WriteFile(cleanPath, data, 0600); err != nil { // #nosec G304 - path is sanitized with filepath.Clean
-			return fmt.Errorf("failed to write token file: %w", err)
-		}
-		return nil
+		return fmt.Errorf("failed to create encryptor: %w", err)
 	}
 
 	// Save encrypted token
 	if err := encryptor.SaveEncryptedFile(cleanPath, data); err != nil {
-		return fmt.Errorf("failed to save encrypted token: %w", err)
+		if _, machine := provider.(crypto.MachineKey); !machine {
+			return fmt.Errorf("failed to save encrypted token: %w", err)
+		}
+		// Fall back to plaintext if the machine key is unavailable (with warning)
+		fmt.Printf("Warning: Could not encrypt token, saving in plaintext: %v\n", err)
+		if err := os.WriteFile(cleanPath, data, 0600); err != nil { // #nosec G304 - path is sanitized with filepath.Clean
+			return fmt.Errorf("failed to write token file: %w", err)
+		}
 	}
 
 	return nil
 }
 
-func (c *Client) loadToken(filename string) (*oauth2.Token, error) {
+func (c *Client) loadToken(filename string) (*storedToken, error) {
 	// Clean path to prevent traversal attacks
 	cleanPath := filepath.Clean(filename)
 
@@ -137,7 +318,7 @@ func (c *Client) loadToken(filename string) (*oauth2.Token, error) {
 
 	// Check if the file is encrypted
 	if crypto.IsEncryptedFile(cleanPath) {
-		encryptor, createErr := crypto.NewTokenEncryptor()
+		encryptor, createErr := crypto.NewTokenEncryptorWithProvider(c.keyProvider())
 		if createErr != nil {
 			return nil, fmt.Errorf("failed to create encryptor: %w", createErr)
 		}
@@ -153,7 +334,7 @@ func (c *Client) loadToken(filename string) (*oauth2.Token, error) {
 		}
 	}
 
-	var token oauth2.Token
+	var token storedToken
 	if err := json.Unmarshal(data, &token); err != nil {
 		return nil, err
 	}
@@ -161,6 +342,21 @@ func (c *Client) loadToken(filename string) (*oauth2.Token, error) {
 	return &token, nil
 }
 
+// RekeyToken re-encrypts a saved token with a different key. Plaintext tokens
+// from older versions are encrypted too.
+func RekeyToken(filename string, from, to crypto.KeyProvider) error {
+	c := &Client{tokenKey: from}
+	stored, err := c.loadToken(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read token with the %s key: %w", c.keyProvider().Name(), err)
+	}
+
+	c.token = &stored.Token
+	c.tokenScope, c.tokenRefreshed = stored.Scope, stored.RefreshedAt
+	c.tokenKey = to
+	return c.SaveToken(filename)
+}
+
 // GetCurrentUser returns the current user's profile
 func (c *Client) GetCurrentUser(ctx context.Context) (*spotify.PrivateUser, error) {
 	if c.client == nil {
@@ -171,6 +367,15 @@ func (c *Client) GetCurrentUser(ctx context.Context) (*spotify.PrivateUser, erro
 
 // GetSavedTracks returns all saved tracks
 func (c *Client) GetSavedTracks(ctx context.Context) ([]spotify.SavedTrack, error) {
+	return c.GetSavedTracksSince(ctx, time.Time{})
+}
+
+// GetSavedTracksSince returns the tracks saved after since. Spotify lists
+// saved tracks newest first, so paging stops at the first track added at or
+// before since. A track saved again is listed with its new added_at, so it
+// is returned even if an earlier backup already had it. A zero since fetches
+// the whole library.
+func (c *Client) GetSavedTracksSince(ctx context.Context, since time.Time) ([]spotify.SavedTrack, error) {
 	if c.client == nil {
 		return nil, fmt.Errorf("client not authenticated")
 	}
@@ -185,7 +390,14 @@ func (c *Client) GetSavedTracks(ctx context.Context) ([]spotify.SavedTrack, erro
 			return nil, fmt.Errorf("failed to get tracks: %w", err)
 		}
 
-		allTracks = append(allTracks, tracks.Tracks...)
+		for _, track := range tracks.Tracks {
+			if !since.IsZero() {
+				if added, err := time.Parse(time.RFC3339, track.AddedAt); err == nil && !added.After(since) {
+					return allTracks, nil
+				}
+			}
+			allTracks = append(allTracks, track)
+		}
 
 		if len(tracks.Tracks) < limit {
 			break
@@ -336,3 +548,168 @@ func (c *Client) GetRecentlyPlayed(ctx context.Context) ([]spotify.RecentlyPlaye
 
 	return items, nil
 }
+
+// GetRecentlyPlayedAfter returns up to 50 tracks played after the given
+// time, oldest first. A zero time returns the most recent plays.
+func (c *Client) GetRecentlyPlayedAfter(ctx context.Context, after time.Time) ([]spotify.RecentlyPlayedItem, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("client not authenticated")
+	}
+
+	opts := &spotify.RecentlyPlayedOptions{Limit: 50}
+	if !after.IsZero() {
+		opts.AfterEpochMs = after.UnixMilli()
+	}
+	items, err := c.client.PlayerRecentlyPlayedOpt(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recently played: %w", err)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].PlayedAt.Before(items[j].PlayedAt)
+	})
+	return items, nil
+}
+
+// GetSavedAlbums returns all albums saved to the user's library
+func (c *Client) GetSavedAlbums(ctx context.Context) ([]spotify.SavedAlbum, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("client not authenticated")
+	}
+
+	var allAlbums []spotify.SavedAlbum
+	limit := 50
+	offset := 0
+
+	for {
+		albums, err := c.client.CurrentUsersAlbums(ctx, spotify.Limit(limit), spotify.Offset(offset))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get saved albums: %w", err)
+		}
+
+		allAlbums = append(allAlbums, albums.Albums...)
+
+		if len(albums.Albums) < limit {
+			break
+		}
+		offset += limit
+	}
+
+	return allAlbums, nil
+}
+
+// GetSavedShows returns all podcasts (shows) the user follows
+func (c *Client) GetSavedShows(ctx context.Context) ([]spotify.SavedShow, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("client not authenticated")
+	}
+
+	var allShows []spotify.SavedShow
+	limit := 50
+	offset := 0
+
+	for {
+		shows, err := c.client.CurrentUsersShows(ctx, spotify.Limit(limit), spotify.Offset(offset))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get saved shows: %w", err)
+		}
+
+		allShows = append(allShows, shows.Shows...)
+
+		if len(shows.Shows) < limit {
+			break
+		}
+		offset += limit
+	}
+
+	return allShows, nil
+}
+
+// GetSavedEpisodes returns all podcast episodes saved to the user's library
+func (c *Client) GetSavedEpisodes(ctx context.Context) ([]SavedEpisode, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("client not authenticated")
+	}
+
+	var allEpisodes []SavedEpisode
+	limit := 50
+	offset := 0
+
+	for {
+		var page struct {
+			Items []SavedEpisode `json:"items"`
+		}
+		params := url.Values{}
+		params.Set("limit", fmt.Sprintf("%d", limit))
+		params.Set("offset", fmt.Sprintf("%d", offset))
+		if err := c.getJSON(ctx, "me/episodes", params, &page); err != nil {
+			return nil, fmt.Errorf("failed to get saved episodes: %w", err)
+		}
+
+		allEpisodes = append(allEpisodes, page.Items...)
+
+		if len(page.Items) < limit {
+			break
+		}
+		offset += limit
+	}
+
+	return allEpisodes, nil
+}
+
+// getJSON performs a GET against an API endpoint not covered by the spotify library
+func (c *Client) getJSON(ctx context.Context, path string, params url.Values, result interface{}) error {
+	return c.doJSON(ctx, http.MethodGet, path, params, nil, result, http.StatusOK)
+}
+
+// doJSON sends a request with an optional JSON body and decodes a JSON response into result.
+// The response status must be one of okStatus.
+func (c *Client) doJSON(ctx context.Context, method, path string, params url.Values, body, result interface{}, okStatus ...int) error {
+	if c.httpClient == nil {
+		return fmt.Errorf("client not authenticated")
+	}
+
+	baseURL := c.baseURL
+	if baseURL == "" {
+		baseURL = defaultAPIBaseURL
+	}
+	endpoint := baseURL + path
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if !slices.Contains(okStatus, resp.StatusCode) {
+		return spotify.Error{Message: fmt.Sprintf("spotify error (status %d)", resp.StatusCode), Status: resp.StatusCode}
+	}
+
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -4,14 +4,19 @@ package spotify
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/zmb3/spotify/v2"
 	"golang.org/x/oauth2"
+
+	"github.com/bkataru/spotigo/internal/crypto"
 )
 
 func TestNewClient(t *testing.T) {
@@ -243,6 +248,48 @@ func TestClient_loadToken(t *testing.T) {
 	}
 }
 
+func TestRekeyToken(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "token.json")
+	keyFile := filepath.Join(dir, "token.key")
+	if err := os.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SPOTIGO_TEST_TOKEN_KEY", "fedcba9876543210fedcba9876543210")
+
+	// Start from a legacy plaintext token
+	data, _ := json.Marshal(&oauth2.Token{AccessToken: "test-access-token", Expiry: time.Now().Add(time.Hour)})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keyed := crypto.KeyFile{Path: keyFile}
+	env := crypto.EnvKey{Var: "SPOTIGO_TEST_TOKEN_KEY"}
+	if err := RekeyToken(path, crypto.MachineKey{}, keyed); err != nil {
+		t.Fatalf("RekeyToken() to keyfile error = %v", err)
+	}
+	if err := RekeyToken(path, keyed, env); err != nil {
+		t.Fatalf("RekeyToken() to env error = %v", err)
+	}
+
+	client, err := NewClient(Config{TokenFile: path, TokenKey: env})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !client.IsAuthenticated() || client.TokenError() != nil {
+		t.Errorf("expected token to load with the env key, got error %v", client.TokenError())
+	}
+
+	// The old key no longer opens it, and the client says why
+	client, _ = NewClient(Config{TokenFile: path, TokenKey: keyed})
+	if client.IsAuthenticated() || client.TokenError() == nil {
+		t.Error("expected the key file to be rejected after rekeying")
+	}
+	if err := RekeyToken(path, keyed, env); err == nil {
+		t.Error("expected RekeyToken() with the wrong key to fail")
+	}
+}
+
 func TestClient_UnauthenticatedMethods(t *testing.T) {
 	client := &Client{}
 	ctx := context.Background()
@@ -294,6 +341,79 @@ func TestClient_UnauthenticatedMethods(t *testing.T) {
 	if err == nil {
 		t.Error("GetRecentlyPlayed() should return error when not authenticated")
 	}
+
+	// Test GetSavedAlbums without authentication
+	_, err = client.GetSavedAlbums(ctx)
+	if err == nil {
+		t.Error("GetSavedAlbums() should return error when not authenticated")
+	}
+
+	// Test GetSavedShows without authentication
+	_, err = client.GetSavedShows(ctx)
+	if err == nil {
+		t.Error("GetSavedShows() should return error when not authenticated")
+	}
+
+	// Test GetSavedEpisodes without authentication
+	_, err = client.GetSavedEpisodes(ctx)
+	if err == nil {
+		t.Error("GetSavedEpisodes() should return error when not authenticated")
+	}
+
+	// Test playlist writes without authentication
+	if err := client.AddPlaylistItems(ctx, "p1", []spotify.URI{"spotify:track:t1"}); err == nil {
+		t.Error("AddPlaylistItems() should return error when not authenticated")
+	}
+	if err := client.ReplacePlaylistItems(ctx, "p1", nil); err == nil {
+		t.Error("ReplacePlaylistItems() should return error when not authenticated")
+	}
+}
+
+func TestClient_LibraryEndpoints(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/me/albums":
+			fmt.Fprint(w, `{"items":[{"added_at":"2024-01-01T00:00:00Z","album":{"id":"al1","name":"Album One"}}]}`)
+		case "/me/shows":
+			fmt.Fprint(w, `{"items":[{"added_at":"2024-01-01T00:00:00Z","show":{"id":"sh1","name":"Show One","publisher":"Pub"}}]}`)
+		case "/me/episodes":
+			if r.URL.Query().Get("limit") != "50" {
+				t.Errorf("expected limit=50, got %q", r.URL.Query().Get("limit"))
+			}
+			fmt.Fprint(w, `{"items":[{"added_at":"2024-01-01T00:00:00Z","episode":{"id":"ep1","name":"Episode One","show":{"name":"Show One"}}}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, DefaultRetryConfig())
+	ctx := context.Background()
+
+	albums, err := client.GetSavedAlbums(ctx)
+	if err != nil {
+		t.Fatalf("GetSavedAlbums() error = %v", err)
+	}
+	if len(albums) != 1 || albums[0].Name != "Album One" {
+		t.Errorf("unexpected albums: %+v", albums)
+	}
+
+	shows, err := client.GetSavedShows(ctx)
+	if err != nil {
+		t.Fatalf("GetSavedShows() error = %v", err)
+	}
+	if len(shows) != 1 || shows[0].Publisher != "Pub" {
+		t.Errorf("unexpected shows: %+v", shows)
+	}
+
+	episodes, err := client.GetSavedEpisodes(ctx)
+	if err != nil {
+		t.Fatalf("GetSavedEpisodes() error = %v", err)
+	}
+	if len(episodes) != 1 || episodes[0].Episode.Name != "Episode One" || episodes[0].Episode.Show.Name != "Show One" {
+		t.Errorf("unexpected episodes: %+v", episodes)
+	}
 }
 
 func TestParseTimeRange(t *testing.T) {
@@ -348,6 +468,7 @@ func TestScopes(t *testing.T) {
 		"user-top-read",
 		"user-read-recently-played",
 		"user-follow-read",
+		"user-read-playback-position",
 	}
 
 	if len(Scopes) != len(expectedScopes) {
@@ -455,3 +576,75 @@ func BenchmarkParseTimeRange(b *testing.B) {
 		_ = parseTimeRange(ranges[i%len(ranges)])
 	}
 }
+
+func TestClient_GetSavedTracksSince(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		// t1 was saved before the last backup and saved again since
+		fmt.Fprint(w, `{"items":[
+			{"added_at":"2024-03-03T00:00:00Z","track":{"id":"t3","name":"Three"}},
+			{"added_at":"2024-03-02T00:00:00Z","track":{"id":"t1","name":"One"}},
+			{"added_at":"2024-03-01T00:00:00Z","track":{"id":"t2","name":"Two"}},
+			{"added_at":"2024-02-01T00:00:00Z","track":{"id":"t0","name":"Zero"}}],
+			"limit":50,"offset":0,"total":4}`)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, DefaultRetryConfig())
+
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tracks, err := client.GetSavedTracksSince(context.Background(), since)
+	if err != nil {
+		t.Fatalf("GetSavedTracksSince() error = %v", err)
+	}
+	if len(tracks) != 2 || tracks[0].ID != "t3" || tracks[1].ID != "t1" {
+		t.Errorf("expected t3 and the re-saved t1, got %v", tracks)
+	}
+	if requests != 1 {
+		t.Errorf("expected paging to stop after 1 request, got %d", requests)
+	}
+
+	all, err := client.GetSavedTracksSince(context.Background(), time.Time{})
+	if err != nil || len(all) != 4 {
+		t.Errorf("GetSavedTracksSince(zero) = %d tracks, %v; want 4", len(all), err)
+	}
+}
+
+func TestClient_GetRecentlyPlayedAfter(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		// Spotify lists the newest play first
+		fmt.Fprint(w, `{"items":[
+			{"played_at":"2024-05-01T12:10:00.000Z","track":{"id":"t2","name":"Two"}},
+			{"played_at":"2024-05-01T12:05:00.000Z","track":{"id":"t1","name":"One"}}]}`)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, DefaultRetryConfig())
+	after := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	items, err := client.GetRecentlyPlayedAfter(context.Background(), after)
+	if err != nil {
+		t.Fatalf("GetRecentlyPlayedAfter() error = %v", err)
+	}
+	if got := query.Get("after"); got != fmt.Sprint(after.UnixMilli()) {
+		t.Errorf("after = %q, want %d", got, after.UnixMilli())
+	}
+	if query.Get("limit") != "50" {
+		t.Errorf("limit = %q, want 50", query.Get("limit"))
+	}
+	if len(items) != 2 || items[0].Track.ID != "t1" {
+		t.Errorf("expected plays oldest first, got %v", items)
+	}
+
+	if _, err := client.GetRecentlyPlayedAfter(context.Background(), time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if query.Has("after") {
+		t.Errorf("zero time should not send a cursor, got %v", query)
+	}
+}
@@ -0,0 +1,249 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRequestBudgetExceeded is returned when a client has used up its request budget
+var ErrRequestBudgetExceeded = errors.New("spotify request budget exceeded")
+
+// RetryConfig controls how rate-limited and transient API failures are retried
+type RetryConfig struct {
+	// MaxRetries is the maximum number of retries per request
+	MaxRetries int
+	// BaseDelay is the initial backoff delay for transient errors
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff delay. A Retry-After longer than this is not waited for.
+	MaxDelay time.Duration
+	// RequestBudget limits the total number of HTTP requests a client may send (0 = unlimited)
+	RequestBudget int
+}
+
+// DefaultRetryConfig returns the retry settings used when none are configured
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxRetries: 5,
+		BaseDelay:  500 * time.Millisecond,
+		MaxDelay:   60 * time.Second,
+	}
+}
+
+// retryTransport is an http.RoundTripper that retries 429 responses, and 5xx
+// responses and network errors for idempotent requests
+type retryTransport struct {
+	base     http.RoundTripper
+	cfg      RetryConfig
+	requests atomic.Int64
+
+	// sleep waits for the given duration, returning early if ctx is done
+	sleep func(ctx context.Context, d time.Duration) error
+
+	randMu sync.Mutex
+	rand   *rand.Rand
+}
+
+// newRetryTransport wraps base with retry and budget handling
+func newRetryTransport(base http.RoundTripper, cfg RetryConfig) *retryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &retryTransport{
+		base:  base,
+		cfg:   cfg,
+		sleep: sleepContext,
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())), // #nosec G404 - jitter does not need a secure source
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	// A POST that failed may still have been applied, so it is only retried
+	// when it cannot have been: on 429, or when no connection was made
+	idempotent := isIdempotent(req.Method)
+
+	for attempt := 0; ; attempt++ {
+		if !t.reserve() {
+			return nil, ErrRequestBudgetExceeded
+		}
+
+		attemptReq, err := rewindRequest(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if attempt >= t.cfg.MaxRetries {
+			return resp, err
+		}
+
+		var delay time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil || !idempotent && !isDialError(err) {
+				return nil, err
+			}
+			delay = t.backoff(attempt)
+		case resp.StatusCode == http.StatusTooManyRequests:
+			retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			if !ok {
+				retryAfter = t.backoff(attempt)
+			}
+			if t.cfg.MaxDelay > 0 && retryAfter > t.cfg.MaxDelay {
+				return resp, nil
+			}
+			delay = retryAfter
+		case isTransientStatus(resp.StatusCode) && idempotent:
+			delay = t.backoff(attempt)
+		default:
+			return resp, nil
+		}
+
+		if resp != nil {
+			drainAndClose(resp.Body)
+		}
+
+		if sleepErr := t.sleep(ctx, delay); sleepErr != nil {
+			return nil, sleepErr
+		}
+	}
+}
+
+// reserve counts a request against the budget, reporting false once it is used up
+func (t *retryTransport) reserve() bool {
+	if t.cfg.RequestBudget <= 0 {
+		t.requests.Add(1)
+		return true
+	}
+	for {
+		sent := t.requests.Load()
+		if sent >= int64(t.cfg.RequestBudget) {
+			return false
+		}
+		if t.requests.CompareAndSwap(sent, sent+1) {
+			return true
+		}
+	}
+}
+
+// Requests returns the number of HTTP requests sent so far, including retries
+func (t *retryTransport) Requests() int {
+	return int(t.requests.Load())
+}
+
+// backoff returns a jittered exponential delay for the given attempt
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	delay := t.cfg.BaseDelay << uint(attempt) // #nosec G115 - attempt is bounded by MaxRetries
+	if delay <= 0 || (t.cfg.MaxDelay > 0 && delay > t.cfg.MaxDelay) {
+		delay = t.cfg.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	// Pick a random delay in [delay/2, delay]
+	half := int64(delay / 2)
+	t.randMu.Lock()
+	jitter := t.rand.Int63n(half + 1)
+	t.randMu.Unlock()
+	return time.Duration(half + jitter)
+}
+
+// rewindRequest returns a request suitable for the given attempt, resetting the body on retries
+func rewindRequest(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("cannot retry request with non-rewindable body")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to rewind request body: %w", err)
+	}
+	clone := req.Clone(req.Context())
+	clone.Body = body
+	return clone, nil
+}
+
+// isTransientStatus reports whether a status code is worth retrying
+func isTransientStatus(status int) bool {
+	switch status {
+	case http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// isIdempotent reports whether repeating a request with method has the same effect as sending it once
+func isIdempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// isDialError reports whether err happened while connecting, before the request was sent
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// parseRetryAfter parses a Retry-After header given as seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if d := when.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// drainAndClose discards the rest of a response body so the connection can be reused
+func drainAndClose(body io.ReadCloser) {
+	if body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, 64*1024))
+	_ = body.Close()
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
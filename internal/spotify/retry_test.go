@@ -0,0 +1,293 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient creates a client pointed at a mock API server with instant retries
+func newTestClient(t *testing.T, serverURL string, cfg RetryConfig) *Client {
+	t.Helper()
+	c := &Client{
+		retry:   newRetryTransport(nil, cfg),
+		baseURL: serverURL + "/",
+	}
+	c.retry.sleep = func(ctx context.Context, d time.Duration) error { return ctx.Err() }
+	c.attach(&http.Client{})
+	return c
+}
+
+func savedTracksPage(n int) string {
+	items := ""
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			items += ","
+		}
+		items += fmt.Sprintf(`{"added_at":"2024-01-01T00:00:00Z","track":{"id":"t%d","name":"Track %d"}}`, i, i)
+	}
+	return fmt.Sprintf(`{"items":[%s],"limit":50,"offset":0,"total":%d}`, items, n)
+}
+
+func TestRetryTransport_RetriesRateLimit(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, savedTracksPage(3))
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, RetryConfig{MaxRetries: 5, BaseDelay: time.Millisecond, MaxDelay: time.Minute})
+
+	tracks, err := client.GetSavedTracks(context.Background())
+	if err != nil {
+		t.Fatalf("GetSavedTracks() error = %v", err)
+	}
+	if len(tracks) != 3 {
+		t.Errorf("expected 3 tracks, got %d", len(tracks))
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 calls, got %d", calls.Load())
+	}
+	if client.RequestCount() != 3 {
+		t.Errorf("expected RequestCount 3, got %d", client.RequestCount())
+	}
+}
+
+func TestRetryTransport_RetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, savedTracksPage(1))
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, RetryConfig{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second})
+
+	if _, err := client.GetSavedTracks(context.Background()); err != nil {
+		t.Fatalf("GetSavedTracks() error = %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 calls, got %d", calls.Load())
+	}
+}
+
+func TestRetryTransport_NonIdempotent(t *testing.T) {
+	var calls atomic.Int32
+	status := http.StatusBadGateway
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	transport := newRetryTransport(nil, RetryConfig{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second})
+	transport.sleep = func(ctx context.Context, d time.Duration) error { return ctx.Err() }
+	post := func() int {
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{"name":"Mix"}`))
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip() error = %v", err)
+		}
+		drainAndClose(resp.Body)
+		return resp.StatusCode
+	}
+
+	// The server may have created the playlist before failing, so a 5xx is not retried
+	if got := post(); got != http.StatusBadGateway || calls.Load() != 1 {
+		t.Errorf("POST after 502 = %d with %d calls, want 502 with 1 call", got, calls.Load())
+	}
+
+	// A rate-limited POST was never applied and is retried
+	calls.Store(0)
+	status = http.StatusTooManyRequests
+	if got := post(); got != http.StatusCreated || calls.Load() != 2 {
+		t.Errorf("POST after 429 = %d with %d calls, want 201 with 2 calls", got, calls.Load())
+	}
+}
+
+func TestRetryTransport_RetriesDialErrors(t *testing.T) {
+	var dials atomic.Int32
+	transport := newRetryTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if dials.Add(1) == 1 {
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+		}
+		if dials.Load() == 2 {
+			return nil, &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset")}
+		}
+		return &http.Response{StatusCode: http.StatusCreated, Body: http.NoBody}, nil
+	}), RetryConfig{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second})
+	transport.sleep = func(ctx context.Context, d time.Duration) error { return ctx.Err() }
+
+	// A POST is retried after a failed dial but not after a reset mid-request
+	req, _ := http.NewRequest(http.MethodPost, "http://spotify.invalid/v1/playlists", nil)
+	if _, err := transport.RoundTrip(req); err == nil || dials.Load() != 2 {
+		t.Errorf("RoundTrip() = %v after %d attempts, want the read error after 2", err, dials.Load())
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestRetryTransport_ConcurrentBudget(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	transport := newRetryTransport(nil, RetryConfig{RequestBudget: 10})
+	var wg sync.WaitGroup
+	var sent atomic.Int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			if resp, err := transport.RoundTrip(req); err == nil {
+				drainAndClose(resp.Body)
+				sent.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if sent.Load() != 10 || transport.Requests() != 10 {
+		t.Errorf("sent %d requests (%d counted), want exactly the budget of 10", sent.Load(), transport.Requests())
+	}
+}
+
+func TestRetryTransport_GivesUpAfterMaxRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, RetryConfig{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Second})
+
+	if _, err := client.GetSavedTracks(context.Background()); err == nil {
+		t.Error("expected error after exhausting retries")
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 calls (1 + 2 retries), got %d", calls.Load())
+	}
+}
+
+func TestRetryTransport_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, RetryConfig{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second})
+
+	if _, err := client.GetSavedTracks(context.Background()); err == nil {
+		t.Error("expected error for 404")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 call, got %d", calls.Load())
+	}
+}
+
+func TestRetryTransport_RetryAfterExceedsMaxDelay(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, RetryConfig{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Minute})
+
+	if _, err := client.GetSavedTracks(context.Background()); err == nil {
+		t.Error("expected error when Retry-After exceeds MaxDelay")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 call, got %d", calls.Load())
+	}
+}
+
+func TestRetryTransport_RequestBudget(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, RetryConfig{MaxRetries: 10, BaseDelay: time.Millisecond, MaxDelay: time.Second, RequestBudget: 4})
+
+	_, err := client.GetSavedTracks(context.Background())
+	if !errors.Is(err, ErrRequestBudgetExceeded) {
+		t.Errorf("expected ErrRequestBudgetExceeded, got %v", err)
+	}
+	if client.RequestCount() != 4 {
+		t.Errorf("expected 4 requests, got %d", client.RequestCount())
+	}
+}
+
+func TestRetryTransport_Backoff(t *testing.T) {
+	rt := newRetryTransport(nil, RetryConfig{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second})
+
+	for attempt := 0; attempt < 8; attempt++ {
+		want := 100 * time.Millisecond << uint(attempt)
+		if want > time.Second {
+			want = time.Second
+		}
+		got := rt.backoff(attempt)
+		if got < want/2 || got > want {
+			t.Errorf("backoff(%d) = %v, want in [%v, %v]", attempt, got, want/2, want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"empty", "", 0, false},
+		{"seconds", "5", 5 * time.Second, true},
+		{"zero", "0", 0, true},
+		{"negative", "-1", 0, false},
+		{"http date", now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second, true},
+		{"past date", now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"garbage", "soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
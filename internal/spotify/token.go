@@ -0,0 +1,113 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// storedToken is the token file format. It adds what Spotify reports about
+// a token to oauth2.Token, which does not keep it when marshaled.
+type storedToken struct {
+	oauth2.Token
+	Scope       string    `json:"scope,omitempty"`
+	RefreshedAt time.Time `json:"refreshed_at,omitempty"`
+}
+
+// TokenInfo describes the current access token
+type TokenInfo struct {
+	// Expiry is when the access token expires; it is refreshed automatically
+	Expiry time.Time
+	// Scopes are the scopes Spotify granted, if it reported them
+	Scopes []string
+	// LastRefresh is when the token was last issued or refreshed
+	LastRefresh time.Time
+	// Refreshable reports whether a refresh token is available
+	Refreshable bool
+}
+
+// TokenInfo returns details of the current token, or false without one
+func (c *Client) TokenInfo() (TokenInfo, bool) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	if c.token == nil {
+		return TokenInfo{}, false
+	}
+	return TokenInfo{
+		Expiry:      c.token.Expiry,
+		Scopes:      strings.Fields(c.tokenScope),
+		LastRefresh: c.tokenRefreshed,
+		Refreshable: c.token.RefreshToken != "",
+	}, true
+}
+
+// setTokenLocked records a newly issued token; the caller holds tokenMu
+func (c *Client) setTokenLocked(token *oauth2.Token, issued time.Time) {
+	// Refresh responses may leave out the scope when it is unchanged
+	if scope, ok := token.Extra("scope").(string); ok && scope != "" {
+		c.tokenScope = scope
+	}
+	c.token = token
+	c.tokenRefreshed = issued
+}
+
+// attachToken builds the API client on a token source that refreshes the
+// token and writes each new token back to the token file
+func (c *Client) attachToken() {
+	c.attach(oauth2.NewClient(context.Background(), &savingTokenSource{client: c, ctx: context.Background()}))
+}
+
+// refreshToken exchanges the refresh token for a new access token. Public
+// clients send only their client ID, as PKCE refreshes need no secret.
+func (c *Client) refreshToken(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+	if c.auth == nil {
+		return nil, fmt.Errorf("not configured for authentication")
+	}
+	return c.auth.TokenSource(ctx, token).Token()
+}
+
+// savingTokenSource refreshes the client's token when it expires and
+// persists the result, so later runs start with a fresh access token
+type savingTokenSource struct {
+	client *Client
+	ctx    context.Context
+}
+
+// Token returns a valid token, refreshing and saving it if needed
+func (s *savingTokenSource) Token() (*oauth2.Token, error) {
+	c := s.client
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.token == nil {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	if c.token.Valid() {
+		return c.token, nil
+	}
+
+	refresh := c.refresh
+	if refresh == nil {
+		refresh = c.refreshToken
+	}
+	token, err := refresh(s.ctx, c.token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh token: %w", err)
+	}
+	if token.AccessToken == c.token.AccessToken {
+		return token, nil
+	}
+
+	c.setTokenLocked(token, time.Now())
+	if c.tokenFile != "" {
+		// The new token is still usable for this run if it cannot be saved
+		if err := c.saveTokenLocked(c.tokenFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save refreshed token: %v\n", err)
+		}
+	}
+	return token, nil
+}
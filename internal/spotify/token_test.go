@@ -0,0 +1,204 @@
+package spotify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestSavingTokenSource_PersistsRefresh(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"user1","display_name":"User One"}`)
+	}))
+	defer server.Close()
+
+	// An expired token from an older version, without scope or refresh time
+	path := filepath.Join(t.TempDir(), "token")
+	expired, _ := json.Marshal(&oauth2.Token{
+		AccessToken:  "old-access",
+		TokenType:    "Bearer",
+		RefreshToken: "refresh",
+		Expiry:       time.Now().Add(-time.Hour),
+	})
+	if err := os.WriteFile(path, expired, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewClient(Config{TokenFile: path, APIBaseURL: server.URL + "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshes := 0
+	client.refresh = func(_ context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+		refreshes++
+		if token.RefreshToken != "refresh" {
+			t.Errorf("refresh called with refresh token %q", token.RefreshToken)
+		}
+		fresh := &oauth2.Token{
+			AccessToken:  "new-access",
+			TokenType:    "Bearer",
+			RefreshToken: "refresh",
+			Expiry:       time.Now().Add(time.Hour),
+		}
+		return fresh.WithExtra(map[string]interface{}{"scope": "user-library-read user-top-read"}), nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetCurrentUser(context.Background()); err != nil {
+			t.Fatalf("GetCurrentUser() error = %v", err)
+		}
+	}
+	if refreshes != 1 {
+		t.Errorf("token refreshed %d times, want 1", refreshes)
+	}
+	if gotAuth != "Bearer new-access" {
+		t.Errorf("Authorization = %q, want the refreshed token", gotAuth)
+	}
+
+	// The refreshed token and its metadata survive a restart
+	restarted, err := NewClient(Config{TokenFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, ok := restarted.TokenInfo()
+	if !ok {
+		t.Fatal("expected the saved token to load")
+	}
+	if restarted.token.AccessToken != "new-access" {
+		t.Errorf("saved access token = %q, want new-access", restarted.token.AccessToken)
+	}
+	if len(info.Scopes) != 2 || info.Scopes[1] != "user-top-read" {
+		t.Errorf("Scopes = %v", info.Scopes)
+	}
+	if time.Since(info.LastRefresh) > time.Minute || !info.Refreshable || info.Expiry.Before(time.Now()) {
+		t.Errorf("unexpected token info %+v", info)
+	}
+}
+
+func TestSavingTokenSource_RefreshError(t *testing.T) {
+	client := &Client{
+		token: &oauth2.Token{AccessToken: "old", Expiry: time.Now().Add(-time.Hour)},
+		refresh: func(context.Context, *oauth2.Token) (*oauth2.Token, error) {
+			return nil, fmt.Errorf("invalid_grant")
+		},
+	}
+	src := &savingTokenSource{client: client, ctx: context.Background()}
+	if _, err := src.Token(); err == nil {
+		t.Error("expected refresh error")
+	}
+	if client.token.AccessToken != "old" {
+		t.Error("failed refresh should keep the old token")
+	}
+
+	if _, ok := (&Client{}).TokenInfo(); ok {
+		t.Error("TokenInfo() without a token should report false")
+	}
+}
+
+func TestClient_PKCEFlow(t *testing.T) {
+	var challenge string
+	grants := map[string]int{}
+	accounts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/token" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, ok := r.BasicAuth(); ok || r.PostForm.Has("client_secret") {
+			t.Error("public client sent a client secret")
+		}
+		if r.PostForm.Get("client_id") != "public-id" {
+			t.Errorf("client_id = %q", r.PostForm.Get("client_id"))
+		}
+
+		grant := r.PostForm.Get("grant_type")
+		grants[grant]++
+		switch grant {
+		case "authorization_code":
+			sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+			if base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+				t.Error("code_verifier does not match the challenge")
+			}
+		case "refresh_token":
+			if r.PostForm.Get("refresh_token") != "refresh" {
+				t.Errorf("refresh_token = %q", r.PostForm.Get("refresh_token"))
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"access-%d","token_type":"Bearer","refresh_token":"refresh","expires_in":3600,"scope":"user-library-read"}`, len(grants)+grants[grant])
+	}))
+	defer accounts.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"user1"}`)
+	}))
+	defer api.Close()
+
+	client, err := NewClient(Config{
+		ClientID:    "public-id",
+		RedirectURI: "http://127.0.0.1:8888/callback",
+		AccountsURL: accounts.URL,
+		APIBaseURL:  api.URL + "/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !client.UsesPKCE() {
+		t.Fatal("a client without a secret should use PKCE")
+	}
+
+	authURL, err := url.Parse(client.GetAuthURL("state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge = authURL.Query().Get("code_challenge")
+	if challenge == "" || authURL.Query().Get("code_challenge_method") != "S256" {
+		t.Fatalf("auth URL lacks a PKCE challenge: %s", authURL)
+	}
+
+	req := httptest.NewRequest("GET", "/callback?code=abc&state=state", nil)
+	if err := client.HandleCallback(context.Background(), "state", req, "http://127.0.0.1:8888/callback"); err != nil {
+		t.Fatalf("HandleCallback() error = %v", err)
+	}
+
+	// An expired access token is refreshed with the client ID alone
+	client.tokenMu.Lock()
+	client.token.Expiry = time.Now().Add(-time.Minute)
+	client.tokenMu.Unlock()
+	if _, err := client.GetCurrentUser(context.Background()); err != nil {
+		t.Fatalf("GetCurrentUser() error = %v", err)
+	}
+	if grants["authorization_code"] != 1 || grants["refresh_token"] != 1 {
+		t.Errorf("token requests = %v", grants)
+	}
+}
+
+func TestClient_ConfidentialClientSkipsPKCE(t *testing.T) {
+	client, err := NewClient(Config{ClientID: "id", ClientSecret: "secret", RedirectURI: "http://127.0.0.1:8888/callback"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.UsesPKCE() {
+		t.Error("a client with a secret should not use PKCE")
+	}
+	authURL, _ := url.Parse(client.GetAuthURL("state"))
+	if authURL.Query().Has("code_challenge") {
+		t.Error("auth URL should not carry a PKCE challenge")
+	}
+}
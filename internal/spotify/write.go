@@ -0,0 +1,165 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// Spotify API batch limits for write endpoints
+const (
+	libraryBatchSize  = 50
+	playlistBatchSize = 100
+)
+
+// batches splits items into consecutive chunks of at most size elements
+func batches[T any](items []T, size int) [][]T {
+	var out [][]T
+	for len(items) > size {
+		out = append(out, items[:size])
+		items = items[size:]
+	}
+	if len(items) > 0 {
+		out = append(out, items)
+	}
+	return out
+}
+
+// IsForbidden reports whether err is a 403 from the Spotify API, which usually
+// means the token lacks the write scopes
+func IsForbidden(err error) bool {
+	var apiErr spotify.Error
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusForbidden
+}
+
+// CheckSavedTracks reports, for each ID, whether the track is in the user's library
+func (c *Client) CheckSavedTracks(ctx context.Context, ids []spotify.ID) ([]bool, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("client not authenticated")
+	}
+
+	saved := make([]bool, 0, len(ids))
+	for _, batch := range batches(ids, libraryBatchSize) {
+		result, err := c.client.UserHasTracks(ctx, batch...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check saved tracks: %w", err)
+		}
+		saved = append(saved, result...)
+	}
+	return saved, nil
+}
+
+// SaveTracks adds tracks to the user's library. Tracks are saved in batches in
+// the given order, so later IDs end up newest.
+func (c *Client) SaveTracks(ctx context.Context, ids []spotify.ID) error {
+	if c.client == nil {
+		return fmt.Errorf("client not authenticated")
+	}
+
+	for _, batch := range batches(ids, libraryBatchSize) {
+		if err := c.client.AddTracksToLibrary(ctx, batch...); err != nil {
+			return fmt.Errorf("failed to save tracks: %w", err)
+		}
+	}
+	return nil
+}
+
+// CheckFollowedArtists reports, for each ID, whether the user follows the artist
+func (c *Client) CheckFollowedArtists(ctx context.Context, ids []spotify.ID) ([]bool, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("client not authenticated")
+	}
+
+	following := make([]bool, 0, len(ids))
+	for _, batch := range batches(ids, libraryBatchSize) {
+		result, err := c.client.CurrentUserFollows(ctx, "artist", batch...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check followed artists: %w", err)
+		}
+		following = append(following, result...)
+	}
+	return following, nil
+}
+
+// FollowArtists follows the given artists
+func (c *Client) FollowArtists(ctx context.Context, ids []spotify.ID) error {
+	if c.client == nil {
+		return fmt.Errorf("client not authenticated")
+	}
+
+	for _, batch := range batches(ids, libraryBatchSize) {
+		if err := c.client.FollowArtist(ctx, batch...); err != nil {
+			return fmt.Errorf("failed to follow artists: %w", err)
+		}
+	}
+	return nil
+}
+
+// FollowPlaylist follows a playlist owned by another user
+func (c *Client) FollowPlaylist(ctx context.Context, playlistID spotify.ID) error {
+	if c.client == nil {
+		return fmt.Errorf("client not authenticated")
+	}
+
+	if err := c.client.FollowPlaylist(ctx, playlistID, true); err != nil {
+		return fmt.Errorf("failed to follow playlist: %w", err)
+	}
+	return nil
+}
+
+// CreatePlaylist creates an empty playlist owned by the current user
+func (c *Client) CreatePlaylist(ctx context.Context, name, description string, public bool) (*spotify.FullPlaylist, error) {
+	user, err := c.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	playlist, err := c.client.CreatePlaylistForUser(ctx, user.ID, name, description, public, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create playlist: %w", err)
+	}
+	return playlist, nil
+}
+
+// AddPlaylistItems appends tracks or episodes to a playlist, preserving order
+func (c *Client) AddPlaylistItems(ctx context.Context, playlistID spotify.ID, uris []spotify.URI) error {
+	if c.client == nil {
+		return fmt.Errorf("client not authenticated")
+	}
+	for _, batch := range batches(uris, playlistBatchSize) {
+		body := map[string][]spotify.URI{"uris": batch}
+		path := "playlists/" + url.PathEscape(string(playlistID)) + "/tracks"
+		if err := c.doJSON(ctx, http.MethodPost, path, nil, body, nil, http.StatusOK, http.StatusCreated); err != nil {
+			return fmt.Errorf("failed to add playlist items: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReplacePlaylistItems replaces the contents of a playlist with the given
+// tracks or episodes, preserving order
+func (c *Client) ReplacePlaylistItems(ctx context.Context, playlistID spotify.ID, uris []spotify.URI) error {
+	if c.client == nil {
+		return fmt.Errorf("client not authenticated")
+	}
+	first := uris
+	if len(first) > playlistBatchSize {
+		first = first[:playlistBatchSize]
+	}
+
+	// Spotify replaces with the first batch and appends the rest
+	body := map[string][]spotify.URI{"uris": first}
+	if body["uris"] == nil {
+		body["uris"] = []spotify.URI{}
+	}
+	path := "playlists/" + url.PathEscape(string(playlistID)) + "/tracks"
+	if err := c.doJSON(ctx, http.MethodPut, path, nil, body, nil, http.StatusOK, http.StatusCreated); err != nil {
+		return fmt.Errorf("failed to replace playlist items: %w", err)
+	}
+
+	return c.AddPlaylistItems(ctx, playlistID, uris[len(first):])
+}
@@ -0,0 +1,270 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+func TestBatches(t *testing.T) {
+	tests := []struct {
+		n, size int
+		want    []int
+	}{
+		{0, 50, nil},
+		{1, 50, []int{1}},
+		{50, 50, []int{50}},
+		{120, 50, []int{50, 50, 20}},
+	}
+
+	for _, tt := range tests {
+		items := make([]int, tt.n)
+		got := batches(items, tt.size)
+		if len(got) != len(tt.want) {
+			t.Errorf("batches(%d, %d) returned %d batches, want %d", tt.n, tt.size, len(got), len(tt.want))
+			continue
+		}
+		for i, b := range got {
+			if len(b) != tt.want[i] {
+				t.Errorf("batches(%d, %d)[%d] has %d items, want %d", tt.n, tt.size, i, len(b), tt.want[i])
+			}
+		}
+	}
+}
+
+func testIDs(prefix string, n int) []spotify.ID {
+	ids := make([]spotify.ID, n)
+	for i := range ids {
+		ids[i] = spotify.ID(fmt.Sprintf("%s%d", prefix, i))
+	}
+	return ids
+}
+
+func TestSaveAndCheckTracks(t *testing.T) {
+	var mu sync.Mutex
+	saved := make(map[string]bool)
+	var putBatches []int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		ids := strings.Split(r.URL.Query().Get("ids"), ",")
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/me/tracks":
+			putBatches = append(putBatches, len(ids))
+			for _, id := range ids {
+				saved[id] = true
+			}
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodGet && r.URL.Path == "/me/tracks/contains":
+			result := make([]bool, len(ids))
+			for i, id := range ids {
+				result[i] = saved[id]
+			}
+			_ = json.NewEncoder(w).Encode(result)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, RetryConfig{})
+	ctx := context.Background()
+	ids := testIDs("t", 120)
+
+	if err := client.SaveTracks(ctx, ids[:70]); err != nil {
+		t.Fatalf("SaveTracks() error = %v", err)
+	}
+	if len(putBatches) != 2 || putBatches[0] != 50 || putBatches[1] != 20 {
+		t.Errorf("unexpected batches %v", putBatches)
+	}
+
+	result, err := client.CheckSavedTracks(ctx, ids)
+	if err != nil {
+		t.Fatalf("CheckSavedTracks() error = %v", err)
+	}
+	if len(result) != len(ids) {
+		t.Fatalf("got %d results, want %d", len(result), len(ids))
+	}
+	for i, ok := range result {
+		if ok != (i < 70) {
+			t.Errorf("track %d saved = %v", i, ok)
+		}
+	}
+}
+
+func TestFollowAndCheckArtists(t *testing.T) {
+	following := make(map[string]bool)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids := strings.Split(r.URL.Query().Get("ids"), ",")
+		if r.URL.Query().Get("type") != "artist" {
+			http.Error(w, "bad type", http.StatusBadRequest)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/me/following":
+			for _, id := range ids {
+				following[id] = true
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && r.URL.Path == "/me/following/contains":
+			result := make([]bool, len(ids))
+			for i, id := range ids {
+				result[i] = following[id]
+			}
+			_ = json.NewEncoder(w).Encode(result)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, RetryConfig{})
+	ctx := context.Background()
+
+	if err := client.FollowArtists(ctx, []spotify.ID{"a1", "a2"}); err != nil {
+		t.Fatalf("FollowArtists() error = %v", err)
+	}
+
+	result, err := client.CheckFollowedArtists(ctx, []spotify.ID{"a1", "a3", "a2"})
+	if err != nil {
+		t.Fatalf("CheckFollowedArtists() error = %v", err)
+	}
+	if len(result) != 3 || !result[0] || result[1] || !result[2] {
+		t.Errorf("unexpected result %v", result)
+	}
+}
+
+func TestPlaylistWrites(t *testing.T) {
+	var items []spotify.URI
+	var created struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Public      bool   `json:"public"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			URIs []spotify.URI `json:"uris"`
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/me":
+			_, _ = w.Write([]byte(`{"id":"me","display_name":"Me"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/users/me/playlists":
+			_ = json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"p1","name":"Restored"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/playlists/p1/tracks":
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if len(body.URIs) > 100 {
+				http.Error(w, "too many", http.StatusBadRequest)
+				return
+			}
+			items = append(items, body.URIs...)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"snapshot_id":"s"}`))
+		case r.Method == http.MethodPut && r.URL.Path == "/playlists/p1/tracks":
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			items = append([]spotify.URI{}, body.URIs...)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"snapshot_id":"s"}`))
+		case r.Method == http.MethodPut && r.URL.Path == "/playlists/p2/followers":
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, RetryConfig{})
+	ctx := context.Background()
+
+	playlist, err := client.CreatePlaylist(ctx, "Restored", "from backup", false)
+	if err != nil {
+		t.Fatalf("CreatePlaylist() error = %v", err)
+	}
+	if playlist.ID != "p1" || created.Name != "Restored" || created.Description != "from backup" || created.Public {
+		t.Errorf("unexpected playlist %+v, request %+v", playlist, created)
+	}
+
+	uris := make([]spotify.URI, 150)
+	for i := range uris {
+		uris[i] = spotify.URI(fmt.Sprintf("spotify:track:t%d", i))
+	}
+	uris[1] = "spotify:episode:e1"
+
+	if err := client.AddPlaylistItems(ctx, "p1", uris); err != nil {
+		t.Fatalf("AddPlaylistItems() error = %v", err)
+	}
+	if len(items) != 150 || items[1] != "spotify:episode:e1" || items[149] != uris[149] {
+		t.Errorf("items not added in order: %d items", len(items))
+	}
+
+	if err := client.ReplacePlaylistItems(ctx, "p1", uris[100:]); err != nil {
+		t.Fatalf("ReplacePlaylistItems() error = %v", err)
+	}
+	if len(items) != 50 || items[0] != uris[100] {
+		t.Errorf("unexpected items after replace: %d", len(items))
+	}
+
+	if err := client.ReplacePlaylistItems(ctx, "p1", nil); err != nil {
+		t.Fatalf("ReplacePlaylistItems(nil) error = %v", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("expected empty playlist, got %d items", len(items))
+	}
+
+	if err := client.FollowPlaylist(ctx, "p2"); err != nil {
+		t.Errorf("FollowPlaylist() error = %v", err)
+	}
+}
+
+func TestIsForbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"error":{"status":403,"message":"Insufficient client scope"}}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, RetryConfig{})
+	ctx := context.Background()
+
+	if err := client.SaveTracks(ctx, []spotify.ID{"t1"}); !IsForbidden(err) {
+		t.Errorf("SaveTracks() error = %v, want forbidden", err)
+	}
+	if err := client.AddPlaylistItems(ctx, "p1", []spotify.URI{"spotify:track:t1"}); !IsForbidden(err) {
+		t.Errorf("AddPlaylistItems() error = %v, want forbidden", err)
+	}
+	if IsForbidden(fmt.Errorf("other")) {
+		t.Error("plain error reported as forbidden")
+	}
+}
+
+func TestNewClient_WriteScopes(t *testing.T) {
+	client, err := NewClient(Config{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		RedirectURI:  "http://localhost:8888/callback",
+		TokenFile:    t.TempDir() + "/token.json",
+		WriteAccess:  true,
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	authURL := client.GetAuthURL("state")
+	for _, scope := range WriteScopes {
+		if !strings.Contains(authURL, scope) {
+			t.Errorf("auth URL missing write scope %s", scope)
+		}
+	}
+}
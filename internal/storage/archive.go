@@ -0,0 +1,713 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Backups are stored as a content-addressed archive under the backup directory:
+//
+//	objects/<aa>/<sha256>.json  one file per library item, stored once
+//	manifests/<type>-<ts>.json  one file per backup, listing object hashes by section
+//
+// Each element of a top-level section array (a saved track, a playlist, ...) is
+// stored as an object. Fields of an element that hold arrays of objects, such as
+// a playlist's tracks, are split out as well so unchanged tracks are shared
+// between playlists and between backups.
+const (
+	archiveObjectsDir   = "objects"
+	archiveManifestsDir = "manifests"
+
+	// objectRefsKey marks a field whose array elements were split out as objects
+	objectRefsKey = "$objects"
+
+	// manifestTimeFormat is the timestamp layout in manifest IDs
+	manifestTimeFormat = "20060102-150405.000"
+
+	// ArchiveSchemaVersion is the manifest format written by this version.
+	// Version 2 added section checksums, item counts and the manifest checksum;
+	// manifests without a version predate it.
+	ArchiveSchemaVersion = 2
+)
+
+// ArchiveManifest describes one backup in the archive
+type ArchiveManifest struct {
+	SchemaVersion int                       `json:"schema_version,omitempty"`
+	ID            string                    `json:"id"`
+	Timestamp     time.Time                 `json:"timestamp"`
+	Type          string                    `json:"type"`
+	Items         int                       `json:"items"`
+	Size          int64                     `json:"size"`
+	Sections      map[string]ArchiveSection `json:"sections"`
+	// Checksum is the SHA-256 of the manifest encoded without its checksum
+	// and signature. It catches corruption, but anyone can recompute it.
+	Checksum string `json:"checksum,omitempty"`
+	// Signature is an HMAC-SHA256 of the same encoding under the storage key,
+	// set when a key is set up, so edits without the key are detected
+	Signature string `json:"signature,omitempty"`
+}
+
+// ArchiveSection references the objects making up one backup section.
+// Array sections list one object per element; other values are a single object.
+type ArchiveSection struct {
+	Items  []string `json:"items,omitempty"`
+	Object string   `json:"object,omitempty"`
+	// Count is the number of elements in an array section, or 1 for other values
+	Count int `json:"count,omitempty"`
+	// SHA256 is the checksum of the section's JSON as reassembled from the archive
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// manifestSignatureContext separates manifest signatures from the keyed
+// hashes naming objects
+var manifestSignatureContext = []byte("spotigo-manifest-v1\n")
+
+// unsignedJSON returns the manifest encoded without its checksum and signature
+func (m *ArchiveManifest) unsignedJSON() ([]byte, error) {
+	unsigned := *m
+	unsigned.Checksum, unsigned.Signature = "", ""
+	return json.Marshal(&unsigned)
+}
+
+// computeChecksum returns the manifest checksum
+func (m *ArchiveManifest) computeChecksum() (string, error) {
+	raw, err := m.unsignedJSON()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// computeSignature returns the manifest signature under the storage key
+func (m *ArchiveManifest) computeSignature(e *Encryption) (string, error) {
+	raw, err := m.unsignedJSON()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(e.enc.KeyedHash(append(append([]byte{}, manifestSignatureContext...), raw...))), nil
+}
+
+// seal sets the manifest checksum, and its signature if a storage key is set up
+func (m *ArchiveManifest) seal(e *Encryption) error {
+	var err error
+	if m.Checksum, err = m.computeChecksum(); err != nil {
+		return fmt.Errorf("failed to checksum manifest: %w", err)
+	}
+	m.Signature = ""
+	if e != nil {
+		if m.Signature, err = m.computeSignature(e); err != nil {
+			return fmt.Errorf("failed to sign manifest: %w", err)
+		}
+	}
+	return nil
+}
+
+// verifySignature checks the manifest signature under the storage key.
+// Without a key, or for manifests without a signature, there is nothing to check.
+func (m *ArchiveManifest) verifySignature(e *Encryption) error {
+	if e == nil || m.Signature == "" {
+		return nil
+	}
+	sig, err := m.computeSignature(e)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(sig), []byte(m.Signature)) {
+		return fmt.Errorf("manifest %s signature mismatch: it was changed without the storage key", m.ID)
+	}
+	return nil
+}
+
+// verifyChecksum checks the manifest checksum. Manifests written before
+// checksums were introduced have none and pass.
+func (m *ArchiveManifest) verifyChecksum() error {
+	if m.Checksum == "" {
+		return nil
+	}
+	sum, err := m.computeChecksum()
+	if err != nil {
+		return err
+	}
+	if sum != m.Checksum {
+		return fmt.Errorf("manifest %s checksum mismatch", m.ID)
+	}
+	return nil
+}
+
+// gcGracePeriod protects recently written files from garbage collection. A
+// backup written concurrently, e.g. by the daemon, stores its objects before
+// the manifest referencing them, so young unreferenced objects and temp files
+// may still be in use.
+var gcGracePeriod = time.Hour
+
+// GCResult summarizes an archive garbage collection run
+type GCResult struct {
+	Objects    int   `json:"objects"`
+	Removed    int   `json:"removed"`
+	FreedBytes int64 `json:"freed_bytes"`
+	// Recent counts unreferenced files kept because they are younger than the grace period
+	Recent int `json:"recent,omitempty"`
+}
+
+// objectWriter stores objects for a single backup, tracking how much was newly written
+type objectWriter struct {
+	store   *Store
+	written int64
+	seen    map[string]bool
+}
+
+// put stores raw JSON as an object and returns its hash
+func (w *objectWriter) put(raw []byte) (string, error) {
+	hash := w.store.objectHash(raw, w.store.Encrypted())
+	if w.seen[hash] {
+		return hash, nil
+	}
+	w.seen[hash] = true
+
+	// A reused object is touched so a concurrent GarbageCollect sees it as
+	// recently written until the manifest referencing it is in place
+	path := w.store.objectPath(hash)
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err == nil {
+		return hash, nil
+	}
+
+	written, err := w.store.writeFile(path, raw)
+	if err != nil {
+		return "", fmt.Errorf("failed to write object %s: %w", hash, err)
+	}
+	w.written += written
+	return hash, nil
+}
+
+// putElement stores an element, splitting out fields that hold arrays of objects
+func (w *objectWriter) putElement(raw json.RawMessage) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		// Not an object: store the value as is
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, raw); err != nil {
+			return "", fmt.Errorf("invalid JSON element: %w", err)
+		}
+		return w.put(buf.Bytes())
+	}
+
+	for name, value := range fields {
+		elems, ok := objectArray(value)
+		if !ok {
+			continue
+		}
+		refs := make([]string, 0, len(elems))
+		for _, elem := range elems {
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, elem); err != nil {
+				return "", fmt.Errorf("invalid JSON in field %s: %w", name, err)
+			}
+			hash, err := w.put(buf.Bytes())
+			if err != nil {
+				return "", err
+			}
+			refs = append(refs, hash)
+		}
+		ref, err := json.Marshal(map[string][]string{objectRefsKey: refs})
+		if err != nil {
+			return "", err
+		}
+		fields[name] = ref
+	}
+
+	// Marshaling the map sorts keys, so identical items always hash the same
+	canonical, err := json.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode element: %w", err)
+	}
+	return w.put(canonical)
+}
+
+// objectArray returns the elements of value if it is a non-empty array of JSON objects
+func objectArray(value json.RawMessage) ([]json.RawMessage, bool) {
+	trimmed := bytes.TrimSpace(value)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return nil, false
+	}
+	var elems []json.RawMessage
+	if err := json.Unmarshal(trimmed, &elems); err != nil || len(elems) == 0 {
+		return nil, false
+	}
+	for _, elem := range elems {
+		if t := bytes.TrimSpace(elem); len(t) == 0 || t[0] != '{' {
+			return nil, false
+		}
+	}
+	return elems, true
+}
+
+// writeArchive stores backup data as objects and writes its manifest
+func (s *Store) writeArchive(backupType string, timestamp time.Time, data interface{}) (*ArchiveManifest, int64, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to encode backup: %w", err)
+	}
+
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &sections); err != nil {
+		return nil, 0, fmt.Errorf("backup data must be a JSON object: %w", err)
+	}
+
+	manifest := &ArchiveManifest{
+		SchemaVersion: ArchiveSchemaVersion,
+		Timestamp:     timestamp,
+		Type:          backupType,
+		Size:          int64(len(encoded)),
+		Sections:      make(map[string]ArchiveSection, len(sections)),
+	}
+
+	w := &objectWriter{store: s, seen: make(map[string]bool)}
+	for name, value := range sections {
+		var elems []json.RawMessage
+		if t := bytes.TrimSpace(value); len(t) > 0 && t[0] == '[' && json.Unmarshal(t, &elems) == nil {
+			section := ArchiveSection{Items: make([]string, 0, len(elems)), Count: len(elems)}
+			for _, elem := range elems {
+				hash, err := w.putElement(elem)
+				if err != nil {
+					return nil, 0, fmt.Errorf("section %s: %w", name, err)
+				}
+				section.Items = append(section.Items, hash)
+			}
+			manifest.Sections[name] = section
+			manifest.Items += len(elems)
+			continue
+		}
+
+		hash, err := w.putElement(value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("section %s: %w", name, err)
+		}
+		manifest.Sections[name] = ArchiveSection{Object: hash, Count: 1}
+	}
+
+	// Checksum each section as it reads back from disk, so a bad write is caught now
+	for name, section := range manifest.Sections {
+		value, err := s.readSection(section)
+		if err != nil {
+			return nil, 0, fmt.Errorf("section %s: %w", name, err)
+		}
+		section.SHA256 = sectionChecksum(value)
+		manifest.Sections[name] = section
+	}
+
+	// The manifest is created exclusively, so backups started within the same
+	// millisecond get numbered IDs instead of overwriting each other
+	stamp := timestamp.Format(manifestTimeFormat)
+	for n := 1; ; n++ {
+		manifest.ID = fmt.Sprintf("%s-%s.json", backupType, stamp)
+		if n > 1 {
+			manifest.ID = fmt.Sprintf("%s-%s-%d.json", backupType, stamp, n)
+		}
+		if err := manifest.seal(s.encryption); err != nil {
+			return nil, 0, err
+		}
+
+		raw, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to encode manifest: %w", err)
+		}
+		written, err := s.writeFileExclusive(s.manifestPath(manifest.ID), raw)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to write manifest: %w", err)
+		}
+
+		return manifest, w.written + written, nil
+	}
+}
+
+// LoadManifest reads the manifest for an archived backup
+func (s *Store) LoadManifest(backupID string) (*ArchiveManifest, error) {
+	path := s.manifestPath(backupID)
+	raw, err := s.readFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var manifest ArchiveManifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest %s: %w", backupID, err)
+	}
+	return &manifest, nil
+}
+
+// listManifests returns metadata for every backup in the archive
+func (s *Store) listManifests() ([]BackupMetadata, error) {
+	entries, err := os.ReadDir(filepath.Join(s.backupDir, archiveManifestsDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read manifest directory: %w", err)
+	}
+
+	backups := make([]BackupMetadata, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		manifest, err := s.LoadManifest(entry.Name())
+		if errors.Is(err, ErrEncrypted) {
+			return nil, err
+		}
+		if err != nil {
+			continue
+		}
+		backups = append(backups, BackupMetadata{
+			ID:        entry.Name(),
+			Timestamp: manifest.Timestamp,
+			Type:      manifest.Type,
+			Items:     manifest.Items,
+			Size:      manifest.Size,
+		})
+	}
+	return backups, nil
+}
+
+// readArchive reassembles an archived backup into its original sections,
+// checking the manifest and section checksums
+func (s *Store) readArchive(manifest *ArchiveManifest) (map[string]json.RawMessage, error) {
+	if err := manifest.verifyChecksum(); err != nil {
+		return nil, err
+	}
+	if err := manifest.verifySignature(s.encryption); err != nil {
+		return nil, err
+	}
+
+	sections := make(map[string]json.RawMessage, len(manifest.Sections))
+	for name, section := range manifest.Sections {
+		value, err := s.readSection(section)
+		if err != nil {
+			return nil, fmt.Errorf("section %s: %w", name, err)
+		}
+		if section.SHA256 != "" && sectionChecksum(value) != section.SHA256 {
+			return nil, fmt.Errorf("section %s checksum mismatch", name)
+		}
+		sections[name] = value
+	}
+	return sections, nil
+}
+
+// readSection reassembles one section
+func (s *Store) readSection(section ArchiveSection) (json.RawMessage, error) {
+	if section.Object != "" {
+		return s.readElement(section.Object)
+	}
+
+	elems := make([]json.RawMessage, 0, len(section.Items))
+	for _, hash := range section.Items {
+		elem, err := s.readElement(hash)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+	}
+	return json.Marshal(elems)
+}
+
+// sectionChecksum returns the SHA-256 of a reassembled section
+func sectionChecksum(value json.RawMessage) string {
+	sum := sha256.Sum256(value)
+	return hex.EncodeToString(sum[:])
+}
+
+// readElement reads an object, expanding any split-out fields
+func (s *Store) readElement(hash string) (json.RawMessage, error) {
+	raw, err := s.readObject(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		return raw, nil
+	}
+
+	for name, value := range fields {
+		refs, ok := objectRefs(value)
+		if !ok {
+			continue
+		}
+		elems := make([]json.RawMessage, 0, len(refs))
+		for _, ref := range refs {
+			elem, err := s.readObject(ref)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, elem)
+		}
+		expanded, err := json.Marshal(elems)
+		if err != nil {
+			return nil, err
+		}
+		fields[name] = expanded
+	}
+
+	return json.Marshal(fields)
+}
+
+// objectRefs returns the hashes of a split-out field
+func objectRefs(value json.RawMessage) ([]string, bool) {
+	trimmed := bytes.TrimSpace(value)
+	if len(trimmed) == 0 || trimmed[0] != '{' || !bytes.Contains(trimmed, []byte(objectRefsKey)) {
+		return nil, false
+	}
+	var ref map[string][]string
+	if err := json.Unmarshal(trimmed, &ref); err != nil || len(ref) != 1 {
+		return nil, false
+	}
+	refs, ok := ref[objectRefsKey]
+	return refs, ok
+}
+
+// readObject reads a stored object and checks it against its hash
+func (s *Store) readObject(hash string) ([]byte, error) {
+	raw, err := os.ReadFile(s.objectPath(hash)) // #nosec G304 - path is constructed from controlled backupDir
+	if err != nil {
+		return nil, fmt.Errorf("missing object %s: %w", hash, err)
+	}
+	encrypted := IsEncrypted(raw)
+	if raw, err = s.encryption.Decode(raw); err != nil {
+		return nil, fmt.Errorf("object %s: %w", hash, err)
+	}
+	if s.objectHash(raw, encrypted) != hash {
+		return nil, fmt.Errorf("object %s is corrupted", hash)
+	}
+	return raw, nil
+}
+
+// objectHash returns the name of an object. Encrypted objects are named by a
+// keyed hash, so their names reveal nothing about the plaintext.
+func (s *Store) objectHash(raw []byte, encrypted bool) string {
+	if encrypted && s.encryption != nil {
+		return hex.EncodeToString(s.encryption.enc.KeyedHash(raw))
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// GarbageCollect removes objects no longer referenced by any backup manifest.
+// Objects and temp files written within the grace period are kept, so it is
+// safe to run while another process is writing a backup.
+func (s *Store) GarbageCollect() (*GCResult, error) {
+	backups, err := s.listManifests()
+	if err != nil {
+		return nil, err
+	}
+
+	// Mark every object reachable from a manifest
+	live := make(map[string]bool)
+	for _, backup := range backups {
+		manifest, err := s.LoadManifest(backup.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, section := range manifest.Sections {
+			hashes := section.Items
+			if section.Object != "" {
+				hashes = []string{section.Object}
+			}
+			for _, hash := range hashes {
+				if err := s.markObject(hash, live); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// Sweep everything else, including leftovers from interrupted writes,
+	// unless it was written too recently to tell it from a backup in progress
+	result := &GCResult{}
+	objectsDir := filepath.Join(s.backupDir, archiveObjectsDir)
+	err = filepath.WalkDir(objectsDir, func(path string, d os.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if os.IsNotExist(walkErr) {
+				return filepath.SkipDir
+			}
+			return walkErr
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		hash := strings.TrimSuffix(d.Name(), ".json")
+		if live[hash] && filepath.Ext(d.Name()) == ".json" {
+			result.Objects++
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if time.Since(info.ModTime()) < gcGracePeriod {
+			result.Recent++
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove object: %w", err)
+		}
+		result.Removed++
+		result.FreedBytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sweep objects: %w", err)
+	}
+
+	return result, nil
+}
+
+// markObject marks an object and any split-out objects it references as live
+func (s *Store) markObject(hash string, live map[string]bool) error {
+	if live[hash] {
+		return nil
+	}
+	live[hash] = true
+
+	raw, err := s.readFile(s.objectPath(hash))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read object %s: %w", hash, err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil
+	}
+	for _, value := range fields {
+		refs, ok := objectRefs(value)
+		if !ok {
+			continue
+		}
+		for _, ref := range refs {
+			live[ref] = true
+		}
+	}
+	return nil
+}
+
+// ArchiveUsage returns the number of stored objects and their total size in bytes
+func (s *Store) ArchiveUsage() (int, int64, error) {
+	var count int
+	var size int64
+	err := filepath.WalkDir(filepath.Join(s.backupDir, archiveObjectsDir), func(path string, d os.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if os.IsNotExist(walkErr) {
+				return filepath.SkipDir
+			}
+			return walkErr
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		count++
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to scan objects: %w", err)
+	}
+	return count, size, nil
+}
+
+// objectPath returns the path of an object, fanned out by the first two hash characters
+func (s *Store) objectPath(hash string) string {
+	hash = filepath.Base(hash)
+	prefix := hash
+	if len(prefix) > 2 {
+		prefix = prefix[:2]
+	}
+	return filepath.Join(s.backupDir, archiveObjectsDir, prefix, hash+".json")
+}
+
+// manifestPath returns the path of a backup manifest
+func (s *Store) manifestPath(backupID string) string {
+	return filepath.Join(s.backupDir, archiveManifestsDir, filepath.Base(filepath.Clean(backupID)))
+}
+
+// writeFileAtomic writes data to a temporary file and renames it into place
+func writeFileAtomic(path string, data []byte) error {
+	return replaceFile(path, data, false)
+}
+
+// writeFileDurable writes data to path atomically and syncs it to disk before
+// it replaces the old content, for files that must survive a crash
+func writeFileDurable(path string, data []byte) error {
+	return replaceFile(path, data, true)
+}
+
+// replaceFile writes data to a temp file and renames it over path
+func replaceFile(path string, data []byte, sync bool) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if sync {
+		if err := tmp.Sync(); err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmpName)
+			return fmt.Errorf("failed to sync temp file: %w", err)
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+	if sync {
+		return syncDir(dir)
+	}
+	return nil
+}
+
+// syncDir flushes a directory's entries, so renames in it survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(filepath.Clean(dir))
+	if err != nil {
+		return fmt.Errorf("failed to open directory: %w", err)
+	}
+	defer func() { _ = d.Close() }()
+	// Some platforms cannot sync directories; the rename itself still happened
+	_ = d.Sync()
+	return nil
+}
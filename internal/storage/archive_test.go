@@ -0,0 +1,314 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func archiveTestData(playlistTracks ...string) map[string]interface{} {
+	tracks := make([]interface{}, 0, len(playlistTracks))
+	for _, id := range playlistTracks {
+		tracks = append(tracks, map[string]interface{}{"track": map[string]interface{}{"id": id}})
+	}
+	return map[string]interface{}{
+		"saved_tracks": []interface{}{
+			map[string]interface{}{"added_at": "2024-01-01T00:00:00Z", "track": map[string]interface{}{"id": "t1", "name": "One"}},
+			map[string]interface{}{"added_at": "2024-01-02T00:00:00Z", "track": map[string]interface{}{"id": "t2", "name": "Two"}},
+		},
+		"playlists": []interface{}{
+			map[string]interface{}{"id": "p1", "name": "Mix", "tracks": tracks},
+		},
+		"top_items": map[string]interface{}{"tracks": map[string]interface{}{"short": []interface{}{}}},
+		"empty":     []interface{}{},
+	}
+}
+
+func countObjects(t *testing.T, store *Store) int {
+	t.Helper()
+	count, _, err := store.ArchiveUsage()
+	if err != nil {
+		t.Fatalf("ArchiveUsage() error = %v", err)
+	}
+	return count
+}
+
+func TestArchive_RoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewStore(tmpDir, filepath.Join(tmpDir, "backups"))
+
+	data := archiveTestData("t1", "t3")
+	metadata, err := store.CreateBackup("all", data)
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	if metadata.Items != 3 {
+		t.Errorf("expected 3 items, got %d", metadata.Items)
+	}
+
+	var restored map[string]interface{}
+	if err := store.LoadBackupJSON(metadata.ID, &restored); err != nil {
+		t.Fatalf("LoadBackupJSON() error = %v", err)
+	}
+
+	var want map[string]interface{}
+	if err := roundTripJSON(data, &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored, want) {
+		t.Errorf("restored backup differs:\n got %v\nwant %v", restored, want)
+	}
+}
+
+func TestArchive_Deduplicates(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewStore(tmpDir, filepath.Join(tmpDir, "backups"))
+
+	first, err := store.CreateBackup("all", archiveTestData("t1", "t3"))
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	objectsAfterFirst := countObjects(t, store)
+
+	// Writing a manifest for identical data stores no new objects
+	if _, _, err := store.writeArchive("again", first.Timestamp, archiveTestData("t1", "t3")); err != nil {
+		t.Fatalf("writeArchive() error = %v", err)
+	}
+	if got := countObjects(t, store); got != objectsAfterFirst {
+		t.Errorf("identical backup added objects: %d -> %d", objectsAfterFirst, got)
+	}
+
+	// Adding a track to the playlist stores the new track and the updated playlist only
+	if _, _, err := store.writeArchive("changed", first.Timestamp, archiveTestData("t1", "t3", "t4")); err != nil {
+		t.Fatalf("writeArchive() error = %v", err)
+	}
+	if got := countObjects(t, store); got != objectsAfterFirst+2 {
+		t.Errorf("expected %d objects after playlist change, got %d", objectsAfterFirst+2, got)
+	}
+
+	backups, err := store.ListBackups()
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+	if len(backups) != 3 {
+		t.Errorf("expected 3 backups, got %d", len(backups))
+	}
+}
+
+// noGCGrace lets GarbageCollect sweep objects as soon as they are unreferenced
+func noGCGrace(t *testing.T) {
+	t.Helper()
+	saved := gcGracePeriod
+	gcGracePeriod = 0
+	t.Cleanup(func() { gcGracePeriod = saved })
+}
+
+func TestArchive_GarbageCollect(t *testing.T) {
+	noGCGrace(t)
+	tmpDir := t.TempDir()
+	store := NewStore(tmpDir, filepath.Join(tmpDir, "backups"))
+
+	old, err := store.CreateBackup("old", archiveTestData("t1", "t3"))
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	current, err := store.CreateBackup("all", archiveTestData("t5"))
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+
+	// Nothing is collectable while both backups exist
+	result, err := store.GarbageCollect()
+	if err != nil {
+		t.Fatalf("GarbageCollect() error = %v", err)
+	}
+	if result.Removed != 0 {
+		t.Errorf("expected nothing removed, got %d", result.Removed)
+	}
+
+	if err := store.DeleteBackup(old.ID); err != nil {
+		t.Fatalf("DeleteBackup() error = %v", err)
+	}
+	result, err = store.GarbageCollect()
+	if err != nil {
+		t.Fatalf("GarbageCollect() error = %v", err)
+	}
+	// The old playlist and its two tracks are no longer referenced
+	if result.Removed != 3 {
+		t.Errorf("expected 3 objects removed, got %d", result.Removed)
+	}
+	if result.FreedBytes <= 0 {
+		t.Error("expected freed bytes to be positive")
+	}
+
+	// The remaining backup is still complete
+	var restored map[string]interface{}
+	if err := store.LoadBackupJSON(current.ID, &restored); err != nil {
+		t.Fatalf("LoadBackupJSON() after GC error = %v", err)
+	}
+}
+
+func TestArchive_GarbageCollectGracePeriod(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewStore(tmpDir, filepath.Join(tmpDir, "backups"))
+
+	old, err := store.CreateBackup("old", archiveTestData("t1", "t3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateBackup("all", archiveTestData("t5")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteBackup(old.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// A backup in progress: an object written before its manifest, and a temp file
+	pending, err := (&objectWriter{store: store, seen: make(map[string]bool)}).put([]byte(`{"id":"t9"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp := filepath.Join(filepath.Dir(store.objectPath(pending)), ".tmp-123")
+	if err := os.WriteFile(tmp, []byte("partial"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := store.GarbageCollect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Removed != 0 || result.Recent != 5 {
+		t.Errorf("young files should be kept: %+v", result)
+	}
+
+	// Once they are older than the grace period they are swept
+	past := time.Now().Add(-2 * gcGracePeriod)
+	err = filepath.WalkDir(filepath.Join(store.backupDir, archiveObjectsDir), func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		return os.Chtimes(path, past, past)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = store.GarbageCollect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Removed != 5 || result.Recent != 0 {
+		t.Errorf("expected the 3 old objects, the pending one and the temp file removed: %+v", result)
+	}
+
+	// Reusing an object refreshes it, so it is protected again
+	kept := store.objectPath(pending)
+	if _, err := (&objectWriter{store: store, seen: make(map[string]bool)}).put([]byte(`{"id":"t9"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(kept, past, past); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&objectWriter{store: store, seen: make(map[string]bool)}).put([]byte(`{"id":"t9"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(kept); err != nil || time.Since(info.ModTime()) > time.Minute {
+		t.Errorf("reused object was not refreshed: %v", err)
+	}
+}
+
+func TestArchive_UniqueManifestIDs(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewStore(tmpDir, filepath.Join(tmpDir, "backups"))
+
+	// Backups started in the same instant must not overwrite each other
+	now := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	first, _, err := store.writeArchive("all", now, archiveTestData("t1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := store.writeArchive("all", now, archiveTestData("t2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID != "all-20240501-120000.123.json" || second.ID != "all-20240501-120000.123-2.json" {
+		t.Errorf("unexpected IDs %s and %s", first.ID, second.ID)
+	}
+
+	backups, err := store.ListBackups()
+	if err != nil || len(backups) != 2 {
+		t.Fatalf("ListBackups() = %v, %v", backups, err)
+	}
+	for _, manifest := range []*ArchiveManifest{first, second} {
+		if result, err := store.VerifyBackup(manifest.ID); err != nil || !result.OK() {
+			t.Errorf("VerifyBackup(%s) = %+v, %v", manifest.ID, result, err)
+		}
+	}
+}
+
+func TestArchive_ListsLegacyBackups(t *testing.T) {
+	tmpDir := t.TempDir()
+	backupDir := filepath.Join(tmpDir, "backups")
+	store := NewStore(tmpDir, backupDir)
+
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	legacy := filepath.Join(backupDir, "all-20230101-000000.json")
+	if err := os.WriteFile(legacy, []byte(`{"saved_tracks":[{"id":"x"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateBackup("all", archiveTestData()); err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+
+	backups, err := store.ListBackups()
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %d", len(backups))
+	}
+
+	var data map[string][]map[string]string
+	if err := store.LoadBackupJSON("all-20230101-000000.json", &data); err != nil {
+		t.Fatalf("LoadBackupJSON(legacy) error = %v", err)
+	}
+	if data["saved_tracks"][0]["id"] != "x" {
+		t.Errorf("unexpected legacy data: %v", data)
+	}
+}
+
+func TestArchive_DetectsCorruption(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewStore(tmpDir, filepath.Join(tmpDir, "backups"))
+
+	metadata, err := store.CreateBackup("all", archiveTestData("t1"))
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	manifest, err := store.LoadManifest(metadata.ID)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+
+	path := store.objectPath(manifest.Sections["saved_tracks"].Items[0])
+	if err := os.WriteFile(path, []byte(`{"tampered":true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var restored map[string]interface{}
+	if err := store.LoadBackupJSON(metadata.ID, &restored); err == nil {
+		t.Error("expected error loading a backup with a corrupted object")
+	}
+}
+
+func roundTripJSON(in interface{}, out interface{}) error {
+	raw, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
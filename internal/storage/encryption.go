@@ -0,0 +1,338 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bkataru/spotigo/internal/crypto"
+)
+
+// Encryption modes for the storage.encryption setting
+const (
+	EncryptionNone       = "none"
+	EncryptionMachine    = "machine"
+	EncryptionPassphrase = "passphrase"
+)
+
+// Key derivation functions recorded in the key parameters file
+const (
+	kdfMachine = "machine"
+	kdfScrypt  = "scrypt"
+)
+
+// keyParamsFile records how the storage key is derived. It lives in the backup
+// directory so a copied backup directory can be unlocked on another machine,
+// and holds no secrets. It has no .json extension so it is never listed as a backup.
+const keyParamsFile = ".encryption"
+
+// pendingKeyParamsFile records a new key while a rotation re-encrypts files
+// with it. It replaces the key parameters once every file has been rewritten.
+const pendingKeyParamsFile = ".encryption.new"
+
+// encryptedPrefix marks files written encrypted; the AES-GCM ciphertext follows
+var encryptedPrefix = []byte("SPOTIGO-ENC1\n")
+
+// keyCheckPlaintext is encrypted into the key parameters to detect a wrong passphrase
+var keyCheckPlaintext = []byte("spotigo")
+
+// scryptParams are the parameters used for new passphrase keys
+var scryptParams = crypto.DefaultScryptParams
+
+var (
+	// ErrEncrypted is returned when reading an encrypted file without a key
+	ErrEncrypted = errors.New("data is encrypted; set storage.encryption to unlock it")
+	// ErrWrongPassphrase is returned when a passphrase does not unlock the storage key
+	ErrWrongPassphrase = errors.New("wrong passphrase for encrypted storage")
+	// ErrRotationInterrupted is returned while a key rotation is unfinished
+	ErrRotationInterrupted = errors.New("a storage key rotation was interrupted; run spotigo auth rekey again with the same --to and new key to finish it")
+)
+
+// keyParams is the content of the key parameters file
+type keyParams struct {
+	KDF    string               `json:"kdf"`
+	Salt   []byte               `json:"salt,omitempty"`
+	Scrypt *crypto.ScryptParams `json:"scrypt,omitempty"`
+	// Check is keyCheckPlaintext encrypted with the derived key
+	Check []byte `json:"check"`
+}
+
+// PassphraseFunc supplies the storage passphrase. confirm is set when a new
+// key is being created, so interactive callers can ask twice.
+type PassphraseFunc func(confirm bool) (string, error)
+
+// Encryption holds the unlocked storage key
+type Encryption struct {
+	enc *crypto.TokenEncryptor
+	kdf string
+	// writes is set when new files should be encrypted
+	writes bool
+}
+
+// IsEncrypted reports whether data was written by an encrypting store
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedPrefix)
+}
+
+// OpenEncryption unlocks the storage key for backupDir according to mode.
+//
+// Once a key has been set up its parameters are kept in the backup directory and
+// decide how it is derived; mode only decides whether new files are encrypted.
+// With mode none and no key set up, OpenEncryption returns nil: files are
+// read and written in plaintext.
+func OpenEncryption(backupDir, mode string, passphrase PassphraseFunc) (*Encryption, error) {
+	if RotationInterrupted(backupDir) {
+		return nil, ErrRotationInterrupted
+	}
+	return openEncryption(backupDir, mode, passphrase)
+}
+
+// OpenRotatingEncryption unlocks the storage key like OpenEncryption, also
+// while a key rotation is unfinished, so RotateEncryption can complete it
+func OpenRotatingEncryption(backupDir, mode string, passphrase PassphraseFunc) (*Encryption, error) {
+	return openEncryption(backupDir, mode, passphrase)
+}
+
+// RotationInterrupted reports whether a key rotation in backupDir was left unfinished
+func RotationInterrupted(backupDir string) bool {
+	return fileExists(filepath.Join(backupDir, pendingKeyParamsFile))
+}
+
+func openEncryption(backupDir, mode string, passphrase PassphraseFunc) (*Encryption, error) {
+	switch mode {
+	case "", EncryptionNone, EncryptionMachine, EncryptionPassphrase:
+	default:
+		return nil, fmt.Errorf("unknown storage encryption %q (use none, machine or passphrase)", mode)
+	}
+
+	path := filepath.Join(backupDir, keyParamsFile)
+	params, err := readKeyParams(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if mode == "" || mode == EncryptionNone {
+			return nil, nil
+		}
+		return createEncryption(path, mode, passphrase)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mode == EncryptionMachine && params.KDF != kdfMachine || mode == EncryptionPassphrase && params.KDF != kdfScrypt {
+		return nil, fmt.Errorf("storage is already encrypted with a %s key; changing storage.encryption to %s is not supported", params.KDF, mode)
+	}
+
+	enc, err := params.unlock(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &Encryption{enc: enc, kdf: params.KDF, writes: mode != "" && mode != EncryptionNone}, nil
+}
+
+// readKeyParams reads a key parameters file
+func readKeyParams(path string) (keyParams, error) {
+	var params keyParams
+	raw, err := os.ReadFile(path) // #nosec G304 - path is constructed from controlled backupDir
+	if errors.Is(err, os.ErrNotExist) {
+		return params, err
+	}
+	if err != nil {
+		return params, fmt.Errorf("failed to read encryption parameters: %w", err)
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return params, fmt.Errorf("invalid encryption parameters in %s: %w", path, err)
+	}
+	return params, nil
+}
+
+// createEncryption sets up a new storage key and records its parameters
+func createEncryption(path, mode string, passphrase PassphraseFunc) (*Encryption, error) {
+	params, enc, err := newKey(mode, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if err := params.write(path); err != nil {
+		return nil, err
+	}
+	return enc, nil
+}
+
+// newKey derives a new storage key for mode, with a fresh salt for passphrase keys
+func newKey(mode string, passphrase PassphraseFunc) (keyParams, *Encryption, error) {
+	params := keyParams{KDF: kdfMachine}
+	if mode == EncryptionPassphrase {
+		salt, err := crypto.NewSalt()
+		if err != nil {
+			return params, nil, err
+		}
+		cost := scryptParams
+		params = keyParams{KDF: kdfScrypt, Salt: salt, Scrypt: &cost}
+	}
+
+	enc, err := params.encryptor(passphrase, true)
+	if err != nil {
+		return params, nil, err
+	}
+	if params.Check, err = enc.Encrypt(keyCheckPlaintext); err != nil {
+		return params, nil, fmt.Errorf("failed to create key check: %w", err)
+	}
+
+	return params, &Encryption{enc: enc, kdf: params.KDF, writes: true}, nil
+}
+
+// write records the key parameters atomically and durably
+func (p keyParams) write(path string) error {
+	raw, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode encryption parameters: %w", err)
+	}
+	if err := writeFileDurable(path, raw); err != nil {
+		return fmt.Errorf("failed to write encryption parameters: %w", err)
+	}
+	return nil
+}
+
+// unlock derives the key described by the parameters and checks it is the
+// one they were recorded with
+func (p keyParams) unlock(passphrase PassphraseFunc) (*crypto.TokenEncryptor, error) {
+	enc, err := p.encryptor(passphrase, false)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := enc.Decrypt(p.Check); err != nil {
+		if p.KDF == kdfScrypt {
+			return nil, ErrWrongPassphrase
+		}
+		return nil, fmt.Errorf("the machine key does not match the one these backups were encrypted with")
+	}
+	return enc, nil
+}
+
+// encryptor derives the key described by the parameters
+func (p keyParams) encryptor(passphrase PassphraseFunc, confirm bool) (*crypto.TokenEncryptor, error) {
+	switch p.KDF {
+	case kdfMachine:
+		return crypto.NewTokenEncryptor()
+	case kdfScrypt:
+		if p.Scrypt == nil {
+			return nil, fmt.Errorf("encryption parameters are missing scrypt costs")
+		}
+		if passphrase == nil {
+			return nil, fmt.Errorf("storage is encrypted with a passphrase but none was provided")
+		}
+		secret, err := passphrase(confirm)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.NewPassphraseEncryptor(secret, p.Salt, *p.Scrypt)
+	default:
+		return nil, fmt.Errorf("unsupported key derivation %q", p.KDF)
+	}
+}
+
+// EncryptionConfigured reports whether a storage key has been set up for backupDir
+func EncryptionConfigured(backupDir string) bool {
+	return fileExists(filepath.Join(backupDir, keyParamsFile))
+}
+
+// KDF returns how the key is derived: machine or scrypt
+func (e *Encryption) KDF() string {
+	return e.kdf
+}
+
+// Encrypts reports whether new files are written encrypted
+func (e *Encryption) Encrypts() bool {
+	return e != nil && e.writes
+}
+
+// Encode prepares data for writing, encrypting it if enabled
+func (e *Encryption) Encode(data []byte) ([]byte, error) {
+	if !e.Encrypts() {
+		return data, nil
+	}
+	ciphertext, err := e.enc.Encrypt(data)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, encryptedPrefix...), ciphertext...), nil
+}
+
+// Decode returns the plaintext of data read from disk. Plaintext files are
+// returned as is, so stores written before encryption was enabled stay readable.
+func (e *Encryption) Decode(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	if e == nil {
+		return nil, ErrEncrypted
+	}
+	plaintext, err := e.enc.Decrypt(data[len(encryptedPrefix):])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+// SetEncryption sets the key used to read and write encrypted files; nil disables it
+func (s *Store) SetEncryption(e *Encryption) {
+	s.encryption = e
+}
+
+// Encryption returns the key used to read and write encrypted files, or nil
+func (s *Store) Encryption() *Encryption {
+	return s.encryption
+}
+
+// Encrypted reports whether the store writes files encrypted
+func (s *Store) Encrypted() bool {
+	return s.encryption.Encrypts()
+}
+
+// readFile reads and decodes a file written by the store
+func (s *Store) readFile(path string) ([]byte, error) {
+	raw, err := os.ReadFile(path) // #nosec G304 - callers construct paths from controlled directories
+	if err != nil {
+		return nil, err
+	}
+	return s.encryption.Decode(raw)
+}
+
+// writeFile encodes data and writes it atomically
+func (s *Store) writeFile(path string, data []byte) (int64, error) {
+	encoded, err := s.encryption.Encode(data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	if err := writeFileAtomic(path, encoded); err != nil {
+		return 0, err
+	}
+	return int64(len(encoded)), nil
+}
+
+// writeFileExclusive encodes data and writes it to a new file. It fails with
+// an error matching os.ErrExist if path is already taken.
+func (s *Store) writeFileExclusive(path string, data []byte) (int64, error) {
+	encoded, err := s.encryption.Encode(data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Clean(path), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600) // #nosec G304 - callers construct paths from controlled directories
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.Write(encoded); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return 0, fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(path)
+		return 0, fmt.Errorf("failed to close file: %w", err)
+	}
+	return int64(len(encoded)), nil
+}
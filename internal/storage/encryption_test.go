@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bkataru/spotigo/internal/crypto"
+)
+
+// fastScrypt keeps passphrase key derivation cheap for the duration of a test
+func fastScrypt(t *testing.T) {
+	t.Helper()
+	saved := scryptParams
+	scryptParams = crypto.ScryptParams{N: 1 << 10, R: 8, P: 1}
+	t.Cleanup(func() { scryptParams = saved })
+}
+
+func passphrase(secret string) PassphraseFunc {
+	return func(bool) (string, error) { return secret, nil }
+}
+
+// assertNoPlaintext fails if any file under dir contains needle
+func assertNoPlaintext(t *testing.T, dir, needle string) {
+	t.Helper()
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		raw, err := os.ReadFile(path) // #nosec G304 - test path
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(raw, []byte(needle)) {
+			t.Errorf("%s contains plaintext %q", path, needle)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenEncryption_None(t *testing.T) {
+	dir := t.TempDir()
+	for _, mode := range []string{"", EncryptionNone} {
+		enc, err := OpenEncryption(dir, mode, nil)
+		if err != nil || enc != nil {
+			t.Errorf("OpenEncryption(%q) = %v, %v; want nil, nil", mode, enc, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, keyParamsFile)); !os.IsNotExist(err) {
+		t.Error("no key parameters should be written without encryption")
+	}
+	if _, err := OpenEncryption(dir, "rot13", nil); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestEncryptedStore_RoundTrip(t *testing.T) {
+	fastScrypt(t)
+	tmpDir := t.TempDir()
+	backupDir := filepath.Join(tmpDir, "backups")
+
+	enc, err := OpenEncryption(backupDir, EncryptionPassphrase, passphrase("hunter2"))
+	if err != nil {
+		t.Fatalf("OpenEncryption() error = %v", err)
+	}
+	if enc.KDF() != kdfScrypt || !enc.Encrypts() {
+		t.Errorf("unexpected encryption %+v", enc)
+	}
+	store := NewStore(tmpDir, backupDir)
+	store.SetEncryption(enc)
+
+	data := archiveTestData("t1", "t3")
+	metadata, err := store.CreateBackup("all", data)
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	if err := store.SaveJSON("saved_tracks.json", data["saved_tracks"]); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing readable on disk, not even object hashes of the plaintext
+	assertNoPlaintext(t, tmpDir, `"One"`)
+	assertNoPlaintext(t, tmpDir, `"t3"`)
+	manifest, err := store.LoadManifest(metadata.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainName := (&Store{}).objectHash([]byte(`{"added_at":"2024-01-01T00:00:00Z","track":{"id":"t1","name":"One"}}`), false)
+	if items := manifest.Sections["saved_tracks"].Items; len(items) != 2 || items[0] == plainName {
+		t.Errorf("encrypted objects should not be named by their plain SHA-256: %v", items)
+	}
+
+	// Another machine with the same passphrase can read everything
+	other, err := OpenEncryption(backupDir, EncryptionPassphrase, passphrase("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := NewStore(tmpDir, backupDir)
+	reader.SetEncryption(other)
+
+	var restored, want map[string]interface{}
+	if err := reader.LoadBackupJSON(metadata.ID, &restored); err != nil {
+		t.Fatalf("LoadBackupJSON() error = %v", err)
+	}
+	if err := roundTripJSON(data, &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(restored, want) {
+		t.Errorf("restored backup differs:\n got %v\nwant %v", restored, want)
+	}
+	var tracks []interface{}
+	if err := reader.LoadJSON("saved_tracks.json", &tracks); err != nil || len(tracks) != 2 {
+		t.Errorf("LoadJSON() = %v, %v", tracks, err)
+	}
+	if result, err := reader.VerifyBackup(metadata.ID); err != nil || !result.OK() {
+		t.Errorf("VerifyBackup() = %+v, %v", result, err)
+	}
+	before := countObjects(t, reader)
+	if gc, err := reader.GarbageCollect(); err != nil || gc.Removed != 0 || countObjects(t, reader) != before {
+		t.Errorf("GarbageCollect() removed live objects: %+v, %v", gc, err)
+	}
+
+	// A wrong passphrase is rejected up front
+	if _, err := OpenEncryption(backupDir, EncryptionPassphrase, passphrase("hunter3")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected ErrWrongPassphrase, got %v", err)
+	}
+
+	// Without the key nothing can be read
+	locked := NewStore(tmpDir, backupDir)
+	if _, err := locked.ListBackups(); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("ListBackups() error = %v, want ErrEncrypted", err)
+	}
+	if err := locked.LoadJSON("saved_tracks.json", &tracks); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("LoadJSON() error = %v, want ErrEncrypted", err)
+	}
+	if _, err := locked.GarbageCollect(); err == nil {
+		t.Error("GarbageCollect() should refuse to sweep objects it cannot read")
+	}
+	if result, err := locked.VerifyBackup(metadata.ID); err != nil || result.OK() {
+		t.Errorf("VerifyBackup() without key = %+v, %v", result, err)
+	}
+}
+
+func TestEncryptedStore_ReadsPlaintext(t *testing.T) {
+	tmpDir := t.TempDir()
+	backupDir := filepath.Join(tmpDir, "backups")
+	store := NewStore(tmpDir, backupDir)
+
+	old, err := store.CreateBackup("old", archiveTestData("t1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := OpenEncryption(backupDir, EncryptionMachine, nil)
+	if err != nil {
+		t.Fatalf("OpenEncryption() error = %v", err)
+	}
+	store.SetEncryption(enc)
+	current, err := store.CreateBackup("all", archiveTestData("t1", "t2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{old.ID, current.ID} {
+		var data map[string]interface{}
+		if err := store.LoadBackupJSON(id, &data); err != nil {
+			t.Errorf("LoadBackupJSON(%s) error = %v", id, err)
+		}
+	}
+	results, err := store.VerifyAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if !result.OK() {
+			t.Errorf("%s: %v", result.ID, result.Problems)
+		}
+	}
+
+	// Turning encryption off keeps existing files readable but writes plaintext
+	readOnly, err := OpenEncryption(backupDir, EncryptionNone, nil)
+	if err != nil || readOnly == nil || readOnly.Encrypts() {
+		t.Fatalf("OpenEncryption(none) = %+v, %v", readOnly, err)
+	}
+	store.SetEncryption(readOnly)
+	if store.Encrypted() {
+		t.Error("store should not encrypt with mode none")
+	}
+	if err := store.SaveJSON("plain.json", map[string]string{"name": "One"}); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(filepath.Join(tmpDir, "plain.json"))
+	if IsEncrypted(raw) {
+		t.Error("expected plaintext file with mode none")
+	}
+
+	// The key type cannot change silently
+	if _, err := OpenEncryption(backupDir, EncryptionPassphrase, passphrase("x")); err == nil || !strings.Contains(err.Error(), "machine") {
+		t.Errorf("expected key type mismatch error, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// RetentionPolicy decides which backups to keep when pruning.
+// A backup is kept if any rule keeps it; the newest backup of each type is always kept.
+type RetentionPolicy struct {
+	// RetainDays keeps every backup younger than this many days (0 = disabled)
+	RetainDays int
+	// KeepDaily keeps the newest backup of each of the last N days that have one
+	KeepDaily int
+	// KeepWeekly keeps the newest backup of each of the last N ISO weeks that have one
+	KeepWeekly int
+	// KeepMonthly keeps the newest backup of each of the last N months that have one
+	KeepMonthly int
+}
+
+// Enabled reports whether the policy would ever remove a backup
+func (p RetentionPolicy) Enabled() bool {
+	return p.RetainDays > 0 || p.KeepDaily > 0 || p.KeepWeekly > 0 || p.KeepMonthly > 0
+}
+
+// PruneResult summarizes a prune run
+type PruneResult struct {
+	Removed []BackupMetadata
+	Kept    int
+	// GC is nil for dry runs
+	GC *GCResult
+}
+
+// SelectPrunable returns the backups the policy would remove, newest first.
+// Backups are grouped by type so that, for example, a tracks-only backup never
+// counts towards the retention of full backups.
+func SelectPrunable(backups []BackupMetadata, policy RetentionPolicy, now time.Time) []BackupMetadata {
+	if !policy.Enabled() {
+		return nil
+	}
+
+	byType := make(map[string][]BackupMetadata)
+	for _, backup := range backups {
+		byType[backup.Type] = append(byType[backup.Type], backup)
+	}
+
+	var prunable []BackupMetadata
+	for _, group := range byType {
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].Timestamp.After(group[j].Timestamp)
+		})
+
+		keep := make([]bool, len(group))
+		keep[0] = true
+
+		if policy.RetainDays > 0 {
+			cutoff := now.AddDate(0, 0, -policy.RetainDays)
+			for i, backup := range group {
+				if backup.Timestamp.After(cutoff) {
+					keep[i] = true
+				}
+			}
+		}
+
+		keepPeriods(group, keep, policy.KeepDaily, func(t time.Time) string {
+			return t.Format("2006-01-02")
+		})
+		keepPeriods(group, keep, policy.KeepWeekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		})
+		keepPeriods(group, keep, policy.KeepMonthly, func(t time.Time) string {
+			return t.Format("2006-01")
+		})
+
+		for i, backup := range group {
+			if !keep[i] {
+				prunable = append(prunable, backup)
+			}
+		}
+	}
+
+	sort.SliceStable(prunable, func(i, j int) bool {
+		return prunable[i].Timestamp.After(prunable[j].Timestamp)
+	})
+	return prunable
+}
+
+// keepPeriods marks the newest backup in each of the first n periods, walking newest first
+func keepPeriods(group []BackupMetadata, keep []bool, n int, period func(time.Time) string) {
+	if n <= 0 {
+		return
+	}
+	seen := make(map[string]bool, n)
+	for i, backup := range group {
+		key := period(backup.Timestamp.Local())
+		if seen[key] {
+			continue
+		}
+		if len(seen) == n {
+			return
+		}
+		seen[key] = true
+		keep[i] = true
+	}
+}
+
+// Prune removes backups not kept by the policy and garbage-collects the archive.
+// With dryRun set nothing is deleted and the result lists what would be removed.
+func (s *Store) Prune(policy RetentionPolicy, now time.Time, dryRun bool) (*PruneResult, error) {
+	backups, err := s.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	prunable := SelectPrunable(backups, policy, now)
+	result := &PruneResult{
+		Removed: prunable,
+		Kept:    len(backups) - len(prunable),
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	for _, backup := range prunable {
+		if err := s.DeleteBackup(backup.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	gc, err := s.GarbageCollect()
+	if err != nil {
+		return nil, err
+	}
+	result.GC = gc
+
+	return result, nil
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func backupsAt(backupType string, times ...time.Time) []BackupMetadata {
+	backups := make([]BackupMetadata, 0, len(times))
+	for _, ts := range times {
+		backups = append(backups, BackupMetadata{
+			ID:        backupType + "-" + ts.Format(snapshotTimeFormat) + ".json",
+			Type:      backupType,
+			Timestamp: ts,
+		})
+	}
+	return backups
+}
+
+func prunedIDs(backups []BackupMetadata) map[string]bool {
+	ids := make(map[string]bool, len(backups))
+	for _, b := range backups {
+		ids[b.ID] = true
+	}
+	return ids
+}
+
+func TestSelectPrunable(t *testing.T) {
+	now := time.Date(2024, 6, 30, 12, 0, 0, 0, time.Local)
+	daysAgo := func(d int) time.Time { return now.AddDate(0, 0, -d) }
+
+	// One backup a day for 90 days
+	var times []time.Time
+	for d := 0; d < 90; d++ {
+		times = append(times, daysAgo(d))
+	}
+	daily := backupsAt("all", times...)
+
+	tests := []struct {
+		name     string
+		backups  []BackupMetadata
+		policy   RetentionPolicy
+		wantKept int
+	}{
+		{"disabled keeps everything", daily, RetentionPolicy{}, 90},
+		{"retain days", daily, RetentionPolicy{RetainDays: 30}, 30},
+		{"keep daily", daily, RetentionPolicy{KeepDaily: 7}, 7},
+		{"keep monthly", daily, RetentionPolicy{KeepMonthly: 3}, 3},
+		{"daily and monthly overlap", daily, RetentionPolicy{KeepDaily: 7, KeepMonthly: 3}, 9},
+		{"newest always kept", backupsAt("all", daysAgo(100), daysAgo(200)), RetentionPolicy{RetainDays: 30}, 1},
+		{"types are independent", append(backupsAt("all", daysAgo(40)), backupsAt("tracks", daysAgo(50))...), RetentionPolicy{RetainDays: 30}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prunable := SelectPrunable(tt.backups, tt.policy, now)
+			if kept := len(tt.backups) - len(prunable); kept != tt.wantKept {
+				t.Errorf("kept %d backups, want %d", kept, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestSelectPrunable_KeepWeekly(t *testing.T) {
+	// Wednesday, so the two backups this week share an ISO week
+	now := time.Date(2024, 6, 26, 12, 0, 0, 0, time.Local)
+	backups := backupsAt("all", now, now.AddDate(0, 0, -1), now.AddDate(0, 0, -7), now.AddDate(0, 0, -14))
+
+	prunable := prunedIDs(SelectPrunable(backups, RetentionPolicy{KeepWeekly: 2}, now))
+	if len(prunable) != 2 {
+		t.Fatalf("expected 2 backups pruned, got %d", len(prunable))
+	}
+	if prunable[backups[0].ID] || prunable[backups[2].ID] {
+		t.Error("expected the newest backup of each of the last two weeks to be kept")
+	}
+}
+
+func TestStore_Prune(t *testing.T) {
+	noGCGrace(t)
+	tmpDir := t.TempDir()
+	store := NewStore(tmpDir, filepath.Join(tmpDir, "backups"))
+
+	now := time.Now()
+	for i, track := range []string{"t1", "t2", "t3"} {
+		ts := now.AddDate(0, 0, -10*i)
+		if _, _, err := store.writeArchive("all", ts, archiveTestData(track)); err != nil {
+			t.Fatalf("writeArchive() error = %v", err)
+		}
+	}
+
+	policy := RetentionPolicy{RetainDays: 5}
+
+	dry, err := store.Prune(policy, now, true)
+	if err != nil {
+		t.Fatalf("Prune(dry run) error = %v", err)
+	}
+	if len(dry.Removed) != 2 || dry.GC != nil {
+		t.Errorf("dry run: expected 2 prunable and no GC, got %d, %v", len(dry.Removed), dry.GC)
+	}
+	if backups, _ := store.ListBackups(); len(backups) != 3 {
+		t.Errorf("dry run removed backups: %d left", len(backups))
+	}
+
+	result, err := store.Prune(policy, now, false)
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if len(result.Removed) != 2 || result.Kept != 1 {
+		t.Errorf("expected 2 removed and 1 kept, got %d and %d", len(result.Removed), result.Kept)
+	}
+	if result.GC == nil || result.GC.Removed == 0 {
+		t.Error("expected pruning to garbage-collect unreferenced objects")
+	}
+	if backups, _ := store.ListBackups(); len(backups) != 1 {
+		t.Errorf("expected 1 backup left, got %d", len(backups))
+	}
+}
@@ -0,0 +1,457 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bkataru/spotigo/internal/history"
+)
+
+// rotateSuffix marks files staged with the new key during a rotation
+const rotateSuffix = ".rotating"
+
+// replaceStaged moves a staged file into place; tests replace it to cut a
+// rotation short
+var replaceStaged = os.Rename
+
+// rotation holds the plaintext of every file encrypted with the storage key
+type rotation struct {
+	// files are data files outside the archive, by path
+	files map[string][]byte
+	// objects are encrypted archive objects, by hash
+	objects map[string][]byte
+	// manifests are encrypted or signed manifests, by path
+	manifests map[string]*ArchiveManifest
+	// plainManifests are the signed manifests stored in plaintext, by path
+	plainManifests map[string]bool
+	// plays are the play log segments holding sealed lines, by path
+	plays map[string][]history.Play
+	// abandoned are objects written by an unfinished rotation being replaced
+	abandoned []string
+}
+
+// RotateEncryption re-encrypts every file written with the store's key with a
+// new key for mode, machine or passphrase, and returns how many files were
+// rewritten. Data files, the play log, manifests and objects are all
+// re-encrypted. Objects are named by a keyed hash, so they are stored under
+// their new names and the manifests are rewritten to match.
+//
+// Every file is decrypted before any is written. The new key parameters are
+// recorded next to the old ones first, and the rewritten files are staged and
+// only then renamed into place, so a rotation cut short leaves every file
+// readable with one of the two keys. Running it again with the same new key
+// finishes it. The old parameters and objects are only dropped at the end.
+// skip lists directories in the data directory that hold other stores, such
+// as profiles. Afterwards the store encrypts new files with the new key.
+func (s *Store) RotateEncryption(mode string, passphrase PassphraseFunc, skip ...string) (int, error) {
+	if s.encryption == nil {
+		return 0, fmt.Errorf("storage encryption is not set up")
+	}
+	if mode != EncryptionMachine && mode != EncryptionPassphrase {
+		return 0, fmt.Errorf("unknown storage encryption %q (use machine or passphrase)", mode)
+	}
+
+	pendingPath := filepath.Join(s.backupDir, pendingKeyParamsFile)
+	params, next, resuming := pendingKey(pendingPath, mode, passphrase)
+	keys := []*Encryption{s.encryption}
+	if resuming {
+		keys = append(keys, next)
+	}
+
+	abandoning := !resuming && RotationInterrupted(s.backupDir)
+	r, err := s.readRotation(skip, keys, abandoning)
+	if err != nil {
+		if abandoning {
+			return 0, fmt.Errorf("%w; finish the interrupted rotation with the same new key", err)
+		}
+		return 0, err
+	}
+
+	if !resuming {
+		if params, next, err = newKey(mode, passphrase); err != nil {
+			return 0, err
+		}
+		if err := params.write(pendingPath); err != nil {
+			return 0, err
+		}
+	}
+	target := &Store{dataDir: s.dataDir, backupDir: s.backupDir, encryption: next}
+	var staged []string
+	stage := func(path string, data []byte) error {
+		if err := writeFileDurable(path+rotateSuffix, data); err != nil {
+			return err
+		}
+		staged = append(staged, path)
+		return nil
+	}
+
+	// Objects first, renaming the split-out objects an object references
+	// before naming the object itself. New names never clash with old ones,
+	// so objects are written in place.
+	count := 0
+	renamed := make(map[string]string, len(r.objects))
+	var rename func(hash string) (string, error)
+	rename = func(hash string) (string, error) {
+		if name, ok := renamed[hash]; ok {
+			return name, nil
+		}
+		raw, ok := r.objects[hash]
+		if !ok {
+			// Plaintext objects keep their names
+			return hash, nil
+		}
+		raw, err := rewriteRefs(raw, rename)
+		if err != nil {
+			return "", err
+		}
+		name := target.objectHash(raw, true)
+		encoded, err := next.Encode(raw)
+		if err != nil {
+			return "", fmt.Errorf("failed to encrypt object %s: %w", name, err)
+		}
+		if err := writeFileDurable(target.objectPath(name), encoded); err != nil {
+			return "", fmt.Errorf("failed to write object %s: %w", name, err)
+		}
+		renamed[hash] = name
+		count++
+		return name, nil
+	}
+	for hash := range r.objects {
+		if _, err := rename(hash); err != nil {
+			return 0, err
+		}
+	}
+
+	for path, manifest := range r.manifests {
+		for name, section := range manifest.Sections {
+			if section.Object != "" {
+				section.Object = renamedObject(renamed, section.Object)
+			}
+			for i, hash := range section.Items {
+				section.Items[i] = renamedObject(renamed, hash)
+			}
+			manifest.Sections[name] = section
+		}
+		// Manifests from before checksums keep none, as schema version 1 has none
+		if manifest.Checksum != "" {
+			if err := manifest.seal(next); err != nil {
+				return 0, fmt.Errorf("manifest %s: %w", manifest.ID, err)
+			}
+		}
+		raw, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode manifest %s: %w", manifest.ID, err)
+		}
+		encoded := raw
+		if !r.plainManifests[path] {
+			if encoded, err = next.Encode(raw); err != nil {
+				return 0, fmt.Errorf("failed to encrypt manifest %s: %w", manifest.ID, err)
+			}
+		}
+		if err := stage(path, encoded); err != nil {
+			return 0, fmt.Errorf("failed to write manifest %s: %w", manifest.ID, err)
+		}
+	}
+
+	for path, raw := range r.files {
+		encoded, err := next.Encode(raw)
+		if err != nil {
+			return 0, fmt.Errorf("failed to re-encrypt %s: %w", path, err)
+		}
+		if err := stage(path, encoded); err != nil {
+			return 0, fmt.Errorf("failed to re-encrypt %s: %w", path, err)
+		}
+	}
+
+	log := &history.Log{Seal: next.Encode}
+	for path, plays := range r.plays {
+		data, err := log.Encode(plays)
+		if err != nil {
+			return 0, err
+		}
+		if err := stage(path, data); err != nil {
+			return 0, fmt.Errorf("failed to reseal %s: %w", path, err)
+		}
+	}
+
+	for _, path := range staged {
+		if err := replaceStaged(path+rotateSuffix, path); err != nil {
+			return 0, fmt.Errorf("failed to replace %s: %w", path, err)
+		}
+		count++
+	}
+
+	if err := os.Rename(pendingPath, filepath.Join(s.backupDir, keyParamsFile)); err != nil {
+		return count, fmt.Errorf("failed to write encryption parameters: %w", err)
+	}
+	if err := syncDir(s.backupDir); err != nil {
+		return count, err
+	}
+	s.encryption = next
+
+	for old, name := range renamed {
+		if old == name {
+			continue
+		}
+		if err := os.Remove(s.objectPath(old)); err != nil && !os.IsNotExist(err) {
+			return count, fmt.Errorf("failed to remove old object %s: %w", old, err)
+		}
+	}
+	for _, hash := range r.abandoned {
+		if err := os.Remove(s.objectPath(hash)); err != nil && !os.IsNotExist(err) {
+			return count, fmt.Errorf("failed to remove object %s: %w", hash, err)
+		}
+	}
+
+	return count, nil
+}
+
+// pendingKey unlocks the new key of an unfinished rotation to mode, so the
+// rotation can be finished. It reports false if there is none, or it was
+// made for another mode or passphrase.
+func pendingKey(path, mode string, passphrase PassphraseFunc) (keyParams, *Encryption, bool) {
+	params, err := readKeyParams(path)
+	if err != nil {
+		return params, nil, false
+	}
+	if mode == EncryptionMachine && params.KDF != kdfMachine || mode == EncryptionPassphrase && params.KDF != kdfScrypt {
+		return params, nil, false
+	}
+	enc, err := params.unlock(passphrase)
+	if err != nil {
+		return params, nil, false
+	}
+	return params, &Encryption{enc: enc, kdf: params.KDF, writes: true}, true
+}
+
+// readRotation decrypts every file encrypted with the store's key. Files
+// already rewritten by an unfinished rotation are read with its key, the
+// last of keys. With abandon set an unfinished rotation to a key that is not
+// in keys is being replaced: it only wrote new objects before it stopped, so
+// objects no key opens are left out. Any file it did replace fails to read.
+func (s *Store) readRotation(skip []string, keys []*Encryption, abandon bool) (*rotation, error) {
+	r := &rotation{
+		files:          make(map[string][]byte),
+		objects:        make(map[string][]byte),
+		manifests:      make(map[string]*ArchiveManifest),
+		plainManifests: make(map[string]bool),
+	}
+	objectsDir := filepath.Join(s.backupDir, archiveObjectsDir)
+	manifestsDir := filepath.Join(s.backupDir, archiveManifestsDir)
+
+	err := walkEncrypted(objectsDir, nil, func(path string, raw []byte) error {
+		name := filepath.Base(path)
+		if filepath.Ext(name) != ".json" {
+			return nil
+		}
+		hash := strings.TrimSuffix(name, ".json")
+		plain, key, err := decodeWith(keys, raw)
+		if err != nil && abandon {
+			r.abandoned = append(r.abandoned, hash)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("object %s: %w", hash, err)
+		}
+		if hex.EncodeToString(key.enc.KeyedHash(plain)) != hash {
+			return fmt.Errorf("object %s is corrupted", hash)
+		}
+		r.objects[hash] = plain
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Plaintext manifests are signed with the key too, so they are re-signed
+	entries, err := os.ReadDir(manifestsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read manifest directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(manifestsDir, entry.Name())
+		raw, err := os.ReadFile(path) // #nosec G304 - path is constructed from controlled backupDir
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest %s: %w", entry.Name(), err)
+		}
+		plain, key, err := decodeWith(keys, raw)
+		if err != nil {
+			return nil, fmt.Errorf("manifest %s: %w", entry.Name(), err)
+		}
+		var manifest ArchiveManifest
+		if err := json.Unmarshal(plain, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to decode manifest %s: %w", entry.Name(), err)
+		}
+		if err := manifest.verifyChecksum(); err != nil {
+			return nil, err
+		}
+
+		if IsEncrypted(raw) {
+			err = manifest.verifySignature(key)
+		} else if manifest.Signature != "" {
+			r.plainManifests[path] = true
+			for _, key := range keys {
+				if err = manifest.verifySignature(key); err == nil {
+					break
+				}
+			}
+		} else {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.manifests[path] = &manifest
+	}
+
+	skipDirs := append([]string{objectsDir, manifestsDir}, skip...)
+	err = walkEncrypted(s.dataDir, skipDirs, func(path string, raw []byte) error {
+		plain, _, err := decodeWith(keys, raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		r.files[path] = plain
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log := history.NewLog(filepath.Join(s.dataDir, history.LogDir))
+	log.Open = func(line []byte) ([]byte, error) {
+		plain, _, err := decodeWith(keys, line)
+		return plain, err
+	}
+	if r.plays, err = log.ReadSealed(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// decodeWith returns the plaintext of raw and the key that decrypted it,
+// trying each of keys in turn
+func decodeWith(keys []*Encryption, raw []byte) ([]byte, *Encryption, error) {
+	var err error
+	for _, key := range keys {
+		var plain []byte
+		if plain, err = key.Decode(raw); err == nil {
+			return plain, key, nil
+		}
+	}
+	return nil, nil, err
+}
+
+// walkEncrypted calls fn with the content of each encrypted file under dir,
+// leaving out the directories in skip
+func walkEncrypted(dir string, skip []string, fn func(path string, raw []byte) error) error {
+	skipped := make(map[string]bool, len(skip))
+	for _, path := range skip {
+		if abs, err := filepath.Abs(path); err == nil {
+			skipped[abs] = true
+		}
+	}
+
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if os.IsNotExist(walkErr) && path == dir {
+				return filepath.SkipDir
+			}
+			return walkErr
+		}
+		if d.IsDir() {
+			if abs, err := filepath.Abs(path); err == nil && skipped[abs] && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Temp and staged files are rewritten from the files they replace
+		name := d.Name()
+		if !d.Type().IsRegular() || strings.HasPrefix(name, ".tmp-") || strings.HasSuffix(name, rotateSuffix) {
+			return nil
+		}
+
+		raw, ok, err := readIfEncrypted(path)
+		if err != nil || !ok {
+			return err
+		}
+		return fn(path, raw)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	return nil
+}
+
+// readIfEncrypted returns the content of path if it starts with the encrypted
+// file prefix, without reading the rest of other files
+func readIfEncrypted(path string) ([]byte, bool, error) {
+	f, err := os.Open(path) // #nosec G304 - path comes from walking a controlled directory
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = f.Close() }()
+
+	head := make([]byte, len(encryptedPrefix))
+	if _, err := io.ReadFull(f, head); err != nil || !bytes.Equal(head, encryptedPrefix) {
+		return nil, false, nil
+	}
+	rest, err := io.ReadAll(f)
+	if err != nil {
+		return nil, false, err
+	}
+	return append(head, rest...), true, nil
+}
+
+// rewriteRefs renames the split-out objects referenced by an object.
+// Objects without references are returned unchanged.
+func rewriteRefs(raw []byte, rename func(string) (string, error)) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		return raw, nil
+	}
+
+	changed := false
+	for name, value := range fields {
+		refs, ok := objectRefs(value)
+		if !ok {
+			continue
+		}
+		names := make([]string, len(refs))
+		for i, ref := range refs {
+			var err error
+			if names[i], err = rename(ref); err != nil {
+				return nil, err
+			}
+		}
+		ref, err := json.Marshal(map[string][]string{objectRefsKey: names})
+		if err != nil {
+			return nil, err
+		}
+		fields[name] = ref
+		changed = true
+	}
+	if !changed {
+		return raw, nil
+	}
+
+	// Marshaling the map sorts keys, as when the object was first written
+	return json.Marshal(fields)
+}
+
+// renamedObject returns the new name of an object, or its name if it kept it
+func renamedObject(renamed map[string]string, hash string) string {
+	if name, ok := renamed[hash]; ok {
+		return name
+	}
+	return hash
+}
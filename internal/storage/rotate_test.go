@@ -0,0 +1,274 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bkataru/spotigo/internal/history"
+)
+
+func TestStore_RotateEncryption(t *testing.T) {
+	fastScrypt(t)
+	tmpDir := t.TempDir()
+	backupDir := filepath.Join(tmpDir, "backups")
+
+	enc, err := OpenEncryption(backupDir, EncryptionPassphrase, passphrase("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore(tmpDir, backupDir)
+	store.SetEncryption(enc)
+
+	first, err := store.CreateBackup("all", archiveTestData("t1", "t3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.CreateBackup("playlists", archiveTestData("t3", "t4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveJSON("saved_tracks.json", []string{"t1", "t2"}); err != nil {
+		t.Fatal(err)
+	}
+	// Another profile's files use another key and are left alone
+	profileDir := filepath.Join(tmpDir, "profiles", "work")
+	other := NewStore(profileDir, filepath.Join(profileDir, "backups"))
+	other.SetEncryption(enc)
+	if err := other.SaveJSON("saved_tracks.json", []string{"t9"}); err != nil {
+		t.Fatal(err)
+	}
+	log := history.NewLog(filepath.Join(tmpDir, history.LogDir))
+	log.Seal, log.Open = enc.Encode, enc.Decode
+	if err := log.Append([]history.Play{{URI: "spotify:track:t1", PlayedAt: time.Now().UTC()}}); err != nil {
+		t.Fatal(err)
+	}
+	objects := countObjects(t, store)
+
+	count, err := store.RotateEncryption(EncryptionPassphrase, passphrase("correct horse"), filepath.Join(tmpDir, "profiles"))
+	if err != nil {
+		t.Fatalf("RotateEncryption() error = %v", err)
+	}
+	if want := objects + 2 + 1 + 1; count != want {
+		t.Errorf("RotateEncryption() rewrote %d files, want %d", count, want)
+	}
+	if got := countObjects(t, store); got != objects {
+		t.Errorf("archive holds %d objects after rotation, want %d", got, objects)
+	}
+
+	if _, err := OpenEncryption(backupDir, EncryptionPassphrase, passphrase("hunter2")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("old passphrase should be rejected, got %v", err)
+	}
+	rotated, err := OpenEncryption(backupDir, EncryptionPassphrase, passphrase("correct horse"))
+	if err != nil {
+		t.Fatalf("OpenEncryption() with new passphrase error = %v", err)
+	}
+	reader := NewStore(tmpDir, backupDir)
+	reader.SetEncryption(rotated)
+
+	for id, data := range map[string]map[string]interface{}{
+		first.ID:  archiveTestData("t1", "t3"),
+		second.ID: archiveTestData("t3", "t4"),
+	} {
+		var restored, want map[string]interface{}
+		if err := reader.LoadBackupJSON(id, &restored); err != nil {
+			t.Fatalf("LoadBackupJSON(%s) error = %v", id, err)
+		}
+		if err := roundTripJSON(data, &want); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(restored, want) {
+			t.Errorf("%s differs after rotation:\n got %v\nwant %v", id, restored, want)
+		}
+		if result, err := reader.VerifyBackup(id); err != nil || !result.OK() {
+			t.Errorf("VerifyBackup(%s) = %+v, %v", id, result, err)
+		}
+	}
+	var tracks []string
+	if err := reader.LoadJSON("saved_tracks.json", &tracks); err != nil || len(tracks) != 2 {
+		t.Errorf("LoadJSON() = %v, %v", tracks, err)
+	}
+	log.Open = rotated.Decode
+	if plays, err := log.ReadAll(); err != nil || len(plays) != 1 {
+		t.Errorf("play log ReadAll() = %v, %v", plays, err)
+	}
+	if gc, err := reader.GarbageCollect(); err != nil || gc.Removed != 0 {
+		t.Errorf("GarbageCollect() after rotation = %+v, %v", gc, err)
+	}
+
+	// The rotating store writes with the new key straight away
+	if _, err := store.CreateBackup("all", archiveTestData("t5")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.ListBackups(); err != nil {
+		t.Errorf("ListBackups() error = %v", err)
+	}
+
+	// The skipped profile still reads with its own key
+	if err := other.LoadJSON("saved_tracks.json", &tracks); err != nil || len(tracks) != 1 {
+		t.Errorf("profile LoadJSON() = %v, %v", tracks, err)
+	}
+}
+
+func TestStore_RotateEncryption_SignedPlaintext(t *testing.T) {
+	fastScrypt(t)
+	tmpDir := t.TempDir()
+	backupDir := filepath.Join(tmpDir, "backups")
+	if _, err := OpenEncryption(backupDir, EncryptionPassphrase, passphrase("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+	enc, err := OpenEncryption(backupDir, EncryptionNone, passphrase("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore(tmpDir, backupDir)
+	store.SetEncryption(enc)
+	backup, err := store.CreateBackup("all", archiveTestData("t1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.RotateEncryption(EncryptionPassphrase, passphrase("correct horse")); err != nil {
+		t.Fatalf("RotateEncryption() error = %v", err)
+	}
+
+	// The manifest stays plaintext, signed with the new key
+	raw, err := os.ReadFile(store.manifestPath(backup.ID))
+	if err != nil || IsEncrypted(raw) {
+		t.Fatalf("manifest should stay plaintext: %v", err)
+	}
+	rotated, err := OpenEncryption(backupDir, EncryptionNone, passphrase("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := NewStore(tmpDir, backupDir)
+	reader.SetEncryption(rotated)
+	if result, err := reader.VerifyBackup(backup.ID); err != nil || !result.OK() || result.Unsigned {
+		t.Errorf("VerifyBackup() after rotation = %+v, %v", result, err)
+	}
+}
+
+func TestStore_RotateEncryption_NotSetUp(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewStore(tmpDir, filepath.Join(tmpDir, "backups"))
+	if _, err := store.RotateEncryption(EncryptionMachine, nil); err == nil {
+		t.Error("expected error without a storage key")
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "backups", keyParamsFile)); !os.IsNotExist(err) {
+		t.Error("no key parameters should be written")
+	}
+}
+
+// interruptRotation makes a rotation fail after moving staged files into place
+func interruptRotation(t *testing.T, after int) {
+	t.Helper()
+	moved := 0
+	replaceStaged = func(from, to string) error {
+		if moved == after {
+			return errors.New("interrupted")
+		}
+		moved++
+		return os.Rename(from, to)
+	}
+	t.Cleanup(func() { replaceStaged = os.Rename })
+}
+
+func TestStore_RotateEncryption_Interrupted(t *testing.T) {
+	fastScrypt(t)
+
+	setup := func(t *testing.T) (*Store, string) {
+		tmpDir := t.TempDir()
+		backupDir := filepath.Join(tmpDir, "backups")
+		enc, err := OpenEncryption(backupDir, EncryptionPassphrase, passphrase("hunter2"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		store := NewStore(tmpDir, backupDir)
+		store.SetEncryption(enc)
+		if _, err := store.CreateBackup("all", archiveTestData("t1", "t3")); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"saved_tracks.json", "playlists.json", "top_tracks.json"} {
+			if err := store.SaveJSON(name, []string{name}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return store, backupDir
+	}
+	// resume opens the old key as auth rekey does and rotates again
+	resume := func(t *testing.T, backupDir, newPassphrase string) error {
+		old, err := OpenRotatingEncryption(backupDir, EncryptionPassphrase, passphrase("hunter2"))
+		if err != nil {
+			t.Fatalf("OpenRotatingEncryption() error = %v", err)
+		}
+		store := NewStore(filepath.Dir(backupDir), backupDir)
+		store.SetEncryption(old)
+		_, err = store.RotateEncryption(EncryptionPassphrase, passphrase(newPassphrase))
+		return err
+	}
+	// assertReadable checks every file opens with the passphrase
+	assertReadable := func(t *testing.T, backupDir, secret string) {
+		t.Helper()
+		enc, err := OpenEncryption(backupDir, EncryptionPassphrase, passphrase(secret))
+		if err != nil {
+			t.Fatalf("OpenEncryption(%s) error = %v", secret, err)
+		}
+		store := NewStore(filepath.Dir(backupDir), backupDir)
+		store.SetEncryption(enc)
+		backups, err := store.ListBackups()
+		if err != nil || len(backups) != 1 {
+			t.Fatalf("ListBackups() = %v, %v", backups, err)
+		}
+		if result, err := store.VerifyBackup(backups[0].ID); err != nil || !result.OK() {
+			t.Errorf("VerifyBackup() = %+v, %v", result, err)
+		}
+		for _, name := range []string{"saved_tracks.json", "playlists.json", "top_tracks.json"} {
+			var got []string
+			if err := store.LoadJSON(name, &got); err != nil || len(got) != 1 || got[0] != name {
+				t.Errorf("LoadJSON(%s) = %v, %v", name, got, err)
+			}
+		}
+	}
+
+	t.Run("midway", func(t *testing.T) {
+		store, backupDir := setup(t)
+		interruptRotation(t, 2)
+		if _, err := store.RotateEncryption(EncryptionPassphrase, passphrase("correct horse")); err == nil {
+			t.Fatal("expected the rotation to be interrupted")
+		}
+		replaceStaged = os.Rename
+
+		if _, err := OpenEncryption(backupDir, EncryptionPassphrase, passphrase("hunter2")); !errors.Is(err, ErrRotationInterrupted) {
+			t.Errorf("OpenEncryption() error = %v, want ErrRotationInterrupted", err)
+		}
+		// Some files already have the new key, so another one cannot finish it
+		if err := resume(t, backupDir, "battery staple"); err == nil {
+			t.Error("expected a different new passphrase to be refused")
+		}
+		if err := resume(t, backupDir, "correct horse"); err != nil {
+			t.Fatalf("finishing the rotation error = %v", err)
+		}
+		if RotationInterrupted(backupDir) {
+			t.Error("the rotation should be finished")
+		}
+		assertReadable(t, backupDir, "correct horse")
+	})
+
+	t.Run("before any file moved", func(t *testing.T) {
+		store, backupDir := setup(t)
+		interruptRotation(t, 0)
+		if _, err := store.RotateEncryption(EncryptionPassphrase, passphrase("correct horse")); err == nil {
+			t.Fatal("expected the rotation to be interrupted")
+		}
+		replaceStaged = os.Rename
+
+		// Every file still has the old key, so any new key will do
+		if err := resume(t, backupDir, "battery staple"); err != nil {
+			t.Fatalf("rotating again error = %v", err)
+		}
+		assertReadable(t, backupDir, "battery staple")
+	})
+}
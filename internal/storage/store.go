@@ -6,14 +6,16 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
 
 // Store handles local file storage for Spotigo data
 type Store struct {
-	dataDir   string
-	backupDir string
+	dataDir    string
+	backupDir  string
+	encryption *Encryption
 }
 
 // NewStore creates a new storage instance
@@ -31,108 +33,102 @@ type BackupMetadata struct {
 	Type      string    `json:"type"` // full, playlists, tracks, etc.
 	Items     int       `json:"items"`
 	Size      int64     `json:"size"`
+	// StoredSize is the number of bytes newly written to the archive for this backup
+	StoredSize int64 `json:"stored_size,omitempty"`
 }
 
-// SaveJSON saves data as JSON to the specified path
+// SnapshotInfo describes a time-stamped snapshot in the history directory
+type SnapshotInfo struct {
+	Kind      string    `json:"kind"`
+	Path      string    `json:"path"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// syncManifestFile is the data file holding the incremental backup manifest
+const syncManifestFile = "sync_manifest.json"
+
+// SyncManifest records the last-seen library state used by incremental backups
+type SyncManifest struct {
+	UpdatedAt   time.Time                `json:"updated_at"`
+	SavedTracks SavedTracksState         `json:"saved_tracks"`
+	Playlists   map[string]PlaylistState `json:"playlists"`
+}
+
+// SavedTracksState records the newest saved track seen by the last backup
+type SavedTracksState struct {
+	Count         int    `json:"count"`
+	LatestID      string `json:"latest_id,omitempty"`
+	LatestAddedAt string `json:"latest_added_at,omitempty"`
+}
+
+// PlaylistState records a playlist's snapshot ID as of the last backup
+type PlaylistState struct {
+	Name       string `json:"name"`
+	SnapshotID string `json:"snapshot_id"`
+	TrackCount int    `json:"track_count"`
+}
+
+// snapshotTimeFormat is the timestamp layout used in snapshot filenames
+const snapshotTimeFormat = "20060102-150405"
+
+// SaveJSON saves data as JSON to the specified path, encrypted if enabled
 func (s *Store) SaveJSON(filename string, data interface{}) error {
 	// Clean filename to prevent path traversal
 	cleanFilename := filepath.Clean(filename)
 	path := filepath.Join(s.dataDir, cleanFilename)
 
-	// Ensure parent directory exists
-	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
-	}
-
-	file, err := os.Create(path) // #nosec G304 - path is sanitized with filepath.Clean
+	raw, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+		return fmt.Errorf("failed to encode JSON: %w", err)
 	}
-	defer func() {
-		if closeErr := file.Close(); closeErr != nil {
-			// Log the error but don't override the original error
-			fmt.Printf("Warning: failed to close file %s: %v\n", path, closeErr)
-		}
-	}()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(data); err != nil {
-		return fmt.Errorf("failed to encode JSON: %w", err)
+	if _, err := s.writeFile(path, append(raw, '\n')); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
 	}
 
 	return nil
 }
 
-// LoadJSON loads JSON data from the specified path
+// LoadJSON loads JSON data from the specified path, decrypting it if needed
 func (s *Store) LoadJSON(filename string, target interface{}) error {
 	// Clean filename to prevent path traversal
 	cleanFilename := filepath.Clean(filename)
 	path := filepath.Join(s.dataDir, cleanFilename)
 
-	file, err := os.Open(path) // #nosec G304 - path is sanitized with filepath.Clean
+	raw, err := s.readFile(path)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
 	}
-	defer func() {
-		if closeErr := file.Close(); closeErr != nil {
-			// Log the error but don't override the original error
-			fmt.Printf("Warning: failed to close file %s: %v\n", path, closeErr)
-		}
-	}()
 
-	if err := json.NewDecoder(file).Decode(target); err != nil {
+	if err := json.Unmarshal(raw, target); err != nil {
 		return fmt.Errorf("failed to decode JSON: %w", err)
 	}
 
 	return nil
 }
 
-// CreateBackup creates a timestamped backup
+// CreateBackup stores data as a timestamped backup in the content-addressed archive.
+// Items already present from earlier backups are not written again.
 func (s *Store) CreateBackup(backupType string, data interface{}) (*BackupMetadata, error) {
 	timestamp := time.Now()
-	timestampStr := timestamp.Format("20060102-150405")
-	filename := fmt.Sprintf("%s-%s.json", backupType, timestampStr)
-	path := filepath.Join(s.backupDir, filename)
 
-	// Ensure backup directory exists
-	if err := os.MkdirAll(s.backupDir, 0750); err != nil {
-		return nil, fmt.Errorf("failed to create backup directory: %w", err)
-	}
-
-	file, err := os.Create(path) // #nosec G304 - path is constructed from controlled backupDir
-	if err != nil {
-		return nil, fmt.Errorf("failed to create backup file: %w", err)
-	}
-	defer func() {
-		if closeErr := file.Close(); closeErr != nil {
-			// Log the error but don't override the original error
-			fmt.Printf("Warning: failed to close backup file %s: %v\n", path, closeErr)
-		}
-	}()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if encErr := encoder.Encode(data); encErr != nil {
-		return nil, fmt.Errorf("failed to encode backup: %w", encErr)
-	}
-
-	// Get file size
-	info, err := file.Stat()
+	manifest, written, err := s.writeArchive(backupType, timestamp, data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to stat backup file: %w", err)
+		return nil, err
 	}
 
-	// Use filename as ID for consistency with ListBackups
 	return &BackupMetadata{
-		ID:        filename,
-		Timestamp: timestamp,
-		Type:      backupType,
-		Size:      info.Size(),
+		ID:         manifest.ID,
+		Timestamp:  timestamp,
+		Type:       backupType,
+		Items:      manifest.Items,
+		Size:       manifest.Size,
+		StoredSize: written,
 	}, nil
 }
 
-// ListBackups returns all available backups
+// ListBackups returns all available backups, newest first.
+// Archived backups and legacy single-file backups are both included.
 func (s *Store) ListBackups() ([]BackupMetadata, error) {
 	entries, err := os.ReadDir(s.backupDir)
 	if err != nil {
@@ -142,12 +138,20 @@ func (s *Store) ListBackups() ([]BackupMetadata, error) {
 		return nil, fmt.Errorf("failed to read backup directory: %w", err)
 	}
 
-	backups := make([]BackupMetadata, 0, len(entries))
+	backups, err := s.listManifests()
+	if err != nil {
+		return nil, err
+	}
+	archived := make(map[string]bool, len(backups))
+	for _, backup := range backups {
+		archived[backup.ID] = true
+	}
+
 	for _, entry := range entries {
 		if entry.IsDir() {
 			continue
 		}
-		if filepath.Ext(entry.Name()) != ".json" {
+		if filepath.Ext(entry.Name()) != ".json" || archived[entry.Name()] {
 			continue
 		}
 
@@ -177,9 +181,112 @@ func (s *Store) ListBackups() ([]BackupMetadata, error) {
 		})
 	}
 
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].Timestamp.After(backups[j].Timestamp)
+	})
+
 	return backups, nil
 }
 
+// DeleteBackup removes a backup. Objects it referenced are reclaimed by GarbageCollect.
+func (s *Store) DeleteBackup(backupID string) error {
+	if path := s.manifestPath(backupID); fileExists(path) {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to delete backup %s: %w", backupID, err)
+		}
+		return nil
+	}
+
+	if err := os.Remove(s.GetBackupPath(backupID)); err != nil {
+		return fmt.Errorf("failed to delete backup %s: %w", backupID, err)
+	}
+	return nil
+}
+
+// LoadSyncManifest loads the incremental backup manifest.
+// A missing manifest yields an empty one.
+func (s *Store) LoadSyncManifest() (*SyncManifest, error) {
+	manifest := &SyncManifest{Playlists: make(map[string]PlaylistState)}
+	if !s.Exists(syncManifestFile) {
+		return manifest, nil
+	}
+
+	if err := s.LoadJSON(syncManifestFile, manifest); err != nil {
+		return nil, fmt.Errorf("failed to load sync manifest: %w", err)
+	}
+	if manifest.Playlists == nil {
+		manifest.Playlists = make(map[string]PlaylistState)
+	}
+
+	return manifest, nil
+}
+
+// SaveSyncManifest persists the incremental backup manifest
+func (s *Store) SaveSyncManifest(manifest *SyncManifest) error {
+	if err := s.SaveJSON(syncManifestFile, manifest); err != nil {
+		return fmt.Errorf("failed to save sync manifest: %w", err)
+	}
+	return nil
+}
+
+// SaveSnapshot writes data as a time-stamped snapshot under history/<kind>/
+func (s *Store) SaveSnapshot(kind string, timestamp time.Time, data interface{}) (*SnapshotInfo, error) {
+	cleanKind := filepath.Base(filepath.Clean(kind))
+	filename := fmt.Sprintf("%s-%s.json", cleanKind, timestamp.UTC().Format(snapshotTimeFormat))
+	relPath := filepath.Join("history", cleanKind, filename)
+
+	if err := s.SaveJSON(relPath, data); err != nil {
+		return nil, fmt.Errorf("failed to save %s snapshot: %w", cleanKind, err)
+	}
+
+	return &SnapshotInfo{
+		Kind:      cleanKind,
+		Path:      relPath,
+		Timestamp: timestamp.UTC().Truncate(time.Second),
+	}, nil
+}
+
+// ListSnapshots returns the snapshots of the given kind, oldest first
+func (s *Store) ListSnapshots(kind string) ([]SnapshotInfo, error) {
+	cleanKind := filepath.Base(filepath.Clean(kind))
+	dir := filepath.Join(s.dataDir, "history", cleanKind)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []SnapshotInfo{}, nil
+		}
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	prefix := cleanKind + "-"
+	snapshots := make([]SnapshotInfo, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".json")
+		timestamp, err := time.Parse(snapshotTimeFormat, stamp)
+		if err != nil {
+			continue
+		}
+
+		snapshots = append(snapshots, SnapshotInfo{
+			Kind:      cleanKind,
+			Path:      filepath.Join("history", cleanKind, name),
+			Timestamp: timestamp,
+		})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Timestamp.Before(snapshots[j].Timestamp)
+	})
+
+	return snapshots, nil
+}
+
 // Exists checks if a file exists
 func (s *Store) Exists(filename string) bool {
 	// Clean filename to prevent path traversal
@@ -189,13 +296,36 @@ func (s *Store) Exists(filename string) bool {
 	return err == nil
 }
 
-// GetBackupPath returns the full path to a backup file
+// GetBackupPath returns the full path to a backup file.
+// For archived backups this is the manifest.
 func (s *Store) GetBackupPath(backupID string) string {
-	return filepath.Join(s.backupDir, backupID)
+	if path := s.manifestPath(backupID); fileExists(path) {
+		return path
+	}
+	return filepath.Join(s.backupDir, filepath.Base(filepath.Clean(backupID)))
 }
 
-// LoadBackupJSON loads a backup file into the target structure
+// LoadBackupJSON loads a backup into the target structure
 func (s *Store) LoadBackupJSON(backupID string, target interface{}) error {
+	if fileExists(s.manifestPath(backupID)) {
+		manifest, err := s.LoadManifest(backupID)
+		if err != nil {
+			return err
+		}
+		sections, err := s.readArchive(manifest)
+		if err != nil {
+			return fmt.Errorf("failed to read backup %s: %w", backupID, err)
+		}
+		raw, err := json.Marshal(sections)
+		if err != nil {
+			return fmt.Errorf("failed to encode backup: %w", err)
+		}
+		if err := json.Unmarshal(raw, target); err != nil {
+			return fmt.Errorf("failed to decode backup JSON: %w", err)
+		}
+		return nil
+	}
+
 	path := s.GetBackupPath(backupID)
 
 	file, err := os.Open(path) // #nosec G304 - path is constructed from controlled backupDir
@@ -216,6 +346,12 @@ func (s *Store) LoadBackupJSON(backupID string, target interface{}) error {
 	return nil
 }
 
+// fileExists reports whether a regular file exists at path
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
 // GetDataDir returns the data directory path
 func (s *Store) GetDataDir() string {
 	return s.dataDir
@@ -346,6 +346,92 @@ func TestStore_SaveJSON_ComplexData(t *testing.T) {
 	}
 }
 
+func TestStore_Snapshots(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewStore(tmpDir, filepath.Join(tmpDir, "backups"))
+
+	// Empty history is not an error
+	snapshots, err := store.ListSnapshots("top_items")
+	if err != nil {
+		t.Fatalf("ListSnapshots on empty history failed: %v", err)
+	}
+	if len(snapshots) != 0 {
+		t.Errorf("expected no snapshots, got %d", len(snapshots))
+	}
+
+	older := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	newer := older.Add(24 * time.Hour)
+
+	// Save out of order to verify sorting
+	if _, err := store.SaveSnapshot("top_items", newer, map[string]string{"day": "2"}); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+	info, err := store.SaveSnapshot("top_items", older, map[string]string{"day": "1"})
+	if err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+	if info.Path != filepath.Join("history", "top_items", "top_items-20240101-120000.json") {
+		t.Errorf("unexpected snapshot path: %s", info.Path)
+	}
+
+	// A snapshot of a different kind should not be listed
+	if _, err := store.SaveSnapshot("recently_played", older, []string{}); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	snapshots, err = store.ListSnapshots("top_items")
+	if err != nil {
+		t.Fatalf("ListSnapshots failed: %v", err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("expected 2 snapshots, got %d", len(snapshots))
+	}
+	if !snapshots[0].Timestamp.Equal(older) || !snapshots[1].Timestamp.Equal(newer) {
+		t.Errorf("snapshots not sorted oldest first: %v, %v", snapshots[0].Timestamp, snapshots[1].Timestamp)
+	}
+
+	var loaded map[string]string
+	if err := store.LoadJSON(snapshots[1].Path, &loaded); err != nil {
+		t.Fatalf("LoadJSON on snapshot failed: %v", err)
+	}
+	if loaded["day"] != "2" {
+		t.Errorf("expected day 2, got %q", loaded["day"])
+	}
+}
+
+func TestStore_SyncManifest(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewStore(tmpDir, filepath.Join(tmpDir, "backups"))
+
+	// Missing manifest yields an empty one
+	manifest, err := store.LoadSyncManifest()
+	if err != nil {
+		t.Fatalf("LoadSyncManifest failed: %v", err)
+	}
+	if manifest.Playlists == nil || len(manifest.Playlists) != 0 {
+		t.Errorf("expected empty playlists map, got %v", manifest.Playlists)
+	}
+
+	manifest.UpdatedAt = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	manifest.SavedTracks = SavedTracksState{Count: 2, LatestID: "t2", LatestAddedAt: "2024-01-01T00:00:00Z"}
+	manifest.Playlists["p1"] = PlaylistState{Name: "Chill", SnapshotID: "snap1", TrackCount: 10}
+
+	if err := store.SaveSyncManifest(manifest); err != nil {
+		t.Fatalf("SaveSyncManifest failed: %v", err)
+	}
+
+	loaded, err := store.LoadSyncManifest()
+	if err != nil {
+		t.Fatalf("LoadSyncManifest failed: %v", err)
+	}
+	if loaded.SavedTracks.LatestID != "t2" || loaded.SavedTracks.Count != 2 {
+		t.Errorf("unexpected saved tracks state: %+v", loaded.SavedTracks)
+	}
+	if loaded.Playlists["p1"].SnapshotID != "snap1" {
+		t.Errorf("unexpected playlist state: %+v", loaded.Playlists["p1"])
+	}
+}
+
 // BenchmarkSaveJSON benchmarks JSON saving
 func BenchmarkSaveJSON(b *testing.B) {
 	tmpDir, _ := os.MkdirTemp("", "spotigo-bench-*")
@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyBackup_Valid(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewStore(tmpDir, filepath.Join(tmpDir, "backups"))
+
+	metadata, err := store.CreateBackup("all", archiveTestData("t1", "t2"))
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+
+	manifest, err := store.LoadManifest(metadata.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.SchemaVersion != ArchiveSchemaVersion || manifest.Checksum == "" {
+		t.Errorf("manifest missing integrity fields: %+v", manifest)
+	}
+	for name, section := range manifest.Sections {
+		if section.SHA256 == "" {
+			t.Errorf("section %s has no checksum", name)
+		}
+	}
+	if manifest.Sections["saved_tracks"].Count != 2 {
+		t.Errorf("saved_tracks count = %d", manifest.Sections["saved_tracks"].Count)
+	}
+
+	result, err := store.VerifyBackup(metadata.ID)
+	if err != nil {
+		t.Fatalf("VerifyBackup() error = %v", err)
+	}
+	if !result.OK() || result.Legacy || result.Sections != 4 || result.Items != 3 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+// tamperManifest rewrites a backup's manifest
+func tamperManifest(t *testing.T, store *Store, id string, edit func(*ArchiveManifest)) {
+	t.Helper()
+	manifest, err := store.LoadManifest(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edit(manifest)
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(store.manifestPath(id), raw, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyBackup_Corruption(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(t *testing.T, store *Store, id string)
+		want    string
+	}{
+		{
+			name: "corrupted object",
+			corrupt: func(t *testing.T, store *Store, id string) {
+				manifest, _ := store.LoadManifest(id)
+				path := store.objectPath(manifest.Sections["saved_tracks"].Items[0])
+				if err := os.WriteFile(path, []byte(`{"truncated":`), 0600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "section saved_tracks: object",
+		},
+		{
+			name: "missing object",
+			corrupt: func(t *testing.T, store *Store, id string) {
+				manifest, _ := store.LoadManifest(id)
+				if err := os.Remove(store.objectPath(manifest.Sections["playlists"].Items[0])); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "section playlists: missing object",
+		},
+		{
+			name: "dropped item",
+			corrupt: func(t *testing.T, store *Store, id string) {
+				tamperManifest(t, store, id, func(m *ArchiveManifest) {
+					section := m.Sections["saved_tracks"]
+					section.Items = section.Items[:1]
+					m.Sections["saved_tracks"] = section
+				})
+			},
+			want: "checksum mismatch",
+		},
+		{
+			name: "re-signed manifest with dropped item",
+			corrupt: func(t *testing.T, store *Store, id string) {
+				tamperManifest(t, store, id, func(m *ArchiveManifest) {
+					section := m.Sections["saved_tracks"]
+					section.Items = section.Items[:1]
+					m.Sections["saved_tracks"] = section
+					m.Checksum, _ = m.computeChecksum()
+				})
+			},
+			want: "section saved_tracks lists 1 items, expected 2",
+		},
+		{
+			name: "truncated manifest",
+			corrupt: func(t *testing.T, store *Store, id string) {
+				raw, _ := os.ReadFile(store.manifestPath(id))
+				if err := os.WriteFile(store.manifestPath(id), raw[:len(raw)/2], 0600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "manifest is not valid JSON",
+		},
+		{
+			name: "newer schema",
+			corrupt: func(t *testing.T, store *Store, id string) {
+				tamperManifest(t, store, id, func(m *ArchiveManifest) { m.SchemaVersion = ArchiveSchemaVersion + 1 })
+			},
+			want: "newer than supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			store := NewStore(tmpDir, filepath.Join(tmpDir, "backups"))
+			metadata, err := store.CreateBackup("all", archiveTestData("t1", "t2"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tt.corrupt(t, store, metadata.ID)
+
+			results, err := store.VerifyAll()
+			if err != nil {
+				t.Fatalf("VerifyAll() error = %v", err)
+			}
+			if len(results) != 1 {
+				t.Fatalf("expected 1 result, got %d", len(results))
+			}
+			result := results[0]
+			if result.OK() {
+				t.Fatal("expected corruption to be detected")
+			}
+			if !strings.Contains(strings.Join(result.Problems, "\n"), tt.want) {
+				t.Errorf("problems %q do not mention %q", result.Problems, tt.want)
+			}
+
+			var data map[string]interface{}
+			if err := store.LoadBackupJSON(metadata.ID, &data); err == nil {
+				t.Error("LoadBackupJSON() should fail for a corrupted backup")
+			}
+		})
+	}
+}
+
+func TestVerifyBackup_Legacy(t *testing.T) {
+	tmpDir := t.TempDir()
+	backupDir := filepath.Join(tmpDir, "backups")
+	store := NewStore(tmpDir, backupDir)
+
+	if err := os.MkdirAll(backupDir, 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(backupDir, "all-20240101-000000.json"), []byte(`{"saved_tracks":[{},{}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(backupDir, "all-20240102-000000.json"), []byte(`{"saved_tracks":[`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	good, err := store.VerifyBackup("all-20240101-000000.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !good.OK() || !good.Legacy || good.Items != 2 {
+		t.Errorf("unexpected result for legacy backup: %+v", good)
+	}
+
+	bad, err := store.VerifyBackup("all-20240102-000000.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bad.OK() {
+		t.Error("truncated legacy backup should fail verification")
+	}
+
+	if _, err := store.VerifyBackup("missing.json"); err == nil {
+		t.Error("expected error for unknown backup")
+	}
+}
+
+func TestVerifyBackup_Signed(t *testing.T) {
+	fastScrypt(t)
+	tmpDir := t.TempDir()
+	backupDir := filepath.Join(tmpDir, "backups")
+	store := NewStore(tmpDir, backupDir)
+
+	unsigned, err := store.CreateBackup("old", archiveTestData("t1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// With mode none an existing key still signs the plaintext manifests
+	if _, err := OpenEncryption(backupDir, EncryptionPassphrase, passphrase("hunter2")); err != nil {
+		t.Fatal(err)
+	}
+	enc, err := OpenEncryption(backupDir, EncryptionNone, passphrase("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetEncryption(enc)
+	signed, err := store.CreateBackup("all", archiveTestData("t1", "t2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := store.LoadManifest(signed.ID)
+	if err != nil || manifest.Signature == "" {
+		t.Fatalf("manifest is not signed: %+v, %v", manifest, err)
+	}
+
+	if result, err := store.VerifyBackup(signed.ID); err != nil || !result.OK() || result.Unsigned {
+		t.Errorf("VerifyBackup(signed) = %+v, %v", result, err)
+	}
+	if result, err := store.VerifyBackup(unsigned.ID); err != nil || !result.OK() || !result.Unsigned {
+		t.Errorf("VerifyBackup(unsigned) = %+v, %v", result, err)
+	}
+
+	// Recomputing the checksum is not enough to hide an edit
+	tamperManifest(t, store, signed.ID, func(m *ArchiveManifest) {
+		section := m.Sections["saved_tracks"]
+		section.Items = section.Items[:1]
+		section.Count = 1
+		m.Sections["saved_tracks"] = section
+		m.Items--
+		m.Checksum, _ = m.computeChecksum()
+	})
+	result, err := store.VerifyBackup(signed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.OK() || !strings.Contains(strings.Join(result.Problems, "\n"), "signature mismatch") {
+		t.Errorf("expected a signature mismatch, got %v", result.Problems)
+	}
+	var data map[string]interface{}
+	if err := store.LoadBackupJSON(signed.ID, &data); err == nil {
+		t.Error("expected loading an edited backup to fail")
+	}
+}
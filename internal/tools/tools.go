@@ -130,7 +130,7 @@ func (m *MusicTools) GetToolDefinitions() []ollama.Tool {
 					"properties": map[string]interface{}{
 						"source": map[string]interface{}{
 							"type":        "string",
-							"description": "Data source: 'saved_tracks.json', 'playlists.json', or 'followed_artists.json'",
+							"description": "Data source: 'saved_tracks.json', 'playlists.json', 'followed_artists.json', or 'play_history.json' (one entry per play with played_at, name, artist, album and ms_played)",
 						},
 						"operation": map[string]interface{}{
 							"type":        "string",